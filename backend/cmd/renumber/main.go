@@ -0,0 +1,188 @@
+// Command renumber menyelaraskan NoRM pasien atau nomor kunjungan EMR lama dengan format penomoran saat ini.
+//
+//	go run ./cmd/renumber -target=patients -mode=keep
+//	go run ./cmd/renumber -target=visits -mode=renumber -dry-run
+//
+// Mode keep membiarkan nomor lama apa adanya dan hanya memajukan sequence melewati nomor yang sudah
+// sesuai format. Mode renumber memberi nomor baru (urut tanggal daftar/pemeriksaan) pada nomor yang
+// belum sesuai format; nomor lama disimpan di no_rm_lama / legacy_visit_id sehingga tetap bisa dicari.
+// EMR yang sudah difinalisasi tidak dinomori ulang karena nomor kunjungan ikut dalam hash tanda tangan.
+// Kolom nomor lama hanya menampung satu nomor, sehingga data yang sudah pernah dinomori ulang tidak
+// dinomori ulang lagi (mis. setelah format diganti kedua kali); perintah berhenti tanpa mengubah apa pun.
+// Nomor kunjungan baru dicatat sebagai versi EMR agar ETag ikut berubah.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/config"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/database"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/handlers"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// errDryRun membatalkan transaksi pada mode -dry-run setelah hasilnya ditampilkan
+var errDryRun = errors.New("dry run")
+
+// legacyRow adalah satu baris dengan nomor yang akan diperiksa
+type legacyRow struct {
+	ID          uint
+	Number      string
+	Legacy      string // no_rm_lama / legacy_visit_id yang sudah terisi dari penomoran ulang sebelumnya
+	Date        time.Time
+	PatientID   uint
+	FinalizedAt *time.Time
+}
+
+func main() {
+	target := flag.String("target", "patients", "patients (NoRM) atau visits (nomor kunjungan EMR)")
+	mode := flag.String("mode", "keep", "keep (pertahankan nomor lama) atau renumber (beri nomor baru)")
+	dryRun := flag.Bool("dry-run", false, "tampilkan hasil tanpa menyimpan perubahan")
+	flag.Parse()
+
+	if *mode != "keep" && *mode != "renumber" {
+		log.Fatalf("Mode %q tidak dikenal, gunakan keep atau renumber", *mode)
+	}
+	if err := config.LoadConfig(); err != nil {
+		log.Fatalf("Gagal memuat konfigurasi: %v", err)
+	}
+	if err := database.ConnectDB(); err != nil {
+		log.Fatalf("Gagal terhubung ke database: %v", err)
+	}
+
+	var generator *database.NumberGenerator
+	switch *target {
+	case "patients":
+		generator = database.PatientNumbers
+	case "visits":
+		generator = database.VisitNumbers
+	default:
+		log.Fatalf("Target %q tidak dikenal, gunakan patients atau visits", *target)
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		maxSeq, err := generator.SyncSequence(tx)
+		if err != nil {
+			return fmt.Errorf("gagal menyelaraskan sequence: %w", err)
+		}
+		rows, err := loadLegacyRows(tx, *target)
+		if err != nil {
+			return fmt.Errorf("gagal membaca nomor lama: %w", err)
+		}
+
+		var legacy []legacyRow
+		for _, row := range rows {
+			if _, ok := generator.Format.ParseSequence(row.Number); !ok {
+				legacy = append(legacy, row)
+			}
+		}
+		log.Printf("Format %s: %s. Nomor urut terbesar yang sudah dipakai: %d, nomor dengan format lain: %d",
+			generator.Label, generator.Format, maxSeq, len(legacy))
+
+		if *mode == "renumber" {
+			for _, row := range legacy {
+				if row.Legacy != "" && row.FinalizedAt == nil {
+					return fmt.Errorf("%s sudah pernah dinomori ulang (nomor lama %s); penomoran ulang kedua akan menghilangkan nomor %s", row.Number, row.Legacy, row.Number)
+				}
+			}
+			renumbered, skipped := 0, 0
+			for _, row := range legacy {
+				if row.FinalizedAt != nil {
+					skipped++
+					continue
+				}
+				number, err := generator.Next(tx, row.Date)
+				if err != nil {
+					return err
+				}
+				if err := saveRenumber(tx, *target, row, number); err != nil {
+					return fmt.Errorf("gagal menyimpan nomor baru untuk %s: %w", row.Number, err)
+				}
+				fmt.Printf("%s -> %s\n", row.Number, number)
+				renumbered++
+			}
+			log.Printf("%d nomor dinomori ulang, %d EMR terfinalisasi dilewati", renumbered, skipped)
+		} else {
+			log.Printf("%d nomor lama dipertahankan", len(legacy))
+		}
+
+		if *dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if errors.Is(err, errDryRun) {
+		log.Println("Dry run: tidak ada perubahan yang disimpan (nilai sequence yang terpakai tidak dikembalikan)")
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatalf("Penomoran gagal: %v", err)
+	}
+	log.Println("Penomoran selesai.")
+}
+
+// loadLegacyRows mengambil semua nomor (termasuk data yang di-soft delete) urut tanggal daftar/pemeriksaan
+func loadLegacyRows(tx *gorm.DB, target string) ([]legacyRow, error) {
+	var rows []legacyRow
+	if target == "patients" {
+		err := tx.Unscoped().Model(&models.Patient{}).
+			Select("id, no_rm AS number, no_rm_lama AS legacy, created_at AS date, id AS patient_id").
+			Order("created_at asc, id asc").Scan(&rows).Error
+		return rows, err
+	}
+	err := tx.Unscoped().Model(&models.MedicalRecord{}).
+		Select("id, visit_id AS number, legacy_visit_id AS legacy, exam_date AS date, patient_id, finalized_at").
+		Order("exam_date asc, id asc").Scan(&rows).Error
+	return rows, err
+}
+
+// saveRenumber menyimpan nomor baru, memindahkan nomor lama ke kolom nomor lama, menaikkan versi data
+// (ETag) dan mencatatnya di audit log
+func saveRenumber(tx *gorm.DB, target string, row legacyRow, number string) error {
+	var err error
+	entry := models.AuditLog{
+		Action:        models.AuditActionUpdate,
+		ActorUsername: "cmd/renumber",
+		EntityID:      row.ID,
+		PatientID:     &row.PatientID,
+		Path:          "cmd/renumber",
+	}
+	field := "noRm"
+	if target == "patients" {
+		entry.EntityType = models.AuditEntityPatient
+		err = tx.Unscoped().Model(&models.Patient{}).Where("id = ?", row.ID).Updates(map[string]interface{}{
+			"no_rm":      number,
+			"no_rm_lama": row.Number,
+			"version":    gorm.Expr("version + 1"),
+		}).Error
+	} else {
+		// Versi EMR terikat ke riwayat medical_record_versions, jadi dinaikkan dengan mencatat versi baru
+		field = "visitId"
+		entry.EntityType = models.AuditEntityMedicalRecord
+		reason := fmt.Sprintf("Penomoran ulang kunjungan %s menjadi %s", row.Number, number)
+		err = handlers.RecordEMRSystemVersion(tx, row.ID, "cmd/renumber", reason, func(tx *gorm.DB) error {
+			return tx.Unscoped().Model(&models.MedicalRecord{}).Where("id = ?", row.ID).Updates(map[string]interface{}{
+				"visit_id":        number,
+				"legacy_visit_id": row.Number,
+			}).Error
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	changes, err := json.Marshal(map[string]map[string]string{field: {"before": row.Number, "after": number}})
+	if err != nil {
+		return err
+	}
+	entry.Changes = changes
+	return tx.Create(&entry).Error
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/MadeAgus22/dental-clinic-backend/pkg/config"
 	"github.com/MadeAgus22/dental-clinic-backend/pkg/database"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/handlers"
 	"github.com/MadeAgus22/dental-clinic-backend/pkg/routes"
 
 	"github.com/gofiber/fiber/v2"
@@ -36,6 +37,9 @@ func main() {
 		logger.Fatal("Gagal melakukan seeding default roles and permissions", zap.Error(err))
 	}
 
+	// Pemeriksa stok di bawah minimum berjalan di latar belakang
+	handlers.StartLowStockChecker(cfg.LowStockCheckInterval, logger)
+
 	// Inisialisasi Fiber App
 	app := fiber.New(fiber.Config{
 		ErrorHandler: func(ctx *fiber.Ctx, err error) error { // Custom error handler
@@ -50,6 +54,11 @@ func main() {
 			}
 			return ctx.Status(code).JSON(fiber.Map{"success": false, "message": message})
 		},
+		// Header IP klien hanya dipercaya dari reverse proxy yang terdaftar, agar c.IP() tidak bisa dipalsukan
+		ProxyHeader:             cfg.ProxyHeader,
+		EnableTrustedProxyCheck: cfg.ProxyHeader != "",
+		TrustedProxies:          cfg.TrustedProxies,
+		EnableIPValidation:      cfg.ProxyHeader != "", // Isi header yang bukan IP valid diabaikan, c.IP() kembali ke IP koneksi
 	})
 
 	// Setup Rute
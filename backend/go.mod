@@ -3,6 +3,7 @@ module github.com/MadeAgus22/dental-clinic-backend
 go 1.24.3
 
 require (
+	github.com/go-pdf/fpdf v0.9.0
 	github.com/go-playground/validator/v10 v10.26.0
 	github.com/gofiber/contrib/fiberzap v1.0.2
 	github.com/gofiber/fiber/v2 v2.52.8
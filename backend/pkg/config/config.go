@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -20,7 +21,60 @@ type Config struct {
 	DBSSLMode    string
 	DBTimezone   string
 	JWTSecretKey string
-	JWTExpiry    time.Duration
+	JWTExpiry    time.Duration // Umur access token (dibuat pendek, diperpanjang lewat refresh token)
+
+	RefreshTokenExpiry time.Duration // Umur refresh token / sesi login sejak aktivitas terakhir
+
+	// Autentikasi dua langkah (TOTP)
+	TwoFactorIssuer          string        // Nama yang tampil di aplikasi authenticator
+	TwoFactorRequiredRoles   []string      // Kode role yang wajib memakai 2FA
+	TwoFactorEncryptionKey   string        // Kunci enkripsi secret TOTP di database
+	TwoFactorChallengeExpiry time.Duration // Umur challenge token antara langkah password dan kode
+
+	// Perlindungan brute-force login
+	LoginMaxAttempts      int           // Kegagalan beruntun per username sebelum akun dikunci sementara
+	LoginMaxAttemptsPerIP int           // Kegagalan per IP sebelum IP dikunci sementara (dibuat longgar karena staf klinik sering berbagi satu IP)
+	LoginBackoffBase      time.Duration // Jeda setelah kegagalan pertama, berlipat dua setiap kegagalan berikutnya
+	LoginLockoutDuration  time.Duration // Lama penguncian, juga jendela waktu penghitung kegagalan
+
+	// Reverse proxy: IP klien (penghitung login per IP, sesi, audit log) dibaca dari ProxyHeader
+	// hanya jika request datang dari salah satu TrustedProxies; kosong berarti memakai IP koneksi langsung
+	ProxyHeader    string   // Header satu nilai yang selalu ditimpa proxy, mis. X-Real-IP
+	TrustedProxies []string // IP atau CIDR reverse proxy
+
+	// Kebijakan password dan reset password
+	PasswordPolicy      PasswordPolicy
+	PasswordResetExpiry time.Duration // Umur link reset password yang dibuat admin
+	FrontendBaseURL     string        // Dipakai untuk menyusun link reset password
+
+	// Batas waktu EMR harus difinalisasi (ditandatangani) oleh dokter sejak tanggal pemeriksaan
+	EMRSignatureDueAfter time.Duration
+
+	// Format nomor rekam medis pasien (NoRM) dan nomor kunjungan EMR (VisitID), lihat utils.NumberFormat
+	PatientNumberFormat string
+	VisitNumberFormat   string
+	ClinicBranchCode    string // Pengganti placeholder {BRANCH}
+
+	// Perilaku saat obat di EMR melebihi stok tersedia: "refuse" (tolak simpan) atau "warn" (simpan dengan peringatan, stok boleh minus)
+	StockOverdrawPolicy string
+
+	// Interval pemeriksaan stok menipis di latar belakang (0 = nonaktif)
+	LowStockCheckInterval time.Duration
+
+	// Identitas klinik untuk kop dokumen cetak (kwitansi, invoice, ringkasan EMR)
+	ClinicName    string
+	ClinicAddress string
+	ClinicPhone   string
+}
+
+// PasswordPolicy adalah aturan password baru (ganti sendiri, reset, maupun diset admin)
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	HistoryCount  int // Password tidak boleh sama dengan N password terakhir (0 = tidak diperiksa)
 }
 
 var AppConfig *Config
@@ -44,12 +98,95 @@ func LoadConfig() error {
 		return fmt.Errorf("DB_PORT tidak valid: %w", err)
 	}
 
-	jwtExpiryHoursStr := getEnv("JWT_EXPIRY_HOURS", "72")
-	jwtExpiryHours, err := strconv.Atoi(jwtExpiryHoursStr)
+	jwtExpiryMinutesStr := getEnv("JWT_EXPIRY_MINUTES", "15")
+	jwtExpiryMinutes, err := strconv.Atoi(jwtExpiryMinutesStr)
+	if err != nil {
+		return fmt.Errorf("JWT_EXPIRY_MINUTES tidak valid: %w", err)
+	}
+
+	refreshExpiryHoursStr := getEnv("REFRESH_TOKEN_EXPIRY_HOURS", "168")
+	refreshExpiryHours, err := strconv.Atoi(refreshExpiryHoursStr)
+	if err != nil {
+		return fmt.Errorf("REFRESH_TOKEN_EXPIRY_HOURS tidak valid: %w", err)
+	}
+
+	challengeExpiryMinutesStr := getEnv("TWO_FACTOR_CHALLENGE_EXPIRY_MINUTES", "5")
+	challengeExpiryMinutes, err := strconv.Atoi(challengeExpiryMinutesStr)
+	if err != nil {
+		return fmt.Errorf("TWO_FACTOR_CHALLENGE_EXPIRY_MINUTES tidak valid: %w", err)
+	}
+
+	var twoFactorRequiredRoles []string
+	for _, kode := range strings.Split(getEnv("TWO_FACTOR_REQUIRED_ROLES", ""), ",") {
+		if kode = strings.ToLower(strings.TrimSpace(kode)); kode != "" {
+			twoFactorRequiredRoles = append(twoFactorRequiredRoles, kode)
+		}
+	}
+
+	loginMaxAttempts, err := strconv.Atoi(getEnv("LOGIN_MAX_ATTEMPTS", "5"))
+	if err != nil {
+		return fmt.Errorf("LOGIN_MAX_ATTEMPTS tidak valid: %w", err)
+	}
+	loginMaxAttemptsPerIP, err := strconv.Atoi(getEnv("LOGIN_MAX_ATTEMPTS_PER_IP", "50"))
+	if err != nil {
+		return fmt.Errorf("LOGIN_MAX_ATTEMPTS_PER_IP tidak valid: %w", err)
+	}
+	loginBackoffBaseSeconds, err := strconv.Atoi(getEnv("LOGIN_BACKOFF_BASE_SECONDS", "1"))
+	if err != nil {
+		return fmt.Errorf("LOGIN_BACKOFF_BASE_SECONDS tidak valid: %w", err)
+	}
+	loginLockoutMinutes, err := strconv.Atoi(getEnv("LOGIN_LOCKOUT_MINUTES", "15"))
+	if err != nil {
+		return fmt.Errorf("LOGIN_LOCKOUT_MINUTES tidak valid: %w", err)
+	}
+
+	proxyHeader := strings.TrimSpace(getEnv("PROXY_HEADER", ""))
+	var trustedProxies []string
+	for _, proxy := range strings.Split(getEnv("TRUSTED_PROXIES", ""), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			trustedProxies = append(trustedProxies, proxy)
+		}
+	}
+	if proxyHeader != "" && len(trustedProxies) == 0 {
+		return fmt.Errorf("TRUSTED_PROXIES wajib diisi jika PROXY_HEADER diatur")
+	}
+	// Header berisi daftar IP (X-Forwarded-For, Forwarded) diawali entri yang ditulis klien sendiri,
+	// sedangkan Fiber mengambil entri pertama; hanya header satu nilai yang ditimpa proxy yang aman
+	switch strings.ToLower(proxyHeader) {
+	case "x-forwarded-for", "forwarded":
+		return fmt.Errorf("PROXY_HEADER %q dapat dipalsukan klien; gunakan header satu nilai yang ditimpa proxy, mis. X-Real-IP", proxyHeader)
+	}
+
+	passwordMinLength, err := strconv.Atoi(getEnv("PASSWORD_MIN_LENGTH", "8"))
+	if err != nil {
+		return fmt.Errorf("PASSWORD_MIN_LENGTH tidak valid: %w", err)
+	}
+	passwordHistoryCount, err := strconv.Atoi(getEnv("PASSWORD_HISTORY_COUNT", "5"))
+	if err != nil {
+		return fmt.Errorf("PASSWORD_HISTORY_COUNT tidak valid: %w", err)
+	}
+	passwordResetExpiryMinutes, err := strconv.Atoi(getEnv("PASSWORD_RESET_EXPIRY_MINUTES", "60"))
 	if err != nil {
-		return fmt.Errorf("JWT_EXPIRY_HOURS tidak valid: %w", err)
+		return fmt.Errorf("PASSWORD_RESET_EXPIRY_MINUTES tidak valid: %w", err)
 	}
 
+	emrSignatureDueHours, err := strconv.Atoi(getEnv("EMR_SIGNATURE_DUE_HOURS", "24"))
+	if err != nil {
+		return fmt.Errorf("EMR_SIGNATURE_DUE_HOURS tidak valid: %w", err)
+	}
+
+	stockOverdrawPolicy := strings.ToLower(getEnv("STOCK_OVERDRAW_POLICY", "refuse"))
+	if stockOverdrawPolicy != "refuse" && stockOverdrawPolicy != "warn" {
+		return fmt.Errorf("STOCK_OVERDRAW_POLICY tidak valid: %q (gunakan refuse atau warn)", stockOverdrawPolicy)
+	}
+
+	lowStockCheckMinutes, err := strconv.Atoi(getEnv("LOW_STOCK_CHECK_INTERVAL_MINUTES", "15"))
+	if err != nil || lowStockCheckMinutes < 0 {
+		return fmt.Errorf("LOW_STOCK_CHECK_INTERVAL_MINUTES tidak valid: %q", getEnv("LOW_STOCK_CHECK_INTERVAL_MINUTES", "15"))
+	}
+
+	jwtSecretKey := getEnv("JWT_SECRET_KEY", "your-secret-key-should-be-long-and-random")
+
 	AppConfig = &Config{
 		Port:         port,
 		DBHost:       getEnv("DB_HOST", "localhost"),
@@ -59,8 +196,47 @@ func LoadConfig() error {
 		DBName:       getEnv("DB_NAME", "klinikgigi"),
 		DBSSLMode:    getEnv("DB_SSLMODE", "disable"),
 		DBTimezone:   getEnv("DB_TIMEZONE", "Asia/Jakarta"),
-		JWTSecretKey: getEnv("JWT_SECRET_KEY", "your-secret-key-should-be-long-and-random"),
-		JWTExpiry:    time.Duration(jwtExpiryHours) * time.Hour,
+		JWTSecretKey: jwtSecretKey,
+		JWTExpiry:    time.Duration(jwtExpiryMinutes) * time.Minute,
+
+		RefreshTokenExpiry: time.Duration(refreshExpiryHours) * time.Hour,
+
+		TwoFactorIssuer:          getEnv("TWO_FACTOR_ISSUER", getEnv("CLINIC_NAME", "Klinik Gigi")),
+		TwoFactorRequiredRoles:   twoFactorRequiredRoles,
+		TwoFactorEncryptionKey:   getEnv("TWO_FACTOR_ENCRYPTION_KEY", jwtSecretKey),
+		TwoFactorChallengeExpiry: time.Duration(challengeExpiryMinutes) * time.Minute,
+
+		LoginMaxAttempts:      loginMaxAttempts,
+		LoginMaxAttemptsPerIP: loginMaxAttemptsPerIP,
+		LoginBackoffBase:      time.Duration(loginBackoffBaseSeconds) * time.Second,
+		LoginLockoutDuration:  time.Duration(loginLockoutMinutes) * time.Minute,
+
+		ProxyHeader:    proxyHeader,
+		TrustedProxies: trustedProxies,
+
+		PasswordPolicy: PasswordPolicy{
+			MinLength:     passwordMinLength,
+			RequireUpper:  getEnvBool("PASSWORD_REQUIRE_UPPER", true),
+			RequireLower:  getEnvBool("PASSWORD_REQUIRE_LOWER", true),
+			RequireDigit:  getEnvBool("PASSWORD_REQUIRE_DIGIT", true),
+			RequireSymbol: getEnvBool("PASSWORD_REQUIRE_SYMBOL", false),
+			HistoryCount:  passwordHistoryCount,
+		},
+		PasswordResetExpiry: time.Duration(passwordResetExpiryMinutes) * time.Minute,
+		FrontendBaseURL:     strings.TrimRight(getEnv("FRONTEND_BASE_URL", "http://localhost:3000"), "/"),
+
+		EMRSignatureDueAfter: time.Duration(emrSignatureDueHours) * time.Hour,
+
+		PatientNumberFormat: getEnv("PATIENT_NUMBER_FORMAT", "RM-{YYYY}-{seq:06}"),
+		VisitNumberFormat:   getEnv("VISIT_NUMBER_FORMAT", "VISIT-{YYYY}{MM}{DD}-{seq:06}"),
+		ClinicBranchCode:    getEnv("CLINIC_BRANCH_CODE", ""),
+
+		StockOverdrawPolicy:   stockOverdrawPolicy,
+		LowStockCheckInterval: time.Duration(lowStockCheckMinutes) * time.Minute,
+
+		ClinicName:    getEnv("CLINIC_NAME", "Klinik Gigi"),
+		ClinicAddress: getEnv("CLINIC_ADDRESS", ""),
+		ClinicPhone:   getEnv("CLINIC_PHONE", ""),
 	}
 	return nil
 }
@@ -71,3 +247,22 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+// getEnvBool membaca environment variable boolean ("true", "1", "false", "0"); nilai tidak dikenal memakai fallback
+func getEnvBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(getEnv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// TwoFactorRequiredForRole memeriksa apakah role wajib memakai autentikasi dua langkah
+func (c *Config) TwoFactorRequiredForRole(roleKode string) bool {
+	for _, kode := range c.TwoFactorRequiredRoles {
+		if kode == strings.ToLower(roleKode) {
+			return true
+		}
+	}
+	return false
+}
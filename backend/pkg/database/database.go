@@ -47,6 +47,9 @@ func ConnectDB() error {
 
 func migrateTables() error {
 	log.Println("Menjalankan Migrasi Database...")
+	if err := ensureUserRolesExist(); err != nil {
+		return err
+	}
 	err := DB.AutoMigrate(
 		&models.User{},
 		&models.Patient{},
@@ -60,11 +63,88 @@ func migrateTables() error {
 		&models.MedicalRecordMedicationItem{},
 		&models.Role{},
 		&models.Permission{},
+		&models.Invoice{},
+		&models.InvoiceLine{},
+		&models.Payment{},
+		&models.QueueEntry{},
+		&models.UserSession{},
+		&models.UserRecoveryCode{},
+		&models.LoginEvent{},
+		&models.LoginThrottle{},
+		&models.PasswordHistory{},
+		&models.PasswordResetToken{},
+		&models.AuditLog{},
+		&models.MedicalRecordVersion{},
+		&models.MedicalRecordAddendum{},
+		&models.StockBatch{},
+		&models.StockMovement{},
+		&models.StockOpname{},
+		&models.StockOpnameItem{},
+		&models.LowStockAlert{},
+		&models.Supplier{},
+		&models.PurchaseOrder{},
+		&models.PurchaseOrderLine{},
+		&models.GoodsReceipt{},
+		&models.GoodsReceiptLine{},
 		// Tambahkan model lain di sini
 	)
 	if err != nil {
 		return fmt.Errorf("gagal migrasi database: %w", err)
 	}
+
+	// Sequence untuk nomor kwitansi agar berurutan dan aman saat pembayaran diproses bersamaan
+	if err := DB.Exec("CREATE SEQUENCE IF NOT EXISTS receipt_number_seq").Error; err != nil {
+		return fmt.Errorf("gagal membuat sequence nomor kwitansi: %w", err)
+	}
+	if err := setupNumberGenerators(); err != nil {
+		return err
+	}
+	if err := ensureAppendOnly("audit_logs", "medical_record_versions", "medical_record_addendums", "stock_movements"); err != nil {
+		return err
+	}
 	log.Println("Migrasi Database Selesai.")
 	return nil
 }
+
+// ensureUserRolesExist membuat role untuk setiap kode role pengguna yang belum ada di tabel roles,
+// agar foreign key users.role -> roles.kode dapat dibuat pada database lama.
+func ensureUserRolesExist() error {
+	if !DB.Migrator().HasTable(&models.User{}) || !DB.Migrator().HasTable(&models.Role{}) {
+		return nil
+	}
+	err := DB.Exec(`INSERT INTO roles (nama, kode, created_at, updated_at)
+		SELECT DISTINCT 'Role ' || u.role, u.role, NOW(), NOW() FROM users u
+		WHERE NOT EXISTS (SELECT 1 FROM roles r WHERE r.kode = u.role)`).Error
+	if err != nil {
+		return fmt.Errorf("gagal menyiapkan role untuk pengguna lama: %w", err)
+	}
+	return nil
+}
+
+// ensureAppendOnly memasang trigger yang menolak UPDATE, DELETE dan TRUNCATE pada tabel yang diberikan,
+// sehingga isinya (jejak audit, riwayat versi dan addendum EMR, buku besar stok) tidak dapat diubah walaupun lewat query langsung dari aplikasi.
+func ensureAppendOnly(tables ...string) error {
+	if err := DB.Exec(`CREATE OR REPLACE FUNCTION reject_append_only_change() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION '% bersifat append-only, perubahan dan penghapusan tidak diizinkan', TG_TABLE_NAME;
+		END;
+		$$ LANGUAGE plpgsql`).Error; err != nil {
+		return fmt.Errorf("gagal membuat fungsi trigger append-only: %w", err)
+	}
+	for _, table := range tables {
+		statements := []string{
+			fmt.Sprintf(`DROP TRIGGER IF EXISTS %s_no_update_delete ON %s`, table, table),
+			fmt.Sprintf(`CREATE TRIGGER %s_no_update_delete BEFORE UPDATE OR DELETE ON %s
+			FOR EACH ROW EXECUTE FUNCTION reject_append_only_change()`, table, table),
+			fmt.Sprintf(`DROP TRIGGER IF EXISTS %s_no_truncate ON %s`, table, table),
+			fmt.Sprintf(`CREATE TRIGGER %s_no_truncate BEFORE TRUNCATE ON %s
+			FOR EACH STATEMENT EXECUTE FUNCTION reject_append_only_change()`, table, table),
+		}
+		for _, stmt := range statements {
+			if err := DB.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("gagal memasang trigger append-only %s: %w", table, err)
+			}
+		}
+	}
+	return nil
+}
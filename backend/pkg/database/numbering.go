@@ -0,0 +1,108 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/config"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/utils"
+
+	"gorm.io/gorm"
+)
+
+// maxNumberAttempts membatasi berapa kali nomor dilewati karena sudah dipakai nomor lama dengan format yang sama
+const maxNumberAttempts = 1000
+
+// NumberGenerator membuat nomor dokumen dari sequence database dengan format yang dapat dikonfigurasi.
+// nextval tidak pernah memberikan nilai yang sama ke dua transaksi, sehingga aman dipakai bersamaan.
+type NumberGenerator struct {
+	Label        string // Dipakai di pesan error, mis. "NoRM"
+	Sequence     string
+	Table        string
+	Column       string
+	LegacyColumn string // Kolom nomor lama hasil penomoran ulang, ikut diperiksa agar tidak dipakai ulang
+	Format       *utils.NumberFormat
+}
+
+// Generator nomor yang disiapkan saat migrasi sesuai konfigurasi
+var (
+	PatientNumbers *NumberGenerator
+	VisitNumbers   *NumberGenerator
+)
+
+// setupNumberGenerators membuat sequence NoRM dan nomor kunjungan lalu memeriksa format dari konfigurasi
+func setupNumberGenerators() error {
+	cfg := config.AppConfig
+	patientFormat, err := utils.ParseNumberFormat(cfg.PatientNumberFormat, cfg.ClinicBranchCode)
+	if err != nil {
+		return fmt.Errorf("PATIENT_NUMBER_FORMAT tidak valid: %w", err)
+	}
+	visitFormat, err := utils.ParseNumberFormat(cfg.VisitNumberFormat, cfg.ClinicBranchCode)
+	if err != nil {
+		return fmt.Errorf("VISIT_NUMBER_FORMAT tidak valid: %w", err)
+	}
+
+	PatientNumbers = &NumberGenerator{Label: "NoRM", Sequence: "patient_number_seq", Table: "patients", Column: "no_rm", LegacyColumn: "no_rm_lama", Format: patientFormat}
+	VisitNumbers = &NumberGenerator{Label: "nomor kunjungan", Sequence: "visit_number_seq", Table: "medical_records", Column: "visit_id", LegacyColumn: "legacy_visit_id", Format: visitFormat}
+	for _, g := range []*NumberGenerator{PatientNumbers, VisitNumbers} {
+		if err := DB.Exec("CREATE SEQUENCE IF NOT EXISTS " + g.Sequence).Error; err != nil {
+			return fmt.Errorf("gagal membuat sequence %s: %w", g.Label, err)
+		}
+	}
+	return nil
+}
+
+// Next mengambil nomor berikutnya untuk dokumen bertanggal t. Nomor yang kebetulan sudah dipakai
+// (nomor lama dengan format sama) dilewati; jalankan cmd/renumber -mode=keep agar sequence melompatinya sekaligus.
+func (g *NumberGenerator) Next(tx *gorm.DB, t time.Time) (string, error) {
+	for attempt := 0; attempt < maxNumberAttempts; attempt++ {
+		var seq int64
+		if err := tx.Raw("SELECT nextval(?)", g.Sequence).Scan(&seq).Error; err != nil {
+			return "", err
+		}
+		number := g.Format.Format(t, seq)
+
+		var count int64
+		if err := tx.Table(g.Table).Where(g.Column+" = ? OR "+g.LegacyColumn+" = ?", number, number).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return number, nil
+		}
+	}
+	return "", fmt.Errorf("tidak menemukan %s yang belum dipakai setelah %d percobaan", g.Label, maxNumberAttempts)
+}
+
+// SyncSequence memajukan sequence melewati nomor urut terbesar yang sudah ada dengan format saat ini,
+// sehingga nomor baru tidak perlu melompati nomor lama satu per satu. Mengembalikan nomor urut terbesar tersebut.
+func (g *NumberGenerator) SyncSequence(tx *gorm.DB) (int64, error) {
+	var numbers []string
+	if err := tx.Table(g.Table).Pluck(g.Column, &numbers).Error; err != nil {
+		return 0, err
+	}
+	var legacyNumbers []string
+	if err := tx.Table(g.Table).Where(g.LegacyColumn+" <> ''").Pluck(g.LegacyColumn, &legacyNumbers).Error; err != nil {
+		return 0, err
+	}
+
+	var maxSeq int64
+	for _, number := range append(numbers, legacyNumbers...) {
+		if seq, ok := g.Format.ParseSequence(number); ok && seq > maxSeq {
+			maxSeq = seq
+		}
+	}
+
+	var current struct {
+		LastValue int64
+		IsCalled  bool
+	}
+	if err := tx.Raw("SELECT last_value, is_called FROM " + g.Sequence).Scan(&current).Error; err != nil {
+		return 0, err
+	}
+	if maxSeq > 0 && (maxSeq > current.LastValue || (maxSeq == current.LastValue && !current.IsCalled)) {
+		if err := tx.Exec("SELECT setval(?, ?)", g.Sequence, maxSeq).Error; err != nil {
+			return 0, err
+		}
+	}
+	return maxSeq, nil
+}
@@ -7,6 +7,10 @@ import (
 	"gorm.io/gorm"
 )
 
+// DoctorPermissionKode menandai pengguna sebagai dokter pemeriksa yang dapat menerima reservasi, antrian dan EMR.
+// Permission ini tidak ikut diberikan otomatis ke role admin.
+const DoctorPermissionKode = "emr:examine"
+
 // DefinePermissions adalah daftar semua permission yang ingin Anda miliki dalam sistem.
 var DefinePermissions = []models.Permission{
 	// Dashboard
@@ -33,6 +37,9 @@ var DefinePermissions = []models.Permission{
 	{Nama: "Ubah EMR", Kode: "emr:update", Grup: "EMR", Deskripsi: "Mengubah data pada EMR yang sudah ada."},
 	{Nama: "Kelola Odontogram", Kode: "emr:manage_odontogram", Grup: "EMR", Deskripsi: "Mengisi dan mengubah data odontogram."},
 	{Nama: "Cetak EMR", Kode: "emr:print", Grup: "EMR", Deskripsi: "Mencetak detail EMR."},
+	{Nama: "Dokter Pemeriksa", Kode: DoctorPermissionKode, Grup: "EMR", Deskripsi: "Dapat dipilih sebagai dokter pada reservasi, antrian dan EMR (tidak otomatis dimiliki admin)."},
+	{Nama: "Finalisasi EMR", Kode: "emr:finalize", Grup: "EMR", Deskripsi: "Menandatangani dan mengunci EMR sebagai dokter pemeriksa."},
+	{Nama: "Tambah Addendum EMR", Kode: "emr:add_addendum", Grup: "EMR", Deskripsi: "Menambahkan addendum pada EMR yang sudah difinalisasi."},
 
 	// Master Data
 	{Nama: "Lihat Master Tindakan", Kode: "master:view_treatments", Grup: "Master Data", Deskripsi: "Melihat daftar master tindakan."},
@@ -40,11 +47,23 @@ var DefinePermissions = []models.Permission{
 	{Nama: "Lihat Master Obat", Kode: "master:view_medications", Grup: "Master Data", Deskripsi: "Melihat daftar master obat."},
 	{Nama: "Kelola Master Obat", Kode: "master:manage_medications", Grup: "Master Data", Deskripsi: "CRUD master obat."},
 
+	// Inventori
+	{Nama: "Lihat Stok Obat", Kode: "inventory:view_stock", Grup: "Inventori", Deskripsi: "Melihat buku besar mutasi stok obat dan bahan."},
+	{Nama: "Kelola Stok Obat", Kode: "inventory:manage_stock", Grup: "Inventori", Deskripsi: "Mencatat mutasi stok manual (masuk, keluar, penyesuaian, retur)."},
+	{Nama: "Hitung Stok Opname", Kode: "inventory:count_stock", Grup: "Inventori", Deskripsi: "Membuka sesi stock opname dan mengisi hasil hitung fisik."},
+	{Nama: "Setujui Stok Opname", Kode: "inventory:approve_stock_count", Grup: "Inventori", Deskripsi: "Menyetujui atau membatalkan sesi stock opname; selisih dicatat sebagai penyesuaian stok."},
+
+	// Pengadaan
+	{Nama: "Lihat Pengadaan", Kode: "procurement:view", Grup: "Pengadaan", Deskripsi: "Melihat supplier, purchase order, penerimaan barang dan laporan PO outstanding."},
+	{Nama: "Kelola Pengadaan", Kode: "procurement:manage", Grup: "Pengadaan", Deskripsi: "Mengelola supplier serta membuat, mengirim dan membatalkan purchase order."},
+	{Nama: "Terima Barang", Kode: "procurement:receive", Grup: "Pengadaan", Deskripsi: "Mencatat penerimaan barang atas purchase order (menambah stok)."},
+
 	// Pengaturan
 	{Nama: "Lihat Daftar Pengguna", Kode: "settings:view_users", Grup: "Pengaturan", Deskripsi: "Melihat daftar pengguna sistem."},
 	{Nama: "Kelola Pengguna (CRUD)", Kode: "settings:manage_users", Grup: "Pengaturan", Deskripsi: "CRUD data pengguna."},
 	{Nama: "Lihat Daftar Role", Kode: "settings:view_roles", Grup: "Pengaturan", Deskripsi: "Melihat daftar role."},
 	{Nama: "Kelola Role & Hak Akses", Kode: "settings:manage_roles", Grup: "Pengaturan", Deskripsi: "CRUD role dan mengatur hak aksesnya."},
+	{Nama: "Lihat Jejak Audit", Kode: "settings:view_audit_log", Grup: "Pengaturan", Deskripsi: "Melihat dan mengekspor jejak audit akses dan perubahan data."},
 
 	// Billing
 	{Nama: "Lihat Tagihan", Kode: "billing:view", Grup: "Billing", Deskripsi: "Melihat data tagihan pasien."},
@@ -155,6 +174,9 @@ func SeedDefaultRolesAndPermissions(db *gorm.DB) error {
 		return err
 	}
 	for _, p := range allPermissions {
+		if p.Kode == DoctorPermissionKode { // Admin bukan dokter pemeriksa kecuali diberikan manual
+			continue
+		}
 		adminPermissionKodes = append(adminPermissionKodes, p.Kode)
 	}
 	if err := seedOrUpdateRole(db, "Administrator", "admin", "Akses penuh ke sistem", adminPermissionKodes); err != nil {
@@ -164,7 +186,8 @@ func SeedDefaultRolesAndPermissions(db *gorm.DB) error {
 	// Role Dokter
 	doctorPermissionKodes := []string{
 		"dashboard:view", "patient:view", "reservation:view_doctor_specific",
-		"emr:view", "emr:create", "emr:update", "emr:manage_odontogram", "emr:print",
+		"emr:view", "emr:create", "emr:update", "emr:manage_odontogram", "emr:print", "emr:finalize", "emr:add_addendum", DoctorPermissionKode,
+		"master:view_treatments", "master:view_medications", // Dibutuhkan untuk memilih tindakan & obat di EMR
 	}
 	if err := seedOrUpdateRole(db, "Dokter Gigi", "dokter", "Akses terkait medis dan pasien", doctorPermissionKodes); err != nil {
 		return err
@@ -174,7 +197,7 @@ func SeedDefaultRolesAndPermissions(db *gorm.DB) error {
 	receptionistPermissionKodes := []string{
 		"dashboard:view", "patient:view", "patient:create", "patient:update", "patient:register_visit",
 		"reservation:view_all", "reservation:create", "reservation:update", "reservation:cancel", "reservation:confirm_arrival",
-		"billing:view", "billing:print_receipt",
+		"billing:view", "billing:create", "billing:process_payment", "billing:print_receipt",
 	}
 	if err := seedOrUpdateRole(db, "Resepsionis", "resepsionis", "Akses terkait pendaftaran dan jadwal", receptionistPermissionKodes); err != nil {
 		return err
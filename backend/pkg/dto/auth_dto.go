@@ -1,21 +1,28 @@
 package dto
 
+import "time"
+
 // LoginRequest DTO untuk request login
 type LoginRequest struct {
 	Username string `json:"username" validate:"required"`
 	Password string `json:"password" validate:"required"`
-	Role     string `json:"role" validate:"required,oneof=admin dokter resepsionis"`
+	Role     string `json:"role" validate:"required,max=50"` // Kode role, divalidasi terhadap tabel roles
 }
 
 // LoginResponse DTO untuk respons login
 type LoginResponse struct {
-	Token       string   `json:"token"`
-	ID          uint     `json:"id"`
-	Username    string   `json:"username"`
-	NamaLengkap string   `json:"namaLengkap"`
-	Email       string   `json:"email"`
-	Role        string   `json:"role"`
-	Permissions []string `json:"permissions,omitempty"`
+	Token        string   `json:"token"`        // Access token berumur pendek
+	RefreshToken string   `json:"refreshToken"` // Dipakai sekali di /auth/refresh untuk mendapat pasangan token baru
+	ExpiresIn    int64    `json:"expiresIn"`    // Umur access token dalam detik
+	SessionID    uint     `json:"sessionId"`
+	ID           uint     `json:"id"`
+	Username     string   `json:"username"`
+	NamaLengkap  string   `json:"namaLengkap"`
+	Email        string   `json:"email"`
+	Role         string   `json:"role"`
+	Permissions  []string `json:"permissions,omitempty"`
+
+	RecoveryCodes []string `json:"recoveryCodes,omitempty"` // Hanya terisi sekali saat 2FA baru diaktifkan lewat login
 }
 
 // RegisterRequest DTO untuk request registrasi user baru (oleh admin)
@@ -24,7 +31,106 @@ type RegisterRequest struct {
 	Username    string `json:"username" validate:"required"`
 	Email       string `json:"email" validate:"required,email"`
 	Password    string `json:"password" validate:"required,min=6"`
-	Role        string `json:"role" validate:"required,oneof=admin dokter resepsionis"`
+	Role        string `json:"role" validate:"required,max=50"` // Kode role, divalidasi terhadap tabel roles
 	Status      string `json:"status,omitempty" validate:"omitempty,oneof=aktif nonaktif"`
 	PhoneNumber string `json:"phoneNumber,omitempty"`
 }
+
+// RefreshTokenRequest DTO untuk memperbarui access token
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+// SessionResponse DTO untuk satu sesi login aktif
+type SessionResponse struct {
+	ID         uint       `json:"id"`
+	Device     string     `json:"device"`
+	UserAgent  string     `json:"userAgent,omitempty"`
+	IPAddress  string     `json:"ipAddress"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastSeenAt *time.Time `json:"lastSeenAt,omitempty"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	Current    bool       `json:"current"` // true untuk sesi yang sedang dipakai request ini
+}
+
+// TwoFactorChallengeResponse DTO untuk login yang masih membutuhkan langkah kedua
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"twoFactorRequired"` // Masukkan kode authenticator di /auth/2fa/verify
+	SetupRequired     bool   `json:"setupRequired"`     // Role mewajibkan 2FA: daftar dulu lewat /auth/2fa/setup dan /auth/2fa/enable
+	ChallengeToken    string `json:"challengeToken"`
+	ExpiresIn         int64  `json:"expiresIn"` // Umur challenge token dalam detik
+}
+
+// TwoFactorLoginRequest DTO untuk langkah kedua login, dengan kode authenticator atau kode pemulihan
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challengeToken" validate:"required"`
+	Code           string `json:"code" validate:"required_without=RecoveryCode"`
+	RecoveryCode   string `json:"recoveryCode" validate:"required_without=Code"`
+}
+
+// TwoFactorChallengeSetupRequest DTO untuk mendaftar 2FA di tengah login (role wajib 2FA)
+type TwoFactorChallengeSetupRequest struct {
+	ChallengeToken string `json:"challengeToken" validate:"required"`
+}
+
+// TwoFactorEnableRequest DTO untuk mengonfirmasi pendaftaran 2FA dengan kode pertama dari authenticator.
+// ChallengeToken hanya diisi saat pendaftaran dilakukan di tengah login.
+type TwoFactorEnableRequest struct {
+	ChallengeToken string `json:"challengeToken,omitempty"`
+	Code           string `json:"code" validate:"required"`
+}
+
+// TwoFactorDisableRequest DTO untuk menonaktifkan 2FA milik sendiri
+type TwoFactorDisableRequest struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required"` // Kode authenticator atau kode pemulihan
+}
+
+// TwoFactorCodeRequest DTO untuk aksi yang perlu konfirmasi kode authenticator
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// TwoFactorSetupResponse DTO berisi secret dan URI otpauth:// untuk ditampilkan sebagai QR code
+type TwoFactorSetupResponse struct {
+	Secret          string `json:"secret"` // Untuk dimasukkan manual jika QR tidak bisa dipindai
+	ProvisioningURI string `json:"provisioningUri"`
+}
+
+// TwoFactorRecoveryCodesResponse DTO berisi kode pemulihan; hanya ditampilkan sekali
+type TwoFactorRecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// TwoFactorStatusResponse DTO untuk status 2FA pengguna
+type TwoFactorStatusResponse struct {
+	Enabled                bool       `json:"enabled"`
+	Required               bool       `json:"required"` // Diwajibkan oleh role pengguna
+	EnabledAt              *time.Time `json:"enabledAt,omitempty"`
+	RecoveryCodesRemaining int64      `json:"recoveryCodesRemaining"`
+}
+
+// ChangePasswordRequest DTO untuk mengganti password sendiri
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" validate:"required"`
+	NewPassword     string `json:"newPassword" validate:"required"`
+}
+
+// PasswordResetLinkResponse DTO berisi link reset password yang dibuat admin; token hanya ditampilkan sekali
+type PasswordResetLinkResponse struct {
+	ResetURL  string    `json:"resetUrl"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// PasswordResetInfoResponse DTO untuk halaman reset password sebelum password baru dikirim
+type PasswordResetInfoResponse struct {
+	Username  string    `json:"username"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// ResetPasswordRequest DTO untuk menyetel password baru memakai token reset
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"newPassword" validate:"required"`
+}
@@ -0,0 +1,16 @@
+package dto
+
+// CreateInvoiceRequest DTO untuk membuat tagihan dari sebuah EMR
+type CreateInvoiceRequest struct {
+	MedicalRecordID uint   `json:"medicalRecordId" validate:"required"`
+	Catatan         string `json:"catatan,omitempty" validate:"omitempty,max=1000"`
+}
+
+// CreatePaymentRequest DTO untuk mencatat pembayaran atas tagihan
+type CreatePaymentRequest struct {
+	Metode       string   `json:"metode" validate:"required,oneof=tunai debit transfer qris"`
+	Jumlah       float64  `json:"jumlah" validate:"required,gt=0"`
+	UangDiterima *float64 `json:"uangDiterima,omitempty" validate:"omitempty,gt=0"` // Khusus tunai, untuk menghitung kembalian
+	NoReferensi  string   `json:"noReferensi,omitempty" validate:"omitempty,max=100"`
+	Catatan      string   `json:"catatan,omitempty" validate:"omitempty,max=500"`
+}
@@ -1,19 +1,23 @@
 package dto
 
 import (
+	"time"
+
 	"github.com/MadeAgus22/dental-clinic-backend/types" // Sesuaikan dengan path module Anda
 )
 
 // OdontogramDetailDTO untuk request/response
 type OdontogramDetailDTO struct {
+	ID            uint                   `json:"id,omitempty"` // Opsional saat update; gigi juga dicocokkan berdasarkan ToothNumber
 	ToothNumber   string                 `json:"toothNumber" validate:"required"`
 	Condition     types.ToothCondition   `json:"condition" validate:"required"` // Menggunakan tipe dari pkg/types
 	TreatmentNote string                 `json:"treatmentNote,omitempty"`
-	History       []OdontogramHistoryDTO `json:"history,omitempty,dive"` // `dive` untuk validasi nested struct
+	History       []OdontogramHistoryDTO `json:"history,omitempty" validate:"omitempty,dive"` // `dive` untuk validasi nested struct
 }
 
 // OdontogramHistoryDTO untuk request/response
 type OdontogramHistoryDTO struct {
+	ID            uint                 `json:"id,omitempty"`                                 // Opsional saat update, untuk mempertahankan entri riwayat yang sama
 	Date          string               `json:"date" validate:"required,datetime=2006-01-02"` // Validasi format tanggal
 	DoctorName    string               `json:"doctorName" validate:"required"`
 	FromCondition types.ToothCondition `json:"fromCondition" validate:"required"`
@@ -24,30 +28,30 @@ type OdontogramHistoryDTO struct {
 // MedicalRecordTreatmentItemDTO untuk request/response
 type MedicalRecordTreatmentItemDTO struct {
 	// TreatmentCatalogID uint    `json:"treatmentCatalogId,omitempty"` // Bisa juga berdasarkan Kode
+	// PriceAtTime dan SubTotal tidak diterima dari client; keduanya diambil dari master tindakan dan dihitung di backend.
+	ID              uint    `json:"id,omitempty"` // Opsional saat update: ID item lama yang diubah; tanpa ID item dicocokkan dari isinya
 	TreatmentCode   string  `json:"code" validate:"required"`
 	ToothNumber     string  `json:"toothNumber,omitempty"` // Nomor gigi jika spesifik
 	Quantity        int     `json:"quantity" validate:"required,min=1"`
-	PriceAtTime     float64 `json:"priceAtTime" validate:"required,gte=0"`
 	DiscountPercent float64 `json:"discountPercent" validate:"omitempty,min=0,max=100"`
-	SubTotal        float64 `json:"subTotal" validate:"required,gte=0"` // Sebaiknya dihitung di backend
 	Notes           string  `json:"notes,omitempty"`
 }
 
 // MedicalRecordMedicationItemDTO untuk request/response
 type MedicalRecordMedicationItemDTO struct {
 	// MedicationCatalogID uint    `json:"medicationCatalogId,omitempty"` // Bisa juga berdasarkan Kode
-	MedicationCode     string  `json:"code" validate:"required"`
-	Quantity           int     `json:"quantity" validate:"required,min=1"`
-	PricePerUnitAtTime float64 `json:"pricePerUnitAtTime" validate:"required,gte=0"`
-	SubTotal           float64 `json:"subTotal" validate:"required,gte=0"` // Sebaiknya dihitung di backend
-	Instruction        string  `json:"instruction,omitempty"`
+	// PricePerUnitAtTime dan SubTotal diambil dari HargaJual master obat dan dihitung di backend.
+	ID             uint   `json:"id,omitempty"` // Opsional saat update: ID item lama yang diubah; tanpa ID item dicocokkan dari isinya
+	MedicationCode string `json:"code" validate:"required"`
+	Quantity       int    `json:"quantity" validate:"required,min=1"`
+	Instruction    string `json:"instruction,omitempty"`
 }
 
 // CreateEMRRequest DTO untuk membuat EMR baru
 type CreateEMRRequest struct {
 	PatientID     uint   `json:"patientId" validate:"required"`
 	DoctorID      uint   `json:"doctorId" validate:"required"`
-	DoctorName    string `json:"doctorName,omitempty"` // Bisa diisi otomatis dari data dokter di backend
+	DoctorName    string `json:"doctorName,omitempty"` // Diabaikan, nama diambil dari data dokter di backend
 	VisitType     string `json:"visitType,omitempty"`
 	Complaint     string `json:"complaint" validate:"required"`
 	Examination   string `json:"examination,omitempty"`
@@ -56,24 +60,97 @@ type CreateEMRRequest struct {
 	Notes         string `json:"notes,omitempty"`
 	// BillingStatus string `json:"billingStatus,omitempty"` // Biasanya di-set terpisah atau default
 
-	Treatments  []MedicalRecordTreatmentItemDTO  `json:"treatments,omitempty,dive"`
-	Medications []MedicalRecordMedicationItemDTO `json:"medications,omitempty,dive"`
-	Odontogram  []OdontogramDetailDTO            `json:"odontogram,omitempty,dive"`
+	Treatments  []MedicalRecordTreatmentItemDTO  `json:"treatments,omitempty" validate:"omitempty,dive"`
+	Medications []MedicalRecordMedicationItemDTO `json:"medications,omitempty" validate:"omitempty,dive"`
+	Odontogram  []OdontogramDetailDTO            `json:"odontogram,omitempty" validate:"omitempty,dive"`
 }
 
 // UpdateEMRRequest DTO untuk memperbarui EMR
 type UpdateEMRRequest struct {
-	DoctorID      uint   `json:"doctorId,omitempty"` // Hanya update jika memang ingin diganti
-	DoctorName    string `json:"doctorName,omitempty"`
+	DoctorID      uint   `json:"doctorId,omitempty"`   // Hanya update jika memang ingin diganti
+	DoctorName    string `json:"doctorName,omitempty"` // Diabaikan, nama diambil dari data dokter di backend
 	VisitType     string `json:"visitType,omitempty"`
 	Complaint     string `json:"complaint,omitempty"` // Biasanya keluhan utama tidak diupdate, tapi tergantung kasus
 	Examination   string `json:"examination,omitempty"`
 	Diagnosis     string `json:"diagnosis,omitempty"`
 	TreatmentPlan string `json:"treatmentPlan,omitempty"`
 	Notes         string `json:"notes,omitempty"`
-	BillingStatus string `json:"billingStatus,omitempty"` // Status pembayaran bisa diupdate di sini atau endpoint terpisah
+	// BillingStatus tidak lagi diubah lewat EMR; status mengikuti tagihan (lihat billing_handler.go)
+	ChangeReason string `json:"changeReason,omitempty"` // Alasan amandemen, disimpan pada versi baru
+
+	Treatments  []MedicalRecordTreatmentItemDTO  `json:"treatments,omitempty" validate:"omitempty,dive"`
+	Medications []MedicalRecordMedicationItemDTO `json:"medications,omitempty" validate:"omitempty,dive"`
+	Odontogram  []OdontogramDetailDTO            `json:"odontogram,omitempty" validate:"omitempty,dive"`
+}
+
+// MedicalRecordVersionSummary adalah satu baris pada daftar versi EMR (tanpa isi snapshot)
+type MedicalRecordVersionSummary struct {
+	VersionNumber int       `json:"versionNumber"`
+	AuthorID      *uint     `json:"authorId,omitempty"`
+	AuthorName    string    `json:"authorName"`
+	ChangeReason  string    `json:"changeReason,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// FieldChange adalah nilai sebuah field sebelum dan sesudah perubahan
+type FieldChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// ItemChange adalah perubahan pada satu item (tindakan, obat, gigi) yang ada di kedua versi
+type ItemChange struct {
+	ID      uint                   `json:"id"`
+	Changes map[string]FieldChange `json:"changes"`
+}
+
+// ItemListDiff merangkum item yang ditambahkan, dihapus dan diubah antara dua versi
+type ItemListDiff struct {
+	Added   []interface{} `json:"added"`
+	Removed []interface{} `json:"removed"`
+	Changed []ItemChange  `json:"changed"`
+}
+
+// MedicalRecordVersionDiffResponse adalah perbandingan dua versi EMR
+type MedicalRecordVersionDiffResponse struct {
+	MedicalRecordID uint                        `json:"medicalRecordId"`
+	From            MedicalRecordVersionSummary `json:"from"`
+	To              MedicalRecordVersionSummary `json:"to"`
+	Fields          map[string]FieldChange      `json:"fields"`
+	Treatments      ItemListDiff                `json:"treatments"`
+	Medications     ItemListDiff                `json:"medications"`
+	Odontogram      ItemListDiff                `json:"odontogram"`
+}
+
+// CreateEMRAddendumRequest DTO untuk menambahkan addendum pada EMR yang sudah difinalisasi
+type CreateEMRAddendumRequest struct {
+	Reason  string `json:"reason" validate:"required,max=500"`
+	Content string `json:"content" validate:"required"`
+}
+
+// EMRSignatureResponse DTO untuk status finalisasi (tanda tangan) EMR
+type EMRSignatureResponse struct {
+	MedicalRecordID  uint       `json:"medicalRecordId"`
+	VisitID          string     `json:"visitId"`
+	Finalized        bool       `json:"finalized"`
+	FinalizedAt      *time.Time `json:"finalizedAt,omitempty"`
+	FinalizedByID    *uint      `json:"finalizedById,omitempty"`
+	FinalizedByName  string     `json:"finalizedByName,omitempty"`
+	FinalizedVersion int        `json:"finalizedVersion,omitempty"`
+	ContentHash      string     `json:"contentHash,omitempty"`
+	CurrentHash      string     `json:"currentHash,omitempty"` // Hash isi EMR saat ini, harus sama dengan ContentHash
+	Intact           bool       `json:"intact"`                // true jika isi EMR tidak berubah sejak difinalisasi
+}
 
-	Treatments  []MedicalRecordTreatmentItemDTO  `json:"treatments,omitempty,dive"`
-	Medications []MedicalRecordMedicationItemDTO `json:"medications,omitempty,dive"`
-	Odontogram  []OdontogramDetailDTO            `json:"odontogram,omitempty,dive"`
+// PendingSignatureResponse DTO untuk EMR yang belum difinalisasi melewati batas waktu
+type PendingSignatureResponse struct {
+	ID           uint      `json:"id"`
+	VisitID      string    `json:"visitId"`
+	PatientID    uint      `json:"patientId"`
+	PatientName  string    `json:"patientName,omitempty"`
+	NoRM         string    `json:"noRm,omitempty"`
+	DoctorID     uint      `json:"doctorId"`
+	DoctorName   string    `json:"doctorName"`
+	ExamDate     time.Time `json:"examDate"`
+	HoursPending int       `json:"hoursPending"` // Jam sejak pemeriksaan
 }
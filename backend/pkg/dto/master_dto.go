@@ -0,0 +1,79 @@
+package dto
+
+import "time"
+
+// CreateTreatmentCatalogRequest DTO untuk membuat master tindakan
+type CreateTreatmentCatalogRequest struct {
+	Kode      string  `json:"kode" validate:"required,max=50"`
+	Nama      string  `json:"nama" validate:"required,max=255"`
+	Kategori  string  `json:"kategori,omitempty" validate:"omitempty,max=100"`
+	Harga     float64 `json:"harga" validate:"gte=0"`
+	Deskripsi string  `json:"deskripsi,omitempty"`
+}
+
+// UpdateTreatmentCatalogRequest DTO untuk memperbarui master tindakan (Kode tidak dapat diubah)
+type UpdateTreatmentCatalogRequest struct {
+	Nama      string   `json:"nama" validate:"omitempty,max=255"`
+	Kategori  string   `json:"kategori,omitempty" validate:"omitempty,max=100"`
+	Harga     *float64 `json:"harga,omitempty" validate:"omitempty,gte=0"` // Pointer agar harga 0 bisa dibedakan dari "tidak dikirim"
+	Deskripsi string   `json:"deskripsi,omitempty"`
+}
+
+// TreatmentCatalogResponse DTO untuk respons master tindakan
+type TreatmentCatalogResponse struct {
+	ID        uint       `json:"id"`
+	Kode      string     `json:"kode"`
+	Nama      string     `json:"nama"`
+	Kategori  string     `json:"kategori,omitempty"`
+	Harga     float64    `json:"harga"`
+	Deskripsi string     `json:"deskripsi,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"` // Terisi jika data sudah dihapus (soft delete)
+}
+
+// CreateMedicationCatalogRequest DTO untuk membuat master obat
+type CreateMedicationCatalogRequest struct {
+	Kode      string  `json:"kode" validate:"required,max=50"`
+	Nama      string  `json:"nama" validate:"required,max=255"`
+	Satuan    string  `json:"satuan,omitempty" validate:"omitempty,max=50"`
+	HargaBeli float64 `json:"hargaBeli,omitempty" validate:"gte=0"`
+	HargaJual float64 `json:"hargaJual" validate:"gte=0"`
+	Stok      int     `json:"stok,omitempty" validate:"gte=0"` // Stok awal, dicatat sebagai mutasi masuk
+	Deskripsi string  `json:"deskripsi,omitempty"`
+
+	StokMinimum     int `json:"stokMinimum,omitempty" validate:"gte=0"`
+	JumlahPemesanan int `json:"jumlahPemesanan,omitempty" validate:"gte=0"`
+}
+
+// UpdateMedicationCatalogRequest DTO untuk memperbarui master obat (Kode tidak dapat diubah).
+// Stok tidak diubah di sini melainkan lewat mutasi stok agar setiap perubahan tercatat.
+type UpdateMedicationCatalogRequest struct {
+	Nama      string   `json:"nama" validate:"omitempty,max=255"`
+	Satuan    string   `json:"satuan,omitempty" validate:"omitempty,max=50"`
+	HargaBeli *float64 `json:"hargaBeli,omitempty" validate:"omitempty,gte=0"`
+	HargaJual *float64 `json:"hargaJual,omitempty" validate:"omitempty,gte=0"`
+	Deskripsi string   `json:"deskripsi,omitempty"`
+
+	StokMinimum     *int `json:"stokMinimum,omitempty" validate:"omitempty,gte=0"` // Pointer agar 0 (berhenti dipantau) bisa dikirim
+	JumlahPemesanan *int `json:"jumlahPemesanan,omitempty" validate:"omitempty,gte=0"`
+}
+
+// MedicationCatalogResponse DTO untuk respons master obat
+type MedicationCatalogResponse struct {
+	ID        uint       `json:"id"`
+	Kode      string     `json:"kode"`
+	Nama      string     `json:"nama"`
+	Satuan    string     `json:"satuan,omitempty"`
+	HargaBeli float64    `json:"hargaBeli,omitempty"`
+	HargaJual float64    `json:"hargaJual"`
+	Stok      int        `json:"stok"`
+	Deskripsi string     `json:"deskripsi,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+
+	StokMinimum     int  `json:"stokMinimum"`
+	JumlahPemesanan int  `json:"jumlahPemesanan"`
+	StokMenipis     bool `json:"stokMenipis"` // Stok < StokMinimum
+}
@@ -30,6 +30,7 @@ type UpdatePatientRequest struct {
 type PatientResponse struct {
 	ID              uint       `json:"id"`
 	NoRM            string     `json:"noRm"`
+	NoRMLama        string     `json:"noRmLama,omitempty"`
 	NamaLengkap     string     `json:"namaLengkap"`
 	TanggalLahir    *time.Time `json:"tanggalLahir,omitempty"`
 	JenisKelamin    string     `json:"jenisKelamin,omitempty"`
@@ -38,6 +39,7 @@ type PatientResponse struct {
 	Email           string     `json:"email,omitempty"`
 	Alergi          string     `json:"alergi,omitempty"`
 	RiwayatPenyakit string     `json:"riwayatPenyakit,omitempty"`
+	Version         int        `json:"version"` // Kirim kembali sebagai header If-Match saat update
 	CreatedAt       time.Time  `json:"createdAt"`
 	UpdatedAt       time.Time  `json:"updatedAt"`
 }
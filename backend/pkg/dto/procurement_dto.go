@@ -0,0 +1,84 @@
+package dto
+
+// CreateSupplierRequest DTO untuk membuat supplier
+type CreateSupplierRequest struct {
+	Kode         string `json:"kode" validate:"required,max=50"`
+	Nama         string `json:"nama" validate:"required,max=255"`
+	Alamat       string `json:"alamat,omitempty"`
+	NomorTelepon string `json:"nomorTelepon,omitempty" validate:"omitempty,max=50"`
+	Email        string `json:"email,omitempty" validate:"omitempty,email,max=255"`
+	Kontak       string `json:"kontak,omitempty" validate:"omitempty,max=255"`
+	Catatan      string `json:"catatan,omitempty"`
+}
+
+// UpdateSupplierRequest DTO untuk memperbarui supplier (Kode tidak dapat diubah)
+type UpdateSupplierRequest struct {
+	Nama         string `json:"nama" validate:"omitempty,max=255"`
+	Alamat       string `json:"alamat,omitempty"`
+	NomorTelepon string `json:"nomorTelepon,omitempty" validate:"omitempty,max=50"`
+	Email        string `json:"email,omitempty" validate:"omitempty,email,max=255"`
+	Kontak       string `json:"kontak,omitempty" validate:"omitempty,max=255"`
+	Catatan      string `json:"catatan,omitempty"`
+}
+
+// PurchaseOrderLineRequest DTO untuk satu obat yang dipesan
+type PurchaseOrderLineRequest struct {
+	MedicationCode string  `json:"medicationCode" validate:"required"`
+	Jumlah         int     `json:"jumlah" validate:"required,gt=0"`
+	HargaBeli      float64 `json:"hargaBeli" validate:"gte=0"`
+}
+
+// SavePurchaseOrderRequest DTO untuk membuat atau mengubah purchase order berstatus draft.
+// Saat mengubah, Lines menggantikan seluruh baris sebelumnya.
+type SavePurchaseOrderRequest struct {
+	SupplierID        uint                       `json:"supplierId" validate:"required"`
+	TanggalDiharapkan string                     `json:"tanggalDiharapkan,omitempty"` // Format YYYY-MM-DD
+	Catatan           string                     `json:"catatan,omitempty" validate:"omitempty,max=1000"`
+	Lines             []PurchaseOrderLineRequest `json:"lines" validate:"required,min=1,dive"`
+}
+
+// CancelPurchaseOrderRequest DTO untuk membatalkan purchase order
+type CancelPurchaseOrderRequest struct {
+	Alasan string `json:"alasan" validate:"required,max=500"`
+}
+
+// GoodsReceiptLineRequest DTO untuk jumlah yang diterima pada satu baris PO.
+// Tanpa NomorBatch barang dicatat sebagai stok tanpa batch; nomor batch yang sudah ada akan ditambah sisanya.
+type GoodsReceiptLineRequest struct {
+	PurchaseOrderLineID uint     `json:"purchaseOrderLineId" validate:"required"`
+	Jumlah              int      `json:"jumlah" validate:"required,gt=0"`
+	HargaBeli           *float64 `json:"hargaBeli,omitempty" validate:"omitempty,gte=0"` // Kosong berarti sesuai harga di PO
+	NomorBatch          string   `json:"nomorBatch,omitempty" validate:"omitempty,max=100"`
+	TanggalKedaluwarsa  string   `json:"tanggalKedaluwarsa,omitempty"` // Format YYYY-MM-DD
+}
+
+// CreateGoodsReceiptRequest DTO untuk mencatat penerimaan barang atas purchase order
+type CreateGoodsReceiptRequest struct {
+	NomorFaktur string                    `json:"nomorFaktur,omitempty" validate:"omitempty,max=100"`
+	Catatan     string                    `json:"catatan,omitempty" validate:"omitempty,max=1000"`
+	Lines       []GoodsReceiptLineRequest `json:"lines" validate:"required,min=1,dive"`
+}
+
+// OutstandingPurchaseOrderLine DTO untuk sisa pesanan satu obat yang belum diterima
+type OutstandingPurchaseOrderLine struct {
+	PurchaseOrderID uint    `json:"purchaseOrderId"`
+	NoPO            string  `json:"noPo"`
+	Status          string  `json:"status"`
+	MedicationCode  string  `json:"medicationCode"`
+	MedicationName  string  `json:"medicationName"`
+	Jumlah          int     `json:"jumlah"`
+	JumlahDiterima  int     `json:"jumlahDiterima"`
+	JumlahSisa      int     `json:"jumlahSisa"`
+	HargaBeli       float64 `json:"hargaBeli"`
+	NilaiSisa       float64 `json:"nilaiSisa"` // JumlahSisa x HargaBeli
+}
+
+// OutstandingSupplierReport DTO untuk rekap PO yang belum diterima penuh per supplier
+type OutstandingSupplierReport struct {
+	SupplierID     uint                           `json:"supplierId"`
+	SupplierKode   string                         `json:"supplierKode"`
+	SupplierNama   string                         `json:"supplierNama"`
+	JumlahPO       int                            `json:"jumlahPo"`
+	TotalNilaiSisa float64                        `json:"totalNilaiSisa"`
+	Lines          []OutstandingPurchaseOrderLine `json:"lines"`
+}
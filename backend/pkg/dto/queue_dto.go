@@ -0,0 +1,48 @@
+package dto
+
+import "time"
+
+// CheckInQueueRequest DTO untuk check-in pasien ke antrian.
+// Isi ReservationID untuk pasien reservasi, atau PatientID + DoctorID untuk walk-in.
+type CheckInQueueRequest struct {
+	ReservationID uint   `json:"reservationId,omitempty"`
+	PatientID     uint   `json:"patientId,omitempty" validate:"required_without=ReservationID"`
+	DoctorID      uint   `json:"doctorId,omitempty" validate:"required_without=ReservationID"`
+	Keluhan       string `json:"keluhan,omitempty" validate:"omitempty,max=1000"`
+	Catatan       string `json:"catatan,omitempty" validate:"omitempty,max=1000"`
+}
+
+// CallNextQueueRequest DTO untuk memanggil pasien berikutnya pada antrian seorang dokter hari ini
+type CallNextQueueRequest struct {
+	DoctorID uint `json:"doctorId" validate:"required"`
+}
+
+// CancelQueueRequest DTO opsional untuk alasan pembatalan antrian
+type CancelQueueRequest struct {
+	Alasan string `json:"alasan,omitempty" validate:"omitempty,max=500"`
+}
+
+// QueueEntryResponse DTO untuk respons data antrian
+type QueueEntryResponse struct {
+	ID              uint       `json:"id"`
+	Tanggal         string     `json:"tanggal"` // YYYY-MM-DD
+	DoctorID        uint       `json:"doctorId"`
+	DoctorName      string     `json:"doctorName"`
+	NomorAntrian    int        `json:"nomorAntrian"`
+	NoTiket         string     `json:"noTiket"` // Nomor antrian terformat, mis. "007"
+	Urutan          int        `json:"urutan"`
+	PatientID       uint       `json:"patientId"`
+	PatientName     string     `json:"patientName,omitempty"`
+	NoRM            string     `json:"noRm,omitempty"`
+	ReservationID   *uint      `json:"reservationId,omitempty"`
+	Sumber          string     `json:"sumber"`
+	Keluhan         string     `json:"keluhan,omitempty"`
+	Catatan         string     `json:"catatan,omitempty"`
+	Status          string     `json:"status"`
+	JumlahPanggilan int        `json:"jumlahPanggilan"`
+	CheckInAt       time.Time  `json:"checkInAt"`
+	CalledAt        *time.Time `json:"calledAt,omitempty"`
+	StartedAt       *time.Time `json:"startedAt,omitempty"`
+	FinishedAt      *time.Time `json:"finishedAt,omitempty"`
+	MedicalRecordID *uint      `json:"medicalRecordId,omitempty"`
+}
@@ -0,0 +1,48 @@
+package dto
+
+import "time"
+
+// CreateReservationRequest DTO untuk membuat reservasi baru
+type CreateReservationRequest struct {
+	PatientID      uint   `json:"patientId" validate:"required"`
+	DoctorID       uint   `json:"doctorId" validate:"required"`
+	Tanggal        string `json:"tanggal" validate:"required,datetime=2006-01-02"` // Format YYYY-MM-DD
+	Waktu          string `json:"waktu" validate:"required,datetime=15:04"`        // Format HH:MM
+	Keluhan        string `json:"keluhan,omitempty" validate:"omitempty,max=1000"`
+	Catatan        string `json:"catatan,omitempty" validate:"omitempty,max=1000"`
+	JenisKunjungan string `json:"jenisKunjungan,omitempty" validate:"omitempty,oneof=Reservasi Walk-in"`
+}
+
+// UpdateReservationRequest DTO untuk memperbarui reservasi
+type UpdateReservationRequest struct {
+	DoctorID       uint   `json:"doctorId,omitempty"`
+	Tanggal        string `json:"tanggal,omitempty" validate:"omitempty,datetime=2006-01-02"`
+	Waktu          string `json:"waktu,omitempty" validate:"omitempty,datetime=15:04"`
+	Keluhan        string `json:"keluhan,omitempty" validate:"omitempty,max=1000"`
+	Catatan        string `json:"catatan,omitempty" validate:"omitempty,max=1000"`
+	JenisKunjungan string `json:"jenisKunjungan,omitempty" validate:"omitempty,oneof=Reservasi Walk-in"`
+	Status         string `json:"status,omitempty" validate:"omitempty,oneof=Dijadwalkan Dikonfirmasi"` // Batal & hadir lewat endpoint khusus
+}
+
+// CancelReservationRequest DTO opsional untuk alasan pembatalan
+type CancelReservationRequest struct {
+	Alasan string `json:"alasan,omitempty" validate:"omitempty,max=500"`
+}
+
+// ReservationResponse DTO untuk respons data reservasi
+type ReservationResponse struct {
+	ID             uint      `json:"id"`
+	PatientID      uint      `json:"patientId"`
+	PatientName    string    `json:"patientName,omitempty"`
+	NoRM           string    `json:"noRm,omitempty"`
+	DoctorID       uint      `json:"doctorId"`
+	DoctorName     string    `json:"doctorName,omitempty"`
+	Tanggal        string    `json:"tanggal"` // YYYY-MM-DD
+	Waktu          string    `json:"waktu"`
+	Keluhan        string    `json:"keluhan,omitempty"`
+	Catatan        string    `json:"catatan,omitempty"`
+	Status         string    `json:"status"`
+	JenisKunjungan string    `json:"jenisKunjungan,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
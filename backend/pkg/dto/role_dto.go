@@ -29,6 +29,7 @@ type RoleResponse struct {
 	Kode        string                `json:"kode"`
 	Deskripsi   string                `json:"deskripsi,omitempty"`
 	Permissions []PermissionSimpleDTO `json:"permissions,omitempty"` // Tampilkan permission yang dimiliki role
+	Version     int                   `json:"version"`               // Kirim kembali sebagai header If-Match saat update
 	CreatedAt   time.Time             `json:"createdAt"`
 	UpdatedAt   time.Time             `json:"updatedAt"`
 }
@@ -0,0 +1,188 @@
+package dto
+
+import "time"
+
+// CreateStockMovementRequest DTO untuk mencatat mutasi stok manual.
+// Quantity selalu positif untuk masuk, keluar dan retur; untuk penyesuaian berisi selisih (boleh negatif).
+type CreateStockMovementRequest struct {
+	MedicationCode string `json:"medicationCode" validate:"required"`
+	Type           string `json:"type" validate:"required,oneof=masuk keluar penyesuaian retur"`
+	Quantity       int    `json:"quantity" validate:"required,ne=0"`
+	Reason         string `json:"reason" validate:"required,max=500"`
+	BatchNumber    string `json:"batchNumber,omitempty" validate:"omitempty,max=100"` // Opsional, untuk mutasi pada batch tertentu
+}
+
+// StockMovementResponse DTO untuk satu baris buku besar stok
+type StockMovementResponse struct {
+	ID               uint      `json:"id"`
+	CreatedAt        time.Time `json:"createdAt"`
+	MedicationID     uint      `json:"medicationId"`
+	MedicationCode   string    `json:"medicationCode"`
+	MedicationName   string    `json:"medicationName"`
+	Type             string    `json:"type"`
+	Quantity         int       `json:"quantity"`
+	StockAfter       int       `json:"stockAfter"`
+	Reason           string    `json:"reason"`
+	StockBatchID     *uint     `json:"stockBatchId,omitempty"`
+	BatchNumber      string    `json:"batchNumber,omitempty"`
+	MedicalRecordID  *uint     `json:"medicalRecordId,omitempty"`
+	MedicationItemID *uint     `json:"medicationItemId,omitempty"`
+	StockOpnameID    *uint     `json:"stockOpnameId,omitempty"`
+	UserID           *uint     `json:"userId,omitempty"`
+	UserName         string    `json:"userName"`
+}
+
+// CreateStockBatchRequest DTO untuk menerima stok dalam batch baru
+type CreateStockBatchRequest struct {
+	MedicationCode     string  `json:"medicationCode" validate:"required"`
+	NomorBatch         string  `json:"nomorBatch" validate:"required,max=100"`
+	TanggalKedaluwarsa string  `json:"tanggalKedaluwarsa,omitempty"` // Format YYYY-MM-DD, kosong untuk bahan tanpa kedaluwarsa
+	Jumlah             int     `json:"jumlah" validate:"required,gt=0"`
+	HargaBeli          float64 `json:"hargaBeli" validate:"gte=0"`
+	Reason             string  `json:"reason,omitempty" validate:"omitempty,max=500"`
+}
+
+// StockBatchResponse DTO untuk satu batch stok
+type StockBatchResponse struct {
+	ID                 uint       `json:"id"`
+	MedicationID       uint       `json:"medicationId"`
+	MedicationCode     string     `json:"medicationCode"`
+	MedicationName     string     `json:"medicationName"`
+	Satuan             string     `json:"satuan,omitempty"`
+	NomorBatch         string     `json:"nomorBatch"`
+	TanggalKedaluwarsa *time.Time `json:"tanggalKedaluwarsa,omitempty"`
+	DaysToExpiry       *int       `json:"daysToExpiry,omitempty"` // Negatif jika sudah kedaluwarsa
+	Expired            bool       `json:"expired"`
+	JumlahAwal         int        `json:"jumlahAwal"`
+	Sisa               int        `json:"sisa"`
+	HargaBeli          float64    `json:"hargaBeli"`
+	NilaiSisa          float64    `json:"nilaiSisa"` // Sisa x HargaBeli
+	CreatedAt          time.Time  `json:"createdAt"`
+}
+
+// WriteOffExpiredRequest DTO untuk memusnahkan stok kedaluwarsa. BatchIDs kosong berarti semua batch kedaluwarsa yang masih bersisa.
+type WriteOffExpiredRequest struct {
+	BatchIDs []uint `json:"batchIds,omitempty"`
+	Reason   string `json:"reason,omitempty" validate:"omitempty,max=500"`
+}
+
+// WriteOffResponse DTO hasil pemusnahan stok
+type WriteOffResponse struct {
+	Movements     []StockMovementResponse `json:"movements"`
+	TotalQuantity int                     `json:"totalQuantity"`
+	TotalValue    float64                 `json:"totalValue"` // Nilai stok yang dimusnahkan berdasarkan HargaBeli batch
+}
+
+// CreateStockOpnameRequest DTO untuk membuka sesi stock opname. MedicationCodes kosong berarti semua obat aktif.
+type CreateStockOpnameRequest struct {
+	Catatan         string   `json:"catatan,omitempty" validate:"omitempty,max=1000"`
+	MedicationCodes []string `json:"medicationCodes,omitempty"`
+}
+
+// StockCountEntry DTO untuk hasil hitung fisik satu obat
+type StockCountEntry struct {
+	MedicationCode string `json:"medicationCode" validate:"required"`
+	JumlahFisik    *int   `json:"jumlahFisik" validate:"required,gte=0"`
+	Catatan        string `json:"catatan,omitempty" validate:"omitempty,max=500"`
+}
+
+// SubmitStockCountRequest DTO untuk mengisi hasil hitung; boleh sebagian dan boleh dikirim ulang untuk mengoreksi
+type SubmitStockCountRequest struct {
+	Items []StockCountEntry `json:"items" validate:"required,min=1,dive"`
+}
+
+// CloseStockOpnameRequest DTO untuk menyetujui atau membatalkan sesi stock opname
+type CloseStockOpnameRequest struct {
+	Catatan string `json:"catatan,omitempty" validate:"omitempty,max=1000"`
+}
+
+// StockOpnameItemResponse DTO untuk satu obat dalam sesi stock opname
+type StockOpnameItemResponse struct {
+	MedicationID   uint       `json:"medicationId"`
+	MedicationCode string     `json:"medicationCode"`
+	MedicationName string     `json:"medicationName"`
+	Satuan         string     `json:"satuan,omitempty"`
+	StokSistem     int        `json:"stokSistem"`     // Stok saat sesi dibuka
+	StokSaatHitung *int       `json:"stokSaatHitung"` // Stok saat obat dihitung, dasar perhitungan selisih
+	JumlahFisik    *int       `json:"jumlahFisik"`    // null jika belum dihitung
+	Selisih        *int       `json:"selisih"`        // JumlahFisik - StokSaatHitung
+	HargaBeli      float64    `json:"hargaBeli"`
+	NilaiSelisih   *float64   `json:"nilaiSelisih"` // Selisih x HargaBeli
+	Catatan        string     `json:"catatan,omitempty"`
+	CountedAt      *time.Time `json:"countedAt,omitempty"`
+	CountedByName  string     `json:"countedByName,omitempty"`
+}
+
+// StockOpnameSummary DTO ringkasan hasil hitung sebuah sesi
+type StockOpnameSummary struct {
+	TotalItem        int     `json:"totalItem"`
+	SudahDihitung    int     `json:"sudahDihitung"`
+	BelumDihitung    int     `json:"belumDihitung"`
+	ItemSelisih      int     `json:"itemSelisih"`
+	NilaiLebih       float64 `json:"nilaiLebih"`       // Nilai selisih positif (fisik lebih banyak dari sistem)
+	NilaiKurang      float64 `json:"nilaiKurang"`      // Nilai selisih negatif, dalam angka positif
+	NilaiSelisihNeto float64 `json:"nilaiSelisihNeto"` // NilaiLebih - NilaiKurang
+}
+
+// StockOpnameResponse DTO untuk sesi stock opname beserta ringkasan dan (pada detail) daftar obatnya
+type StockOpnameResponse struct {
+	ID            uint                      `json:"id"`
+	NoOpname      string                    `json:"noOpname"`
+	Status        string                    `json:"status"`
+	Catatan       string                    `json:"catatan,omitempty"`
+	StartedByName string                    `json:"startedByName"`
+	ClosedAt      *time.Time                `json:"closedAt,omitempty"`
+	ClosedByName  string                    `json:"closedByName,omitempty"`
+	CreatedAt     time.Time                 `json:"createdAt"`
+	Summary       StockOpnameSummary        `json:"summary"`
+	Items         []StockOpnameItemResponse `json:"items,omitempty"`
+	Movements     []StockMovementResponse   `json:"movements,omitempty"` // Mutasi penyesuaian yang dicatat saat disetujui
+}
+
+// LowStockAlertResponse DTO untuk peringatan stok menipis beserta stok obat saat ini
+type LowStockAlertResponse struct {
+	ID                 uint       `json:"id"`
+	MedicationID       uint       `json:"medicationId"`
+	MedicationCode     string     `json:"medicationCode"`
+	MedicationName     string     `json:"medicationName"`
+	Satuan             string     `json:"satuan,omitempty"`
+	Status             string     `json:"status"`
+	StokSaatPeringatan int        `json:"stokSaatPeringatan"`
+	StokMinimum        int        `json:"stokMinimum"`
+	StokSaatIni        int        `json:"stokSaatIni"`
+	JumlahPemesanan    int        `json:"jumlahPemesanan"`
+	CreatedAt          time.Time  `json:"createdAt"`
+	AcknowledgedAt     *time.Time `json:"acknowledgedAt,omitempty"`
+	AcknowledgedByName string     `json:"acknowledgedByName,omitempty"`
+	Catatan            string     `json:"catatan,omitempty"`
+	ResolvedAt         *time.Time `json:"resolvedAt,omitempty"`
+}
+
+// AcknowledgeLowStockAlertRequest DTO untuk menandai peringatan stok menipis sudah ditindaklanjuti
+type AcknowledgeLowStockAlertRequest struct {
+	Catatan string `json:"catatan,omitempty" validate:"omitempty,max=500"` // Mis. nomor PO yang sudah dibuat
+}
+
+// LowStockCheckResponse DTO hasil pemeriksaan stok menipis yang dijalankan manual
+type LowStockCheckResponse struct {
+	Dibuat  int `json:"dibuat"`  // Peringatan baru
+	Selesai int `json:"selesai"` // Peringatan yang ditutup karena stok sudah pulih
+}
+
+// ReorderSuggestionResponse DTO untuk saran jumlah pemesanan ulang satu obat
+type ReorderSuggestionResponse struct {
+	MedicationID       uint     `json:"medicationId"`
+	MedicationCode     string   `json:"medicationCode"`
+	MedicationName     string   `json:"medicationName"`
+	Satuan             string   `json:"satuan,omitempty"`
+	Stok               int      `json:"stok"`
+	StokMinimum        int      `json:"stokMinimum"`
+	JumlahPemesanan    int      `json:"jumlahPemesanan"`
+	Pemakaian          int      `json:"pemakaian"`          // Jumlah obat di EMR selama periode
+	RataRataHarian     float64  `json:"rataRataHarian"`     // Pemakaian / jumlah hari periode
+	PerkiraanHabisHari *float64 `json:"perkiraanHabisHari"` // Stok / RataRataHarian, null jika tidak ada pemakaian
+	PesananOutstanding int      `json:"pesananOutstanding"` // Sisa PO terkirim yang belum diterima
+	JumlahDisarankan   int      `json:"jumlahDisarankan"`
+	HargaBeli          float64  `json:"hargaBeli"`
+	EstimasiBiaya      float64  `json:"estimasiBiaya"` // JumlahDisarankan x HargaBeli
+}
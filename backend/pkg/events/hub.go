@@ -0,0 +1,160 @@
+// Package events menyediakan hub publish/subscribe di dalam proses untuk mendorong
+// kejadian (antrian, reservasi, EMR, stok) ke klien yang berlangganan lewat stream.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Jenis event yang dikirim ke klien
+const (
+	TypeQueueCheckedIn      = "queue.checked_in"
+	TypeQueueCalled         = "queue.called"
+	TypeQueueUpdated        = "queue.updated"
+	TypeReservationCreated  = "reservation.created"
+	TypeReservationCanceled = "reservation.cancelled"
+	TypeEMRFinished         = "emr.finished"
+	TypeStockLow            = "stock.low"
+)
+
+// subscriberBuffer adalah jumlah event yang boleh tertunda per klien sebelum event dibuang
+const subscriberBuffer = 64
+
+// historySize adalah jumlah event terakhir yang disimpan untuk dikirim ulang ke klien yang tersambung kembali
+const historySize = 256
+
+// Event adalah satu kejadian yang dikirim ke pelanggan
+type Event struct {
+	ID        uint64      `json:"id"`
+	Type      string      `json:"type"`
+	DoctorID  uint        `json:"doctorId,omitempty"` // 0 untuk event yang tidak terkait dokter (mis. stok)
+	Data      interface{} `json:"data"`
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+// Filter membatasi event yang diterima pelanggan. Map kosong berarti semua.
+// DoctorIDs hanya berlaku untuk event milik dokter; HiddenTypes adalah jenis event yang tidak boleh
+// diterima pelanggan karena tidak memiliki hak akses atas datanya.
+type Filter struct {
+	DoctorIDs   map[uint]bool
+	Types       map[string]bool
+	HiddenTypes map[string]bool
+}
+
+func (f Filter) match(e Event) bool {
+	if f.HiddenTypes[e.Type] {
+		return false
+	}
+	if e.DoctorID != 0 && len(f.DoctorIDs) > 0 && !f.DoctorIDs[e.DoctorID] {
+		return false
+	}
+	if len(f.Types) > 0 && !f.Types[e.Type] {
+		return false
+	}
+	return true
+}
+
+// Subscription adalah satu koneksi klien (mis. satu tab browser)
+type Subscription struct {
+	C      <-chan Event
+	ch     chan Event
+	filter Filter
+}
+
+// Hub menyalurkan event ke semua pelanggan yang filternya cocok dan menyimpan event terakhir
+// agar klien yang tersambung kembali (header Last-Event-ID) tidak kehilangan event.
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[*Subscription]struct{}
+	history     []Event // urut berdasarkan ID, paling banyak historySize event
+	lastID      uint64
+}
+
+// NewHub membuat hub kosong
+func NewHub() *Hub {
+	return &Hub{subscribers: make(map[*Subscription]struct{})}
+}
+
+// Subscribe mendaftarkan pelanggan baru. Panggil Unsubscribe saat koneksi ditutup.
+func (h *Hub) Subscribe(filter Filter) *Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.subscribeLocked(filter)
+}
+
+// SubscribeSince mendaftarkan pelanggan yang tersambung kembali setelah event lastID. Event sesudahnya
+// yang masih tersimpan dan cocok dengan filter dikembalikan untuk dikirim lebih dulu; event baru
+// baru masuk ke channel setelahnya, sehingga tidak ada yang terlewat atau terkirim dua kali.
+// complete bernilai false jika sebagian event sudah tidak tersimpan (atau ID berasal dari proses
+// server sebelumnya); klien perlu memuat ulang datanya.
+func (h *Hub) SubscribeSince(filter Filter, lastID uint64) (sub *Subscription, replay []Event, complete bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	complete = lastID <= h.lastID
+	if len(h.history) > 0 && h.history[0].ID > lastID+1 {
+		complete = false
+	}
+	for _, e := range h.history {
+		if e.ID > lastID && filter.match(e) {
+			replay = append(replay, e)
+		}
+	}
+	return h.subscribeLocked(filter), replay, complete
+}
+
+func (h *Hub) subscribeLocked(filter Filter) *Subscription {
+	ch := make(chan Event, subscriberBuffer)
+	sub := &Subscription{C: ch, ch: ch, filter: filter}
+	h.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe menghapus pelanggan dan menutup channel-nya
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	if _, ok := h.subscribers[sub]; ok {
+		delete(h.subscribers, sub)
+		close(sub.ch)
+	}
+	h.mu.Unlock()
+}
+
+// Publish mengirim event ke pelanggan tanpa menunggu; klien yang terlalu lambat kehilangan event
+// tersebut dan diharapkan memuat ulang data saat tersambung kembali.
+func (h *Hub) Publish(eventType string, doctorID uint, data interface{}) {
+	e := Event{
+		Type:      eventType,
+		DoctorID:  doctorID,
+		Data:      data,
+		Timestamp: time.Now(),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	// ID diberikan di dalam lock agar urutan ID sama dengan urutan history dan pengiriman
+	h.lastID++
+	e.ID = h.lastID
+	if len(h.history) == historySize {
+		h.history = append(h.history[:0], h.history[1:]...)
+	}
+	h.history = append(h.history, e)
+	for sub := range h.subscribers {
+		if !sub.filter.match(e) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+		}
+	}
+}
+
+// Bus adalah hub bersama yang dipakai handler dan endpoint stream
+var Bus = NewHub()
+
+// Publish mengirim event melalui Bus
+func Publish(eventType string, doctorID uint, data interface{}) {
+	Bus.Publish(eventType, doctorID, data)
+}
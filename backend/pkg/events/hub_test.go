@@ -0,0 +1,67 @@
+package events
+
+import (
+	"reflect"
+	"testing"
+)
+
+func eventIDs(list []Event) []uint64 {
+	var ids []uint64
+	for _, e := range list {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
+
+func TestHubSubscribeSince(t *testing.T) {
+	h := NewHub()
+	h.Publish(TypeQueueCalled, 1, nil)  // ID 1
+	h.Publish(TypeQueueCalled, 2, nil)  // ID 2
+	h.Publish(TypeStockLow, 0, nil)     // ID 3
+	h.Publish(TypeQueueUpdated, 1, nil) // ID 4
+
+	tests := []struct {
+		name     string
+		filter   Filter
+		lastID   uint64
+		want     []uint64
+		complete bool
+	}{
+		{"semua event sesudah ID", Filter{}, 2, []uint64{3, 4}, true},
+		{"sudah menerima event terakhir", Filter{}, 4, nil, true},
+		{"filter dokter tetap berlaku", Filter{DoctorIDs: map[uint]bool{2: true}}, 0, []uint64{2, 3}, true},
+		{"jenis tersembunyi tidak dikirim ulang", Filter{HiddenTypes: map[string]bool{TypeStockLow: true}}, 1, []uint64{2, 4}, true},
+		{"ID dari proses server sebelumnya", Filter{}, 99, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, replay, complete := h.SubscribeSince(tt.filter, tt.lastID)
+			defer h.Unsubscribe(sub)
+			if got := eventIDs(replay); !reflect.DeepEqual(got, tt.want) || complete != tt.complete {
+				t.Errorf("SubscribeSince(%d) = %v, %v, want %v, %v", tt.lastID, got, complete, tt.want, tt.complete)
+			}
+		})
+	}
+}
+
+func TestHubSubscribeSinceHistoryOverflow(t *testing.T) {
+	h := NewHub()
+	for i := 0; i < historySize+10; i++ {
+		h.Publish(TypeQueueUpdated, 1, nil)
+	}
+
+	sub, replay, complete := h.SubscribeSince(Filter{}, 5)
+	defer h.Unsubscribe(sub)
+	if complete {
+		t.Error("event yang sudah keluar dari history harus membuat complete = false")
+	}
+	if len(replay) != historySize || replay[0].ID != 11 {
+		t.Errorf("replay = %d event mulai ID %d, want %d event mulai ID 11", len(replay), replay[0].ID, historySize)
+	}
+
+	// Event sesudah replay masuk ke channel, bukan ke replay
+	h.Publish(TypeQueueCalled, 1, nil)
+	if e := <-sub.C; e.ID != uint64(historySize+11) {
+		t.Errorf("event baru ID %d, want %d", e.ID, historySize+11)
+	}
+}
@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/database"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/middleware"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/models"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// auditFound menerjemahkan hasil pencarian entitas audit; data yang tidak ditemukan bukan error
+func auditFound(err error) (bool, error) {
+	if err == gorm.ErrRecordNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// auditFindByID mengambil satu baris berdasarkan ID angka
+func auditFindByID(query *gorm.DB, dest interface{}, key string) (bool, error) {
+	id, err := strconv.ParseUint(key, 10, 32)
+	if err != nil {
+		return false, nil
+	}
+	return auditFound(query.First(dest, uint(id)).Error)
+}
+
+// PatientAudit adalah target audit untuk rute pasien (parameter :id berisi ID, NoRM atau NoRM lama)
+var PatientAudit = middleware.AuditTarget{
+	EntityType: models.AuditEntityPatient,
+	Param:      "id",
+	Load: func(key string, full bool) (*middleware.AuditSnapshot, error) {
+		var patient models.Patient
+		found, err := auditFound(findPatientByParam(database.DB, key, &patient))
+		if !found {
+			return nil, err
+		}
+		return &middleware.AuditSnapshot{EntityID: patient.ID, PatientID: &patient.ID, Data: patient}, nil
+	},
+}
+
+// PatientListAudit adalah target audit untuk daftar/pencarian pasien
+var PatientListAudit = middleware.AuditTarget{EntityType: models.AuditEntityPatient}
+
+// UserListAudit adalah target audit untuk daftar pengguna
+var UserListAudit = middleware.AuditTarget{EntityType: models.AuditEntityUser}
+
+// RoleListAudit adalah target audit untuk daftar role
+var RoleListAudit = middleware.AuditTarget{EntityType: models.AuditEntityRole}
+
+// MedicalRecordAudit adalah target audit untuk rute EMR (parameter :id berisi ID, VisitID atau VisitID lama)
+var MedicalRecordAudit = middleware.AuditTarget{
+	EntityType: models.AuditEntityMedicalRecord,
+	Param:      "id",
+	Load: func(key string, full bool) (*middleware.AuditSnapshot, error) {
+		var emr models.MedicalRecord
+		query := database.DB
+		if full {
+			query = query.
+				Preload("Treatments", func(db *gorm.DB) *gorm.DB { return db.Order("id asc") }).
+				Preload("Medications", func(db *gorm.DB) *gorm.DB { return db.Order("id asc") }).
+				Preload("Odontogram", func(db *gorm.DB) *gorm.DB { return db.Order("tooth_number asc") }).
+				Preload("Addenda", func(db *gorm.DB) *gorm.DB { return db.Order("id asc") })
+		}
+		found, err := auditFound(findEMRByParam(query, key, &emr))
+		if !found {
+			return nil, err
+		}
+		return &middleware.AuditSnapshot{EntityID: emr.ID, PatientID: &emr.PatientID, Data: emr}, nil
+	},
+}
+
+// PatientMedicalRecordsAudit adalah target audit untuk daftar EMR seorang pasien
+var PatientMedicalRecordsAudit = middleware.AuditTarget{
+	EntityType:   models.AuditEntityMedicalRecord,
+	PatientParam: "patientId",
+}
+
+// ReservationAudit adalah target audit untuk rute reservasi (parameter :id)
+var ReservationAudit = middleware.AuditTarget{
+	EntityType: models.AuditEntityReservation,
+	Param:      "id",
+	Load: func(key string, full bool) (*middleware.AuditSnapshot, error) {
+		var reservation models.Reservation
+		found, err := auditFindByID(database.DB, &reservation, key)
+		if !found {
+			return nil, err
+		}
+		return &middleware.AuditSnapshot{EntityID: reservation.ID, PatientID: &reservation.PatientID, Data: reservation}, nil
+	},
+}
+
+// ReservationListAudit adalah target audit untuk daftar reservasi
+var ReservationListAudit = middleware.AuditTarget{EntityType: models.AuditEntityReservation}
+
+// QueueAudit adalah target audit untuk rute antrian (parameter :id)
+var QueueAudit = middleware.AuditTarget{
+	EntityType: models.AuditEntityQueueEntry,
+	Param:      "id",
+	Load: func(key string, full bool) (*middleware.AuditSnapshot, error) {
+		var entry models.QueueEntry
+		found, err := auditFindByID(database.DB, &entry, key)
+		if !found {
+			return nil, err
+		}
+		return &middleware.AuditSnapshot{EntityID: entry.ID, PatientID: &entry.PatientID, Data: entry}, nil
+	},
+}
+
+// QueueListAudit adalah target audit untuk daftar antrian
+var QueueListAudit = middleware.AuditTarget{EntityType: models.AuditEntityQueueEntry}
+
+// QueueExaminationAudit mencatat EMR yang dibuat saat pemeriksaan dari antrian dimulai.
+// Respons memulai pemeriksaan berisi ID antrian, sehingga EMR dimuat melalui antrian tersebut.
+var QueueExaminationAudit = middleware.AuditTarget{
+	EntityType: models.AuditEntityMedicalRecord,
+	Param:      "id",
+	Load: func(key string, full bool) (*middleware.AuditSnapshot, error) {
+		var entry models.QueueEntry
+		found, err := auditFindByID(database.DB, &entry, key)
+		if !found || entry.MedicalRecordID == nil {
+			return nil, err
+		}
+		return MedicalRecordAudit.Load(strconv.FormatUint(uint64(*entry.MedicalRecordID), 10), full)
+	},
+}
+
+// InvoiceAudit adalah target audit untuk rute tagihan (parameter :id)
+var InvoiceAudit = middleware.AuditTarget{
+	EntityType: models.AuditEntityInvoice,
+	Param:      "id",
+	Load: func(key string, full bool) (*middleware.AuditSnapshot, error) {
+		var invoice models.Invoice
+		query := database.DB
+		if full {
+			query = query.
+				Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("id asc") }).
+				Preload("Payments", func(db *gorm.DB) *gorm.DB { return db.Order("id asc") })
+		}
+		found, err := auditFindByID(query, &invoice, key)
+		if !found {
+			return nil, err
+		}
+		return &middleware.AuditSnapshot{EntityID: invoice.ID, PatientID: &invoice.PatientID, Data: invoice}, nil
+	},
+}
+
+// InvoiceListAudit adalah target audit untuk daftar tagihan
+var InvoiceListAudit = middleware.AuditTarget{EntityType: models.AuditEntityInvoice}
+
+// EMRInvoiceAudit adalah target audit untuk tagihan yang dicari dari EMR-nya (parameter :emrId)
+var EMRInvoiceAudit = middleware.AuditTarget{
+	EntityType: models.AuditEntityInvoice,
+	Param:      "emrId",
+	Load: func(key string, full bool) (*middleware.AuditSnapshot, error) {
+		emrID, err := strconv.ParseUint(key, 10, 32)
+		if err != nil {
+			return nil, nil
+		}
+		var invoice models.Invoice
+		found, err := auditFound(database.DB.Where("medical_record_id = ?", uint(emrID)).First(&invoice).Error)
+		if !found {
+			return nil, err
+		}
+		return &middleware.AuditSnapshot{EntityID: invoice.ID, PatientID: &invoice.PatientID, Data: invoice}, nil
+	},
+}
+
+// PaymentAudit adalah target audit untuk rute pembayaran (parameter :paymentId); pasien diambil dari tagihannya
+var PaymentAudit = middleware.AuditTarget{
+	EntityType: models.AuditEntityPayment,
+	Param:      "paymentId",
+	Load: func(key string, full bool) (*middleware.AuditSnapshot, error) {
+		var payment models.Payment
+		found, err := auditFindByID(database.DB, &payment, key)
+		if !found {
+			return nil, err
+		}
+		snapshot := &middleware.AuditSnapshot{EntityID: payment.ID, Data: payment}
+		var invoice models.Invoice
+		if err := database.DB.Select("id", "patient_id").First(&invoice, payment.InvoiceID).Error; err == nil {
+			snapshot.PatientID = &invoice.PatientID
+		}
+		return snapshot, nil
+	},
+}
+
+// UserAudit adalah target audit untuk rute manajemen pengguna (parameter :userId)
+var UserAudit = middleware.AuditTarget{
+	EntityType: models.AuditEntityUser,
+	Param:      "userId",
+	Load: func(key string, full bool) (*middleware.AuditSnapshot, error) {
+		var user models.User
+		found, err := auditFindByID(database.DB, &user, key)
+		if !found {
+			return nil, err
+		}
+		return &middleware.AuditSnapshot{EntityID: user.ID, Data: user}, nil
+	},
+}
+
+// RoleAudit adalah target audit untuk rute role (parameter :roleId), termasuk daftar permission-nya
+var RoleAudit = middleware.AuditTarget{
+	EntityType: models.AuditEntityRole,
+	Param:      "roleId",
+	Load: func(key string, full bool) (*middleware.AuditSnapshot, error) {
+		var role models.Role
+		query := database.DB
+		if full {
+			query = query.Preload("Permissions", func(db *gorm.DB) *gorm.DB { return db.Order("kode asc") })
+		}
+		found, err := auditFindByID(query, &role, key)
+		if !found {
+			return nil, err
+		}
+		return &middleware.AuditSnapshot{EntityID: role.ID, Data: role}, nil
+	},
+}
+
+// auditLogQuery menerapkan filter query string pada audit log
+func auditLogQuery(c *fiber.Ctx) *gorm.DB {
+	query := database.DB.Model(&models.AuditLog{})
+	if patientID := c.Query("patientId"); patientID != "" {
+		query = query.Where("patient_id = ?", patientID)
+	}
+	if actorID := c.Query("actorId"); actorID != "" {
+		query = query.Where("actor_id = ?", actorID)
+	}
+	if entityType := c.Query("entityType"); entityType != "" {
+		query = query.Where("entity_type = ?", entityType)
+	}
+	if entityID := c.Query("entityId"); entityID != "" {
+		query = query.Where("entity_id = ?", entityID)
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if startDate := c.Query("startDate"); startDate != "" {
+		query = query.Where("created_at::date >= ?", startDate)
+	}
+	if endDate := c.Query("endDate"); endDate != "" {
+		query = query.Where("created_at::date <= ?", endDate)
+	}
+	return query.Session(&gorm.Session{})
+}
+
+// GetAuditLogs menampilkan jejak audit. Filter: patientId (semua akses ke data pasien tertentu),
+// actorId (semua aksi seorang pengguna), entityType, entityId, action, startDate, endDate.
+func GetAuditLogs(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	if limit <= 0 {
+		limit = 20
+	}
+	if page <= 0 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+
+	query := auditLogQuery(c)
+	var totalRecords int64
+	if err := query.Count(&totalRecords).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal menghitung total audit log", err.Error())
+	}
+
+	auditLogs := []models.AuditLog{}
+	if err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&auditLogs).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal mengambil data audit log", err.Error())
+	}
+
+	paginationData := fiber.Map{
+		"currentPage":  page,
+		"totalPages":   int(math.Ceil(float64(totalRecords) / float64(limit))),
+		"totalRecords": totalRecords,
+		"pageSize":     limit,
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success":    true,
+		"data":       auditLogs,
+		"pagination": paginationData,
+		"message":    "Data audit log berhasil diambil",
+	})
+}
+
+// csvSafeCell mencegah formula injection saat CSV dibuka di spreadsheet:
+// sel yang diawali =, +, -, @, tab atau CR diberi awalan petik tunggal agar dibaca sebagai teks
+func csvSafeCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// ExportAuditLogs mengunduh jejak audit dalam format CSV dengan filter yang sama seperti GetAuditLogs
+func ExportAuditLogs(c *fiber.Ctx) error {
+	var buf bytes.Buffer
+	buf.WriteString("\uFEFF") // BOM agar Excel membaca UTF-8 dengan benar
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"Waktu", "Aksi", "Entitas", "ID Entitas", "ID Pasien", "ID Pengguna", "Username", "Role", "IP", "Method", "Path", "Perubahan"})
+
+	// FindInBatches mengurutkan berdasarkan ID, sama dengan urutan waktu pencatatan
+	var batch []models.AuditLog
+	result := auditLogQuery(c).FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+		for _, entry := range batch {
+			patientID, actorID := "", ""
+			if entry.PatientID != nil {
+				patientID = strconv.FormatUint(uint64(*entry.PatientID), 10)
+			}
+			if entry.ActorID != nil {
+				actorID = strconv.FormatUint(uint64(*entry.ActorID), 10)
+			}
+			entityID := ""
+			if entry.EntityID != 0 {
+				entityID = strconv.FormatUint(uint64(entry.EntityID), 10)
+			}
+			w.Write([]string{
+				entry.CreatedAt.Format(time.RFC3339), entry.Action, entry.EntityType, entityID, patientID, actorID,
+				csvSafeCell(entry.ActorUsername), csvSafeCell(entry.ActorRole), csvSafeCell(entry.IPAddress), entry.Method,
+				csvSafeCell(entry.Path), csvSafeCell(string(entry.Changes)),
+			})
+		}
+		return nil
+	})
+	if result.Error != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal mengekspor audit log", result.Error.Error())
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal menyusun CSV audit log", err.Error())
+	}
+
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="audit-log-%s.csv"`, time.Now().Format("20060102-150405")))
+	return c.Status(fiber.StatusOK).Send(buf.Bytes())
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 	"time" // Tambahkan import time jika belum ada
 
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/config"
 	"github.com/MadeAgus22/dental-clinic-backend/pkg/database"
 	"github.com/MadeAgus22/dental-clinic-backend/pkg/dto"
 	"github.com/MadeAgus22/dental-clinic-backend/pkg/models"
@@ -44,47 +45,68 @@ func LoginUser(c *fiber.Ctx) error {
 		return utils.ValidationErrorResponse(c, err.Error())
 	}
 
+	keys := loginThrottleKeys(req.Username, c.IP())
+	if handled, err := guardLogin(c, keys, req.Username, nil); handled {
+		return err
+	}
+
+	const invalidCredentials = "Username, password, atau peran tidak sesuai."
 	var user models.User
-	if err := database.DB.Where("LOWER(username) = ? AND role = ?", strings.ToLower(req.Username), req.Role).First(&user).Error; err != nil {
+	if err := database.DB.Preload("RoleDetail").Where("LOWER(username) = ? AND role = ?", strings.ToLower(req.Username), strings.ToLower(req.Role)).First(&user).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return utils.ErrorResponse(c, fiber.StatusUnauthorized, "Username, password, atau peran tidak sesuai.")
+			return failLogin(c, keys, req.Username, nil, models.LoginEventInvalidCredentials, invalidCredentials)
 		}
 		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
 	}
 
+	// Password diperiksa lebih dulu agar status akun tidak terbuka tanpa password yang benar
+	if !utils.CheckPasswordHash(req.Password, user.PasswordHash) {
+		return failLogin(c, keys, req.Username, &user.ID, models.LoginEventInvalidCredentials, invalidCredentials)
+	}
+
 	if user.Status != "aktif" {
+		recordLoginEvent(c, req.Username, &user.ID, false, models.LoginEventUserInactive)
 		return utils.ErrorResponse(c, fiber.StatusForbidden, "Akun pengguna tidak aktif.")
 	}
 
-	if !utils.CheckPasswordHash(req.Password, user.PasswordHash) {
-		return utils.ErrorResponse(c, fiber.StatusUnauthorized, "Username, password, atau peran tidak sesuai.")
+	if user.RoleDetail.ID == 0 { // Role sudah dihapus
+		recordLoginEvent(c, req.Username, &user.ID, false, models.LoginEventRoleInactive)
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "Role pengguna sudah tidak aktif.")
+	}
+
+	// Login dua langkah: password benar, lanjutkan dengan kode authenticator
+	if user.TwoFactorEnabled {
+		return twoFactorChallengeResponse(c, user, utils.ChallengeTwoFactor)
 	}
+	if config.AppConfig.TwoFactorRequiredForRole(user.Role) {
+		return twoFactorChallengeResponse(c, user, utils.ChallengeTwoFactorSetup)
+	}
+
+	return completeLogin(c, user, nil)
+}
+
+// completeLogin mencatat waktu login, membuat sesi baru dan mengirim pasangan token ke klien.
+// Penghitung kegagalan username baru direset di sini, setelah langkah kedua (jika ada) juga lolos.
+func completeLogin(c *fiber.Ctx, user models.User, recoveryCodes []string) error {
+	clearLoginThrottle(user.Username)
+	recordLoginEvent(c, user.Username, &user.ID, true, models.LoginEventSuccess)
 
 	// Update LastLogin
 	now := time.Now()
 	user.LastLogin = &now
-	database.DB.Save(&user)
+	database.DB.Model(&user).Update("last_login", now)
 
-	token, err := utils.GenerateJWT(user.ID, user.Username, user.Role)
+	session, refreshToken, err := createSession(database.DB, user.ID, c.Get(fiber.HeaderUserAgent), c.IP())
 	if err != nil {
-		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal membuat token", err.Error())
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal membuat sesi login", err.Error())
 	}
 
-	permissionKodes, err := getPermissionCodesForRole(user.Role)
+	response, err := buildLoginResponse(user, session, refreshToken)
 	if err != nil {
-		// Log error tapi tetap lanjutkan login, mungkin dengan permission kosong
-		fmt.Printf("Peringatan: Gagal mengambil permission untuk role %s: %v\n", user.Role, err)
-	}
-
-	return utils.SuccessResponse(c, fiber.StatusOK, "Login berhasil", dto.LoginResponse{
-		Token:       token,
-		ID:          user.ID,
-		Username:    user.Username,
-		NamaLengkap: user.NamaLengkap,
-		Email:       user.Email,
-		Role:        user.Role,
-		Permissions: permissionKodes, // Sertakan permission
-	})
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal membuat token", err.Error())
+	}
+	response.RecoveryCodes = recoveryCodes
+	return utils.SuccessResponse(c, fiber.StatusOK, "Login berhasil", response)
 }
 
 func GetCurrentUser(c *fiber.Ctx) error {
@@ -102,17 +124,18 @@ func GetCurrentUser(c *fiber.Ctx) error {
 
 	// Buat DTO UserResponse
 	userResponse := dto.UserResponse{
-		ID:          user.ID,
-		NamaLengkap: user.NamaLengkap,
-		Username:    user.Username,
-		Email:       user.Email,
-		Role:        user.Role,
-		Status:      user.Status,
-		PhoneNumber: user.PhoneNumber,
-		LastLogin:   user.LastLogin,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		Permissions: permissionKodes, // Sertakan permission
+		ID:               user.ID,
+		NamaLengkap:      user.NamaLengkap,
+		Username:         user.Username,
+		Email:            user.Email,
+		Role:             user.Role,
+		Status:           user.Status,
+		PhoneNumber:      user.PhoneNumber,
+		TwoFactorEnabled: user.TwoFactorEnabled,
+		LastLogin:        user.LastLogin,
+		CreatedAt:        user.CreatedAt,
+		UpdatedAt:        user.UpdatedAt,
+		Permissions:      permissionKodes, // Sertakan permission
 	}
 
 	return utils.SuccessResponse(c, fiber.StatusOK, "Data user berhasil diambil", userResponse)
@@ -0,0 +1,415 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/database"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/dto"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/models"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errInvoiceAlreadyExists = errors.New("tagihan untuk EMR ini sudah ada")
+	errInvoiceHasPayments   = errors.New("tagihan sudah memiliki pembayaran")
+	errInvoiceEmpty         = errors.New("EMR tidak memiliki item yang dapat ditagih")
+)
+
+// buildInvoiceLines menyalin item tindakan dan obat EMR menjadi baris tagihan.
+// EMR harus sudah di-preload dengan Treatments.TreatmentCatalog dan Medications.MedicationCatalog.
+func buildInvoiceLines(emr models.MedicalRecord) []models.InvoiceLine {
+	lines := make([]models.InvoiceLine, 0, len(emr.Treatments)+len(emr.Medications))
+	for _, t := range emr.Treatments {
+		lines = append(lines, models.InvoiceLine{
+			Tipe:            models.InvoiceLineTypeTindakan,
+			ReferensiItemID: t.ID,
+			Kode:            t.TreatmentCatalog.Kode,
+			Deskripsi:       t.TreatmentCatalog.Nama,
+			ToothNumber:     t.ToothNumber,
+			Quantity:        t.Quantity,
+			HargaSatuan:     t.PriceAtTime,
+			DiscountPercent: t.DiscountPercent,
+			SubTotal:        t.SubTotal,
+		})
+	}
+	for _, m := range emr.Medications {
+		lines = append(lines, models.InvoiceLine{
+			Tipe:            models.InvoiceLineTypeObat,
+			ReferensiItemID: m.ID,
+			Kode:            m.MedicationCatalog.Kode,
+			Deskripsi:       m.MedicationCatalog.Nama,
+			Quantity:        m.Quantity,
+			HargaSatuan:     m.PricePerUnitAtTime,
+			SubTotal:        m.SubTotal,
+		})
+	}
+	return lines
+}
+
+// invoiceStatusFor menentukan status tagihan dari total dan jumlah yang sudah dibayar.
+// Tagihan bernilai nol tidak pernah dianggap lunas.
+func invoiceStatusFor(total, totalDibayar float64) string {
+	switch {
+	case total > 0 && totalDibayar >= total:
+		return models.InvoiceStatusLunas
+	case totalDibayar > 0:
+		return models.InvoiceStatusSebagian
+	default:
+		return models.InvoiceStatusBelumLunas
+	}
+}
+
+// recalculateInvoice menghitung ulang Total, SisaTagihan dan Status tagihan
+func recalculateInvoice(invoice *models.Invoice) {
+	total := 0.0
+	for _, line := range invoice.Lines {
+		total += line.SubTotal
+	}
+	invoice.Total = roundCurrency(total)
+	invoice.TotalDibayar = roundCurrency(invoice.TotalDibayar)
+	invoice.SisaTagihan = roundCurrency(math.Max(invoice.Total-invoice.TotalDibayar, 0))
+	invoice.Status = invoiceStatusFor(invoice.Total, invoice.TotalDibayar)
+}
+
+// syncEMRBillingStatus menyalin status tagihan ke kolom BillingStatus EMR (denormalisasi untuk daftar EMR)
+func syncEMRBillingStatus(tx *gorm.DB, medicalRecordID uint, status string) error {
+	return tx.Model(&models.MedicalRecord{}).Where("id = ?", medicalRecordID).Update("billing_status", status).Error
+}
+
+// loadEMRForBilling mengambil EMR beserta item yang dibutuhkan untuk tagihan, sambil mengunci barisnya
+func loadEMRForBilling(tx *gorm.DB, medicalRecordID uint) (models.MedicalRecord, error) {
+	var emr models.MedicalRecord
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&emr, medicalRecordID).Error
+	if err != nil {
+		return emr, err
+	}
+	err = tx.Preload("Treatments.TreatmentCatalog", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Preload("Medications.MedicationCatalog", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		First(&emr, medicalRecordID).Error
+	return emr, err
+}
+
+// nextReceiptNumber mengambil nomor kwitansi berikutnya dari sequence database
+func nextReceiptNumber(tx *gorm.DB) (string, error) {
+	var seq int64
+	if err := tx.Raw("SELECT nextval('receipt_number_seq')").Scan(&seq).Error; err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("KW-%06d", seq), nil
+}
+
+// findInvoiceDetail mengambil tagihan lengkap dengan pasien, baris dan pembayarannya
+func findInvoiceDetail(invoiceID uint) (models.Invoice, error) {
+	var invoice models.Invoice
+	err := database.DB.Preload("Patient").
+		Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("id asc") }).
+		Preload("Payments", func(db *gorm.DB) *gorm.DB { return db.Order("tanggal asc") }).
+		First(&invoice, invoiceID).Error
+	return invoice, err
+}
+
+// CreateInvoice membuat tagihan dari item tindakan dan obat sebuah EMR
+func CreateInvoice(c *fiber.Ctx) error {
+	req := new(dto.CreateInvoiceRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	if err := validate.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	var invoice models.Invoice
+	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		emr, err := loadEMRForBilling(tx, req.MedicalRecordID)
+		if err != nil {
+			return err
+		}
+
+		var existingCount int64
+		if err := tx.Model(&models.Invoice{}).Where("medical_record_id = ?", emr.ID).Count(&existingCount).Error; err != nil {
+			return err
+		}
+		if existingCount > 0 {
+			return errInvoiceAlreadyExists
+		}
+
+		now := time.Now()
+		invoice = models.Invoice{
+			MedicalRecordID: emr.ID,
+			PatientID:       emr.PatientID,
+			Tanggal:         now,
+			Catatan:         req.Catatan,
+			CreatedByID:     c.Locals("user_id").(uint),
+			Lines:           buildInvoiceLines(emr),
+		}
+		recalculateInvoice(&invoice)
+		if len(invoice.Lines) == 0 || invoice.Total <= 0 {
+			return errInvoiceEmpty
+		}
+
+		if err := tx.Create(&invoice).Error; err != nil {
+			return err
+		}
+		// Nomor tagihan memakai ID agar unik tanpa perlu query urutan tambahan
+		invoice.NoInvoice = fmt.Sprintf("INV-%s-%06d", now.Format("20060102"), invoice.ID)
+		if err := tx.Model(&invoice).Update("no_invoice", invoice.NoInvoice).Error; err != nil {
+			return err
+		}
+		return syncEMRBillingStatus(tx, emr.ID, invoice.Status)
+	})
+
+	if errTx != nil {
+		if errTx == gorm.ErrRecordNotFound {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "EMR tidak ditemukan")
+		}
+		if errTx == errInvoiceEmpty {
+			return utils.ErrorResponse(c, fiber.StatusBadRequest, "Tagihan tidak dapat dibuat: EMR tidak memiliki tindakan atau obat dengan nilai lebih dari nol.")
+		}
+		if errTx == errInvoiceAlreadyExists {
+			return utils.ErrorResponse(c, fiber.StatusConflict, "Tagihan untuk EMR ini sudah dibuat sebelumnya.")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal membuat tagihan", errTx.Error())
+	}
+
+	createdInvoice, _ := findInvoiceDetail(invoice.ID)
+	return utils.SuccessResponse(c, fiber.StatusCreated, "Tagihan berhasil dibuat", createdInvoice)
+}
+
+// RegenerateInvoice menyusun ulang baris tagihan dari EMR terbaru, hanya jika belum ada pembayaran
+func RegenerateInvoice(c *fiber.Ctx) error {
+	invoiceID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Invoice ID tidak valid")
+	}
+
+	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		var invoice models.Invoice
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&invoice, uint(invoiceID)).Error; err != nil {
+			return err
+		}
+
+		var paymentCount int64
+		if err := tx.Model(&models.Payment{}).Where("invoice_id = ?", invoice.ID).Count(&paymentCount).Error; err != nil {
+			return err
+		}
+		if paymentCount > 0 {
+			return errInvoiceHasPayments
+		}
+
+		emr, err := loadEMRForBilling(tx, invoice.MedicalRecordID)
+		if err != nil {
+			return err
+		}
+
+		invoice.Lines = buildInvoiceLines(emr)
+		recalculateInvoice(&invoice)
+		if len(invoice.Lines) == 0 || invoice.Total <= 0 {
+			return errInvoiceEmpty
+		}
+
+		if err := tx.Unscoped().Where("invoice_id = ?", invoice.ID).Delete(&models.InvoiceLine{}).Error; err != nil {
+			return err
+		}
+		for i := range invoice.Lines {
+			invoice.Lines[i].InvoiceID = invoice.ID
+		}
+		if err := tx.Create(&invoice.Lines).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&invoice).Select("total", "total_dibayar", "sisa_tagihan", "status").Updates(&invoice).Error; err != nil {
+			return err
+		}
+		return syncEMRBillingStatus(tx, invoice.MedicalRecordID, invoice.Status)
+	})
+
+	if errTx != nil {
+		if errTx == gorm.ErrRecordNotFound {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "Tagihan atau EMR tidak ditemukan")
+		}
+		if errTx == errInvoiceEmpty {
+			return utils.ErrorResponse(c, fiber.StatusBadRequest, "Tagihan tidak dapat disusun ulang: EMR tidak memiliki tindakan atau obat dengan nilai lebih dari nol.")
+		}
+		if errTx == errInvoiceHasPayments {
+			return utils.ErrorResponse(c, fiber.StatusConflict, "Tagihan yang sudah memiliki pembayaran tidak dapat disusun ulang.")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal menyusun ulang tagihan", errTx.Error())
+	}
+
+	invoice, _ := findInvoiceDetail(uint(invoiceID))
+	return utils.SuccessResponse(c, fiber.StatusOK, "Tagihan berhasil disusun ulang", invoice)
+}
+
+// GetInvoices mengambil daftar tagihan dengan pagination dan filter
+func GetInvoices(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "10"))
+	if limit <= 0 {
+		limit = 10
+	}
+	if page <= 0 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+
+	query := database.DB.Model(&models.Invoice{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if patientID := c.Query("patientId"); patientID != "" {
+		query = query.Where("patient_id = ?", patientID)
+	}
+	if medicalRecordID := c.Query("medicalRecordId"); medicalRecordID != "" {
+		query = query.Where("medical_record_id = ?", medicalRecordID)
+	}
+	if startDate := c.Query("startDate"); startDate != "" {
+		query = query.Where("tanggal::date >= ?", startDate)
+	}
+	if endDate := c.Query("endDate"); endDate != "" {
+		query = query.Where("tanggal::date <= ?", endDate)
+	}
+	query = query.Session(&gorm.Session{})
+
+	var totalRecords int64
+	if err := query.Count(&totalRecords).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal menghitung total tagihan", err.Error())
+	}
+
+	invoices := []models.Invoice{}
+	if err := query.Preload("Patient").Order("tanggal DESC").Offset(offset).Limit(limit).Find(&invoices).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal mengambil data tagihan", err.Error())
+	}
+
+	paginationData := fiber.Map{
+		"currentPage":  page,
+		"totalPages":   int(math.Ceil(float64(totalRecords) / float64(limit))),
+		"totalRecords": totalRecords,
+		"pageSize":     limit,
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success":    true,
+		"data":       invoices,
+		"pagination": paginationData,
+		"message":    "Data tagihan berhasil diambil",
+	})
+}
+
+// GetInvoiceByID mengambil detail tagihan beserta baris dan riwayat pembayarannya
+func GetInvoiceByID(c *fiber.Ctx) error {
+	invoiceID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Invoice ID tidak valid")
+	}
+
+	invoice, err := findInvoiceDetail(uint(invoiceID))
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "Tagihan tidak ditemukan")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Tagihan ditemukan", invoice)
+}
+
+// CreatePayment mencatat pembayaran (penuh atau sebagian) atas tagihan dan memperbarui statusnya
+func CreatePayment(c *fiber.Ctx) error {
+	invoiceID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Invoice ID tidak valid")
+	}
+
+	req := new(dto.CreatePaymentRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	if err := validate.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+	if req.UangDiterima != nil && req.Metode != models.PaymentMethodTunai {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Uang diterima hanya berlaku untuk pembayaran tunai")
+	}
+
+	userID := c.Locals("user_id").(uint)
+	var cashier models.User
+	if err := database.DB.First(&cashier, userID).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusUnauthorized, "Pengguna tidak ditemukan")
+	}
+
+	var payment models.Payment
+	var statusCode int
+	var message string
+	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		var invoice models.Invoice
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Lines").First(&invoice, uint(invoiceID)).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				statusCode, message = fiber.StatusNotFound, "Tagihan tidak ditemukan"
+			}
+			return err
+		}
+
+		if invoice.Status == models.InvoiceStatusLunas {
+			statusCode, message = fiber.StatusConflict, "Tagihan sudah lunas."
+			return errors.New(message)
+		}
+		jumlah := roundCurrency(req.Jumlah)
+		if jumlah > invoice.SisaTagihan {
+			statusCode, message = fiber.StatusBadRequest, fmt.Sprintf("Jumlah pembayaran melebihi sisa tagihan (%.2f).", invoice.SisaTagihan)
+			return errors.New(message)
+		}
+
+		payment = models.Payment{
+			InvoiceID:      invoice.ID,
+			Tanggal:        time.Now(),
+			Metode:         req.Metode,
+			Jumlah:         jumlah,
+			NoReferensi:    req.NoReferensi,
+			Catatan:        req.Catatan,
+			ReceivedByID:   cashier.ID,
+			ReceivedByName: cashier.NamaLengkap,
+		}
+		if req.UangDiterima != nil {
+			if *req.UangDiterima < jumlah {
+				statusCode, message = fiber.StatusBadRequest, "Uang diterima kurang dari jumlah pembayaran."
+				return errors.New(message)
+			}
+			payment.UangDiterima = roundCurrency(*req.UangDiterima)
+			payment.Kembalian = roundCurrency(*req.UangDiterima - jumlah)
+		}
+		noKwitansi, err := nextReceiptNumber(tx)
+		if err != nil {
+			return err
+		}
+		payment.NoKwitansi = noKwitansi
+		if err := tx.Create(&payment).Error; err != nil {
+			return err
+		}
+
+		invoice.TotalDibayar += jumlah
+		recalculateInvoice(&invoice)
+		if err := tx.Model(&invoice).Select("total", "total_dibayar", "sisa_tagihan", "status").Updates(&invoice).Error; err != nil {
+			return err
+		}
+		return syncEMRBillingStatus(tx, invoice.MedicalRecordID, invoice.Status)
+	})
+
+	if errTx != nil {
+		if statusCode != 0 {
+			return utils.ErrorResponse(c, statusCode, message)
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal memproses pembayaran", errTx.Error())
+	}
+
+	invoice, _ := findInvoiceDetail(uint(invoiceID))
+	return utils.SuccessResponse(c, fiber.StatusCreated, "Pembayaran berhasil dicatat", fiber.Map{
+		"payment": payment,
+		"invoice": invoice,
+	})
+}
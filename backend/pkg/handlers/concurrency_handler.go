@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errVersionConflict dikembalikan jika data diubah pengguna lain di antara pembacaan dan penyimpanan
+var errVersionConflict = errors.New("data sudah diubah pengguna lain")
+
+// ifMatchRequiredResponse dikirim jika PUT tidak menyertakan header If-Match
+func ifMatchRequiredResponse(c *fiber.Ctx) error {
+	return utils.ErrorResponse(c, fiber.StatusPreconditionRequired, "Header If-Match wajib diisi dengan ETag data yang terakhir dibaca")
+}
+
+// updateWithVersion menyimpan semua kolom model (kecuali relasi) hanya jika versi di database masih expectedVersion.
+// Field Version pada model harus sudah dinaikkan oleh pemanggil. Mengembalikan errVersionConflict jika tidak ada baris
+// yang cocok, yaitu data sudah diubah pengguna lain sejak dibaca.
+func updateWithVersion(tx *gorm.DB, model interface{}, expectedVersion int) error {
+	result := tx.Model(model).Where("version = ?", expectedVersion).
+		Select("*").Omit("id", "created_at", "deleted_at", clause.Associations).
+		Updates(model)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errVersionConflict
+	}
+	return nil
+}
@@ -1,19 +1,135 @@
 package handlers
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"math"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/MadeAgus22/dental-clinic-backend/pkg/database" // Sesuaikan dengan path module Anda
 	"github.com/MadeAgus22/dental-clinic-backend/pkg/dto"      // Sesuaikan
 	"github.com/MadeAgus22/dental-clinic-backend/pkg/models"   // Sesuaikan
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/pdf"      // Sesuaikan
 	"github.com/MadeAgus22/dental-clinic-backend/pkg/utils"    // Sesuaikan
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// unknownCatalogCodesError dikembalikan jika kode tindakan/obat pada EMR tidak ada di master data
+type unknownCatalogCodesError struct {
+	Catalog string
+	Codes   []string
+}
+
+func (e *unknownCatalogCodesError) Error() string {
+	return fmt.Sprintf("kode %s tidak ditemukan di master data: %s", e.Catalog, strings.Join(e.Codes, ", "))
+}
+
+// roundCurrency membulatkan nominal ke 2 angka desimal
+func roundCurrency(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+// buildTreatmentItems mencocokkan kode tindakan dengan master tindakan, lalu mengisi harga dari
+// master (snapshot) dan menghitung SubTotal di backend. Harga dari client tidak pernah dipakai.
+func buildTreatmentItems(db *gorm.DB, treatmentDTOs []dto.MedicalRecordTreatmentItemDTO) ([]models.MedicalRecordTreatmentItem, error) {
+	if len(treatmentDTOs) == 0 {
+		return nil, nil
+	}
+
+	codes := make([]string, 0, len(treatmentDTOs))
+	for _, t := range treatmentDTOs {
+		codes = append(codes, t.TreatmentCode)
+	}
+	var catalogs []models.TreatmentCatalog
+	if err := db.Where("kode IN ?", codes).Find(&catalogs).Error; err != nil {
+		return nil, err
+	}
+	catalogByCode := make(map[string]models.TreatmentCatalog, len(catalogs))
+	for _, catalog := range catalogs {
+		catalogByCode[catalog.Kode] = catalog
+	}
+
+	var unknown []string
+	items := make([]models.MedicalRecordTreatmentItem, 0, len(treatmentDTOs))
+	for _, treatmentDTO := range treatmentDTOs {
+		catalog, ok := catalogByCode[treatmentDTO.TreatmentCode]
+		if !ok {
+			unknown = append(unknown, treatmentDTO.TreatmentCode)
+			continue
+		}
+		items = append(items, models.MedicalRecordTreatmentItem{
+			TreatmentCatalogID: catalog.ID,
+			ToothNumber:        treatmentDTO.ToothNumber,
+			Quantity:           treatmentDTO.Quantity,
+			PriceAtTime:        catalog.Harga,
+			DiscountPercent:    treatmentDTO.DiscountPercent,
+			SubTotal:           treatmentSubTotal(catalog.Harga, treatmentDTO.Quantity, treatmentDTO.DiscountPercent),
+			Notes:              treatmentDTO.Notes,
+		})
+	}
+	if len(unknown) > 0 {
+		return nil, &unknownCatalogCodesError{Catalog: "tindakan", Codes: unknown}
+	}
+	return items, nil
+}
+
+// buildMedicationItems mencocokkan kode obat dengan master obat, lalu mengisi HargaJual sebagai
+// harga per unit dan menghitung SubTotal di backend.
+func buildMedicationItems(db *gorm.DB, medicationDTOs []dto.MedicalRecordMedicationItemDTO) ([]models.MedicalRecordMedicationItem, error) {
+	if len(medicationDTOs) == 0 {
+		return nil, nil
+	}
+
+	codes := make([]string, 0, len(medicationDTOs))
+	for _, m := range medicationDTOs {
+		codes = append(codes, m.MedicationCode)
+	}
+	var catalogs []models.MedicationCatalog
+	if err := db.Where("kode IN ?", codes).Find(&catalogs).Error; err != nil {
+		return nil, err
+	}
+	catalogByCode := make(map[string]models.MedicationCatalog, len(catalogs))
+	for _, catalog := range catalogs {
+		catalogByCode[catalog.Kode] = catalog
+	}
+
+	var unknown []string
+	items := make([]models.MedicalRecordMedicationItem, 0, len(medicationDTOs))
+	for _, medDTO := range medicationDTOs {
+		catalog, ok := catalogByCode[medDTO.MedicationCode]
+		if !ok {
+			unknown = append(unknown, medDTO.MedicationCode)
+			continue
+		}
+		items = append(items, models.MedicalRecordMedicationItem{
+			MedicationCatalogID: catalog.ID,
+			Quantity:            medDTO.Quantity,
+			PricePerUnitAtTime:  catalog.HargaJual,
+			SubTotal:            roundCurrency(catalog.HargaJual * float64(medDTO.Quantity)),
+			Instruction:         medDTO.Instruction,
+		})
+	}
+	if len(unknown) > 0 {
+		return nil, &unknownCatalogCodesError{Catalog: "obat", Codes: unknown}
+	}
+	return items, nil
+}
+
+// catalogErrorResponse memetakan error dari buildTreatmentItems/buildMedicationItems ke respons HTTP
+func catalogErrorResponse(c *fiber.Ctx, err error) error {
+	var unknownErr *unknownCatalogCodesError
+	if errors.As(err, &unknownErr) {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, fmt.Sprintf("Kode %s tidak dikenal", unknownErr.Catalog), unknownErr.Error())
+	}
+	return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal mengambil data master", err.Error())
+}
+
 // CreateEMR membuat rekam medis baru
 func CreateEMR(c *fiber.Ctx) error {
 	req := new(dto.CreateEMRRequest) // Anda perlu membuat DTO ini
@@ -25,49 +141,42 @@ func CreateEMR(c *fiber.Ctx) error {
 		return utils.ValidationErrorResponse(c, err.Error())
 	}
 
+	// Dokter harus pengguna aktif ber-permission dokter pemeriksa; namanya diambil dari data pengguna
+	doctor, err := findDoctor(database.DB, req.DoctorID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.ErrorResponse(c, fiber.StatusBadRequest, "Dokter tidak ditemukan")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
+	}
+
 	// Mapping DTO ke Model EMR
 	emr := models.MedicalRecord{
-		VisitID:       fmt.Sprintf("VISIT-%d-%s", req.PatientID, time.Now().Format("20060102150405")), // Contoh VisitID
 		PatientID:     req.PatientID,
-		DoctorID:      req.DoctorID,
-		DoctorName:    req.DoctorName, // Sebaiknya diambil dari data dokter berdasarkan DoctorID
-		ExamDate:      time.Now(),     // Atau dari request jika bisa diatur
+		DoctorID:      doctor.ID,
+		DoctorName:    doctor.NamaLengkap,
+		ExamDate:      time.Now(), // Atau dari request jika bisa diatur
 		VisitType:     req.VisitType,
 		Complaint:     req.Complaint,
 		Examination:   req.Examination,
 		Diagnosis:     req.Diagnosis,
 		TreatmentPlan: req.TreatmentPlan,
 		Notes:         req.Notes,
-		BillingStatus: "Belum Lunas", // Default
-	}
-
-	// Handle Treatments
-	for _, treatmentDTO := range req.Treatments {
-		// Anda mungkin perlu mencari TreatmentCatalogID berdasarkan kode tindakan
-		// Untuk sementara, kita asumsikan TreatmentCatalogID sudah ada di DTO atau kode unik
-		item := models.MedicalRecordTreatmentItem{
-			// TreatmentCatalogID: treatmentDTO.TreatmentCatalogID, // atau cari berdasarkan kode
-			ToothNumber:     treatmentDTO.ToothNumber,
-			Quantity:        treatmentDTO.Quantity,
-			PriceAtTime:     treatmentDTO.PriceAtTime, // Ambil harga dari master saat itu
-			DiscountPercent: treatmentDTO.DiscountPercent,
-			SubTotal:        treatmentDTO.SubTotal, // Hitung di frontend atau backend
-			Notes:           treatmentDTO.Notes,
-		}
-		emr.Treatments = append(emr.Treatments, item)
+		BillingStatus: models.InvoiceStatusBelumLunas, // Default sampai tagihan dibuat
 	}
 
-	// Handle Medications
-	for _, medDTO := range req.Medications {
-		item := models.MedicalRecordMedicationItem{
-			// MedicationCatalogID: medDTO.MedicationCatalogID, // atau cari berdasarkan kode
-			Quantity:           medDTO.Quantity,
-			PricePerUnitAtTime: medDTO.PricePerUnitAtTime,
-			SubTotal:           medDTO.SubTotal,
-			Instruction:        medDTO.Instruction,
-		}
-		emr.Medications = append(emr.Medications, item)
+	// Handle Treatments & Medications: kode dicocokkan ke master, harga dihitung di backend
+	treatments, err := buildTreatmentItems(database.DB, req.Treatments)
+	if err != nil {
+		return catalogErrorResponse(c, err)
+	}
+	emr.Treatments = treatments
+
+	medications, err := buildMedicationItems(database.DB, req.Medications)
+	if err != nil {
+		return catalogErrorResponse(c, err)
 	}
+	emr.Medications = medications
 
 	// Handle Odontogram
 	for _, odontoDTO := range req.Odontogram {
@@ -91,10 +200,24 @@ func CreateEMR(c *fiber.Ctx) error {
 	}
 
 	// Transaksi Database
-	err := database.DB.Transaction(func(tx *gorm.DB) error {
+	version := emrVersionAuthor(c, "")
+	ledger := newStockLedger(c)
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Nomor kunjungan dari sequence database sesuai VISIT_NUMBER_FORMAT
+		visitID, err := database.VisitNumbers.Next(tx, emr.ExamDate)
+		if err != nil {
+			return err
+		}
+		emr.VisitID = visitID
 		if err := tx.Create(&emr).Error; err != nil {
 			return err
 		}
+		// Stok obat dikurangi dalam transaksi yang sama dengan penyimpanan EMR
+		for _, item := range emr.Medications {
+			if err := ledger.postEMRItem(tx, emr.VisitID, item, item.Quantity, "Obat diberikan lewat EMR"); err != nil {
+				return err
+			}
+		}
 		// GORM akan otomatis menyimpan relasi has many (Treatments, Medications, Odontogram)
 		// jika primary key EMR sudah ter-generate dan foreign key di-set dengan benar.
 		// Namun, untuk OdontogramHistory yang nested, Anda mungkin perlu menyimpannya secara manual
@@ -116,22 +239,22 @@ func CreateEMR(c *fiber.Ctx) error {
 				}
 			}
 		}
-		return nil
+		return recordEMRVersion(tx, &emr, version)
 	})
 
 	if err != nil {
-		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal menyimpan EMR", err.Error())
+		return emrErrorResponse(c, err, "Gagal menyimpan EMR")
 	}
 
 	// Ambil ulang EMR dengan semua relasinya untuk respons
 	var createdEMR models.MedicalRecord
 	database.DB.Preload("Patient").
-		Preload("Treatments.TreatmentCatalog"). // Asumsi ada relasi ini di model
-		Preload("Medications.MedicationCatalog").
+		Preload("Treatments.TreatmentCatalog", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }). // Asumsi ada relasi ini di model
+		Preload("Medications.MedicationCatalog", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
 		Preload("Odontogram.History").
 		First(&createdEMR, emr.ID)
 
-	return utils.SuccessResponse(c, fiber.StatusCreated, "EMR berhasil dibuat", createdEMR)
+	return utils.SuccessResponseWithWarnings(c, fiber.StatusCreated, "EMR berhasil dibuat", createdEMR, ledger.warnings)
 }
 
 // GetEMRsByPatient mengambil semua EMR untuk seorang pasien
@@ -145,8 +268,8 @@ func GetEMRsByPatient(c *fiber.Ctx) error {
 	var emrs []models.MedicalRecord
 	query := database.DB.Where("patient_id = ?", uint(patientID)).
 		Preload("Patient").
-		Preload("Treatments.TreatmentCatalog").
-		Preload("Medications.MedicationCatalog").
+		Preload("Treatments.TreatmentCatalog", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Preload("Medications.MedicationCatalog", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
 		Preload("Odontogram.History").
 		Order("exam_date desc")
 
@@ -168,16 +291,18 @@ func GetEMRByID(c *fiber.Ctx) error {
 
 	query := database.DB.
 		Preload("Patient").
-		Preload("Treatments.TreatmentCatalog").
-		Preload("Medications.MedicationCatalog").
-		Preload("Odontogram.History")
+		Preload("Treatments.TreatmentCatalog", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Preload("Medications.MedicationCatalog", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Preload("Odontogram.History").
+		Preload("Addenda", func(db *gorm.DB) *gorm.DB { return db.Order("id asc") }).
+		Session(&gorm.Session{}) // dipakai ulang untuk pencarian VisitID tanpa mewarisi kondisi id
 
 	// Coba cari berdasarkan ID internal EMR (angka) dulu
 	if emrID, err := strconv.ParseUint(idParam, 10, 32); err == nil {
 		if errDb := query.First(&emr, uint(emrID)).Error; errDb != nil {
 			if errDb == gorm.ErrRecordNotFound {
 				// Jika tidak ketemu, coba cari berdasarkan VisitID
-				if errDbVisit := query.Where("visit_id = ?", idParam).First(&emr).Error; errDbVisit != nil {
+				if errDbVisit := query.Where("visit_id = ? OR legacy_visit_id = ?", idParam, idParam).First(&emr).Error; errDbVisit != nil {
 					if errDbVisit == gorm.ErrRecordNotFound {
 						return utils.ErrorResponse(c, fiber.StatusNotFound, "EMR tidak ditemukan")
 					}
@@ -189,7 +314,7 @@ func GetEMRByID(c *fiber.Ctx) error {
 		}
 	} else {
 		// Jika idParam bukan angka, anggap sebagai VisitID
-		if errDb := query.Where("visit_id = ?", idParam).First(&emr).Error; errDb != nil {
+		if errDb := query.Where("visit_id = ? OR legacy_visit_id = ?", idParam, idParam).First(&emr).Error; errDb != nil {
 			if errDb == gorm.ErrRecordNotFound {
 				return utils.ErrorResponse(c, fiber.StatusNotFound, "EMR tidak ditemukan")
 			}
@@ -197,24 +322,60 @@ func GetEMRByID(c *fiber.Ctx) error {
 		}
 	}
 
+	utils.SetETag(c, emr.Version)
 	return utils.SuccessResponse(c, fiber.StatusOK, "EMR berhasil diambil", emr)
 }
 
-// UpdateEMR memperbarui data EMR
-func UpdateEMR(c *fiber.Ctx) error {
+// PrintEMR membuat PDF ringkasan satu EMR (untuk rujukan atau salinan pasien), dicari berdasarkan ID atau VisitID
+func PrintEMR(c *fiber.Ctx) error {
 	idParam := c.Params("id")
-	var existingEMR models.MedicalRecord
+	var emr models.MedicalRecord
 
-	// Cari EMR yang ada berdasarkan ID internal atau VisitID
-	query := database.DB.Preload("Treatments").Preload("Medications").Preload("Odontogram.History")
-	if emrID, err := strconv.ParseUint(idParam, 10, 32); err == nil {
-		if errDb := query.First(&existingEMR, uint(emrID)).Error; errDb != nil {
-			return utils.ErrorResponse(c, fiber.StatusNotFound, "EMR tidak ditemukan (by ID)")
-		}
+	query := database.DB.
+		Preload("Patient").
+		Preload("Treatments", func(db *gorm.DB) *gorm.DB { return db.Order("id asc") }).
+		Preload("Treatments.TreatmentCatalog", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Preload("Medications", func(db *gorm.DB) *gorm.DB { return db.Order("id asc") }).
+		Preload("Medications.MedicationCatalog", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Preload("Odontogram", func(db *gorm.DB) *gorm.DB { return db.Order("tooth_number asc") })
+
+	var err error
+	if emrID, parseErr := strconv.ParseUint(idParam, 10, 32); parseErr == nil {
+		err = query.Where("id = ? OR visit_id = ? OR legacy_visit_id = ?", uint(emrID), idParam, idParam).First(&emr).Error
 	} else {
-		if errDb := query.Where("visit_id = ?", idParam).First(&existingEMR).Error; errDb != nil {
-			return utils.ErrorResponse(c, fiber.StatusNotFound, "EMR tidak ditemukan (by VisitID)")
+		err = query.Where("visit_id = ? OR legacy_visit_id = ?", idParam, idParam).First(&emr).Error
+	}
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "EMR tidak ditemukan")
 		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan database", err.Error())
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.RenderEMRSummary(&buf, clinicInfo(), emr); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal membuat PDF rekam medis", err.Error())
+	}
+	return sendPDF(c, fmt.Sprintf("EMR-%s.pdf", emr.VisitID), &buf)
+}
+
+// loadEMRForResponse mengambil EMR beserta pasien, item dan odontogramnya untuk respons handler
+func loadEMRForResponse(emrID uint) models.MedicalRecord {
+	var emr models.MedicalRecord
+	database.DB.Preload("Patient").
+		Preload("Treatments.TreatmentCatalog", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Preload("Medications.MedicationCatalog", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Preload("Odontogram.History").
+		First(&emr, emrID)
+	return emr
+}
+
+// UpdateEMR memperbarui data EMR. Setiap penyimpanan dicatat sebagai versi baru (lihat emr_version_handler.go).
+// Header If-Match wajib berisi ETag (nomor versi) EMR yang terakhir dibaca.
+func UpdateEMR(c *fiber.Ctx) error {
+	expectedVersion, ok := utils.IfMatchVersion(c)
+	if !ok {
+		return ifMatchRequiredResponse(c)
 	}
 
 	req := new(dto.UpdateEMRRequest) // Anda perlu membuat DTO ini
@@ -225,122 +386,75 @@ func UpdateEMR(c *fiber.Ctx) error {
 		return utils.ValidationErrorResponse(c, err.Error())
 	}
 
-	// Update field EMR utama
-	existingEMR.VisitType = req.VisitType
-	existingEMR.Complaint = req.Complaint
-	existingEMR.Examination = req.Examination
-	existingEMR.Diagnosis = req.Diagnosis
-	existingEMR.TreatmentPlan = req.TreatmentPlan
-	existingEMR.Notes = req.Notes
-	existingEMR.BillingStatus = req.BillingStatus
-	if req.DoctorID != 0 { // Hanya update jika ada perubahan
-		existingEMR.DoctorID = req.DoctorID
-		existingEMR.DoctorName = req.DoctorName // Asumsi nama dokter juga diupdate
-	}
-	// existingEMR.ExamDate bisa diupdate jika diizinkan
-
+	version := emrVersionAuthor(c, req.ChangeReason)
+	ledger := newStockLedger(c)
+	var existingEMR models.MedicalRecord
 	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
-		// Update EMR utama
-		if err := tx.Save(&existingEMR).Error; err != nil {
+		// Cari EMR yang ada berdasarkan ID internal atau VisitID; baris dikunci agar nomor versi tidak bentrok
+		if err := findEMRByParam(tx.Clauses(clause.Locking{Strength: "UPDATE"}), c.Params("id"), &existingEMR); err != nil {
 			return err
 		}
 
-		// Hapus Treatments, Medications, Odontogram lama (atau gunakan pendekatan update yang lebih canggih)
-		if err := tx.Where("medical_record_id = ?", existingEMR.ID).Delete(&models.MedicalRecordTreatmentItem{}).Error; err != nil {
-			return err
+		if existingEMR.FinalizedAt != nil {
+			return &emrError{fiber.StatusConflict, "EMR sudah difinalisasi dan tidak dapat diubah. Tambahkan addendum untuk koreksi."}
+		}
+		if existingEMR.Version != expectedVersion {
+			return errVersionConflict
 		}
-		if err := tx.Where("medical_record_id = ?", existingEMR.ID).Delete(&models.MedicalRecordMedicationItem{}).Error; err != nil {
+		if err := ensureEMRBaselineVersion(tx, &existingEMR); err != nil {
 			return err
 		}
 
-		// Hapus OdontogramHistory dulu sebelum OdontogramDetail
-		var oldOdontogramDetails []models.OdontogramDetail
-		if err := tx.Where("medical_record_id = ?", existingEMR.ID).Find(&oldOdontogramDetails).Error; err == nil {
-			for _, detail := range oldOdontogramDetails {
-				if err := tx.Where("odontogram_detail_id = ?", detail.ID).Delete(&models.OdontogramHistory{}).Error; err != nil {
-					return err
+		// Update field EMR utama
+		existingEMR.VisitType = req.VisitType
+		existingEMR.Complaint = req.Complaint
+		existingEMR.Examination = req.Examination
+		existingEMR.Diagnosis = req.Diagnosis
+		existingEMR.TreatmentPlan = req.TreatmentPlan
+		existingEMR.Notes = req.Notes
+		// Dokter pemeriksa menentukan siapa yang boleh memfinalisasi, jadi ID divalidasi dan nama diambil dari data pengguna
+		if req.DoctorID != 0 && req.DoctorID != existingEMR.DoctorID {
+			doctor, err := findDoctor(tx, req.DoctorID)
+			if err != nil {
+				if err == gorm.ErrRecordNotFound {
+					return &emrError{fiber.StatusBadRequest, "Dokter tidak ditemukan"}
 				}
+				return err
 			}
+			existingEMR.DoctorID = doctor.ID
+			existingEMR.DoctorName = doctor.NamaLengkap
 		}
-		if err := tx.Where("medical_record_id = ?", existingEMR.ID).Delete(&models.OdontogramDetail{}).Error; err != nil {
+		// existingEMR.ExamDate bisa diupdate jika diizinkan
+		if err := tx.Omit("Patient").Save(&existingEMR).Error; err != nil {
 			return err
 		}
 
-		// Tambahkan Treatments baru
-		for _, treatmentDTO := range req.Treatments {
-			item := models.MedicalRecordTreatmentItem{
-				MedicalRecordID: existingEMR.ID,
-				// TreatmentCatalogID: treatmentDTO.TreatmentCatalogID,
-				ToothNumber:     treatmentDTO.ToothNumber,
-				Quantity:        treatmentDTO.Quantity,
-				PriceAtTime:     treatmentDTO.PriceAtTime,
-				DiscountPercent: treatmentDTO.DiscountPercent,
-				SubTotal:        treatmentDTO.SubTotal,
-				Notes:           treatmentDTO.Notes,
-			}
-			if err := tx.Create(&item).Error; err != nil {
-				return err
-			}
+		// Item disamakan dengan request tanpa hapus-tulis ulang, agar ID item yang tidak berubah tetap sama
+		if err := reconcileTreatmentItems(tx, existingEMR.ID, req.Treatments); err != nil {
+			return err
 		}
-
-		// Tambahkan Medications baru
-		for _, medDTO := range req.Medications {
-			item := models.MedicalRecordMedicationItem{
-				MedicalRecordID: existingEMR.ID,
-				// MedicationCatalogID: medDTO.MedicationCatalogID,
-				Quantity:           medDTO.Quantity,
-				PricePerUnitAtTime: medDTO.PricePerUnitAtTime,
-				SubTotal:           medDTO.SubTotal,
-				Instruction:        medDTO.Instruction,
-			}
-			if err := tx.Create(&item).Error; err != nil {
-				return err
-			}
+		if err := reconcileMedicationItems(tx, ledger, existingEMR, req.Medications); err != nil {
+			return err
 		}
-
-		// Tambahkan Odontogram baru
-		for _, odontoDTO := range req.Odontogram {
-			detail := models.OdontogramDetail{
-				MedicalRecordID: existingEMR.ID,
-				ToothNumber:     odontoDTO.ToothNumber,
-				Condition:       odontoDTO.Condition,
-				TreatmentNote:   odontoDTO.TreatmentNote,
-			}
-			if err := tx.Create(&detail).Error; err != nil {
-				return err
-			} // Simpan detail untuk dapat ID
-
-			for _, historyDTO := range odontoDTO.History {
-				parsedDate, _ := time.Parse("2006-01-02", historyDTO.Date)
-				historyEntry := models.OdontogramHistory{
-					OdontogramDetailID: detail.ID, // Gunakan ID dari detail yang baru disimpan
-					Date:               parsedDate,
-					DoctorName:         historyDTO.DoctorName,
-					FromCondition:      historyDTO.FromCondition,
-					ToCondition:        historyDTO.ToCondition,
-					Note:               historyDTO.Note,
-				}
-				if err := tx.Create(&historyEntry).Error; err != nil {
-					return err
-				}
-			}
+		if err := reconcileOdontogram(tx, existingEMR.ID, req.Odontogram); err != nil {
+			return err
 		}
-		return nil
+		return recordEMRVersion(tx, &existingEMR, version)
 	})
 
+	if errors.Is(errTx, errVersionConflict) {
+		// Baris EMR dikunci selama pemeriksaan versi, sehingga ketidakcocokan selalu berarti If-Match sudah usang
+		currentEMR := loadEMRForResponse(existingEMR.ID)
+		return utils.VersionConflictResponse(c, fiber.StatusPreconditionFailed, "EMR sudah diubah pengguna lain, muat ulang data terbaru", currentEMR.Version, currentEMR)
+	}
 	if errTx != nil {
-		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal memperbarui EMR", errTx.Error())
+		return emrErrorResponse(c, errTx, "Gagal memperbarui EMR")
 	}
 
 	// Ambil ulang EMR yang sudah diupdate
-	var updatedEMR models.MedicalRecord
-	database.DB.Preload("Patient").
-		Preload("Treatments.TreatmentCatalog").
-		Preload("Medications.MedicationCatalog").
-		Preload("Odontogram.History").
-		First(&updatedEMR, existingEMR.ID)
-
-	return utils.SuccessResponse(c, fiber.StatusOK, "EMR berhasil diperbarui", updatedEMR)
+	updatedEMR := loadEMRForResponse(existingEMR.ID)
+	utils.SetETag(c, updatedEMR.Version)
+	return utils.SuccessResponseWithWarnings(c, fiber.StatusOK, "EMR berhasil diperbarui", updatedEMR, ledger.warnings)
 }
 
 // Anda juga perlu membuat DTO untuk CreateEMRRequest dan UpdateEMRRequest di pkg/dto/emr_dto.go
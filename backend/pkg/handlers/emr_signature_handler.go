@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/config"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/database"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/dto"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/middleware"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/models"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// emrContentHash menghitung SHA-256 dari snapshot isi EMR saat ini (format yang sama dengan riwayat versi).
+// Nama tindakan/obat tidak ikut di-hash karena diambil dari master yang namanya boleh diperbaiki kemudian.
+func emrContentHash(tx *gorm.DB, emrID uint) (string, error) {
+	snapshot, err := loadEMRSnapshot(tx, emrID)
+	if err != nil {
+		return "", err
+	}
+	for i := range snapshot.Treatments {
+		snapshot.Treatments[i].Name = ""
+	}
+	for i := range snapshot.Medications {
+		snapshot.Medications[i].Name = ""
+	}
+	raw, err := json.Marshal(snapshot)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// mapEMRSignature menyusun status finalisasi EMR; currentHash kosong jika EMR belum difinalisasi
+func mapEMRSignature(emr models.MedicalRecord, currentHash string) dto.EMRSignatureResponse {
+	return dto.EMRSignatureResponse{
+		MedicalRecordID:  emr.ID,
+		VisitID:          emr.VisitID,
+		Finalized:        emr.FinalizedAt != nil,
+		FinalizedAt:      emr.FinalizedAt,
+		FinalizedByID:    emr.FinalizedByID,
+		FinalizedByName:  emr.FinalizedByName,
+		FinalizedVersion: emr.FinalizedVersion,
+		ContentHash:      emr.ContentHash,
+		CurrentHash:      currentHash,
+		Intact:           emr.FinalizedAt != nil && currentHash == emr.ContentHash,
+	}
+}
+
+// FinalizeEMR menandatangani dan mengunci EMR. Hanya dokter pemeriksa yang dapat memfinalisasi;
+// penanda tangan, waktu dan hash isi EMR dicatat, lalu UpdateEMR menolak perubahan berikutnya.
+// Header If-Match opsional: jika dikirim, EMR hanya difinalisasi bila isinya masih versi yang dilihat dokter.
+func FinalizeEMR(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+	expectedVersion, checkVersion := utils.IfMatchVersion(c)
+	signer := emrVersionAuthor(c, "")
+
+	var emr models.MedicalRecord
+	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := findEMRByParam(tx.Clauses(clause.Locking{Strength: "UPDATE"}), c.Params("id"), &emr); err != nil {
+			return err
+		}
+		if emr.FinalizedAt != nil {
+			return &emrError{fiber.StatusConflict, "EMR sudah difinalisasi sebelumnya"}
+		}
+		if emr.DoctorID != userID {
+			return &emrError{fiber.StatusForbidden, "Hanya dokter pemeriksa yang dapat memfinalisasi EMR ini"}
+		}
+		if checkVersion && emr.Version != expectedVersion {
+			return errVersionConflict
+		}
+		if err := ensureEMRBaselineVersion(tx, &emr); err != nil {
+			return err
+		}
+
+		contentHash, err := emrContentHash(tx, emr.ID)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		emr.FinalizedAt = &now
+		emr.FinalizedByID = &userID
+		emr.FinalizedByName = signer.AuthorName
+		emr.FinalizedVersion = emr.Version
+		emr.ContentHash = contentHash
+		return tx.Model(&models.MedicalRecord{}).Where("id = ?", emr.ID).Updates(map[string]interface{}{
+			"finalized_at":      emr.FinalizedAt,
+			"finalized_by_id":   emr.FinalizedByID,
+			"finalized_by_name": emr.FinalizedByName,
+			"finalized_version": emr.FinalizedVersion,
+			"content_hash":      emr.ContentHash,
+		}).Error
+	})
+	if errors.Is(errTx, errVersionConflict) {
+		currentEMR := loadEMRForResponse(emr.ID)
+		return utils.VersionConflictResponse(c, fiber.StatusPreconditionFailed, "EMR sudah diubah sejak terakhir dibaca, periksa kembali sebelum finalisasi", currentEMR.Version, currentEMR)
+	}
+	if errTx != nil {
+		return emrErrorResponse(c, errTx, "Gagal memfinalisasi EMR")
+	}
+
+	return utils.SuccessResponse(c, fiber.StatusOK, "EMR berhasil difinalisasi", mapEMRSignature(emr, emr.ContentHash))
+}
+
+// GetEMRSignature menampilkan status finalisasi EMR dan memeriksa apakah isinya masih sama dengan yang ditandatangani
+func GetEMRSignature(c *fiber.Ctx) error {
+	emr, err := findEMRForVersions(c)
+	if emr.ID == 0 {
+		return err
+	}
+
+	currentHash := ""
+	if emr.FinalizedAt != nil {
+		if currentHash, err = emrContentHash(database.DB, emr.ID); err != nil {
+			return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal menghitung hash isi EMR", err.Error())
+		}
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Status finalisasi EMR berhasil diambil", mapEMRSignature(emr, currentHash))
+}
+
+// CreateEMRAddendum menambahkan addendum (koreksi/catatan susulan) pada EMR yang sudah difinalisasi.
+// EMR yang belum difinalisasi cukup diubah lewat UpdateEMR.
+func CreateEMRAddendum(c *fiber.Ctx) error {
+	req := new(dto.CreateEMRAddendumRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	if err := validate.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	author := emrVersionAuthor(c, "")
+	addendum := models.MedicalRecordAddendum{
+		AuthorID:   c.Locals("user_id").(uint),
+		AuthorName: author.AuthorName,
+		Reason:     req.Reason,
+		Content:    req.Content,
+	}
+	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		var emr models.MedicalRecord
+		if err := findEMRByParam(tx.Clauses(clause.Locking{Strength: "UPDATE"}), c.Params("id"), &emr); err != nil {
+			return err
+		}
+		if emr.FinalizedAt == nil {
+			return &emrError{fiber.StatusConflict, "EMR belum difinalisasi, ubah EMR secara langsung"}
+		}
+		addendum.MedicalRecordID = emr.ID
+		return tx.Create(&addendum).Error
+	})
+	if errTx != nil {
+		return emrErrorResponse(c, errTx, "Gagal menambahkan addendum EMR")
+	}
+
+	return utils.SuccessResponse(c, fiber.StatusCreated, "Addendum EMR berhasil ditambahkan", addendum)
+}
+
+// GetEMRAddenda menampilkan semua addendum sebuah EMR sesuai urutan penulisan
+func GetEMRAddenda(c *fiber.Ctx) error {
+	emr, err := findEMRForVersions(c)
+	if emr.ID == 0 {
+		return err
+	}
+
+	addenda := []models.MedicalRecordAddendum{}
+	if err := database.DB.Where("medical_record_id = ?", emr.ID).Order("id asc").Find(&addenda).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal mengambil addendum EMR", err.Error())
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Addendum EMR berhasil diambil", addenda)
+}
+
+// GetPendingSignatureEMRs menampilkan EMR yang belum difinalisasi lebih lama dari EMR_SIGNATURE_DUE_HOURS
+// sejak pemeriksaan. Filter doctorId; tanpa filter, pengguna yang boleh menandatangani EMR dan tercatat sebagai
+// dokter pemeriksa melihat EMR miliknya sendiri, sedangkan pengguna lain melihat semua dokter.
+func GetPendingSignatureEMRs(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	if limit <= 0 {
+		limit = 20
+	}
+	if page <= 0 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+
+	now := time.Now()
+	query := database.DB.Model(&models.MedicalRecord{}).
+		Where("finalized_at IS NULL AND exam_date <= ?", now.Add(-config.AppConfig.EMRSignatureDueAfter))
+	if doctorID := c.Query("doctorId"); doctorID != "" {
+		query = query.Where("doctor_id = ?", doctorID)
+	} else if userID, ok := c.Locals("user_id").(uint); ok && middleware.HasPermission(c, doctorPermission) {
+		var examined int64
+		if err := database.DB.Model(&models.MedicalRecord{}).Where("doctor_id = ?", userID).Count(&examined).Error; err != nil {
+			return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
+		}
+		if examined > 0 {
+			query = query.Where("doctor_id = ?", userID)
+		}
+	}
+	query = query.Session(&gorm.Session{})
+
+	var totalRecords int64
+	if err := query.Count(&totalRecords).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal menghitung EMR yang belum difinalisasi", err.Error())
+	}
+
+	var emrs []models.MedicalRecord
+	if err := query.Preload("Patient").Order("doctor_id asc, exam_date asc").Offset(offset).Limit(limit).Find(&emrs).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal mengambil EMR yang belum difinalisasi", err.Error())
+	}
+
+	response := make([]dto.PendingSignatureResponse, 0, len(emrs))
+	for _, emr := range emrs {
+		response = append(response, dto.PendingSignatureResponse{
+			ID:           emr.ID,
+			VisitID:      emr.VisitID,
+			PatientID:    emr.PatientID,
+			PatientName:  emr.Patient.NamaLengkap,
+			NoRM:         emr.Patient.NoRM,
+			DoctorID:     emr.DoctorID,
+			DoctorName:   emr.DoctorName,
+			ExamDate:     emr.ExamDate,
+			HoursPending: int(now.Sub(emr.ExamDate).Hours()),
+		})
+	}
+
+	paginationData := fiber.Map{
+		"currentPage":  page,
+		"totalPages":   int(math.Ceil(float64(totalRecords) / float64(limit))),
+		"totalRecords": totalRecords,
+		"pageSize":     limit,
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success":    true,
+		"data":       response,
+		"pagination": paginationData,
+		"message":    "Daftar EMR menunggu tanda tangan berhasil diambil",
+	})
+}
@@ -0,0 +1,673 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/database"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/dto"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/models"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// emrError adalah error perubahan EMR yang dipetakan langsung ke status HTTP,
+// mis. item dengan ID milik EMR lain atau EMR yang sudah difinalisasi
+type emrError struct {
+	Status  int
+	Message string
+}
+
+func (e *emrError) Error() string { return e.Message }
+
+// emrErrorResponse memetakan error dari transaksi perubahan EMR ke respons HTTP
+func emrErrorResponse(c *fiber.Ctx, err error, fallbackMessage string) error {
+	var eErr *emrError
+	var unknownErr *unknownCatalogCodesError
+	var shortage *insufficientStockError
+	switch {
+	case errors.As(err, &eErr):
+		return utils.ErrorResponse(c, eErr.Status, eErr.Message)
+	case errors.As(err, &shortage):
+		return utils.ErrorResponse(c, fiber.StatusConflict, shortage.Error())
+	case errors.As(err, &unknownErr):
+		return catalogErrorResponse(c, err)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return utils.ErrorResponse(c, fiber.StatusNotFound, "EMR tidak ditemukan")
+	}
+	return utils.ErrorResponse(c, fiber.StatusInternalServerError, fallbackMessage, err.Error())
+}
+
+// findEMRByParam mencari EMR dari parameter rute berisi ID internal atau VisitID
+func findEMRByParam(db *gorm.DB, idParam string, emr *models.MedicalRecord) error {
+	if emrID, err := strconv.ParseUint(idParam, 10, 32); err == nil {
+		return db.Where("id = ? OR visit_id = ? OR legacy_visit_id = ?", uint(emrID), idParam, idParam).First(emr).Error
+	}
+	return db.Where("visit_id = ? OR legacy_visit_id = ?", idParam, idParam).First(emr).Error
+}
+
+// emrVersionAuthor menyiapkan versi EMR baru atas nama pengguna yang sedang login
+func emrVersionAuthor(c *fiber.Ctx, reason string) models.MedicalRecordVersion {
+	version := models.MedicalRecordVersion{ChangeReason: reason}
+	version.AuthorID, version.AuthorName = actingUser(c)
+	return version
+}
+
+// loadEMRSnapshot membaca isi EMR saat ini menjadi snapshot versi. Master yang sudah dihapus tetap dibaca.
+func loadEMRSnapshot(tx *gorm.DB, emrID uint) (models.MedicalRecordSnapshot, error) {
+	unscoped := func(db *gorm.DB) *gorm.DB { return db.Unscoped() }
+	var emr models.MedicalRecord
+	err := tx.
+		Preload("Treatments", func(db *gorm.DB) *gorm.DB { return db.Order("id asc") }).
+		Preload("Treatments.TreatmentCatalog", unscoped).
+		Preload("Medications", func(db *gorm.DB) *gorm.DB { return db.Order("id asc") }).
+		Preload("Medications.MedicationCatalog", unscoped).
+		Preload("Odontogram", func(db *gorm.DB) *gorm.DB { return db.Order("tooth_number asc, id asc") }).
+		Preload("Odontogram.History", func(db *gorm.DB) *gorm.DB { return db.Order("date asc, id asc") }).
+		First(&emr, emrID).Error
+	if err != nil {
+		return models.MedicalRecordSnapshot{}, err
+	}
+
+	snapshot := models.MedicalRecordSnapshot{
+		VisitID:       emr.VisitID,
+		PatientID:     emr.PatientID,
+		DoctorID:      emr.DoctorID,
+		DoctorName:    emr.DoctorName,
+		ExamDate:      emr.ExamDate,
+		VisitType:     emr.VisitType,
+		Complaint:     emr.Complaint,
+		Examination:   emr.Examination,
+		Diagnosis:     emr.Diagnosis,
+		TreatmentPlan: emr.TreatmentPlan,
+		Notes:         emr.Notes,
+		Treatments:    []models.TreatmentItemSnapshot{},
+		Medications:   []models.MedicationItemSnapshot{},
+		Odontogram:    []models.OdontogramSnapshot{},
+	}
+	for _, t := range emr.Treatments {
+		snapshot.Treatments = append(snapshot.Treatments, models.TreatmentItemSnapshot{
+			ID:              t.ID,
+			Code:            t.TreatmentCatalog.Kode,
+			Name:            t.TreatmentCatalog.Nama,
+			ToothNumber:     t.ToothNumber,
+			Quantity:        t.Quantity,
+			PriceAtTime:     t.PriceAtTime,
+			DiscountPercent: t.DiscountPercent,
+			SubTotal:        t.SubTotal,
+			Notes:           t.Notes,
+		})
+	}
+	for _, m := range emr.Medications {
+		snapshot.Medications = append(snapshot.Medications, models.MedicationItemSnapshot{
+			ID:                 m.ID,
+			Code:               m.MedicationCatalog.Kode,
+			Name:               m.MedicationCatalog.Nama,
+			Quantity:           m.Quantity,
+			PricePerUnitAtTime: m.PricePerUnitAtTime,
+			SubTotal:           m.SubTotal,
+			Instruction:        m.Instruction,
+		})
+	}
+	for _, detail := range emr.Odontogram {
+		tooth := models.OdontogramSnapshot{
+			ID:            detail.ID,
+			ToothNumber:   detail.ToothNumber,
+			Condition:     detail.Condition,
+			TreatmentNote: detail.TreatmentNote,
+			History:       []models.OdontogramHistorySnapshot{},
+		}
+		for _, h := range detail.History {
+			tooth.History = append(tooth.History, models.OdontogramHistorySnapshot{
+				ID:            h.ID,
+				Date:          h.Date.Format("2006-01-02"),
+				DoctorName:    h.DoctorName,
+				FromCondition: h.FromCondition,
+				ToCondition:   h.ToCondition,
+				Note:          h.Note,
+			})
+		}
+		snapshot.Odontogram = append(snapshot.Odontogram, tooth)
+	}
+	return snapshot, nil
+}
+
+// recordEMRVersion menyimpan isi EMR saat ini sebagai versi berikutnya dan menaikkan nomor versi EMR.
+// Dipanggil di dalam transaksi yang sama dengan perubahan EMR; untuk update, baris EMR harus sudah dikunci.
+func recordEMRVersion(tx *gorm.DB, emr *models.MedicalRecord, version models.MedicalRecordVersion) error {
+	snapshot, err := loadEMRSnapshot(tx, emr.ID)
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	version.MedicalRecordID = emr.ID
+	version.VersionNumber = emr.Version + 1
+	version.Snapshot = raw
+	if err := tx.Create(&version).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.MedicalRecord{}).Where("id = ?", emr.ID).UpdateColumn("version", version.VersionNumber).Error; err != nil {
+		return err
+	}
+	emr.Version = version.VersionNumber
+	return nil
+}
+
+// RecordEMRSystemVersion mencatat perubahan EMR yang dilakukan di luar API (mis. cmd/renumber) sebagai versi baru,
+// sehingga ETag/If-Match klien ikut berubah dan riwayat versi tetap lengkap. apply dijalankan setelah versi awal
+// EMR lama disimpan. EMR yang sudah dihapus hanya diubah tanpa versi karena tidak lagi dapat dibuka lewat API.
+func RecordEMRSystemVersion(tx *gorm.DB, emrID uint, authorName, reason string, apply func(tx *gorm.DB) error) error {
+	var emr models.MedicalRecord
+	if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).First(&emr, emrID).Error; err != nil {
+		return err
+	}
+	if emr.DeletedAt.Valid {
+		return apply(tx)
+	}
+	if err := ensureEMRBaselineVersion(tx, &emr); err != nil {
+		return err
+	}
+	if err := apply(tx); err != nil {
+		return err
+	}
+	return recordEMRVersion(tx, &emr, models.MedicalRecordVersion{AuthorName: authorName, ChangeReason: reason})
+}
+
+// ensureEMRBaselineVersion menyimpan isi EMR lama yang dibuat sebelum ada riwayat versi sebagai versi pertama,
+// sebelum EMR tersebut diubah atau difinalisasi
+func ensureEMRBaselineVersion(tx *gorm.DB, emr *models.MedicalRecord) error {
+	if emr.Version > 0 {
+		return nil
+	}
+	return recordEMRVersion(tx, emr, models.MedicalRecordVersion{
+		CreatedAt:    emr.UpdatedAt,
+		ChangeReason: "Versi awal sebelum riwayat versi EMR diaktifkan",
+	})
+}
+
+// matchEMRItems memasangkan item request dengan item lama EMR. Item yang membawa ID dipasangkan langsung
+// (ID harus milik EMR ini), item tanpa ID dipasangkan dengan item lama yang belum terpakai dan dianggap sama.
+// Hasilnya indeks item lama untuk setiap item request (-1 untuk item baru) dan penanda item lama yang terpakai.
+func matchEMRItems(label string, existingIDs, requestedIDs []uint, same func(existingIdx, requestedIdx int) bool) ([]int, []bool, error) {
+	indexByID := make(map[uint]int, len(existingIDs))
+	for i, id := range existingIDs {
+		indexByID[id] = i
+	}
+	used := make([]bool, len(existingIDs))
+	matches := make([]int, len(requestedIDs))
+	for j, id := range requestedIDs {
+		matches[j] = -1
+		if id == 0 {
+			continue
+		}
+		i, ok := indexByID[id]
+		if !ok {
+			return nil, nil, &emrError{fiber.StatusBadRequest, fmt.Sprintf("Item %s dengan ID %d tidak ditemukan pada EMR ini", label, id)}
+		}
+		if used[i] {
+			return nil, nil, &emrError{fiber.StatusBadRequest, fmt.Sprintf("Item %s dengan ID %d dikirim lebih dari sekali", label, id)}
+		}
+		used[i] = true
+		matches[j] = i
+	}
+	for j, id := range requestedIDs {
+		if id != 0 {
+			continue
+		}
+		for i := range existingIDs {
+			if !used[i] && same(i, j) {
+				used[i] = true
+				matches[j] = i
+				break
+			}
+		}
+	}
+	return matches, used, nil
+}
+
+// treatmentSubTotal menghitung SubTotal item tindakan
+func treatmentSubTotal(price float64, quantity int, discountPercent float64) float64 {
+	return roundCurrency(price * float64(quantity) * (1 - discountPercent/100))
+}
+
+// sameTreatmentItem membandingkan isi item tindakan yang dikirim client (harga tidak ikut dibandingkan)
+func sameTreatmentItem(a, b models.MedicalRecordTreatmentItem) bool {
+	return a.TreatmentCatalogID == b.TreatmentCatalogID && a.ToothNumber == b.ToothNumber && a.Quantity == b.Quantity &&
+		a.DiscountPercent == b.DiscountPercent && a.Notes == b.Notes
+}
+
+// sameMedicationItem membandingkan isi item obat yang dikirim client (harga tidak ikut dibandingkan)
+func sameMedicationItem(a, b models.MedicalRecordMedicationItem) bool {
+	return a.MedicationCatalogID == b.MedicationCatalogID && a.Quantity == b.Quantity && a.Instruction == b.Instruction
+}
+
+// reconcileTreatmentItems menyamakan item tindakan EMR dengan request tanpa menghapus lalu membuat ulang semua baris.
+// Item yang tidak berubah tetap dengan ID yang sama (baris tagihan merujuk ID ini), item yang berubah diperbarui,
+// item baru ditambahkan dan item yang tidak ada lagi di request dihapus. Harga item lama tidak mengikuti
+// perubahan harga master selama tindakannya sama.
+func reconcileTreatmentItems(tx *gorm.DB, emrID uint, requested []dto.MedicalRecordTreatmentItemDTO) error {
+	desired, err := buildTreatmentItems(tx, requested)
+	if err != nil {
+		return err
+	}
+	var existing []models.MedicalRecordTreatmentItem
+	if err := tx.Where("medical_record_id = ?", emrID).Order("id asc").Find(&existing).Error; err != nil {
+		return err
+	}
+
+	existingIDs := make([]uint, len(existing))
+	for i, item := range existing {
+		existingIDs[i] = item.ID
+	}
+	requestedIDs := make([]uint, len(requested))
+	for j, item := range requested {
+		requestedIDs[j] = item.ID
+	}
+	matches, used, err := matchEMRItems("tindakan", existingIDs, requestedIDs, func(i, j int) bool {
+		return sameTreatmentItem(existing[i], desired[j])
+	})
+	if err != nil {
+		return err
+	}
+
+	for j, item := range desired {
+		if i := matches[j]; i >= 0 {
+			old := existing[i]
+			if sameTreatmentItem(old, item) {
+				continue
+			}
+			if old.TreatmentCatalogID == item.TreatmentCatalogID {
+				item.PriceAtTime = old.PriceAtTime
+				item.SubTotal = treatmentSubTotal(item.PriceAtTime, item.Quantity, item.DiscountPercent)
+			}
+			item.BaseModel = old.BaseModel
+		}
+		item.MedicalRecordID = emrID
+		if err := tx.Save(&item).Error; err != nil {
+			return err
+		}
+	}
+	for i := range existing {
+		if !used[i] {
+			if err := tx.Delete(&existing[i]).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// reconcileMedicationItems sama seperti reconcileTreatmentItems untuk item obat, sekaligus mencatat mutasi stok
+// sebesar selisih jumlah obat sehingga stok selalu sama dengan obat yang tercatat di EMR.
+func reconcileMedicationItems(tx *gorm.DB, ledger *stockLedger, emr models.MedicalRecord, requested []dto.MedicalRecordMedicationItemDTO) error {
+	desired, err := buildMedicationItems(tx, requested)
+	if err != nil {
+		return err
+	}
+	var existing []models.MedicalRecordMedicationItem
+	if err := tx.Where("medical_record_id = ?", emr.ID).Order("id asc").Find(&existing).Error; err != nil {
+		return err
+	}
+
+	existingIDs := make([]uint, len(existing))
+	for i, item := range existing {
+		existingIDs[i] = item.ID
+	}
+	requestedIDs := make([]uint, len(requested))
+	for j, item := range requested {
+		requestedIDs[j] = item.ID
+	}
+	matches, used, err := matchEMRItems("obat", existingIDs, requestedIDs, func(i, j int) bool {
+		return sameMedicationItem(existing[i], desired[j])
+	})
+	if err != nil {
+		return err
+	}
+
+	// Item yang dihapus diproses lebih dulu agar stoknya kembali sebelum obat baru dikurangkan
+	for i := range existing {
+		if !used[i] {
+			if err := tx.Delete(&existing[i]).Error; err != nil {
+				return err
+			}
+			if err := ledger.postEMRItem(tx, emr.VisitID, existing[i], -existing[i].Quantity, "Obat dihapus dari EMR"); err != nil {
+				return err
+			}
+		}
+	}
+	for j, item := range desired {
+		i := matches[j]
+		if i >= 0 {
+			old := existing[i]
+			if sameMedicationItem(old, item) {
+				continue
+			}
+			if old.MedicationCatalogID == item.MedicationCatalogID {
+				item.PricePerUnitAtTime = old.PricePerUnitAtTime
+				item.SubTotal = roundCurrency(item.PricePerUnitAtTime * float64(item.Quantity))
+			}
+			item.BaseModel = old.BaseModel
+		}
+		item.MedicalRecordID = emr.ID
+		if err := tx.Save(&item).Error; err != nil {
+			return err
+		}
+
+		// Stok mengikuti selisih jumlah; jika obatnya diganti, obat lama dikembalikan dan obat baru dikurangkan
+		switch {
+		case i >= 0 && existing[i].MedicationCatalogID == item.MedicationCatalogID:
+			err = ledger.postEMRItem(tx, emr.VisitID, item, item.Quantity-existing[i].Quantity, "Jumlah obat di EMR diubah")
+		case i >= 0:
+			if err = ledger.postEMRItem(tx, emr.VisitID, existing[i], -existing[i].Quantity, "Obat di EMR diganti"); err == nil {
+				err = ledger.postEMRItem(tx, emr.VisitID, item, item.Quantity, "Obat diberikan lewat EMR")
+			}
+		default:
+			err = ledger.postEMRItem(tx, emr.VisitID, item, item.Quantity, "Obat diberikan lewat EMR")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reconcileOdontogram menyamakan odontogram EMR dengan request. Gigi dicocokkan berdasarkan ID atau nomor gigi,
+// entri riwayat berdasarkan ID atau isinya, sehingga data yang tidak berubah tetap dengan ID yang sama.
+func reconcileOdontogram(tx *gorm.DB, emrID uint, requested []dto.OdontogramDetailDTO) error {
+	var existing []models.OdontogramDetail
+	if err := tx.Preload("History", func(db *gorm.DB) *gorm.DB { return db.Order("id asc") }).
+		Where("medical_record_id = ?", emrID).Order("id asc").Find(&existing).Error; err != nil {
+		return err
+	}
+
+	existingIDs := make([]uint, len(existing))
+	for i, detail := range existing {
+		existingIDs[i] = detail.ID
+	}
+	requestedIDs := make([]uint, len(requested))
+	for j, detail := range requested {
+		requestedIDs[j] = detail.ID
+	}
+	matches, used, err := matchEMRItems("odontogram", existingIDs, requestedIDs, func(i, j int) bool {
+		return existing[i].ToothNumber == requested[j].ToothNumber
+	})
+	if err != nil {
+		return err
+	}
+
+	for j, odontoDTO := range requested {
+		detail := models.OdontogramDetail{MedicalRecordID: emrID}
+		if i := matches[j]; i >= 0 {
+			detail = existing[i]
+		}
+		if detail.ID == 0 || detail.ToothNumber != odontoDTO.ToothNumber || detail.Condition != odontoDTO.Condition || detail.TreatmentNote != odontoDTO.TreatmentNote {
+			detail.ToothNumber = odontoDTO.ToothNumber
+			detail.Condition = odontoDTO.Condition
+			detail.TreatmentNote = odontoDTO.TreatmentNote
+			if err := tx.Omit("History").Save(&detail).Error; err != nil {
+				return err
+			}
+		}
+		if err := reconcileOdontogramHistory(tx, detail, odontoDTO.History); err != nil {
+			return err
+		}
+	}
+	for i := range existing {
+		if used[i] {
+			continue
+		}
+		if err := tx.Where("odontogram_detail_id = ?", existing[i].ID).Delete(&models.OdontogramHistory{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&existing[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reconcileOdontogramHistory menyamakan riwayat satu gigi; detail.History berisi riwayat lama (kosong untuk gigi baru)
+func reconcileOdontogramHistory(tx *gorm.DB, detail models.OdontogramDetail, requested []dto.OdontogramHistoryDTO) error {
+	existingIDs := make([]uint, len(detail.History))
+	for i, h := range detail.History {
+		existingIDs[i] = h.ID
+	}
+	requestedIDs := make([]uint, len(requested))
+	for j, h := range requested {
+		requestedIDs[j] = h.ID
+	}
+	sameHistory := func(old models.OdontogramHistory, h dto.OdontogramHistoryDTO) bool {
+		return old.Date.Format("2006-01-02") == h.Date && old.DoctorName == h.DoctorName &&
+			old.FromCondition == h.FromCondition && old.ToCondition == h.ToCondition && old.Note == h.Note
+	}
+	matches, used, err := matchEMRItems("riwayat odontogram", existingIDs, requestedIDs, func(i, j int) bool {
+		return sameHistory(detail.History[i], requested[j])
+	})
+	if err != nil {
+		return err
+	}
+
+	for j, historyDTO := range requested {
+		entry := models.OdontogramHistory{}
+		if i := matches[j]; i >= 0 {
+			entry = detail.History[i]
+			if sameHistory(entry, historyDTO) {
+				continue
+			}
+		}
+		parsedDate, _ := time.Parse("2006-01-02", historyDTO.Date)
+		entry.OdontogramDetailID = detail.ID
+		entry.Date = parsedDate
+		entry.DoctorName = historyDTO.DoctorName
+		entry.FromCondition = historyDTO.FromCondition
+		entry.ToCondition = historyDTO.ToCondition
+		entry.Note = historyDTO.Note
+		if err := tx.Save(&entry).Error; err != nil {
+			return err
+		}
+	}
+	for i := range detail.History {
+		if !used[i] {
+			if err := tx.Delete(&detail.History[i]).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// mapEMRVersionSummary memetakan versi EMR ke baris daftar versi
+func mapEMRVersionSummary(version models.MedicalRecordVersion) dto.MedicalRecordVersionSummary {
+	return dto.MedicalRecordVersionSummary{
+		VersionNumber: version.VersionNumber,
+		AuthorID:      version.AuthorID,
+		AuthorName:    version.AuthorName,
+		ChangeReason:  version.ChangeReason,
+		CreatedAt:     version.CreatedAt,
+	}
+}
+
+// findEMRForVersions mencari EMR dari parameter :id untuk rute riwayat versi
+func findEMRForVersions(c *fiber.Ctx) (models.MedicalRecord, error) {
+	var emr models.MedicalRecord
+	if err := findEMRByParam(database.DB, c.Params("id"), &emr); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return emr, utils.ErrorResponse(c, fiber.StatusNotFound, "EMR tidak ditemukan")
+		}
+		return emr, utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan database", err.Error())
+	}
+	return emr, nil
+}
+
+// GetEMRVersions menampilkan daftar versi sebuah EMR, terbaru lebih dulu
+func GetEMRVersions(c *fiber.Ctx) error {
+	emr, err := findEMRForVersions(c)
+	if emr.ID == 0 {
+		return err
+	}
+
+	var versions []models.MedicalRecordVersion
+	if err := database.DB.Omit("snapshot").Where("medical_record_id = ?", emr.ID).Order("version_number desc").Find(&versions).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal mengambil riwayat versi EMR", err.Error())
+	}
+	response := make([]dto.MedicalRecordVersionSummary, 0, len(versions))
+	for _, version := range versions {
+		response = append(response, mapEMRVersionSummary(version))
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Riwayat versi EMR berhasil diambil", response)
+}
+
+// findEMRVersion mengambil satu versi EMR berdasarkan nomor versinya
+func findEMRVersion(emrID uint, versionNumber int) (models.MedicalRecordVersion, error) {
+	var version models.MedicalRecordVersion
+	err := database.DB.Where("medical_record_id = ? AND version_number = ?", emrID, versionNumber).First(&version).Error
+	return version, err
+}
+
+// GetEMRVersion menampilkan isi lengkap EMR pada satu versi
+func GetEMRVersion(c *fiber.Ctx) error {
+	emr, err := findEMRForVersions(c)
+	if emr.ID == 0 {
+		return err
+	}
+	versionNumber, err := strconv.Atoi(c.Params("version"))
+	if err != nil || versionNumber <= 0 {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Nomor versi tidak valid")
+	}
+
+	version, err := findEMRVersion(emr.ID, versionNumber)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "Versi EMR tidak ditemukan")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal mengambil versi EMR", err.Error())
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Versi EMR berhasil diambil", version)
+}
+
+// snapshotFields mengubah nilai apa pun menjadi map/list JSON generik agar bisa dibandingkan per field
+func snapshotFields(value interface{}) interface{} {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	var fields interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil
+	}
+	return fields
+}
+
+// diffFields membandingkan dua map field dan mengembalikan field yang berbeda
+func diffFields(before, after map[string]interface{}) map[string]dto.FieldChange {
+	changes := map[string]dto.FieldChange{}
+	for k, v := range before {
+		if !reflect.DeepEqual(v, after[k]) {
+			changes[k] = dto.FieldChange{Before: v, After: after[k]}
+		}
+	}
+	for k, v := range after {
+		if _, seen := before[k]; !seen {
+			changes[k] = dto.FieldChange{After: v}
+		}
+	}
+	return changes
+}
+
+// diffSnapshotItems membandingkan dua daftar item snapshot berdasarkan ID item
+func diffSnapshotItems(before, after interface{}) dto.ItemListDiff {
+	result := dto.ItemListDiff{Added: []interface{}{}, Removed: []interface{}{}, Changed: []dto.ItemChange{}}
+	toMaps := func(items interface{}) ([]map[string]interface{}, map[float64]map[string]interface{}) {
+		list, _ := snapshotFields(items).([]interface{})
+		ordered := make([]map[string]interface{}, 0, len(list))
+		byID := make(map[float64]map[string]interface{}, len(list))
+		for _, entry := range list {
+			if item, ok := entry.(map[string]interface{}); ok {
+				id, _ := item["id"].(float64)
+				ordered = append(ordered, item)
+				byID[id] = item
+			}
+		}
+		return ordered, byID
+	}
+	beforeItems, beforeByID := toMaps(before)
+	afterItems, afterByID := toMaps(after)
+
+	for _, item := range beforeItems {
+		id, _ := item["id"].(float64)
+		newItem, ok := afterByID[id]
+		if !ok {
+			result.Removed = append(result.Removed, item)
+			continue
+		}
+		if changes := diffFields(item, newItem); len(changes) > 0 {
+			result.Changed = append(result.Changed, dto.ItemChange{ID: uint(id), Changes: changes})
+		}
+	}
+	for _, item := range afterItems {
+		id, _ := item["id"].(float64)
+		if _, ok := beforeByID[id]; !ok {
+			result.Added = append(result.Added, item)
+		}
+	}
+	return result
+}
+
+// DiffEMRVersions membandingkan dua versi EMR (?from=&to=). Tanpa parameter, versi terakhir dibandingkan
+// dengan versi sebelumnya. Item dibandingkan berdasarkan ID sehingga perubahan pada item yang sama terlihat.
+func DiffEMRVersions(c *fiber.Ctx) error {
+	emr, err := findEMRForVersions(c)
+	if emr.ID == 0 {
+		return err
+	}
+
+	to := c.QueryInt("to", emr.Version)
+	from := c.QueryInt("from", to-1)
+	if from <= 0 || to <= 0 {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Nomor versi tidak valid", "EMR ini belum memiliki dua versi untuk dibandingkan")
+	}
+
+	versions := make([]models.MedicalRecordVersion, 2)
+	snapshots := make([]models.MedicalRecordSnapshot, 2)
+	for i, number := range []int{from, to} {
+		version, err := findEMRVersion(emr.ID, number)
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return utils.ErrorResponse(c, fiber.StatusNotFound, fmt.Sprintf("Versi EMR %d tidak ditemukan", number))
+			}
+			return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal mengambil versi EMR", err.Error())
+		}
+		if err := json.Unmarshal(version.Snapshot, &snapshots[i]); err != nil {
+			return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Snapshot versi EMR tidak dapat dibaca", err.Error())
+		}
+		versions[i] = version
+	}
+
+	before, _ := snapshotFields(snapshots[0]).(map[string]interface{})
+	after, _ := snapshotFields(snapshots[1]).(map[string]interface{})
+	for _, key := range []string{"treatments", "medications", "odontogram"} {
+		delete(before, key)
+		delete(after, key)
+	}
+
+	return utils.SuccessResponse(c, fiber.StatusOK, "Perbandingan versi EMR berhasil dibuat", dto.MedicalRecordVersionDiffResponse{
+		MedicalRecordID: emr.ID,
+		From:            mapEMRVersionSummary(versions[0]),
+		To:              mapEMRVersionSummary(versions[1]),
+		Fields:          diffFields(before, after),
+		Treatments:      diffSnapshotItems(snapshots[0].Treatments, snapshots[1].Treatments),
+		Medications:     diffSnapshotItems(snapshots[0].Medications, snapshots[1].Medications),
+		Odontogram:      diffSnapshotItems(snapshots[0].Odontogram, snapshots[1].Odontogram),
+	})
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/events"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/middleware"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// streamHeartbeatInterval menjaga koneksi tetap hidup melewati proxy yang memutus koneksi idle
+const streamHeartbeatInterval = 15 * time.Second
+
+// StreamEvents membuka Server-Sent Events untuk kejadian antrian, reservasi, EMR dan stok menipis.
+// Query opsional: doctorId (bisa dipisah koma) dan types (mis. "queue.called,queue.checked_in").
+// Pengguna tanpa akses lihat-semua (mis. dokter) hanya menerima event untuk dirinya sendiri;
+// event stok hanya dikirim ke pengguna dengan inventory:view_stock.
+// Setiap tab browser adalah langganan terpisah sehingga banyak tab dapat terbuka bersamaan.
+// Saat tersambung kembali, event sesudah header Last-Event-ID dikirim ulang dari buffer hub; jika
+// sebagian sudah tidak tersimpan, event stream.reset dikirim agar klien memuat ulang datanya.
+func StreamEvents(c *fiber.Ctx) error {
+	filter := events.Filter{DoctorIDs: map[uint]bool{}, Types: map[string]bool{}, HiddenTypes: map[string]bool{}}
+	if !middleware.HasPermission(c, "inventory:view_stock") {
+		filter.HiddenTypes[events.TypeStockLow] = true
+	}
+
+	if middleware.HasPermission(c, "patient:register_visit") || middleware.HasPermission(c, "reservation:view_all") {
+		if doctorIDParam := c.Query("doctorId"); doctorIDParam != "" {
+			for _, part := range strings.Split(doctorIDParam, ",") {
+				doctorID, err := strconv.ParseUint(strings.TrimSpace(part), 10, 32)
+				if err != nil {
+					return utils.ErrorResponse(c, fiber.StatusBadRequest, "Doctor ID tidak valid")
+				}
+				filter.DoctorIDs[uint(doctorID)] = true
+			}
+		}
+	} else {
+		filter.DoctorIDs[c.Locals("user_id").(uint)] = true
+	}
+	if typesParam := c.Query("types"); typesParam != "" {
+		for _, t := range strings.Split(typesParam, ",") {
+			filter.Types[strings.TrimSpace(t)] = true
+		}
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no") // Nonaktifkan buffering di reverse proxy nginx
+
+	userID, _ := c.Locals("user_id").(uint)
+	sessionID, _ := c.Locals("session_id").(uint)
+
+	var sub *events.Subscription
+	var replay []events.Event
+	complete := true
+	if lastEventID, err := strconv.ParseUint(c.Get("Last-Event-ID"), 10, 64); err == nil {
+		sub, replay, complete = events.Bus.SubscribeSince(filter, lastEventID)
+	} else {
+		sub = events.Bus.Subscribe(filter)
+	}
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer events.Bus.Unsubscribe(sub)
+
+		heartbeat := time.NewTicker(streamHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		fmt.Fprint(w, "retry: 3000\n: terhubung\n\n")
+		if !complete {
+			fmt.Fprint(w, "event: stream.reset\ndata: {}\n\n")
+		}
+		for _, e := range replay {
+			writeStreamEvent(w, e)
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		for {
+			select {
+			case e, ok := <-sub.C:
+				if !ok {
+					return
+				}
+				writeStreamEvent(w, e)
+			case <-heartbeat.C:
+				// Koneksi stream berumur panjang: putuskan jika sesi sudah dicabut (logout, user dinonaktifkan)
+				if active, err := middleware.SessionIsActive(sessionID, userID); err == nil && !active {
+					fmt.Fprint(w, "event: session.revoked\ndata: {}\n\n")
+					w.Flush()
+					return
+				}
+				fmt.Fprint(w, ": ping\n\n")
+			}
+			// Flush gagal berarti klien sudah menutup koneksi
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	})
+	return nil
+}
+
+// writeStreamEvent menulis satu event dalam format SSE; ID dipakai browser sebagai Last-Event-ID
+func writeStreamEvent(w *bufio.Writer, e events.Event) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, payload)
+}
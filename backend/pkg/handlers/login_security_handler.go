@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/config"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/database"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/models"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// loginThrottleKey adalah satu penghitung kegagalan login (per username atau per IP)
+type loginThrottleKey struct {
+	Scope string
+	Key   string
+}
+
+// loginThrottleKeys membuat penghitung yang berlaku untuk satu percobaan login
+func loginThrottleKeys(username, ip string) []loginThrottleKey {
+	return []loginThrottleKey{
+		{Scope: models.LoginThrottleUsername, Key: strings.ToLower(strings.TrimSpace(username))},
+		{Scope: models.LoginThrottleIP, Key: ip},
+	}
+}
+
+// checkLoginThrottle mengembalikan sisa waktu tunggu terlama dari penghitung yang masih terkunci.
+// lockedOut bernilai true jika penguncian disebabkan batas kegagalan, bukan sekadar jeda backoff.
+func checkLoginThrottle(keys []loginThrottleKey) (retryAfter time.Duration, lockedOut bool, err error) {
+	now := time.Now()
+	for _, k := range keys {
+		var throttles []models.LoginThrottle
+		err = database.DB.Where("scope = ? AND key = ? AND locked_until > ?", k.Scope, k.Key, now).Limit(1).Find(&throttles).Error
+		if err != nil {
+			return 0, false, err
+		}
+		if len(throttles) == 0 {
+			continue
+		}
+		if wait := throttles[0].LockedUntil.Sub(now); wait > retryAfter {
+			retryAfter = wait
+		}
+		if throttles[0].FailedCount >= loginMaxAttemptsFor(k.Scope) {
+			lockedOut = true
+		}
+	}
+	return retryAfter, lockedOut, nil
+}
+
+// loginMaxAttemptsFor mengembalikan batas kegagalan untuk cakupan penghitung
+func loginMaxAttemptsFor(scope string) int {
+	if scope == models.LoginThrottleIP {
+		return config.AppConfig.LoginMaxAttemptsPerIP
+	}
+	return config.AppConfig.LoginMaxAttempts
+}
+
+// registerLoginFailure menambah penghitung kegagalan dan menghitung jeda berikutnya.
+// Jeda backoff eksponensial hanya untuk username; IP hanya dikunci setelah batasnya tercapai
+// agar satu staf yang salah ketik tidak menghambat staf lain di jaringan klinik yang sama.
+// Mengembalikan true jika kegagalan ini memicu penguncian.
+func registerLoginFailure(keys []loginThrottleKey) (bool, error) {
+	cfg := config.AppConfig
+	lockedOut := false
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		for _, k := range keys {
+			if k.Key == "" {
+				continue
+			}
+			throttle := models.LoginThrottle{Scope: k.Scope, Key: k.Key}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&throttle).Error; err != nil {
+				return err
+			}
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("scope = ? AND key = ?", k.Scope, k.Key).First(&throttle).Error; err != nil {
+				return err
+			}
+
+			// Kegagalan lama di luar jendela waktu tidak dihitung lagi
+			if throttle.LastFailedAt != nil && now.Sub(*throttle.LastFailedAt) >= cfg.LoginLockoutDuration {
+				throttle.FailedCount = 0
+			}
+			throttle.FailedCount++
+			throttle.LastFailedAt = &now
+			throttle.LockedUntil = nil
+
+			if throttle.FailedCount >= loginMaxAttemptsFor(k.Scope) {
+				lockedUntil := now.Add(cfg.LoginLockoutDuration)
+				throttle.LockedUntil = &lockedUntil
+				lockedOut = true
+			} else if k.Scope == models.LoginThrottleUsername && cfg.LoginBackoffBase > 0 {
+				delay := cfg.LoginBackoffBase << (throttle.FailedCount - 1)
+				if delay <= 0 || delay > cfg.LoginLockoutDuration {
+					delay = cfg.LoginLockoutDuration
+				}
+				lockedUntil := now.Add(delay)
+				throttle.LockedUntil = &lockedUntil
+			}
+
+			if err := tx.Save(&throttle).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return lockedOut, err
+}
+
+// clearLoginThrottle menghapus penghitung kegagalan username setelah login berhasil.
+// Penghitung IP sengaja tidak dihapus agar satu akun valid tidak bisa dipakai untuk mereset IP penyerang.
+func clearLoginThrottle(username string) {
+	err := database.DB.Where("scope = ? AND key = ?", models.LoginThrottleUsername, strings.ToLower(username)).Delete(&models.LoginThrottle{}).Error
+	if err != nil {
+		fmt.Printf("Peringatan: Gagal mereset penghitung login untuk %s: %v\n", username, err)
+	}
+}
+
+// recordLoginEvent mencatat percobaan login; kegagalan mencatat tidak menggagalkan login
+func recordLoginEvent(c *fiber.Ctx, username string, userID *uint, success bool, reason string) {
+	userAgent := c.Get(fiber.HeaderUserAgent)
+	event := models.LoginEvent{
+		Username:  username,
+		UserID:    userID,
+		IPAddress: c.IP(),
+		Device:    utils.DescribeUserAgent(userAgent),
+		UserAgent: userAgent,
+		Success:   success,
+		Reason:    reason,
+	}
+	if err := database.DB.Create(&event).Error; err != nil {
+		fmt.Printf("Peringatan: Gagal mencatat login event untuk %s: %v\n", username, err)
+	}
+}
+
+// loginThrottledResponse mengirim 429 beserta header Retry-After
+func loginThrottledResponse(c *fiber.Ctx, retryAfter time.Duration, lockedOut bool) error {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+	if lockedOut {
+		return utils.ErrorResponse(c, fiber.StatusTooManyRequests,
+			fmt.Sprintf("Terlalu banyak percobaan login gagal. Akun dikunci sementara, coba lagi dalam %d menit atau hubungi admin.", int(math.Ceil(retryAfter.Minutes()))))
+	}
+	return utils.ErrorResponse(c, fiber.StatusTooManyRequests, fmt.Sprintf("Terlalu banyak percobaan login. Coba lagi dalam %d detik.", seconds))
+}
+
+// guardLogin menolak percobaan login yang masih dalam masa tunggu; mengembalikan true jika respons sudah dikirim
+func guardLogin(c *fiber.Ctx, keys []loginThrottleKey, username string, userID *uint) (bool, error) {
+	retryAfter, lockedOut, err := checkLoginThrottle(keys)
+	if err != nil {
+		return true, utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
+	}
+	if retryAfter <= 0 {
+		return false, nil
+	}
+	recordLoginEvent(c, username, userID, false, models.LoginEventThrottled)
+	return true, loginThrottledResponse(c, retryAfter, lockedOut)
+}
+
+// failLogin mencatat kegagalan login dan mengirim respons 401 dengan pesan yang diberikan
+func failLogin(c *fiber.Ctx, keys []loginThrottleKey, username string, userID *uint, reason string, message string) error {
+	lockedOut, err := registerLoginFailure(keys)
+	if err != nil {
+		fmt.Printf("Peringatan: Gagal mencatat kegagalan login untuk %s: %v\n", username, err)
+	}
+	if lockedOut {
+		reason = models.LoginEventLockedOut
+	}
+	recordLoginEvent(c, username, userID, false, reason)
+	return utils.ErrorResponse(c, fiber.StatusUnauthorized, message)
+}
+
+// UnlockUserLogin membuka penguncian login seorang pengguna (admin)
+func UnlockUserLogin(c *fiber.Ctx) error {
+	userID, err := strconv.ParseUint(c.Params("userId"), 10, 32)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "User ID tidak valid")
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, uint(userID)).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "Pengguna tidak ditemukan")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
+	}
+
+	result := database.DB.Where("scope = ? AND key = ?", models.LoginThrottleUsername, strings.ToLower(user.Username)).Delete(&models.LoginThrottle{})
+	if result.Error != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal membuka kunci login", result.Error.Error())
+	}
+	if result.RowsAffected == 0 {
+		return utils.SuccessResponse(c, fiber.StatusOK, "Pengguna tidak sedang terkunci", nil)
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Kunci login pengguna berhasil dibuka", nil)
+}
+
+// GetLoginLockouts menampilkan username dan IP yang sedang terkunci atau dalam masa tunggu
+func GetLoginLockouts(c *fiber.Ctx) error {
+	throttles := []models.LoginThrottle{}
+	query := database.DB.Where("locked_until > ?", time.Now())
+	if scope := c.Query("scope"); scope != "" {
+		query = query.Where("scope = ?", scope)
+	}
+	if err := query.Order("locked_until DESC").Find(&throttles).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal mengambil data penguncian login", err.Error())
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Data penguncian login berhasil diambil", throttles)
+}
+
+// DeleteLoginLockout membuka satu penguncian, mis. IP jaringan klinik yang terkunci
+func DeleteLoginLockout(c *fiber.Ctx) error {
+	throttleID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "ID penguncian tidak valid")
+	}
+	result := database.DB.Delete(&models.LoginThrottle{}, uint(throttleID))
+	if result.Error != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal membuka kunci login", result.Error.Error())
+	}
+	if result.RowsAffected == 0 {
+		return utils.ErrorResponse(c, fiber.StatusNotFound, "Data penguncian tidak ditemukan")
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Kunci login berhasil dibuka", nil)
+}
+
+// GetLoginEvents menampilkan riwayat percobaan login dengan filter dan pagination
+func GetLoginEvents(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	if limit <= 0 {
+		limit = 20
+	}
+	if page <= 0 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+
+	query := database.DB.Model(&models.LoginEvent{})
+	if username := c.Query("username"); username != "" {
+		query = query.Where("LOWER(username) = ?", strings.ToLower(username))
+	}
+	if userID := c.Query("userId"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if ip := c.Query("ip"); ip != "" {
+		query = query.Where("ip_address = ?", ip)
+	}
+	if success := c.Query("success"); success != "" {
+		query = query.Where("success = ?", success == "true")
+	}
+	if reason := c.Query("reason"); reason != "" {
+		query = query.Where("reason = ?", reason)
+	}
+	if startDate := c.Query("startDate"); startDate != "" {
+		query = query.Where("created_at::date >= ?", startDate)
+	}
+	if endDate := c.Query("endDate"); endDate != "" {
+		query = query.Where("created_at::date <= ?", endDate)
+	}
+	query = query.Session(&gorm.Session{})
+
+	var totalRecords int64
+	if err := query.Count(&totalRecords).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal menghitung total login event", err.Error())
+	}
+
+	loginEvents := []models.LoginEvent{}
+	if err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&loginEvents).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal mengambil data login event", err.Error())
+	}
+
+	paginationData := fiber.Map{
+		"currentPage":  page,
+		"totalPages":   int(math.Ceil(float64(totalRecords) / float64(limit))),
+		"totalRecords": totalRecords,
+		"pageSize":     limit,
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success":    true,
+		"data":       loginEvents,
+		"pagination": paginationData,
+		"message":    "Data login event berhasil diambil",
+	})
+}
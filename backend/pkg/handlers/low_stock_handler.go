@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/database"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/dto"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/events"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/models"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// mapLowStockAlertToResponse memetakan peringatan (dengan MedicationCatalog ter-preload) ke DTO respons
+func mapLowStockAlertToResponse(a models.LowStockAlert) dto.LowStockAlertResponse {
+	return dto.LowStockAlertResponse{
+		ID:                 a.ID,
+		MedicationID:       a.MedicationCatalogID,
+		MedicationCode:     a.MedicationCatalog.Kode,
+		MedicationName:     a.MedicationCatalog.Nama,
+		Satuan:             a.MedicationCatalog.Satuan,
+		Status:             a.Status,
+		StokSaatPeringatan: a.Stok,
+		StokMinimum:        a.StokMinimum,
+		StokSaatIni:        a.MedicationCatalog.Stok,
+		JumlahPemesanan:    a.MedicationCatalog.JumlahPemesanan,
+		CreatedAt:          a.CreatedAt,
+		AcknowledgedAt:     a.AcknowledgedAt,
+		AcknowledgedByName: a.AcknowledgedByName,
+		Catatan:            a.Catatan,
+		ResolvedAt:         a.ResolvedAt,
+	}
+}
+
+// CheckLowStock menutup peringatan obat yang stoknya sudah pulih (atau tidak lagi dipantau), lalu membuat
+// peringatan baru untuk obat dengan Stok < StokMinimum yang belum memiliki peringatan terbuka.
+// Peringatan baru juga dikirim sebagai event stock.low ke klien yang berlangganan.
+func CheckLowStock() (dto.LowStockCheckResponse, error) {
+	var result dto.LowStockCheckResponse
+	var raised []models.LowStockAlert
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		recovered := tx.Unscoped().Model(&models.MedicationCatalog{}).Select("id").
+			Where("stok_minimum = 0 OR stok >= stok_minimum OR deleted_at IS NOT NULL")
+		resolve := tx.Model(&models.LowStockAlert{}).
+			Where("resolved_at IS NULL AND medication_catalog_id IN (?)", recovered).
+			Updates(map[string]interface{}{"status": models.LowStockAlertStatusSelesai, "resolved_at": time.Now()})
+		if resolve.Error != nil {
+			return resolve.Error
+		}
+		result.Selesai = int(resolve.RowsAffected)
+
+		var lowStock []models.MedicationCatalog
+		if err := tx.Where("stok_minimum > 0 AND stok < stok_minimum").
+			Where("id NOT IN (?)", tx.Model(&models.LowStockAlert{}).Select("medication_catalog_id").Where("resolved_at IS NULL")).
+			Order("kode ASC").Find(&lowStock).Error; err != nil {
+			return err
+		}
+		for _, medication := range lowStock {
+			alert := models.LowStockAlert{
+				MedicationCatalogID: medication.ID,
+				Status:              models.LowStockAlertStatusAktif,
+				Stok:                medication.Stok,
+				StokMinimum:         medication.StokMinimum,
+			}
+			// Index unik parsial mencegah peringatan ganda jika pemeriksaan berjalan bersamaan di beberapa instance
+			created := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&alert)
+			if created.Error != nil {
+				return created.Error
+			}
+			if created.RowsAffected > 0 {
+				alert.MedicationCatalog = medication
+				raised = append(raised, alert)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	result.Dibuat = len(raised)
+	for _, alert := range raised {
+		events.Publish(events.TypeStockLow, 0, mapLowStockAlertToResponse(alert))
+	}
+	return result, nil
+}
+
+// StartLowStockChecker menjalankan CheckLowStock saat server mulai lalu setiap interval di latar belakang
+func StartLowStockChecker(interval time.Duration, logger *zap.Logger) {
+	if interval <= 0 {
+		logger.Info("Pemeriksaan stok menipis dinonaktifkan")
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			result, err := CheckLowStock()
+			if err != nil {
+				logger.Error("Gagal memeriksa stok menipis", zap.Error(err))
+			} else if result.Dibuat > 0 || result.Selesai > 0 {
+				logger.Info("Pemeriksaan stok menipis", zap.Int("dibuat", result.Dibuat), zap.Int("selesai", result.Selesai))
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// RunLowStockCheck menjalankan pemeriksaan stok menipis saat itu juga tanpa menunggu jadwal berikutnya
+func RunLowStockCheck(c *fiber.Ctx) error {
+	result, err := CheckLowStock()
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal memeriksa stok menipis", err.Error())
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Pemeriksaan stok menipis selesai", result)
+}
+
+// GetLowStockAlerts menampilkan peringatan stok menipis. Tanpa filter status hanya peringatan terbuka
+// (aktif dan diakui) yang ditampilkan; status=semua menampilkan seluruh riwayat.
+func GetLowStockAlerts(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	if limit <= 0 {
+		limit = 20
+	}
+	if page <= 0 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+
+	query := database.DB.Model(&models.LowStockAlert{})
+	switch status := c.Query("status"); status {
+	case "":
+		query = query.Where("resolved_at IS NULL")
+	case "semua":
+	default:
+		query = query.Where("status = ?", status)
+	}
+	if code := c.Query("medicationCode"); code != "" {
+		query = query.Where("medication_catalog_id IN (?)", database.DB.Unscoped().Model(&models.MedicationCatalog{}).Select("id").Where("kode = ?", code))
+	}
+	query = query.Session(&gorm.Session{})
+
+	var totalRecords int64
+	if err := query.Count(&totalRecords).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal menghitung peringatan stok", err.Error())
+	}
+
+	var alerts []models.LowStockAlert
+	if err := query.Preload("MedicationCatalog", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&alerts).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal mengambil peringatan stok", err.Error())
+	}
+
+	response := make([]dto.LowStockAlertResponse, 0, len(alerts))
+	for _, a := range alerts {
+		response = append(response, mapLowStockAlertToResponse(a))
+	}
+
+	paginationData := fiber.Map{
+		"currentPage":  page,
+		"totalPages":   int(math.Ceil(float64(totalRecords) / float64(limit))),
+		"totalRecords": totalRecords,
+		"pageSize":     limit,
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success":    true,
+		"data":       response,
+		"pagination": paginationData,
+		"message":    "Peringatan stok berhasil diambil",
+	})
+}
+
+// AcknowledgeLowStockAlert menandai peringatan aktif sudah ditindaklanjuti. Peringatan tetap terbuka
+// sampai stok kembali mencapai minimum sehingga tidak muncul peringatan baru untuk obat yang sama.
+func AcknowledgeLowStockAlert(c *fiber.Ctx) error {
+	alertID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Alert ID tidak valid")
+	}
+	req := new(dto.AcknowledgeLowStockAlertRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	if err := validate.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	userID, userName := actingUser(c)
+	var alert models.LowStockAlert
+	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&alert, uint(alertID)).Error; err != nil {
+			return err
+		}
+		if alert.Status != models.LowStockAlertStatusAktif {
+			return &stockError{fiber.StatusConflict, fmt.Sprintf("Peringatan berstatus %s tidak dapat diakui", alert.Status)}
+		}
+		now := time.Now()
+		alert.Status = models.LowStockAlertStatusDiakui
+		alert.AcknowledgedAt = &now
+		alert.AcknowledgedByID = userID
+		alert.AcknowledgedByName = userName
+		alert.Catatan = req.Catatan
+		return tx.Model(&alert).Select("status", "acknowledged_at", "acknowledged_by_id", "acknowledged_by_name", "catatan").Updates(&alert).Error
+	})
+	if errTx != nil {
+		if errors.Is(errTx, gorm.ErrRecordNotFound) {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "Peringatan stok tidak ditemukan")
+		}
+		return stockErrorResponse(c, errTx, "Gagal mengakui peringatan stok")
+	}
+
+	if err := database.DB.Unscoped().First(&alert.MedicationCatalog, alert.MedicationCatalogID).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Peringatan stok berhasil diakui", mapLowStockAlertToResponse(alert))
+}
+
+// GetReorderSuggestions menyusun saran pemesanan ulang dari pemakaian obat di EMR selama N hari terakhir (?days, default 30).
+// Kebutuhan = StokMinimum + rata-rata pemakaian harian x coverDays (default 30) - stok - sisa PO yang belum diterima,
+// dibulatkan ke atas ke kelipatan JumlahPemesanan. Hanya obat yang perlu dipesan ditampilkan kecuali includeAll=true.
+// Hasil diurutkan dari perkiraan stok habis paling cepat.
+func GetReorderSuggestions(c *fiber.Ctx) error {
+	days, err := strconv.Atoi(c.Query("days", "30"))
+	if err != nil || days <= 0 {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Parameter days harus berupa angka lebih dari 0")
+	}
+	coverDays, err := strconv.Atoi(c.Query("coverDays", "30"))
+	if err != nil || coverDays < 0 {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Parameter coverDays harus berupa angka positif")
+	}
+	includeAll := c.Query("includeAll") == "true"
+
+	type quantityByMedication struct {
+		MedicationCatalogID uint
+		Total               int
+	}
+	var usages []quantityByMedication
+	if err := database.DB.Table("medical_record_medication_items AS mi").
+		Select("mi.medication_catalog_id, SUM(mi.quantity) AS total").
+		Joins("JOIN medical_records mr ON mr.id = mi.medical_record_id").
+		Where("mi.deleted_at IS NULL AND mr.deleted_at IS NULL AND mr.exam_date >= ?", time.Now().AddDate(0, 0, -days)).
+		Group("mi.medication_catalog_id").Scan(&usages).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal menghitung pemakaian obat", err.Error())
+	}
+	var outstanding []quantityByMedication
+	if err := database.DB.Table("purchase_order_lines AS l").
+		Select("l.medication_catalog_id, SUM(l.jumlah - l.jumlah_diterima) AS total").
+		Joins("JOIN purchase_orders po ON po.id = l.purchase_order_id").
+		Where("l.deleted_at IS NULL AND po.deleted_at IS NULL AND po.status IN ?", []string{models.PurchaseOrderStatusDikirim, models.PurchaseOrderStatusDiterimaSebagian}).
+		Group("l.medication_catalog_id").Scan(&outstanding).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal menghitung pesanan outstanding", err.Error())
+	}
+	usageByID := make(map[uint]int, len(usages))
+	for _, u := range usages {
+		usageByID[u.MedicationCatalogID] = u.Total
+	}
+	outstandingByID := make(map[uint]int, len(outstanding))
+	for _, o := range outstanding {
+		outstandingByID[o.MedicationCatalogID] = o.Total
+	}
+
+	var medications []models.MedicationCatalog
+	if err := database.DB.Order("kode ASC").Find(&medications).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal mengambil master obat", err.Error())
+	}
+
+	suggestions := []dto.ReorderSuggestionResponse{}
+	for _, m := range medications {
+		usage := usageByID[m.ID]
+		if m.StokMinimum == 0 && usage == 0 && !includeAll {
+			continue
+		}
+		average := float64(usage) / float64(days)
+		suggestion := dto.ReorderSuggestionResponse{
+			MedicationID:       m.ID,
+			MedicationCode:     m.Kode,
+			MedicationName:     m.Nama,
+			Satuan:             m.Satuan,
+			Stok:               m.Stok,
+			StokMinimum:        m.StokMinimum,
+			JumlahPemesanan:    m.JumlahPemesanan,
+			Pemakaian:          usage,
+			RataRataHarian:     math.Round(average*100) / 100,
+			PesananOutstanding: outstandingByID[m.ID],
+			HargaBeli:          m.HargaBeli,
+		}
+		if average > 0 {
+			daysLeft := math.Round(float64(max(m.Stok, 0))/average*10) / 10
+			suggestion.PerkiraanHabisHari = &daysLeft
+		}
+
+		need := m.StokMinimum + int(math.Ceil(average*float64(coverDays))) - m.Stok - suggestion.PesananOutstanding
+		if need > 0 {
+			if m.JumlahPemesanan > 0 {
+				need = (need + m.JumlahPemesanan - 1) / m.JumlahPemesanan * m.JumlahPemesanan
+			}
+			suggestion.JumlahDisarankan = need
+			suggestion.EstimasiBiaya = roundCurrency(float64(need) * m.HargaBeli)
+		}
+		if suggestion.JumlahDisarankan == 0 && !includeAll {
+			continue
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		a, b := suggestions[i].PerkiraanHabisHari, suggestions[j].PerkiraanHabisHari
+		if a == nil || b == nil {
+			return a != nil
+		}
+		return *a < *b
+	})
+	return utils.SuccessResponse(c, fiber.StatusOK, fmt.Sprintf("Saran pemesanan berdasarkan pemakaian %d hari terakhir berhasil diambil", days), suggestions)
+}
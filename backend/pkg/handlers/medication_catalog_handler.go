@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/database"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/dto"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/models"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// mapMedicationCatalogToResponse mengubah models.MedicationCatalog menjadi dto.MedicationCatalogResponse
+func mapMedicationCatalogToResponse(m models.MedicationCatalog) dto.MedicationCatalogResponse {
+	return dto.MedicationCatalogResponse{
+		ID:        m.ID,
+		Kode:      m.Kode,
+		Nama:      m.Nama,
+		Satuan:    m.Satuan,
+		HargaBeli: m.HargaBeli,
+		HargaJual: m.HargaJual,
+		Stok:      m.Stok,
+		Deskripsi: m.Deskripsi,
+		CreatedAt: m.CreatedAt,
+		UpdatedAt: m.UpdatedAt,
+		DeletedAt: deletedAtPtr(m.DeletedAt),
+
+		StokMinimum:     m.StokMinimum,
+		JumlahPemesanan: m.JumlahPemesanan,
+		StokMenipis:     m.StokMinimum > 0 && m.Stok < m.StokMinimum,
+	}
+}
+
+// CreateMedicationCatalog membuat master obat baru
+func CreateMedicationCatalog(c *fiber.Ctx) error {
+	req := new(dto.CreateMedicationCatalogRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	req.Kode = strings.TrimSpace(req.Kode)
+	if err := validate.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	var existing models.MedicationCatalog
+	if err := database.DB.Unscoped().Where("LOWER(kode) = LOWER(?)", req.Kode).First(&existing).Error; err == nil {
+		if existing.DeletedAt.Valid {
+			return utils.ErrorResponse(c, fiber.StatusConflict, fmt.Sprintf("Obat dengan kode '%s' pernah dihapus. Pulihkan data tersebut alih-alih membuat baru.", existing.Kode))
+		}
+		return utils.ErrorResponse(c, fiber.StatusConflict, fmt.Sprintf("Obat dengan kode '%s' sudah ada.", existing.Kode))
+	} else if err != gorm.ErrRecordNotFound {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
+	}
+
+	medication := models.MedicationCatalog{
+		Kode:      req.Kode,
+		Nama:      req.Nama,
+		Satuan:    req.Satuan,
+		HargaBeli: req.HargaBeli,
+		HargaJual: req.HargaJual,
+		Deskripsi: req.Deskripsi,
+
+		StokMinimum:     req.StokMinimum,
+		JumlahPemesanan: req.JumlahPemesanan,
+	}
+	ledger := newStockLedger(c)
+	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&medication).Error; err != nil {
+			return err
+		}
+		if req.Stok == 0 {
+			return nil
+		}
+		movement, err := ledger.post(tx, models.StockMovement{
+			MedicationCatalogID: medication.ID,
+			Type:                models.StockMovementMasuk,
+			Quantity:            req.Stok,
+			Reason:              "Stok awal",
+		}, false)
+		medication.Stok = movement.StockAfter
+		return err
+	})
+	if errTx != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal menyimpan master obat", errTx.Error())
+	}
+	return utils.SuccessResponse(c, fiber.StatusCreated, "Master obat berhasil dibuat", mapMedicationCatalogToResponse(medication))
+}
+
+// GetMedicationCatalogs mengambil daftar master obat dengan pagination dan pencarian
+func GetMedicationCatalogs(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "10"))
+	if limit <= 0 {
+		limit = 10
+	}
+	if page <= 0 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+
+	query := database.DB.Model(&models.MedicationCatalog{})
+	if c.Query("includeDeleted") == "true" {
+		query = query.Unscoped()
+	}
+	if search := c.Query("search"); search != "" {
+		searchPattern := "%" + search + "%"
+		query = query.Where("kode ILIKE ? OR nama ILIKE ?", searchPattern, searchPattern)
+	}
+	query = query.Session(&gorm.Session{})
+
+	var totalRecords int64
+	if err := query.Count(&totalRecords).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal menghitung total master obat", err.Error())
+	}
+
+	var medications []models.MedicationCatalog
+	if err := query.Order("kode ASC").Offset(offset).Limit(limit).Find(&medications).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal mengambil data master obat", err.Error())
+	}
+
+	medicationResponses := []dto.MedicationCatalogResponse{}
+	for _, m := range medications {
+		medicationResponses = append(medicationResponses, mapMedicationCatalogToResponse(m))
+	}
+
+	paginationData := fiber.Map{
+		"currentPage":  page,
+		"totalPages":   int(math.Ceil(float64(totalRecords) / float64(limit))),
+		"totalRecords": totalRecords,
+		"pageSize":     limit,
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success":    true,
+		"data":       medicationResponses,
+		"pagination": paginationData,
+		"message":    "Data master obat berhasil diambil",
+	})
+}
+
+// GetMedicationCatalogByKode mengambil master obat berdasarkan kode
+func GetMedicationCatalogByKode(c *fiber.Ctx) error {
+	var medication models.MedicationCatalog
+	if err := database.DB.Where("kode = ?", c.Params("kode")).First(&medication).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "Master obat tidak ditemukan")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Master obat ditemukan", mapMedicationCatalogToResponse(medication))
+}
+
+// UpdateMedicationCatalog memperbarui master obat berdasarkan kode
+func UpdateMedicationCatalog(c *fiber.Ctx) error {
+	var medication models.MedicationCatalog
+	if err := database.DB.Where("kode = ?", c.Params("kode")).First(&medication).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "Master obat tidak ditemukan")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
+	}
+
+	req := new(dto.UpdateMedicationCatalogRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	if err := validate.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	if req.Nama != "" {
+		medication.Nama = req.Nama
+	}
+	if req.Satuan != "" {
+		medication.Satuan = req.Satuan
+	}
+	if req.HargaBeli != nil {
+		medication.HargaBeli = *req.HargaBeli
+	}
+	if req.HargaJual != nil {
+		medication.HargaJual = *req.HargaJual
+	}
+	if req.Deskripsi != "" {
+		medication.Deskripsi = req.Deskripsi
+	}
+	if req.StokMinimum != nil {
+		medication.StokMinimum = *req.StokMinimum
+	}
+	if req.JumlahPemesanan != nil {
+		medication.JumlahPemesanan = *req.JumlahPemesanan
+	}
+
+	// Stok tidak ikut disimpan agar mutasi stok yang terjadi bersamaan tidak tertimpa
+	if err := database.DB.Omit("stok").Save(&medication).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal memperbarui master obat", err.Error())
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Master obat berhasil diperbarui", mapMedicationCatalogToResponse(medication))
+}
+
+// DeleteMedicationCatalog menghapus master obat (soft delete)
+func DeleteMedicationCatalog(c *fiber.Ctx) error {
+	var medication models.MedicationCatalog
+	if err := database.DB.Where("kode = ?", c.Params("kode")).First(&medication).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "Master obat tidak ditemukan")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
+	}
+
+	if err := database.DB.Delete(&medication).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal menghapus master obat", err.Error())
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Master obat berhasil dihapus", nil)
+}
+
+// RestoreMedicationCatalog memulihkan master obat yang sudah dihapus
+func RestoreMedicationCatalog(c *fiber.Ctx) error {
+	var medication models.MedicationCatalog
+	if err := database.DB.Unscoped().Where("kode = ?", c.Params("kode")).First(&medication).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "Master obat tidak ditemukan")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
+	}
+	if !medication.DeletedAt.Valid {
+		return utils.ErrorResponse(c, fiber.StatusConflict, "Master obat tidak dalam keadaan terhapus")
+	}
+
+	if err := database.DB.Unscoped().Model(&medication).Update("deleted_at", nil).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal memulihkan master obat", err.Error())
+	}
+	medication.DeletedAt = gorm.DeletedAt{}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Master obat berhasil dipulihkan", mapMedicationCatalogToResponse(medication))
+}
@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/config"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/database"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/dto"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/models"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// passwordPolicyError berisi daftar pelanggaran kebijakan password
+type passwordPolicyError struct {
+	Violations []string
+}
+
+func (e *passwordPolicyError) Error() string { return "password tidak memenuhi kebijakan" }
+
+// errPasswordResetInvalid dipakai untuk token reset yang salah, sudah dipakai atau kedaluwarsa
+var errPasswordResetInvalid = errors.New("link reset password tidak valid atau sudah kedaluwarsa")
+
+// checkNewPassword memeriksa password baru terhadap kebijakan dan riwayat password pengguna.
+// Password saat ini ikut dihitung sebagai salah satu dari N password terakhir.
+func checkNewPassword(db *gorm.DB, user models.User, newPassword string) error {
+	if violations := utils.ValidatePasswordPolicy(newPassword, user.Username); len(violations) > 0 {
+		return &passwordPolicyError{Violations: violations}
+	}
+
+	historyCount := config.AppConfig.PasswordPolicy.HistoryCount
+	if historyCount <= 0 {
+		return nil
+	}
+	hashes := []string{user.PasswordHash}
+	var histories []models.PasswordHistory
+	if err := db.Where("user_id = ?", user.ID).Order("created_at DESC").Limit(historyCount).Find(&histories).Error; err != nil {
+		return err
+	}
+	for _, h := range histories {
+		if len(hashes) >= historyCount {
+			break
+		}
+		if h.PasswordHash != user.PasswordHash {
+			hashes = append(hashes, h.PasswordHash)
+		}
+	}
+	for _, hash := range hashes {
+		if utils.CheckPasswordHash(newPassword, hash) {
+			return &passwordPolicyError{Violations: []string{
+				"Password baru tidak boleh sama dengan " + strconv.Itoa(historyCount) + " password terakhir",
+			}}
+		}
+	}
+	return nil
+}
+
+// recordPasswordChange mencatat hash password baru ke riwayat, memangkas riwayat lama,
+// lalu mencabut semua sesi pengguna agar login ulang dengan password baru.
+func recordPasswordChange(tx *gorm.DB, userID uint, passwordHash string) error {
+	now := time.Now()
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).Update("password_changed_at", now).Error; err != nil {
+		return err
+	}
+
+	historyCount := config.AppConfig.PasswordPolicy.HistoryCount
+	if historyCount > 0 {
+		if err := tx.Create(&models.PasswordHistory{UserID: userID, PasswordHash: passwordHash}).Error; err != nil {
+			return err
+		}
+		keep := tx.Model(&models.PasswordHistory{}).Select("id").Where("user_id = ?", userID).Order("created_at DESC").Limit(historyCount)
+		if err := tx.Where("user_id = ? AND id NOT IN (?)", userID, keep).Delete(&models.PasswordHistory{}).Error; err != nil {
+			return err
+		}
+	} else if err := tx.Where("user_id = ?", userID).Delete(&models.PasswordHistory{}).Error; err != nil {
+		return err
+	}
+
+	return revokeUserSessions(tx, userID, models.SessionRevokedPassword)
+}
+
+// setUserPassword menyimpan password baru yang sudah lolos checkNewPassword
+func setUserPassword(tx *gorm.DB, userID uint, newPassword string) error {
+	hashedPassword, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).Update("password_hash", hashedPassword).Error; err != nil {
+		return err
+	}
+	return recordPasswordChange(tx, userID, hashedPassword)
+}
+
+// passwordErrorResponse memetakan error penggantian password ke respons HTTP
+func passwordErrorResponse(c *fiber.Ctx, err error) error {
+	var policyErr *passwordPolicyError
+	if errors.As(err, &policyErr) {
+		return utils.ValidationErrorResponse(c, policyErr.Violations)
+	}
+	if err == errPasswordResetInvalid {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Link reset password tidak valid atau sudah kedaluwarsa")
+	}
+	return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal mengubah password", err.Error())
+}
+
+// ChangeMyPassword mengganti password milik sendiri; semua sesi (termasuk yang sedang dipakai) dicabut
+func ChangeMyPassword(c *fiber.Ctx) error {
+	req := new(dto.ChangePasswordRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	if err := validateAuth.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, c.Locals("user_id").(uint)).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusNotFound, "User tidak ditemukan")
+	}
+	if !utils.CheckPasswordHash(req.CurrentPassword, user.PasswordHash) {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Password saat ini tidak sesuai")
+	}
+	if err := checkNewPassword(database.DB, user, req.NewPassword); err != nil {
+		return passwordErrorResponse(c, err)
+	}
+
+	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		return setUserPassword(tx, user.ID, req.NewPassword)
+	})
+	if errTx != nil {
+		return passwordErrorResponse(c, errTx)
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Password berhasil diubah. Silakan login kembali.", nil)
+}
+
+// CreatePasswordResetLink membuat link reset password sekali pakai untuk seorang pengguna (admin).
+// Link sebelumnya yang belum dipakai langsung tidak berlaku.
+func CreatePasswordResetLink(c *fiber.Ctx) error {
+	userID, err := strconv.ParseUint(c.Params("userId"), 10, 32)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "User ID tidak valid")
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, uint(userID)).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "Pengguna tidak ditemukan")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
+	}
+	if user.Status != "aktif" {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Pengguna tidak aktif, aktifkan terlebih dahulu")
+	}
+
+	token, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal membuat token reset", err.Error())
+	}
+	resetToken := models.PasswordResetToken{
+		UserID:      user.ID,
+		TokenHash:   utils.HashToken(token),
+		ExpiresAt:   time.Now().Add(config.AppConfig.PasswordResetExpiry),
+		CreatedByID: c.Locals("user_id").(uint),
+	}
+	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL AND expires_at > ?", user.ID, time.Now()).
+			Update("expires_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(&resetToken).Error
+	})
+	if errTx != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal membuat link reset password", errTx.Error())
+	}
+
+	return utils.SuccessResponse(c, fiber.StatusCreated, "Link reset password berhasil dibuat", dto.PasswordResetLinkResponse{
+		ResetURL:  config.AppConfig.FrontendBaseURL + "/reset-password?token=" + url.QueryEscape(token),
+		Token:     token,
+		ExpiresAt: resetToken.ExpiresAt,
+	})
+}
+
+// findPasswordResetToken mengambil token reset yang masih berlaku beserta penggunanya
+func findPasswordResetToken(tx *gorm.DB, token string, lock bool) (models.PasswordResetToken, error) {
+	var resetToken models.PasswordResetToken
+	query := tx
+	if lock {
+		query = query.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+	err := query.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", utils.HashToken(token), time.Now()).First(&resetToken).Error
+	if err == gorm.ErrRecordNotFound {
+		return resetToken, errPasswordResetInvalid
+	}
+	if err != nil {
+		return resetToken, err
+	}
+	if err := tx.First(&resetToken.User, resetToken.UserID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return resetToken, errPasswordResetInvalid
+		}
+		return resetToken, err
+	}
+	if resetToken.User.Status != "aktif" {
+		return resetToken, errPasswordResetInvalid
+	}
+	return resetToken, nil
+}
+
+// VerifyPasswordResetToken memeriksa token reset sebelum form password baru ditampilkan.
+// Token dikirim lewat body (bukan URL) agar tidak tercatat di log akses.
+func VerifyPasswordResetToken(c *fiber.Ctx) error {
+	req := new(dto.ResetPasswordRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	if req.Token == "" {
+		return utils.ValidationErrorResponse(c, "token wajib diisi")
+	}
+
+	resetToken, err := findPasswordResetToken(database.DB, req.Token, false)
+	if err != nil {
+		return passwordErrorResponse(c, err)
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Link reset password valid", dto.PasswordResetInfoResponse{
+		Username:  resetToken.User.Username,
+		ExpiresAt: resetToken.ExpiresAt,
+	})
+}
+
+// ResetPassword menyetel password baru memakai token reset dari admin. Token hanya bisa dipakai sekali.
+func ResetPassword(c *fiber.Ctx) error {
+	req := new(dto.ResetPasswordRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	if err := validateAuth.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	var username string
+	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		resetToken, err := findPasswordResetToken(tx, req.Token, true)
+		if err != nil {
+			return err
+		}
+		if err := checkNewPassword(tx, resetToken.User, req.NewPassword); err != nil {
+			return err
+		}
+		if err := setUserPassword(tx, resetToken.UserID, req.NewPassword); err != nil {
+			return err
+		}
+		username = resetToken.User.Username
+		return tx.Model(&resetToken).Update("used_at", time.Now()).Error
+	})
+	if errTx != nil {
+		return passwordErrorResponse(c, errTx)
+	}
+
+	// Password baru dari admin: buka juga penguncian login akibat percobaan password lama
+	clearLoginThrottle(username)
+	return utils.SuccessResponse(c, fiber.StatusOK, "Password berhasil direset. Silakan login dengan password baru.", nil)
+}
@@ -1,7 +1,7 @@
 package handlers
 
 import (
-	"fmt"
+	"errors"
 	"strconv"
 	"time"
 
@@ -18,6 +18,26 @@ import (
 
 var validate = validator.New()
 
+// mapPatientToResponse memetakan model pasien ke DTO respons
+func mapPatientToResponse(patient models.Patient) dto.PatientResponse {
+	return dto.PatientResponse{
+		ID:              patient.ID,
+		NoRM:            patient.NoRM,
+		NoRMLama:        patient.NoRMLama,
+		NamaLengkap:     patient.NamaLengkap,
+		TanggalLahir:    patient.TanggalLahir,
+		JenisKelamin:    patient.JenisKelamin,
+		Alamat:          patient.Alamat,
+		NomorTelepon:    patient.NomorTelepon,
+		Email:           patient.Email,
+		Alergi:          patient.Alergi,
+		RiwayatPenyakit: patient.RiwayatPenyakit,
+		Version:         patient.Version,
+		CreatedAt:       patient.CreatedAt,
+		UpdatedAt:       patient.UpdatedAt,
+	}
+}
+
 // CreatePatient membuat pasien baru
 func CreatePatient(c *fiber.Ctx) error {
 	req := new(dto.CreatePatientRequest)
@@ -40,8 +60,14 @@ func CreatePatient(c *fiber.Ctx) error {
 		tglLahir = &parsedDate
 	}
 
+	// NoRM diambil dari sequence database sesuai PATIENT_NUMBER_FORMAT sehingga tidak bentrok walau pendaftaran bersamaan
+	noRM, err := database.PatientNumbers.Next(database.DB, time.Now())
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal membuat nomor rekam medis", err.Error())
+	}
+
 	patient := models.Patient{
-		NoRM:            fmt.Sprintf("RM-%d", time.Now().Unix()), // Contoh pembuatan NoRM
+		NoRM:            noRM,
 		NamaLengkap:     req.NamaLengkap,
 		TanggalLahir:    tglLahir,
 		JenisKelamin:    req.JenisKelamin,
@@ -56,21 +82,8 @@ func CreatePatient(c *fiber.Ctx) error {
 		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal menyimpan pasien", err.Error())
 	}
 
-	response := dto.PatientResponse{
-		ID:              patient.ID,
-		NoRM:            patient.NoRM,
-		NamaLengkap:     patient.NamaLengkap,
-		TanggalLahir:    patient.TanggalLahir,
-		JenisKelamin:    patient.JenisKelamin,
-		Alamat:          patient.Alamat,
-		NomorTelepon:    patient.NomorTelepon,
-		Email:           patient.Email,
-		Alergi:          patient.Alergi,
-		RiwayatPenyakit: patient.RiwayatPenyakit,
-		CreatedAt:       patient.CreatedAt,
-		UpdatedAt:       patient.UpdatedAt,
-	}
-	return utils.SuccessResponse(c, fiber.StatusCreated, "Pasien berhasil dibuat", response)
+	utils.SetETag(c, patient.Version)
+	return utils.SuccessResponse(c, fiber.StatusCreated, "Pasien berhasil dibuat", mapPatientToResponse(patient))
 }
 
 // GetPatients mengambil semua pasien dengan pagination
@@ -102,20 +115,7 @@ func GetPatients(c *fiber.Ctx) error {
 
 	var patientResponses []dto.PatientResponse
 	for _, p := range patients {
-		patientResponses = append(patientResponses, dto.PatientResponse{
-			ID:              p.ID,
-			NoRM:            p.NoRM,
-			NamaLengkap:     p.NamaLengkap,
-			TanggalLahir:    p.TanggalLahir,
-			JenisKelamin:    p.JenisKelamin,
-			Alamat:          p.Alamat,
-			NomorTelepon:    p.NomorTelepon,
-			Email:           p.Email,
-			Alergi:          p.Alergi,
-			RiwayatPenyakit: p.RiwayatPenyakit,
-			CreatedAt:       p.CreatedAt,
-			UpdatedAt:       p.UpdatedAt,
-		})
+		patientResponses = append(patientResponses, mapPatientToResponse(p))
 	}
 
 	// Data untuk pagination di frontend
@@ -133,6 +133,19 @@ func GetPatients(c *fiber.Ctx) error {
 	})
 }
 
+// findPatientByParam mencari pasien dari parameter rute berisi ID internal, NoRM atau NoRM lama.
+// Parameter berupa angka dicoba sebagai ID lebih dulu, lalu sebagai NoRM.
+func findPatientByParam(db *gorm.DB, idParam string, patient *models.Patient) error {
+	db = db.Session(&gorm.Session{})
+	if patientID, err := strconv.ParseUint(idParam, 10, 32); err == nil {
+		err := db.First(patient, uint(patientID)).Error
+		if err != gorm.ErrRecordNotFound {
+			return err
+		}
+	}
+	return db.Where("no_rm = ? OR no_rm_lama = ?", idParam, idParam).First(patient).Error
+}
+
 // GetPatientByIDOrNoRM mengambil pasien berdasarkan ID atau NoRM
 func GetPatientByIDOrNoRM(c *fiber.Ctx) error {
 	idParam := c.Params("id")
@@ -145,65 +158,38 @@ func GetPatientByIDOrNoRM(c *fiber.Ctx) error {
 		return db.Where("tanggal >= ?", time.Now().Format("2006-01-02")).Order("tanggal asc, waktu asc").Limit(5) // Reservasi mendatang
 	})
 
-	// Coba cari berdasarkan ID (uint) dulu
-	if patientID, err := strconv.ParseUint(idParam, 10, 32); err == nil {
-		if errDb := query.First(&patient, uint(patientID)).Error; errDb != nil {
-			if errDb == gorm.ErrRecordNotFound {
-				// Jika tidak ketemu, coba cari berdasarkan NoRM
-				if errDbNoRM := query.Where("no_rm = ?", idParam).First(&patient).Error; errDbNoRM != nil {
-					if errDbNoRM == gorm.ErrRecordNotFound {
-						return utils.ErrorResponse(c, fiber.StatusNotFound, "Pasien tidak ditemukan")
-					}
-					return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan database", errDbNoRM.Error())
-				}
-			} else {
-				return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan database", errDb.Error())
-			}
-		}
-	} else {
-		// Jika idParam bukan angka, anggap sebagai NoRM
-		if errDb := query.Where("no_rm = ?", idParam).First(&patient).Error; errDb != nil {
-			if errDb == gorm.ErrRecordNotFound {
-				return utils.ErrorResponse(c, fiber.StatusNotFound, "Pasien tidak ditemukan")
-			}
-			return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan database", errDb.Error())
+	if errDb := findPatientByParam(query, idParam, &patient); errDb != nil {
+		if errDb == gorm.ErrRecordNotFound {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "Pasien tidak ditemukan")
 		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan database", errDb.Error())
 	}
 
-	response := dto.PatientResponse{
-		ID:              patient.ID,
-		NoRM:            patient.NoRM,
-		NamaLengkap:     patient.NamaLengkap,
-		TanggalLahir:    patient.TanggalLahir,
-		JenisKelamin:    patient.JenisKelamin,
-		Alamat:          patient.Alamat,
-		NomorTelepon:    patient.NomorTelepon,
-		Email:           patient.Email,
-		Alergi:          patient.Alergi,
-		RiwayatPenyakit: patient.RiwayatPenyakit,
-		CreatedAt:       patient.CreatedAt,
-		UpdatedAt:       patient.UpdatedAt,
-	}
+	response := mapPatientToResponse(patient)
 	// Anda mungkin ingin menambahkan data relasi (EMR, Reservasi) ke respons jika diperlukan
 	// Misal, response.MedicalRecords = patient.MedicalRecords
 
+	utils.SetETag(c, patient.Version)
 	return utils.SuccessResponse(c, fiber.StatusOK, "Pasien ditemukan", response)
 }
 
-// UpdatePatient memperbarui data pasien
+// UpdatePatient memperbarui data pasien. Header If-Match wajib berisi ETag pasien yang terakhir dibaca.
 func UpdatePatient(c *fiber.Ctx) error {
 	idParam := c.Params("id")
 	var patient models.Patient
 
+	expectedVersion, ok := utils.IfMatchVersion(c)
+	if !ok {
+		return ifMatchRequiredResponse(c)
+	}
+
 	// Cari pasien
-	if patientID, err := strconv.ParseUint(idParam, 10, 32); err == nil {
-		if errDb := database.DB.First(&patient, uint(patientID)).Error; errDb != nil { // ...
-			return utils.ErrorResponse(c, fiber.StatusNotFound, "Pasien tidak ditemukan")
-		}
-	} else {
-		if errDb := database.DB.Where("no_rm = ?", idParam).First(&patient).Error; errDb != nil { // ...
-			return utils.ErrorResponse(c, fiber.StatusNotFound, "Pasien tidak ditemukan")
-		}
+	if errDb := findPatientByParam(database.DB, idParam, &patient); errDb != nil {
+		return utils.ErrorResponse(c, fiber.StatusNotFound, "Pasien tidak ditemukan")
+	}
+
+	if patient.Version != expectedVersion {
+		return utils.VersionConflictResponse(c, fiber.StatusPreconditionFailed, "Data pasien sudah diubah pengguna lain, muat ulang data terbaru", patient.Version, mapPatientToResponse(patient))
 	}
 
 	req := new(dto.UpdatePatientRequest)
@@ -243,15 +229,19 @@ func UpdatePatient(c *fiber.Ctx) error {
 		patient.RiwayatPenyakit = req.RiwayatPenyakit
 	}
 
-	if err := database.DB.Save(&patient).Error; err != nil {
+	// Simpan hanya jika belum ada yang mengubah pasien sejak dibaca di atas
+	patient.Version = expectedVersion + 1
+	if err := updateWithVersion(database.DB, &patient, expectedVersion); err != nil {
+		if errors.Is(err, errVersionConflict) {
+			var current models.Patient
+			database.DB.First(&current, patient.ID)
+			return utils.VersionConflictResponse(c, fiber.StatusConflict, "Data pasien diubah pengguna lain saat sedang disimpan, muat ulang data terbaru", current.Version, mapPatientToResponse(current))
+		}
 		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal memperbarui pasien", err.Error())
 	}
 
-	response := dto.PatientResponse{
-		// ... (map field ke response DTO)
-		ID: patient.ID, NamaLengkap: patient.NamaLengkap, NoRM: patient.NoRM, //...
-	}
-	return utils.SuccessResponse(c, fiber.StatusOK, "Data pasien berhasil diperbarui", response)
+	utils.SetETag(c, patient.Version)
+	return utils.SuccessResponse(c, fiber.StatusOK, "Data pasien berhasil diperbarui", mapPatientToResponse(patient))
 }
 
 // DeletePatient menghapus data pasien (soft delete)
@@ -259,14 +249,8 @@ func DeletePatient(c *fiber.Ctx) error {
 	idParam := c.Params("id")
 	var patient models.Patient
 
-	if patientID, err := strconv.ParseUint(idParam, 10, 32); err == nil {
-		if errDb := database.DB.First(&patient, uint(patientID)).Error; errDb != nil {
-			return utils.ErrorResponse(c, fiber.StatusNotFound, "Pasien tidak ditemukan")
-		}
-	} else {
-		if errDb := database.DB.Where("no_rm = ?", idParam).First(&patient).Error; errDb != nil {
-			return utils.ErrorResponse(c, fiber.StatusNotFound, "Pasien tidak ditemukan")
-		}
+	if errDb := findPatientByParam(database.DB, idParam, &patient); errDb != nil {
+		return utils.ErrorResponse(c, fiber.StatusNotFound, "Pasien tidak ditemukan")
 	}
 
 	if err := database.DB.Delete(&patient).Error; err != nil {
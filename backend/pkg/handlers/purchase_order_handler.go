@@ -0,0 +1,576 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/database"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/dto"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/models"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// procurementError adalah error alur pengadaan yang dipetakan langsung ke status HTTP, mis. PO yang bukan draft diubah
+type procurementError struct {
+	Status  int
+	Message string
+}
+
+func (e *procurementError) Error() string { return e.Message }
+
+// procurementErrorResponse memetakan error pengadaan (termasuk error mutasi stok saat penerimaan) ke respons HTTP
+func procurementErrorResponse(c *fiber.Ctx, err error, fallbackMessage string) error {
+	var pErr *procurementError
+	if errors.As(err, &pErr) {
+		return utils.ErrorResponse(c, pErr.Status, pErr.Message)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return utils.ErrorResponse(c, fiber.StatusNotFound, "Purchase order tidak ditemukan")
+	}
+	return stockErrorResponse(c, err, fallbackMessage)
+}
+
+// parseOptionalDate mengubah tanggal YYYY-MM-DD opsional menjadi *time.Time
+func parseOptionalDate(value, field string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, &procurementError{fiber.StatusBadRequest, fmt.Sprintf("Format %s tidak valid (YYYY-MM-DD)", field)}
+	}
+	return &t, nil
+}
+
+// buildPurchaseOrderLines menyusun baris PO dari request; setiap obat hanya boleh muncul sekali
+func buildPurchaseOrderLines(tx *gorm.DB, requested []dto.PurchaseOrderLineRequest) ([]models.PurchaseOrderLine, float64, error) {
+	lines := make([]models.PurchaseOrderLine, 0, len(requested))
+	seen := make(map[uint]bool)
+	var total float64
+	for _, r := range requested {
+		var medication models.MedicationCatalog
+		if err := tx.Where("kode = ?", r.MedicationCode).First(&medication).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, 0, &procurementError{fiber.StatusBadRequest, fmt.Sprintf("Obat dengan kode '%s' tidak ditemukan", r.MedicationCode)}
+			}
+			return nil, 0, err
+		}
+		if seen[medication.ID] {
+			return nil, 0, &procurementError{fiber.StatusBadRequest, fmt.Sprintf("Obat '%s' tercantum lebih dari sekali", r.MedicationCode)}
+		}
+		seen[medication.ID] = true
+
+		subTotal := roundCurrency(float64(r.Jumlah) * r.HargaBeli)
+		lines = append(lines, models.PurchaseOrderLine{
+			MedicationCatalogID: medication.ID,
+			Jumlah:              r.Jumlah,
+			HargaBeli:           r.HargaBeli,
+			SubTotal:            subTotal,
+		})
+		total += subTotal
+	}
+	return lines, roundCurrency(total), nil
+}
+
+// ensureActiveSupplier memastikan supplier ada dan belum dihapus
+func ensureActiveSupplier(tx *gorm.DB, supplierID uint) error {
+	var supplier models.Supplier
+	if err := tx.First(&supplier, supplierID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &procurementError{fiber.StatusBadRequest, "Supplier tidak ditemukan"}
+		}
+		return err
+	}
+	return nil
+}
+
+// lockPurchaseOrder mengunci baris PO untuk perubahan status atau penerimaan barang
+func lockPurchaseOrder(tx *gorm.DB, c *fiber.Ctx) (models.PurchaseOrder, error) {
+	var po models.PurchaseOrder
+	poID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return po, &procurementError{fiber.StatusBadRequest, "Purchase order ID tidak valid"}
+	}
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&po, uint(poID)).Error
+	return po, err
+}
+
+// findPurchaseOrderDetail mengambil PO beserta supplier, baris pesanan dan riwayat penerimaannya
+func findPurchaseOrderDetail(poID uint) (models.PurchaseOrder, error) {
+	var po models.PurchaseOrder
+	err := database.DB.Preload("Supplier", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("id asc") }).
+		Preload("Lines.MedicationCatalog", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Preload("Receipts", func(db *gorm.DB) *gorm.DB { return db.Order("tanggal_terima asc") }).
+		Preload("Receipts.Lines", func(db *gorm.DB) *gorm.DB { return db.Order("id asc") }).
+		First(&po, poID).Error
+	return po, err
+}
+
+// purchaseOrderStatusFor menentukan status PO yang sudah dikirim berdasarkan jumlah yang diterima tiap baris
+func purchaseOrderStatusFor(lines []models.PurchaseOrderLine) string {
+	received := false
+	complete := true
+	for _, line := range lines {
+		if line.JumlahDiterima > 0 {
+			received = true
+		}
+		if line.JumlahDiterima < line.Jumlah {
+			complete = false
+		}
+	}
+	switch {
+	case complete:
+		return models.PurchaseOrderStatusDiterima
+	case received:
+		return models.PurchaseOrderStatusDiterimaSebagian
+	}
+	return models.PurchaseOrderStatusDikirim
+}
+
+// CreatePurchaseOrder membuat purchase order baru berstatus draft
+func CreatePurchaseOrder(c *fiber.Ctx) error {
+	req := new(dto.SavePurchaseOrderRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	if err := validate.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	var po models.PurchaseOrder
+	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := ensureActiveSupplier(tx, req.SupplierID); err != nil {
+			return err
+		}
+		expected, err := parseOptionalDate(req.TanggalDiharapkan, "tanggal diharapkan")
+		if err != nil {
+			return err
+		}
+		lines, total, err := buildPurchaseOrderLines(tx, req.Lines)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		po = models.PurchaseOrder{
+			SupplierID:        req.SupplierID,
+			Status:            models.PurchaseOrderStatusDraft,
+			TanggalPesan:      now,
+			TanggalDiharapkan: expected,
+			Total:             total,
+			Catatan:           req.Catatan,
+			CreatedByID:       c.Locals("user_id").(uint),
+			Lines:             lines,
+		}
+		if err := tx.Create(&po).Error; err != nil {
+			return err
+		}
+		// Nomor PO memakai ID seperti nomor tagihan
+		po.NoPO = fmt.Sprintf("PO-%s-%06d", now.Format("20060102"), po.ID)
+		return tx.Model(&po).Update("no_po", po.NoPO).Error
+	})
+	if errTx != nil {
+		return procurementErrorResponse(c, errTx, "Gagal membuat purchase order")
+	}
+
+	createdPO, _ := findPurchaseOrderDetail(po.ID)
+	return utils.SuccessResponse(c, fiber.StatusCreated, "Purchase order berhasil dibuat", createdPO)
+}
+
+// UpdatePurchaseOrder mengubah supplier, tanggal, catatan dan baris PO. Hanya PO berstatus draft yang dapat diubah.
+func UpdatePurchaseOrder(c *fiber.Ctx) error {
+	req := new(dto.SavePurchaseOrderRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	if err := validate.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	var poID uint
+	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		po, err := lockPurchaseOrder(tx, c)
+		if err != nil {
+			return err
+		}
+		poID = po.ID
+		if po.Status != models.PurchaseOrderStatusDraft {
+			return &procurementError{fiber.StatusConflict, "Hanya purchase order berstatus draft yang dapat diubah"}
+		}
+		if err := ensureActiveSupplier(tx, req.SupplierID); err != nil {
+			return err
+		}
+		expected, err := parseOptionalDate(req.TanggalDiharapkan, "tanggal diharapkan")
+		if err != nil {
+			return err
+		}
+		lines, total, err := buildPurchaseOrderLines(tx, req.Lines)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Where("purchase_order_id = ?", po.ID).Delete(&models.PurchaseOrderLine{}).Error; err != nil {
+			return err
+		}
+		for i := range lines {
+			lines[i].PurchaseOrderID = po.ID
+		}
+		if err := tx.Create(&lines).Error; err != nil {
+			return err
+		}
+		return tx.Model(&po).Updates(map[string]interface{}{
+			"supplier_id":        req.SupplierID,
+			"tanggal_diharapkan": expected,
+			"catatan":            req.Catatan,
+			"total":              total,
+		}).Error
+	})
+	if errTx != nil {
+		return procurementErrorResponse(c, errTx, "Gagal memperbarui purchase order")
+	}
+
+	po, _ := findPurchaseOrderDetail(poID)
+	return utils.SuccessResponse(c, fiber.StatusOK, "Purchase order berhasil diperbarui", po)
+}
+
+// SendPurchaseOrder menandai PO draft sebagai sudah dikirim ke supplier; sejak itu barang dapat diterima
+func SendPurchaseOrder(c *fiber.Ctx) error {
+	var poID uint
+	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		po, err := lockPurchaseOrder(tx, c)
+		if err != nil {
+			return err
+		}
+		poID = po.ID
+		if po.Status != models.PurchaseOrderStatusDraft {
+			return &procurementError{fiber.StatusConflict, "Hanya purchase order berstatus draft yang dapat dikirim"}
+		}
+		userID := c.Locals("user_id").(uint)
+		now := time.Now()
+		return tx.Model(&po).Updates(map[string]interface{}{
+			"status":     models.PurchaseOrderStatusDikirim,
+			"sent_at":    &now,
+			"sent_by_id": &userID,
+		}).Error
+	})
+	if errTx != nil {
+		return procurementErrorResponse(c, errTx, "Gagal mengirim purchase order")
+	}
+
+	po, _ := findPurchaseOrderDetail(poID)
+	return utils.SuccessResponse(c, fiber.StatusOK, "Purchase order berhasil dikirim", po)
+}
+
+// CancelPurchaseOrder membatalkan PO yang belum diterima penuh. Barang yang sudah diterima tetap tercatat di stok;
+// sisa pesanan tidak lagi dihitung sebagai outstanding.
+func CancelPurchaseOrder(c *fiber.Ctx) error {
+	req := new(dto.CancelPurchaseOrderRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	if err := validate.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	var poID uint
+	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		po, err := lockPurchaseOrder(tx, c)
+		if err != nil {
+			return err
+		}
+		poID = po.ID
+		if po.Status == models.PurchaseOrderStatusDiterima || po.Status == models.PurchaseOrderStatusDibatalkan {
+			return &procurementError{fiber.StatusConflict, fmt.Sprintf("Purchase order berstatus %s tidak dapat dibatalkan", po.Status)}
+		}
+		return tx.Model(&po).Updates(map[string]interface{}{
+			"status":  models.PurchaseOrderStatusDibatalkan,
+			"catatan": joinNote(po.Catatan, "Dibatalkan: "+req.Alasan),
+		}).Error
+	})
+	if errTx != nil {
+		return procurementErrorResponse(c, errTx, "Gagal membatalkan purchase order")
+	}
+
+	po, _ := findPurchaseOrderDetail(poID)
+	return utils.SuccessResponse(c, fiber.StatusOK, "Purchase order berhasil dibatalkan", po)
+}
+
+// GetPurchaseOrders mengambil daftar PO dengan pagination. Filter: status, supplierId, startDate, endDate.
+func GetPurchaseOrders(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "10"))
+	if limit <= 0 {
+		limit = 10
+	}
+	if page <= 0 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+
+	query := database.DB.Model(&models.PurchaseOrder{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if supplierID := c.Query("supplierId"); supplierID != "" {
+		query = query.Where("supplier_id = ?", supplierID)
+	}
+	if startDate := c.Query("startDate"); startDate != "" {
+		query = query.Where("tanggal_pesan::date >= ?", startDate)
+	}
+	if endDate := c.Query("endDate"); endDate != "" {
+		query = query.Where("tanggal_pesan::date <= ?", endDate)
+	}
+	query = query.Session(&gorm.Session{})
+
+	var totalRecords int64
+	if err := query.Count(&totalRecords).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal menghitung total purchase order", err.Error())
+	}
+
+	purchaseOrders := []models.PurchaseOrder{}
+	if err := query.Preload("Supplier", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Order("tanggal_pesan DESC, id DESC").Offset(offset).Limit(limit).Find(&purchaseOrders).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal mengambil data purchase order", err.Error())
+	}
+
+	paginationData := fiber.Map{
+		"currentPage":  page,
+		"totalPages":   int(math.Ceil(float64(totalRecords) / float64(limit))),
+		"totalRecords": totalRecords,
+		"pageSize":     limit,
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success":    true,
+		"data":       purchaseOrders,
+		"pagination": paginationData,
+		"message":    "Data purchase order berhasil diambil",
+	})
+}
+
+// GetPurchaseOrderByID mengambil detail PO beserta baris dan riwayat penerimaannya
+func GetPurchaseOrderByID(c *fiber.Ctx) error {
+	poID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Purchase order ID tidak valid")
+	}
+
+	po, err := findPurchaseOrderDetail(uint(poID))
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "Purchase order tidak ditemukan")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Purchase order ditemukan", po)
+}
+
+// receiveIntoBatch menyiapkan batch tujuan penerimaan: batch baru dibuat, batch yang sudah ada ditambah sisanya.
+// Master obat dikunci lebih dulu agar urutan kunci sama dengan stockLedger.post.
+func receiveIntoBatch(tx *gorm.DB, medicationID uint, line dto.GoodsReceiptLineRequest, expiry *time.Time, price float64) (*uint, error) {
+	if line.NomorBatch == "" {
+		return nil, nil
+	}
+	if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.MedicationCatalog{}, medicationID).Error; err != nil {
+		return nil, err
+	}
+
+	var batch models.StockBatch
+	err := tx.Unscoped().Where("medication_catalog_id = ? AND nomor_batch = ?", medicationID, line.NomorBatch).First(&batch).Error
+	if err == nil {
+		if batch.DeletedAt.Valid {
+			return nil, &procurementError{fiber.StatusConflict, fmt.Sprintf("Batch %s untuk obat ini sudah dihapus", line.NomorBatch)}
+		}
+		if expiry != nil && (batch.TanggalKedaluwarsa == nil || batch.TanggalKedaluwarsa.Format("2006-01-02") != expiry.Format("2006-01-02")) {
+			return nil, &procurementError{fiber.StatusConflict, fmt.Sprintf("Batch %s sudah tercatat dengan tanggal kedaluwarsa berbeda", line.NomorBatch)}
+		}
+		return &batch.ID, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	batch = models.StockBatch{
+		MedicationCatalogID: medicationID,
+		NomorBatch:          line.NomorBatch,
+		TanggalKedaluwarsa:  expiry,
+		JumlahAwal:          line.Jumlah,
+		HargaBeli:           price,
+	}
+	if err := tx.Create(&batch).Error; err != nil {
+		return nil, err
+	}
+	return &batch.ID, nil
+}
+
+// CreateGoodsReceipt mencatat penerimaan barang atas PO yang sudah dikirim. Setiap baris menambah stok lewat
+// mutasi masuk, memperbarui HargaBeli master obat dengan harga terakhir, lalu status PO dihitung ulang.
+func CreateGoodsReceipt(c *fiber.Ctx) error {
+	req := new(dto.CreateGoodsReceiptRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	if err := validate.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	ledger := newStockLedger(c)
+	var poID uint
+	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		po, err := lockPurchaseOrder(tx, c)
+		if err != nil {
+			return err
+		}
+		poID = po.ID
+		if po.Status != models.PurchaseOrderStatusDikirim && po.Status != models.PurchaseOrderStatusDiterimaSebagian {
+			return &procurementError{fiber.StatusConflict, fmt.Sprintf("Barang tidak dapat diterima untuk purchase order berstatus %s", po.Status)}
+		}
+		if err := tx.Where("purchase_order_id = ?", po.ID).Order("id asc").Find(&po.Lines).Error; err != nil {
+			return err
+		}
+		linesByID := make(map[uint]*models.PurchaseOrderLine, len(po.Lines))
+		for i := range po.Lines {
+			linesByID[po.Lines[i].ID] = &po.Lines[i]
+		}
+
+		now := time.Now()
+		receipt := models.GoodsReceipt{
+			PurchaseOrderID: po.ID,
+			TanggalTerima:   now,
+			NomorFaktur:     req.NomorFaktur,
+			Catatan:         req.Catatan,
+			ReceivedByID:    ledger.userID,
+			ReceivedByName:  ledger.userName,
+		}
+		if err := tx.Create(&receipt).Error; err != nil {
+			return err
+		}
+		receipt.NoPenerimaan = fmt.Sprintf("GR-%s-%06d", now.Format("20060102"), receipt.ID)
+		if err := tx.Model(&receipt).Update("no_penerimaan", receipt.NoPenerimaan).Error; err != nil {
+			return err
+		}
+
+		for _, r := range req.Lines {
+			line, ok := linesByID[r.PurchaseOrderLineID]
+			if !ok {
+				return &procurementError{fiber.StatusBadRequest, fmt.Sprintf("Baris PO %d bukan bagian dari purchase order ini", r.PurchaseOrderLineID)}
+			}
+			if outstanding := line.Jumlah - line.JumlahDiterima; r.Jumlah > outstanding {
+				return &procurementError{fiber.StatusConflict, fmt.Sprintf("Jumlah diterima untuk baris PO %d melebihi sisa pesanan (%d)", line.ID, outstanding)}
+			}
+			expiry, err := parseOptionalDate(r.TanggalKedaluwarsa, "tanggal kedaluwarsa")
+			if err != nil {
+				return err
+			}
+			price := line.HargaBeli
+			if r.HargaBeli != nil {
+				price = *r.HargaBeli
+			}
+
+			batchID, err := receiveIntoBatch(tx, line.MedicationCatalogID, r, expiry, price)
+			if err != nil {
+				return err
+			}
+			movement, err := ledger.post(tx, models.StockMovement{
+				MedicationCatalogID: line.MedicationCatalogID,
+				StockBatchID:        batchID,
+				Type:                models.StockMovementMasuk,
+				Quantity:            r.Jumlah,
+				Reason:              fmt.Sprintf("Penerimaan %s atas %s", receipt.NoPenerimaan, po.NoPO),
+			}, false)
+			if err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Model(&models.MedicationCatalog{}).Where("id = ?", line.MedicationCatalogID).Update("harga_beli", price).Error; err != nil {
+				return err
+			}
+
+			line.JumlahDiterima += r.Jumlah
+			if err := tx.Model(line).Update("jumlah_diterima", line.JumlahDiterima).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(&models.GoodsReceiptLine{
+				GoodsReceiptID:      receipt.ID,
+				PurchaseOrderLineID: line.ID,
+				MedicationCatalogID: line.MedicationCatalogID,
+				Jumlah:              r.Jumlah,
+				HargaBeli:           price,
+				NomorBatch:          r.NomorBatch,
+				TanggalKedaluwarsa:  expiry,
+				StockBatchID:        batchID,
+				StockMovementID:     movement.ID,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&po).Update("status", purchaseOrderStatusFor(po.Lines)).Error
+	})
+	if errTx != nil {
+		return procurementErrorResponse(c, errTx, "Gagal mencatat penerimaan barang")
+	}
+
+	po, _ := findPurchaseOrderDetail(poID)
+	return utils.SuccessResponse(c, fiber.StatusCreated, "Penerimaan barang berhasil dicatat", po)
+}
+
+// GetOutstandingPurchaseOrderReport merekap sisa pesanan yang belum diterima dari PO yang sudah dikirim,
+// dikelompokkan per supplier. Filter opsional: supplierId.
+func GetOutstandingPurchaseOrderReport(c *fiber.Ctx) error {
+	query := database.DB.Where("status IN ?", []string{models.PurchaseOrderStatusDikirim, models.PurchaseOrderStatusDiterimaSebagian})
+	if supplierID := c.Query("supplierId"); supplierID != "" {
+		query = query.Where("supplier_id = ?", supplierID)
+	}
+
+	var purchaseOrders []models.PurchaseOrder
+	if err := query.Preload("Supplier", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Where("jumlah_diterima < jumlah").Order("id asc") }).
+		Preload("Lines.MedicationCatalog", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Order("tanggal_pesan ASC, id ASC").Find(&purchaseOrders).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal mengambil purchase order outstanding", err.Error())
+	}
+
+	reports := []dto.OutstandingSupplierReport{}
+	indexBySupplier := make(map[uint]int)
+	for _, po := range purchaseOrders {
+		idx, ok := indexBySupplier[po.SupplierID]
+		if !ok {
+			reports = append(reports, dto.OutstandingSupplierReport{
+				SupplierID:   po.SupplierID,
+				SupplierKode: po.Supplier.Kode,
+				SupplierNama: po.Supplier.Nama,
+				Lines:        []dto.OutstandingPurchaseOrderLine{},
+			})
+			idx = len(reports) - 1
+			indexBySupplier[po.SupplierID] = idx
+		}
+		report := &reports[idx]
+		report.JumlahPO++
+		for _, line := range po.Lines {
+			sisa := line.Jumlah - line.JumlahDiterima
+			nilaiSisa := roundCurrency(float64(sisa) * line.HargaBeli)
+			report.Lines = append(report.Lines, dto.OutstandingPurchaseOrderLine{
+				PurchaseOrderID: po.ID,
+				NoPO:            po.NoPO,
+				Status:          po.Status,
+				MedicationCode:  line.MedicationCatalog.Kode,
+				MedicationName:  line.MedicationCatalog.Nama,
+				Jumlah:          line.Jumlah,
+				JumlahDiterima:  line.JumlahDiterima,
+				JumlahSisa:      sisa,
+				HargaBeli:       line.HargaBeli,
+				NilaiSisa:       nilaiSisa,
+			})
+			report.TotalNilaiSisa = roundCurrency(report.TotalNilaiSisa + nilaiSisa)
+		}
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Laporan purchase order outstanding berhasil diambil", reports)
+}
@@ -0,0 +1,495 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/database"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/dto"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/events"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/middleware"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/models"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// queueError membawa status HTTP dan pesan untuk pelanggaran aturan antrian di dalam transaksi
+type queueError struct {
+	Status  int
+	Message string
+}
+
+func (e *queueError) Error() string { return e.Message }
+
+// activeQueueStatuses adalah status antrian yang belum selesai/batal
+var activeQueueStatuses = []string{models.QueueStatusMenunggu, models.QueueStatusDipanggil, models.QueueStatusDiperiksa}
+
+// mapQueueEntryToResponse mengubah models.QueueEntry menjadi dto.QueueEntryResponse
+func mapQueueEntryToResponse(q models.QueueEntry) dto.QueueEntryResponse {
+	return dto.QueueEntryResponse{
+		ID:              q.ID,
+		Tanggal:         q.Tanggal.Format("2006-01-02"),
+		DoctorID:        q.DoctorID,
+		DoctorName:      q.DoctorName,
+		NomorAntrian:    q.NomorAntrian,
+		NoTiket:         fmt.Sprintf("%03d", q.NomorAntrian),
+		Urutan:          q.Urutan,
+		PatientID:       q.PatientID,
+		PatientName:     q.Patient.NamaLengkap,
+		NoRM:            q.Patient.NoRM,
+		ReservationID:   q.ReservationID,
+		Sumber:          q.Sumber,
+		Keluhan:         q.Keluhan,
+		Catatan:         q.Catatan,
+		Status:          q.Status,
+		JumlahPanggilan: q.JumlahPanggilan,
+		CheckInAt:       q.CheckInAt,
+		CalledAt:        q.CalledAt,
+		StartedAt:       q.StartedAt,
+		FinishedAt:      q.FinishedAt,
+		MedicalRecordID: q.MedicalRecordID,
+	}
+}
+
+// queueToday mengembalikan tanggal hari ini (tanpa jam) dalam format yang sama dengan kolom date
+func queueToday() time.Time {
+	today, _ := time.Parse("2006-01-02", time.Now().Format("2006-01-02"))
+	return today
+}
+
+// canManageQueue memeriksa cakupan akses: tanpa patient:register_visit, dokter hanya boleh
+// mengelola antrian miliknya sendiri.
+func canManageQueue(c *fiber.Ctx, doctorID uint) bool {
+	if middleware.HasPermission(c, "patient:register_visit") {
+		return true
+	}
+	userID, _ := c.Locals("user_id").(uint)
+	return doctorID == userID
+}
+
+// nextQueuePosition mengunci baris dokter lalu mengembalikan nomor tiket dan urutan berikutnya
+// untuk dokter tersebut pada tanggal yang diberikan. Harus dipanggil di dalam transaksi.
+func nextQueuePosition(tx *gorm.DB, doctorID uint, tanggal time.Time) (nomor int, urutan int, err error) {
+	var doctor models.User
+	if err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&doctor, doctorID).Error; err != nil {
+		return 0, 0, err
+	}
+
+	var result struct {
+		MaxNomor  int
+		MaxUrutan int
+	}
+	err = tx.Unscoped().Model(&models.QueueEntry{}).
+		Select("COALESCE(MAX(nomor_antrian), 0) AS max_nomor, COALESCE(MAX(urutan), 0) AS max_urutan").
+		Where("doctor_id = ? AND tanggal = ?", doctorID, tanggal.Format("2006-01-02")).
+		Scan(&result).Error
+	return result.MaxNomor + 1, result.MaxUrutan + 1, err
+}
+
+// queueErrorResponse mengubah error dari transaksi antrian menjadi respons HTTP
+func queueErrorResponse(c *fiber.Ctx, err error, fallbackMessage string) error {
+	var qErr *queueError
+	if errors.As(err, &qErr) {
+		return utils.ErrorResponse(c, qErr.Status, qErr.Message)
+	}
+	if err == gorm.ErrRecordNotFound {
+		return utils.ErrorResponse(c, fiber.StatusNotFound, "Data antrian tidak ditemukan")
+	}
+	return utils.ErrorResponse(c, fiber.StatusInternalServerError, fallbackMessage, err.Error())
+}
+
+// CheckInQueue mendaftarkan pasien ke antrian hari ini, dari reservasi atau sebagai walk-in
+func CheckInQueue(c *fiber.Ctx) error {
+	req := new(dto.CheckInQueueRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	if err := validate.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	today := queueToday()
+	userID, _ := c.Locals("user_id").(uint)
+	entry := models.QueueEntry{
+		Tanggal:     today,
+		Keluhan:     req.Keluhan,
+		Catatan:     req.Catatan,
+		Status:      models.QueueStatusMenunggu,
+		CheckInAt:   time.Now(),
+		CheckInByID: userID,
+	}
+
+	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		if req.ReservationID != 0 {
+			var reservation models.Reservation
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&reservation, req.ReservationID).Error; err != nil {
+				if err == gorm.ErrRecordNotFound {
+					return &queueError{fiber.StatusNotFound, "Reservasi tidak ditemukan"}
+				}
+				return err
+			}
+			if reservation.Tanggal.Format("2006-01-02") != today.Format("2006-01-02") {
+				return &queueError{fiber.StatusConflict, fmt.Sprintf("Reservasi dijadwalkan pada %s, bukan hari ini.", reservation.Tanggal.Format("2006-01-02"))}
+			}
+			switch reservation.Status {
+			case models.ReservationStatusDijadwalkan, models.ReservationStatusDikonfirmasi, models.ReservationStatusHadir:
+			default:
+				return &queueError{fiber.StatusConflict, fmt.Sprintf("Reservasi berstatus '%s' tidak dapat di-check-in.", reservation.Status)}
+			}
+
+			var existing int64
+			if err := tx.Model(&models.QueueEntry{}).
+				Where("reservation_id = ? AND status IN ?", reservation.ID, activeQueueStatuses).
+				Count(&existing).Error; err != nil {
+				return err
+			}
+			if existing > 0 {
+				return &queueError{fiber.StatusConflict, "Reservasi ini sudah masuk antrian."}
+			}
+
+			entry.ReservationID = &reservation.ID
+			entry.Sumber = models.QueueSourceReservasi
+			entry.PatientID = reservation.PatientID
+			entry.DoctorID = reservation.DoctorID
+			entry.DoctorName = reservation.DoctorName
+			if entry.Keluhan == "" {
+				entry.Keluhan = reservation.Keluhan
+			}
+
+			if reservation.Status != models.ReservationStatusHadir {
+				if err := tx.Model(&reservation).Update("status", models.ReservationStatusHadir).Error; err != nil {
+					return err
+				}
+			}
+		} else {
+			var patient models.Patient
+			if err := tx.First(&patient, req.PatientID).Error; err != nil {
+				if err == gorm.ErrRecordNotFound {
+					return &queueError{fiber.StatusNotFound, "Pasien tidak ditemukan"}
+				}
+				return err
+			}
+			doctor, err := findDoctor(tx, req.DoctorID)
+			if err != nil {
+				if err == gorm.ErrRecordNotFound {
+					return &queueError{fiber.StatusBadRequest, "Dokter tidak ditemukan"}
+				}
+				return err
+			}
+			entry.Sumber = models.QueueSourceWalkIn
+			entry.PatientID = patient.ID
+			entry.DoctorID = doctor.ID
+			entry.DoctorName = doctor.NamaLengkap
+		}
+
+		nomor, urutan, err := nextQueuePosition(tx, entry.DoctorID, today)
+		if err != nil {
+			return err
+		}
+
+		// Pasien yang sama tidak boleh mengantri dua kali pada dokter yang sama di hari yang sama
+		var duplicate int64
+		if err := tx.Model(&models.QueueEntry{}).
+			Where("patient_id = ? AND doctor_id = ? AND tanggal = ? AND status IN ?", entry.PatientID, entry.DoctorID, today.Format("2006-01-02"), activeQueueStatuses).
+			Count(&duplicate).Error; err != nil {
+			return err
+		}
+		if duplicate > 0 {
+			return &queueError{fiber.StatusConflict, "Pasien sudah berada di antrian dokter ini hari ini."}
+		}
+
+		entry.NomorAntrian = nomor
+		entry.Urutan = urutan
+		return tx.Create(&entry).Error
+	})
+	if errTx != nil {
+		return queueErrorResponse(c, errTx, "Gagal melakukan check-in antrian")
+	}
+
+	database.DB.Preload("Patient").First(&entry, entry.ID)
+	response := mapQueueEntryToResponse(entry)
+	events.Publish(events.TypeQueueCheckedIn, entry.DoctorID, response)
+	return utils.SuccessResponse(c, fiber.StatusCreated, fmt.Sprintf("Check-in berhasil, nomor antrian %03d", entry.NomorAntrian), response)
+}
+
+// GetQueue mengambil antrian pada satu tanggal (default hari ini), dapat difilter per dokter dan status
+func GetQueue(c *fiber.Ctx) error {
+	tanggal := c.Query("tanggal", queueToday().Format("2006-01-02"))
+	if _, err := time.Parse("2006-01-02", tanggal); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Format tanggal tidak valid (YYYY-MM-DD)")
+	}
+
+	query := database.DB.Preload("Patient").Where("tanggal = ?", tanggal)
+
+	if middleware.HasPermission(c, "patient:register_visit") {
+		if doctorIDParam := c.Query("doctorId"); doctorIDParam != "" {
+			doctorID, err := strconv.ParseUint(doctorIDParam, 10, 32)
+			if err != nil {
+				return utils.ErrorResponse(c, fiber.StatusBadRequest, "Doctor ID tidak valid")
+			}
+			query = query.Where("doctor_id = ?", uint(doctorID))
+		}
+	} else {
+		// Dokter hanya melihat antriannya sendiri
+		query = query.Where("doctor_id = ?", c.Locals("user_id").(uint))
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status IN ?", strings.Split(status, ","))
+	}
+
+	var entries []models.QueueEntry
+	if err := query.Order("doctor_id ASC, urutan ASC").Find(&entries).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal mengambil data antrian", err.Error())
+	}
+
+	responses := []dto.QueueEntryResponse{}
+	for _, entry := range entries {
+		responses = append(responses, mapQueueEntryToResponse(entry))
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Data antrian berhasil diambil", responses)
+}
+
+// GetQueueEntryByID mengambil detail satu nomor antrian
+func GetQueueEntryByID(c *fiber.Ctx) error {
+	entryID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "ID antrian tidak valid")
+	}
+
+	var entry models.QueueEntry
+	if err := database.DB.Preload("Patient").First(&entry, uint(entryID)).Error; err != nil {
+		return queueErrorResponse(c, err, "Kesalahan server database")
+	}
+	if !canManageQueue(c, entry.DoctorID) {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "Anda tidak memiliki izin untuk antrian dokter ini")
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Data antrian ditemukan", mapQueueEntryToResponse(entry))
+}
+
+// CallNextQueue memanggil pasien menunggu dengan urutan terkecil pada antrian dokter hari ini
+func CallNextQueue(c *fiber.Ctx) error {
+	req := new(dto.CallNextQueueRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	if err := validate.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+	if !canManageQueue(c, req.DoctorID) {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "Anda tidak memiliki izin untuk antrian dokter ini")
+	}
+
+	today := queueToday().Format("2006-01-02")
+	var entry models.QueueEntry
+	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Kunci baris dokter agar dua pemanggilan bersamaan tidak memanggil pasien yang sama
+		var doctor models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&doctor, req.DoctorID).Error; err != nil {
+			return err
+		}
+
+		var called int64
+		if err := tx.Model(&models.QueueEntry{}).
+			Where("doctor_id = ? AND tanggal = ? AND status = ?", req.DoctorID, today, models.QueueStatusDipanggil).
+			Count(&called).Error; err != nil {
+			return err
+		}
+		if called > 0 {
+			return &queueError{fiber.StatusConflict, "Masih ada pasien yang dipanggil. Mulai pemeriksaan, lewati, atau batalkan terlebih dahulu."}
+		}
+
+		if err := tx.Where("doctor_id = ? AND tanggal = ? AND status = ?", req.DoctorID, today, models.QueueStatusMenunggu).
+			Order("urutan ASC").First(&entry).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return &queueError{fiber.StatusNotFound, "Tidak ada pasien yang menunggu."}
+			}
+			return err
+		}
+
+		now := time.Now()
+		entry.Status = models.QueueStatusDipanggil
+		entry.CalledAt = &now
+		entry.JumlahPanggilan++
+		return tx.Save(&entry).Error
+	})
+	if errTx != nil {
+		return queueErrorResponse(c, errTx, "Gagal memanggil antrian berikutnya")
+	}
+
+	database.DB.Preload("Patient").First(&entry, entry.ID)
+	response := mapQueueEntryToResponse(entry)
+	events.Publish(events.TypeQueueCalled, entry.DoctorID, response)
+	return utils.SuccessResponse(c, fiber.StatusOK, fmt.Sprintf("Memanggil nomor antrian %03d", entry.NomorAntrian), response)
+}
+
+// updateQueueEntry mengunci satu entri antrian berdasarkan parameter :id, memastikan statusnya termasuk
+// allowedStatuses, lalu menjalankan apply di dalam transaksi yang sama.
+func updateQueueEntry(c *fiber.Ctx, allowedStatuses []string, apply func(tx *gorm.DB, entry *models.QueueEntry) error) (models.QueueEntry, error) {
+	var entry models.QueueEntry
+	entryID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return entry, &queueError{fiber.StatusBadRequest, "ID antrian tidak valid"}
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&entry, uint(entryID)).Error; err != nil {
+			return err
+		}
+		if !canManageQueue(c, entry.DoctorID) {
+			return &queueError{fiber.StatusForbidden, "Anda tidak memiliki izin untuk antrian dokter ini"}
+		}
+
+		allowed := false
+		for _, status := range allowedStatuses {
+			if entry.Status == status {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return &queueError{fiber.StatusConflict, fmt.Sprintf("Aksi tidak dapat dilakukan pada antrian berstatus '%s'.", entry.Status)}
+		}
+
+		if err := apply(tx, &entry); err != nil {
+			return err
+		}
+		return tx.Omit("Patient", "Reservation", "MedicalRecord").Save(&entry).Error
+	})
+	if err != nil {
+		return entry, err
+	}
+
+	database.DB.Preload("Patient").First(&entry, entry.ID)
+	return entry, nil
+}
+
+// RecallQueue memanggil ulang pasien yang sedang dipanggil
+func RecallQueue(c *fiber.Ctx) error {
+	entry, err := updateQueueEntry(c, []string{models.QueueStatusDipanggil}, func(tx *gorm.DB, entry *models.QueueEntry) error {
+		now := time.Now()
+		entry.CalledAt = &now
+		entry.JumlahPanggilan++
+		return nil
+	})
+	if err != nil {
+		return queueErrorResponse(c, err, "Gagal memanggil ulang antrian")
+	}
+	response := mapQueueEntryToResponse(entry)
+	events.Publish(events.TypeQueueCalled, entry.DoctorID, response)
+	return utils.SuccessResponse(c, fiber.StatusOK, fmt.Sprintf("Memanggil ulang nomor antrian %03d", entry.NomorAntrian), response)
+}
+
+// SkipQueue melewati pasien yang dipanggil tetapi belum hadir; pasien kembali menunggu di urutan paling belakang
+func SkipQueue(c *fiber.Ctx) error {
+	entry, err := updateQueueEntry(c, []string{models.QueueStatusDipanggil}, func(tx *gorm.DB, entry *models.QueueEntry) error {
+		_, urutan, err := nextQueuePosition(tx, entry.DoctorID, entry.Tanggal)
+		if err != nil {
+			return err
+		}
+		entry.Status = models.QueueStatusMenunggu
+		entry.Urutan = urutan
+		return nil
+	})
+	if err != nil {
+		return queueErrorResponse(c, err, "Gagal melewati antrian")
+	}
+	response := mapQueueEntryToResponse(entry)
+	events.Publish(events.TypeQueueUpdated, entry.DoctorID, response)
+	return utils.SuccessResponse(c, fiber.StatusOK, fmt.Sprintf("Nomor antrian %03d dilewati", entry.NomorAntrian), response)
+}
+
+// StartQueueExamination memulai pemeriksaan pasien yang dipanggil dan membuat EMR yang terhubung
+func StartQueueExamination(c *fiber.Ctx) error {
+	version := emrVersionAuthor(c, "")
+	entry, err := updateQueueEntry(c, []string{models.QueueStatusDipanggil}, func(tx *gorm.DB, entry *models.QueueEntry) error {
+		now := time.Now()
+		visitID, err := database.VisitNumbers.Next(tx, now)
+		if err != nil {
+			return err
+		}
+		emr := models.MedicalRecord{
+			VisitID:       visitID,
+			PatientID:     entry.PatientID,
+			DoctorID:      entry.DoctorID,
+			DoctorName:    entry.DoctorName,
+			ExamDate:      now,
+			VisitType:     entry.Sumber,
+			Complaint:     entry.Keluhan,
+			BillingStatus: models.InvoiceStatusBelumLunas,
+		}
+		if err := tx.Create(&emr).Error; err != nil {
+			return err
+		}
+		if err := recordEMRVersion(tx, &emr, version); err != nil {
+			return err
+		}
+		entry.Status = models.QueueStatusDiperiksa
+		entry.StartedAt = &now
+		entry.MedicalRecordID = &emr.ID
+		return nil
+	})
+	if err != nil {
+		return queueErrorResponse(c, err, "Gagal memulai pemeriksaan")
+	}
+	response := mapQueueEntryToResponse(entry)
+	events.Publish(events.TypeQueueUpdated, entry.DoctorID, response)
+	return utils.SuccessResponse(c, fiber.StatusOK, "Pemeriksaan dimulai, EMR berhasil dibuat", response)
+}
+
+// FinishQueue menandai pemeriksaan selesai; reservasi terkait ikut ditandai Selesai
+func FinishQueue(c *fiber.Ctx) error {
+	entry, err := updateQueueEntry(c, []string{models.QueueStatusDiperiksa}, func(tx *gorm.DB, entry *models.QueueEntry) error {
+		now := time.Now()
+		entry.Status = models.QueueStatusSelesai
+		entry.FinishedAt = &now
+		if entry.ReservationID != nil {
+			return tx.Model(&models.Reservation{}).Where("id = ?", *entry.ReservationID).
+				Update("status", models.ReservationStatusSelesai).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return queueErrorResponse(c, err, "Gagal menyelesaikan antrian")
+	}
+	response := mapQueueEntryToResponse(entry)
+	events.Publish(events.TypeEMRFinished, entry.DoctorID, response)
+	return utils.SuccessResponse(c, fiber.StatusOK, "Pemeriksaan selesai", response)
+}
+
+// CancelQueue membatalkan antrian yang belum diperiksa
+func CancelQueue(c *fiber.Ctx) error {
+	req := new(dto.CancelQueueRequest)
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(req); err != nil {
+			return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+		}
+		if err := validate.Struct(req); err != nil {
+			return utils.ValidationErrorResponse(c, err.Error())
+		}
+	}
+
+	entry, err := updateQueueEntry(c, []string{models.QueueStatusMenunggu, models.QueueStatusDipanggil}, func(tx *gorm.DB, entry *models.QueueEntry) error {
+		now := time.Now()
+		entry.Status = models.QueueStatusBatal
+		entry.FinishedAt = &now
+		if req.Alasan != "" {
+			if entry.Catatan != "" {
+				entry.Catatan += "\n"
+			}
+			entry.Catatan += "Alasan pembatalan: " + req.Alasan
+		}
+		return nil
+	})
+	if err != nil {
+		return queueErrorResponse(c, err, "Gagal membatalkan antrian")
+	}
+	response := mapQueueEntryToResponse(entry)
+	events.Publish(events.TypeQueueUpdated, entry.DoctorID, response)
+	return utils.SuccessResponse(c, fiber.StatusOK, "Antrian berhasil dibatalkan", response)
+}
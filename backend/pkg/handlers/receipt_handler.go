@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/config"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/database"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/models"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/pdf"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// clinicInfo mengambil identitas klinik dari konfigurasi untuk kop dokumen cetak
+func clinicInfo() pdf.ClinicInfo {
+	cfg := config.AppConfig
+	return pdf.ClinicInfo{Name: cfg.ClinicName, Address: cfg.ClinicAddress, Phone: cfg.ClinicPhone}
+}
+
+// sendPDF mengirim isi buffer sebagai file PDF yang ditampilkan langsung di browser
+func sendPDF(c *fiber.Ctx, filename string, buf *bytes.Buffer) error {
+	c.Set(fiber.HeaderContentType, "application/pdf")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`inline; filename="%s"`, filename))
+	return c.Status(fiber.StatusOK).Send(buf.Bytes())
+}
+
+// findInvoiceForPrint mengambil tagihan beserta semua relasi yang dicetak
+func findInvoiceForPrint(db *gorm.DB) (models.Invoice, error) {
+	var invoice models.Invoice
+	err := db.Preload("Patient").
+		Preload("MedicalRecord").
+		Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("id asc") }).
+		Preload("Payments", func(db *gorm.DB) *gorm.DB { return db.Order("id asc") }).
+		First(&invoice).Error
+	return invoice, err
+}
+
+// PrintInvoice membuat PDF invoice berdasarkan ID tagihan
+func PrintInvoice(c *fiber.Ctx) error {
+	invoiceID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Invoice ID tidak valid")
+	}
+
+	invoice, err := findInvoiceForPrint(database.DB.Where("id = ?", uint(invoiceID)))
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "Tagihan tidak ditemukan")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
+	}
+	return renderInvoicePDF(c, invoice)
+}
+
+// PrintInvoiceByEMR membuat PDF invoice untuk sebuah rekam medis (EMR)
+func PrintInvoiceByEMR(c *fiber.Ctx) error {
+	emrID, err := strconv.ParseUint(c.Params("emrId"), 10, 32)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "EMR ID tidak valid")
+	}
+
+	invoice, err := findInvoiceForPrint(database.DB.Where("medical_record_id = ?", uint(emrID)))
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "Tagihan untuk EMR ini belum dibuat")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
+	}
+	return renderInvoicePDF(c, invoice)
+}
+
+func renderInvoicePDF(c *fiber.Ctx, invoice models.Invoice) error {
+	var buf bytes.Buffer
+	if err := pdf.RenderInvoice(&buf, clinicInfo(), invoice); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal membuat PDF invoice", err.Error())
+	}
+	return sendPDF(c, invoice.NoInvoice+".pdf", &buf)
+}
+
+// PrintPaymentReceipt membuat PDF kwitansi untuk satu pembayaran.
+// Pembayaran lama yang belum bernomor akan diberi nomor kwitansi saat pertama kali dicetak.
+func PrintPaymentReceipt(c *fiber.Ctx) error {
+	paymentID, err := strconv.ParseUint(c.Params("paymentId"), 10, 32)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Payment ID tidak valid")
+	}
+
+	var payment models.Payment
+	if err := database.DB.First(&payment, uint(paymentID)).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "Pembayaran tidak ditemukan")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
+	}
+
+	if payment.NoKwitansi == "" {
+		noKwitansi, err := nextReceiptNumber(database.DB)
+		if err != nil {
+			return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal membuat nomor kwitansi", err.Error())
+		}
+		result := database.DB.Model(&payment).Where("no_kwitansi IS NULL OR no_kwitansi = ''").Update("no_kwitansi", noKwitansi)
+		if result.Error != nil {
+			return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal menyimpan nomor kwitansi", result.Error.Error())
+		}
+		if result.RowsAffected == 0 { // Sudah diberi nomor oleh request lain
+			database.DB.First(&payment, payment.ID)
+		} else {
+			payment.NoKwitansi = noKwitansi
+		}
+	}
+
+	invoice, err := findInvoiceForPrint(database.DB.Where("id = ?", payment.InvoiceID))
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal mengambil data tagihan", err.Error())
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.RenderReceipt(&buf, clinicInfo(), invoice, payment); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal membuat PDF kwitansi", err.Error())
+	}
+	return sendPDF(c, payment.NoKwitansi+".pdf", &buf)
+}
@@ -0,0 +1,456 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/database"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/dto"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/events"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/middleware"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/models"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errReservationSlotTaken dikembalikan jika dokter sudah memiliki reservasi aktif pada slot yang sama
+var errReservationSlotTaken = errors.New("slot dokter sudah terisi")
+
+// reservationError adalah error bisnis pada perubahan reservasi beserta status HTTP-nya
+type reservationError struct {
+	Status  int
+	Message string
+}
+
+func (e *reservationError) Error() string { return e.Message }
+
+// mapReservationToResponse mengubah models.Reservation menjadi dto.ReservationResponse
+func mapReservationToResponse(r models.Reservation) dto.ReservationResponse {
+	return dto.ReservationResponse{
+		ID:             r.ID,
+		PatientID:      r.PatientID,
+		PatientName:    r.Patient.NamaLengkap,
+		NoRM:           r.Patient.NoRM,
+		DoctorID:       r.DoctorID,
+		DoctorName:     r.DoctorName,
+		Tanggal:        r.Tanggal.Format("2006-01-02"),
+		Waktu:          r.Waktu,
+		Keluhan:        r.Keluhan,
+		Catatan:        r.Catatan,
+		Status:         r.Status,
+		JenisKunjungan: r.JenisKunjungan,
+		CreatedAt:      r.CreatedAt,
+		UpdatedAt:      r.UpdatedAt,
+	}
+}
+
+// doctorPermission menandai pengguna sebagai dokter pemeriksa, tanpa bergantung pada kode role tertentu
+const doctorPermission = database.DoctorPermissionKode
+
+// findDoctor mengambil user aktif (belum dihapus) berdasarkan ID dan memastikan role-nya memiliki doctorPermission
+func findDoctor(db *gorm.DB, doctorID uint) (models.User, error) {
+	var doctor models.User
+	err := db.Preload("RoleDetail.Permissions", "kode = ?", doctorPermission).
+		Where("status = ?", "aktif").
+		First(&doctor, doctorID).Error
+	if err == nil && len(doctor.RoleDetail.Permissions) == 0 {
+		err = gorm.ErrRecordNotFound
+	}
+	return doctor, err
+}
+
+// lockDoctorAndCheckSlot mengunci baris dokter (agar booking paralel untuk dokter yang sama berurutan)
+// lalu memastikan belum ada reservasi aktif lain pada tanggal dan waktu yang sama.
+func lockDoctorAndCheckSlot(tx *gorm.DB, doctorID uint, tanggal time.Time, waktu string, excludeID uint) error {
+	var doctor models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&doctor, doctorID).Error; err != nil {
+		return err
+	}
+
+	var count int64
+	query := tx.Model(&models.Reservation{}).
+		Where("doctor_id = ? AND tanggal = ? AND waktu = ?", doctorID, tanggal.Format("2006-01-02"), waktu).
+		Where("status <> ?", models.ReservationStatusDibatalkan)
+	if excludeID != 0 {
+		query = query.Where("id <> ?", excludeID)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errReservationSlotTaken
+	}
+	return nil
+}
+
+// findReservation mengambil reservasi berdasarkan parameter :id beserta data pasiennya
+func findReservation(c *fiber.Ctx) (models.Reservation, error) {
+	var reservation models.Reservation
+	reservationID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return reservation, gorm.ErrRecordNotFound
+	}
+	err = database.DB.Preload("Patient").First(&reservation, uint(reservationID)).Error
+	return reservation, err
+}
+
+// canAccessReservation memeriksa cakupan akses: tanpa reservation:view_all, dokter hanya boleh
+// melihat reservasi miliknya sendiri.
+func canAccessReservation(c *fiber.Ctx, reservation models.Reservation) bool {
+	if middleware.HasPermission(c, "reservation:view_all") {
+		return true
+	}
+	userID, _ := c.Locals("user_id").(uint)
+	return reservation.DoctorID == userID
+}
+
+// CreateReservation membuat reservasi baru dan menolak double-booking slot dokter
+func CreateReservation(c *fiber.Ctx) error {
+	req := new(dto.CreateReservationRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	if err := validate.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	tanggal, err := time.Parse("2006-01-02", req.Tanggal)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Format tanggal tidak valid (YYYY-MM-DD)", err.Error())
+	}
+
+	var patient models.Patient
+	if err := database.DB.First(&patient, req.PatientID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "Pasien tidak ditemukan")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
+	}
+
+	doctor, err := findDoctor(database.DB, req.DoctorID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.ErrorResponse(c, fiber.StatusBadRequest, "Dokter tidak ditemukan")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
+	}
+
+	jenisKunjungan := "Reservasi"
+	if req.JenisKunjungan != "" {
+		jenisKunjungan = req.JenisKunjungan
+	}
+
+	reservation := models.Reservation{
+		PatientID:      patient.ID,
+		DoctorID:       doctor.ID,
+		DoctorName:     doctor.NamaLengkap,
+		Tanggal:        tanggal,
+		Waktu:          req.Waktu,
+		Keluhan:        req.Keluhan,
+		Catatan:        req.Catatan,
+		Status:         models.ReservationStatusDijadwalkan,
+		JenisKunjungan: jenisKunjungan,
+	}
+
+	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockDoctorAndCheckSlot(tx, doctor.ID, tanggal, req.Waktu, 0); err != nil {
+			return err
+		}
+		return tx.Create(&reservation).Error
+	})
+	if errTx != nil {
+		if errTx == errReservationSlotTaken {
+			return utils.ErrorResponse(c, fiber.StatusConflict, fmt.Sprintf("Dokter %s sudah memiliki reservasi pada %s pukul %s.", doctor.NamaLengkap, req.Tanggal, req.Waktu))
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal menyimpan reservasi", errTx.Error())
+	}
+
+	reservation.Patient = patient
+	response := mapReservationToResponse(reservation)
+	events.Publish(events.TypeReservationCreated, reservation.DoctorID, response)
+	return utils.SuccessResponse(c, fiber.StatusCreated, "Reservasi berhasil dibuat", response)
+}
+
+// GetReservations mengambil daftar reservasi dengan pagination serta filter tanggal, rentang tanggal, dokter dan status
+func GetReservations(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "10"))
+	if limit <= 0 {
+		limit = 10
+	}
+	if page <= 0 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+
+	query := database.DB.Model(&models.Reservation{}).Joins("Patient")
+
+	if date := c.Query("date"); date != "" {
+		query = query.Where("reservations.tanggal = ?", date)
+	}
+	if startDate := c.Query("startDate"); startDate != "" {
+		query = query.Where("reservations.tanggal >= ?", startDate)
+	}
+	if endDate := c.Query("endDate"); endDate != "" {
+		query = query.Where("reservations.tanggal <= ?", endDate)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("reservations.status = ?", status)
+	}
+	if search := c.Query("search"); search != "" {
+		searchPattern := "%" + search + "%"
+		query = query.Where(`"Patient".nama_lengkap ILIKE ? OR "Patient".no_rm ILIKE ?`, searchPattern, searchPattern)
+	}
+
+	if middleware.HasPermission(c, "reservation:view_all") {
+		if doctorIDParam := c.Query("doctorId"); doctorIDParam != "" {
+			doctorID, err := strconv.ParseUint(doctorIDParam, 10, 32)
+			if err != nil {
+				return utils.ErrorResponse(c, fiber.StatusBadRequest, "Doctor ID tidak valid")
+			}
+			query = query.Where("reservations.doctor_id = ?", uint(doctorID))
+		}
+	} else {
+		// Hanya reservation:view_doctor_specific, batasi ke reservasi milik dokter yang login
+		query = query.Where("reservations.doctor_id = ?", c.Locals("user_id").(uint))
+	}
+	query = query.Session(&gorm.Session{}) // Agar Count dan Find tidak saling mengubah statement
+
+	var totalRecords int64
+	if err := query.Count(&totalRecords).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal menghitung total reservasi", err.Error())
+	}
+
+	var reservations []models.Reservation
+	if err := query.Order("reservations.tanggal ASC, reservations.waktu ASC").Offset(offset).Limit(limit).Find(&reservations).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal mengambil data reservasi", err.Error())
+	}
+
+	reservationResponses := []dto.ReservationResponse{}
+	for _, r := range reservations {
+		reservationResponses = append(reservationResponses, mapReservationToResponse(r))
+	}
+
+	paginationData := fiber.Map{
+		"currentPage":  page,
+		"totalPages":   int(math.Ceil(float64(totalRecords) / float64(limit))),
+		"totalRecords": totalRecords,
+		"pageSize":     limit,
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success":    true,
+		"data":       reservationResponses,
+		"pagination": paginationData,
+		"message":    "Data reservasi berhasil diambil",
+	})
+}
+
+// GetReservationByID mengambil detail satu reservasi
+func GetReservationByID(c *fiber.Ctx) error {
+	reservation, err := findReservation(c)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "Reservasi tidak ditemukan")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
+	}
+	if !canAccessReservation(c, reservation) {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "Anda tidak memiliki izin untuk melihat reservasi ini")
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Reservasi ditemukan", mapReservationToResponse(reservation))
+}
+
+// UpdateReservation memperbarui jadwal atau detail reservasi. Baris reservasi dikunci selama perubahan
+// dan hanya kolom yang berubah yang ditulis, sehingga pembatalan/konfirmasi kehadiran paralel tidak tertimpa.
+func UpdateReservation(c *fiber.Ctx) error {
+	reservationID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusNotFound, "Reservasi tidak ditemukan")
+	}
+
+	req := new(dto.UpdateReservationRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	if err := validate.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+	var tanggal time.Time
+	if req.Tanggal != "" {
+		if tanggal, err = time.Parse("2006-01-02", req.Tanggal); err != nil {
+			return utils.ErrorResponse(c, fiber.StatusBadRequest, "Format tanggal tidak valid (YYYY-MM-DD)", err.Error())
+		}
+	}
+
+	var reservation models.Reservation
+	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&reservation, uint(reservationID)).Error; err != nil {
+			return err
+		}
+		switch reservation.Status {
+		case models.ReservationStatusDibatalkan, models.ReservationStatusHadir, models.ReservationStatusSelesai:
+			return &reservationError{Status: fiber.StatusConflict, Message: fmt.Sprintf("Reservasi dengan status '%s' tidak dapat diubah.", reservation.Status)}
+		}
+
+		updates := map[string]interface{}{}
+		slotChanged := false
+		if req.DoctorID != 0 && req.DoctorID != reservation.DoctorID {
+			doctor, err := findDoctor(tx, req.DoctorID)
+			if err != nil {
+				if err == gorm.ErrRecordNotFound {
+					return &reservationError{Status: fiber.StatusBadRequest, Message: "Dokter tidak ditemukan"}
+				}
+				return err
+			}
+			reservation.DoctorID = doctor.ID
+			reservation.DoctorName = doctor.NamaLengkap
+			updates["doctor_id"] = doctor.ID
+			updates["doctor_name"] = doctor.NamaLengkap
+			slotChanged = true
+		}
+		if req.Tanggal != "" && !tanggal.Equal(reservation.Tanggal) {
+			reservation.Tanggal = tanggal
+			updates["tanggal"] = tanggal
+			slotChanged = true
+		}
+		if req.Waktu != "" && req.Waktu != reservation.Waktu {
+			reservation.Waktu = req.Waktu
+			updates["waktu"] = req.Waktu
+			slotChanged = true
+		}
+		if req.Keluhan != "" && req.Keluhan != reservation.Keluhan {
+			updates["keluhan"] = req.Keluhan
+		}
+		if req.Catatan != "" && req.Catatan != reservation.Catatan {
+			updates["catatan"] = req.Catatan
+		}
+		if req.JenisKunjungan != "" && req.JenisKunjungan != reservation.JenisKunjungan {
+			updates["jenis_kunjungan"] = req.JenisKunjungan
+		}
+		if req.Status != "" && req.Status != reservation.Status {
+			updates["status"] = req.Status
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+
+		if slotChanged {
+			if err := lockDoctorAndCheckSlot(tx, reservation.DoctorID, reservation.Tanggal, reservation.Waktu, reservation.ID); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&reservation).Updates(updates).Error
+	})
+	if errTx != nil {
+		var resErr *reservationError
+		switch {
+		case errTx == gorm.ErrRecordNotFound:
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "Reservasi tidak ditemukan")
+		case errors.As(errTx, &resErr):
+			return utils.ErrorResponse(c, resErr.Status, resErr.Message)
+		case errTx == errReservationSlotTaken:
+			return utils.ErrorResponse(c, fiber.StatusConflict, fmt.Sprintf("Dokter %s sudah memiliki reservasi pada %s pukul %s.", reservation.DoctorName, reservation.Tanggal.Format("2006-01-02"), reservation.Waktu))
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal memperbarui reservasi", errTx.Error())
+	}
+
+	reservation, err = findReservation(c)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Reservasi berhasil diperbarui", mapReservationToResponse(reservation))
+}
+
+// updateReservationLocked mengunci baris reservasi di dalam transaksi, lalu menulis kolom hasil apply.
+// apply memeriksa status terbaru dan mengembalikan kolom yang berubah. Reservasi dikembalikan beserta data pasien.
+func updateReservationLocked(c *fiber.Ctx, apply func(reservation *models.Reservation) (map[string]interface{}, error)) (models.Reservation, error) {
+	var reservation models.Reservation
+	reservationID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return reservation, gorm.ErrRecordNotFound
+	}
+	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&reservation, uint(reservationID)).Error; err != nil {
+			return err
+		}
+		updates, err := apply(&reservation)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&reservation).Updates(updates).Error
+	})
+	if errTx != nil {
+		return reservation, errTx
+	}
+	return findReservation(c)
+}
+
+// reservationErrorResponse memetakan error dari updateReservationLocked ke respons HTTP
+func reservationErrorResponse(c *fiber.Ctx, err error, fallbackMessage string) error {
+	var resErr *reservationError
+	if errors.As(err, &resErr) {
+		return utils.ErrorResponse(c, resErr.Status, resErr.Message)
+	}
+	if err == gorm.ErrRecordNotFound {
+		return utils.ErrorResponse(c, fiber.StatusNotFound, "Reservasi tidak ditemukan")
+	}
+	return utils.ErrorResponse(c, fiber.StatusInternalServerError, fallbackMessage, err.Error())
+}
+
+// CancelReservation membatalkan reservasi (status menjadi Dibatalkan, data tidak dihapus)
+func CancelReservation(c *fiber.Ctx) error {
+	req := new(dto.CancelReservationRequest)
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(req); err != nil {
+			return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+		}
+		if err := validate.Struct(req); err != nil {
+			return utils.ValidationErrorResponse(c, err.Error())
+		}
+	}
+
+	reservation, err := updateReservationLocked(c, func(reservation *models.Reservation) (map[string]interface{}, error) {
+		if reservation.Status == models.ReservationStatusDibatalkan {
+			return nil, &reservationError{Status: fiber.StatusConflict, Message: "Reservasi sudah dibatalkan sebelumnya."}
+		}
+		if reservation.Status == models.ReservationStatusHadir || reservation.Status == models.ReservationStatusSelesai {
+			return nil, &reservationError{Status: fiber.StatusConflict, Message: fmt.Sprintf("Reservasi dengan status '%s' tidak dapat dibatalkan.", reservation.Status)}
+		}
+		updates := map[string]interface{}{"status": models.ReservationStatusDibatalkan}
+		if req.Alasan != "" {
+			catatan := reservation.Catatan
+			if catatan != "" {
+				catatan += "\n"
+			}
+			updates["catatan"] = catatan + "Alasan pembatalan: " + req.Alasan
+		}
+		return updates, nil
+	})
+	if err != nil {
+		return reservationErrorResponse(c, err, "Gagal membatalkan reservasi")
+	}
+	response := mapReservationToResponse(reservation)
+	events.Publish(events.TypeReservationCanceled, reservation.DoctorID, response)
+	return utils.SuccessResponse(c, fiber.StatusOK, "Reservasi berhasil dibatalkan", response)
+}
+
+// ConfirmReservationArrival menandai pasien reservasi telah hadir di klinik
+func ConfirmReservationArrival(c *fiber.Ctx) error {
+	reservation, err := updateReservationLocked(c, func(reservation *models.Reservation) (map[string]interface{}, error) {
+		if reservation.Status != models.ReservationStatusDijadwalkan && reservation.Status != models.ReservationStatusDikonfirmasi {
+			return nil, &reservationError{Status: fiber.StatusConflict, Message: fmt.Sprintf("Kehadiran tidak dapat dikonfirmasi untuk reservasi berstatus '%s'.", reservation.Status)}
+		}
+		return map[string]interface{}{"status": models.ReservationStatusHadir}, nil
+	})
+	if err != nil {
+		return reservationErrorResponse(c, err, "Gagal mengonfirmasi kehadiran")
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Kehadiran pasien berhasil dikonfirmasi", mapReservationToResponse(reservation))
+}
@@ -1,22 +1,35 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
 
 	"github.com/MadeAgus22/dental-clinic-backend/pkg/database"
 	"github.com/MadeAgus22/dental-clinic-backend/pkg/dto"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/middleware"
 	"github.com/MadeAgus22/dental-clinic-backend/pkg/models"
 	"github.com/MadeAgus22/dental-clinic-backend/pkg/utils"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var roleValidate = validator.New()
 
+// errRoleInUse dikembalikan jika role yang akan dihapus masih dipakai pengguna
+var errRoleInUse = errors.New("role masih digunakan oleh pengguna")
+
+// findRoleByKode mengambil role aktif berdasarkan kode; dipakai untuk memvalidasi role pengguna
+func findRoleByKode(db *gorm.DB, kode string) (models.Role, error) {
+	var role models.Role
+	err := db.Where("kode = ?", strings.ToLower(kode)).First(&role).Error
+	return role, err
+}
+
 // mapPermissionsToSimpleDTO adalah helper untuk mengubah []models.Permission menjadi []dto.PermissionSimpleDTO
 func mapPermissionsToSimpleDTO(permissions []models.Permission) []dto.PermissionSimpleDTO {
 	var dtos []dto.PermissionSimpleDTO
@@ -34,6 +47,20 @@ func mapPermissionsToSimpleDTO(permissions []models.Permission) []dto.Permission
 	return dtos
 }
 
+// mapRoleToResponse memetakan role (dengan Permissions sudah di-preload) ke DTO respons
+func mapRoleToResponse(role models.Role) dto.RoleResponse {
+	return dto.RoleResponse{
+		ID:          role.ID,
+		Nama:        role.Nama,
+		Kode:        role.Kode,
+		Deskripsi:   role.Deskripsi,
+		Permissions: mapPermissionsToSimpleDTO(role.Permissions),
+		Version:     role.Version,
+		CreatedAt:   role.CreatedAt,
+		UpdatedAt:   role.UpdatedAt,
+	}
+}
+
 // CreateRole membuat role baru beserta hak aksesnya
 func CreateRole(c *fiber.Ctx) error {
 	req := new(dto.CreateRoleRequest)
@@ -44,9 +71,10 @@ func CreateRole(c *fiber.Ctx) error {
 		return utils.ValidationErrorResponse(c, err.Error())
 	}
 
+	// Unscoped: kode milik role yang sudah dihapus tetap tercatat di riwayat pengguna sehingga tidak boleh dipakai ulang
 	var existingRole models.Role
-	if err := database.DB.Where("LOWER(kode) = LOWER(?)", req.Kode).First(&existingRole).Error; err == nil {
-		return utils.ErrorResponse(c, fiber.StatusConflict, fmt.Sprintf("Role dengan kode '%s' sudah ada.", req.Kode))
+	if err := database.DB.Unscoped().Where("LOWER(kode) = LOWER(?)", req.Kode).First(&existingRole).Error; err == nil {
+		return utils.ErrorResponse(c, fiber.StatusConflict, fmt.Sprintf("Role dengan kode '%s' sudah ada atau pernah dipakai.", req.Kode))
 	} else if err != gorm.ErrRecordNotFound {
 		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
 	}
@@ -88,11 +116,8 @@ func CreateRole(c *fiber.Ctx) error {
 	var createdRoleWithPermissions models.Role
 	database.DB.Preload("Permissions").First(&createdRoleWithPermissions, newRole.ID)
 
-	return utils.SuccessResponse(c, fiber.StatusCreated, "Role berhasil dibuat", dto.RoleResponse{
-		ID: createdRoleWithPermissions.ID, Nama: createdRoleWithPermissions.Nama, Kode: createdRoleWithPermissions.Kode, Deskripsi: createdRoleWithPermissions.Deskripsi,
-		Permissions: mapPermissionsToSimpleDTO(createdRoleWithPermissions.Permissions),
-		CreatedAt:   createdRoleWithPermissions.CreatedAt, UpdatedAt: createdRoleWithPermissions.UpdatedAt,
-	})
+	utils.SetETag(c, createdRoleWithPermissions.Version)
+	return utils.SuccessResponse(c, fiber.StatusCreated, "Role berhasil dibuat", mapRoleToResponse(createdRoleWithPermissions))
 }
 
 // GetAllRoles mengambil semua role
@@ -104,15 +129,7 @@ func GetAllRoles(c *fiber.Ctx) error {
 
 	roleResponses := []dto.RoleResponse{}
 	for _, role := range roles {
-		roleResponses = append(roleResponses, dto.RoleResponse{
-			ID:          role.ID,
-			Nama:        role.Nama,
-			Kode:        role.Kode,
-			Deskripsi:   role.Deskripsi,
-			Permissions: mapPermissionsToSimpleDTO(role.Permissions),
-			CreatedAt:   role.CreatedAt,
-			UpdatedAt:   role.UpdatedAt,
-		})
+		roleResponses = append(roleResponses, mapRoleToResponse(role))
 	}
 	return utils.SuccessResponse(c, fiber.StatusOK, "Data role berhasil diambil", roleResponses)
 }
@@ -131,24 +148,28 @@ func GetRoleByID(c *fiber.Ctx) error {
 		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
 	}
 
-	return utils.SuccessResponse(c, fiber.StatusOK, "Data role berhasil diambil", dto.RoleResponse{
-		ID: role.ID, Nama: role.Nama, Kode: role.Kode, Deskripsi: role.Deskripsi,
-		Permissions: mapPermissionsToSimpleDTO(role.Permissions),
-		CreatedAt:   role.CreatedAt, UpdatedAt: role.UpdatedAt,
-	})
+	utils.SetETag(c, role.Version)
+	return utils.SuccessResponse(c, fiber.StatusOK, "Data role berhasil diambil", mapRoleToResponse(role))
 }
 
-// UpdateRole memperbarui data role dan hak aksesnya
+// UpdateRole memperbarui data role dan hak aksesnya. Header If-Match wajib berisi ETag role yang terakhir dibaca.
 func UpdateRole(c *fiber.Ctx) error {
 	roleID, err := strconv.ParseUint(c.Params("roleId"), 10, 32)
 	if err != nil {
 		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Role ID tidak valid")
 	}
+	expectedVersion, ok := utils.IfMatchVersion(c)
+	if !ok {
+		return ifMatchRequiredResponse(c)
+	}
 
 	var role models.Role
 	if err := database.DB.Preload("Permissions").First(&role, uint(roleID)).Error; err != nil { // Preload agar bisa diupdate asosiasinya
 		return utils.ErrorResponse(c, fiber.StatusNotFound, "Role tidak ditemukan")
 	}
+	if role.Version != expectedVersion {
+		return utils.VersionConflictResponse(c, fiber.StatusPreconditionFailed, "Role sudah diubah pengguna lain, muat ulang data terbaru", role.Version, mapRoleToResponse(role))
+	}
 
 	req := new(dto.UpdateRoleRequest)
 	if err := c.BodyParser(req); err != nil {
@@ -160,8 +181,8 @@ func UpdateRole(c *fiber.Ctx) error {
 
 	if req.Kode != "" && strings.ToLower(req.Kode) != strings.ToLower(role.Kode) {
 		var existingRole models.Role
-		if errCheck := database.DB.Where("LOWER(kode) = LOWER(?) AND id != ?", req.Kode, role.ID).First(&existingRole).Error; errCheck == nil {
-			return utils.ErrorResponse(c, fiber.StatusConflict, fmt.Sprintf("Role dengan kode '%s' sudah ada.", req.Kode))
+		if errCheck := database.DB.Unscoped().Where("LOWER(kode) = LOWER(?) AND id != ?", req.Kode, role.ID).First(&existingRole).Error; errCheck == nil {
+			return utils.ErrorResponse(c, fiber.StatusConflict, fmt.Sprintf("Role dengan kode '%s' sudah ada atau pernah dipakai.", req.Kode))
 		} else if errCheck != gorm.ErrRecordNotFound {
 			return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database saat memeriksa kode role", errCheck.Error())
 		}
@@ -179,7 +200,8 @@ func UpdateRole(c *fiber.Ctx) error {
 	} // Memperbolehkan deskripsi kosong jika dikirim string kosong
 
 	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Save(&role).Error; err != nil {
+		role.Version = expectedVersion + 1
+		if err := updateWithVersion(tx, &role, expectedVersion); err != nil {
 			return err
 		}
 
@@ -200,18 +222,21 @@ func UpdateRole(c *fiber.Ctx) error {
 		return nil
 	})
 
+	if errors.Is(errTx, errVersionConflict) {
+		var currentRole models.Role
+		database.DB.Preload("Permissions").First(&currentRole, role.ID)
+		return utils.VersionConflictResponse(c, fiber.StatusConflict, "Role diubah pengguna lain saat sedang disimpan, muat ulang data terbaru", currentRole.Version, mapRoleToResponse(currentRole))
+	}
 	if errTx != nil {
 		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal memperbarui role", errTx.Error())
 	}
+	middleware.InvalidateRolePermissions(role.ID)
 
 	var updatedRoleWithPermissions models.Role
 	database.DB.Preload("Permissions").First(&updatedRoleWithPermissions, role.ID)
 
-	return utils.SuccessResponse(c, fiber.StatusOK, "Role berhasil diperbarui", dto.RoleResponse{
-		ID: updatedRoleWithPermissions.ID, Nama: updatedRoleWithPermissions.Nama, Kode: updatedRoleWithPermissions.Kode, Deskripsi: updatedRoleWithPermissions.Deskripsi,
-		Permissions: mapPermissionsToSimpleDTO(updatedRoleWithPermissions.Permissions),
-		CreatedAt:   updatedRoleWithPermissions.CreatedAt, UpdatedAt: updatedRoleWithPermissions.UpdatedAt,
-	})
+	utils.SetETag(c, updatedRoleWithPermissions.Version)
+	return utils.SuccessResponse(c, fiber.StatusOK, "Role berhasil diperbarui", mapRoleToResponse(updatedRoleWithPermissions))
 }
 
 // DeleteRole menghapus role
@@ -226,13 +251,19 @@ func DeleteRole(c *fiber.Ctx) error {
 		return utils.ErrorResponse(c, fiber.StatusNotFound, "Role tidak ditemukan")
 	}
 
-	var userCount int64
-	database.DB.Model(&models.User{}).Where("role = ?", roleToDelete.Kode).Count(&userCount)
-	if userCount > 0 {
-		return utils.ErrorResponse(c, fiber.StatusConflict, "Role tidak dapat dihapus karena masih digunakan oleh pengguna.")
-	}
-
 	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Kunci role agar tidak ada pengguna baru yang ditetapkan ke role ini selama penghapusan
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&roleToDelete, roleToDelete.ID).Error; err != nil {
+			return err
+		}
+		var userCount int64
+		if err := tx.Model(&models.User{}).Where("role = ?", roleToDelete.Kode).Count(&userCount).Error; err != nil {
+			return err
+		}
+		if userCount > 0 {
+			return errRoleInUse
+		}
+
 		if err := tx.Model(&roleToDelete).Association("Permissions").Clear(); err != nil {
 			return err
 		}
@@ -243,7 +274,11 @@ func DeleteRole(c *fiber.Ctx) error {
 	})
 
 	if errTx != nil {
+		if errTx == errRoleInUse {
+			return utils.ErrorResponse(c, fiber.StatusConflict, "Role tidak dapat dihapus karena masih digunakan oleh pengguna.")
+		}
 		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal menghapus role", errTx.Error())
 	}
+	middleware.InvalidateRolePermissions(roleToDelete.ID)
 	return utils.SuccessResponse(c, fiber.StatusOK, "Role berhasil dihapus", nil)
 }
@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/config"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/database"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/dto"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/models"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// createSession membuat sesi login baru dan mengembalikan refresh token dalam bentuk asli (hanya hash yang disimpan)
+func createSession(db *gorm.DB, userID uint, userAgent, ip string) (models.UserSession, string, error) {
+	refreshToken, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return models.UserSession{}, "", err
+	}
+	now := time.Now()
+	session := models.UserSession{
+		UserID:           userID,
+		RefreshTokenHash: utils.HashToken(refreshToken),
+		ExpiresAt:        now.Add(config.AppConfig.RefreshTokenExpiry),
+		Device:           utils.DescribeUserAgent(userAgent),
+		UserAgent:        userAgent,
+		IPAddress:        ip,
+		LastSeenAt:       &now,
+	}
+	if err := db.Create(&session).Error; err != nil {
+		return models.UserSession{}, "", err
+	}
+	return session, refreshToken, nil
+}
+
+// buildLoginResponse membuat access token untuk sesi lalu menyusun respons login
+func buildLoginResponse(user models.User, session models.UserSession, refreshToken string) (dto.LoginResponse, error) {
+	token, err := utils.GenerateJWT(user.ID, user.Username, user.Role, user.RoleDetail.ID, session.ID)
+	if err != nil {
+		return dto.LoginResponse{}, err
+	}
+	permissionKodes, err := getPermissionCodesForRole(user.Role)
+	if err != nil {
+		// Log error tapi tetap lanjutkan login, mungkin dengan permission kosong
+		fmt.Printf("Peringatan: Gagal mengambil permission untuk role %s: %v\n", user.Role, err)
+	}
+	return dto.LoginResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(config.AppConfig.JWTExpiry.Seconds()),
+		ID:           user.ID,
+		Username:     user.Username,
+		NamaLengkap:  user.NamaLengkap,
+		Email:        user.Email,
+		Role:         user.Role,
+		Permissions:  permissionKodes,
+		SessionID:    session.ID,
+	}, nil
+}
+
+// revokeUserSessions mencabut semua sesi aktif milik pengguna; access token yang masih beredar langsung ditolak JWTMiddleware
+func revokeUserSessions(db *gorm.DB, userID uint, reason string) error {
+	now := time.Now()
+	return db.Model(&models.UserSession{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Updates(map[string]interface{}{"revoked_at": now, "revoked_reason": reason}).Error
+}
+
+// RefreshToken menukar refresh token dengan access token baru dan merotasi refresh token.
+// Refresh token lama yang dipakai ulang dianggap bocor sehingga seluruh sesinya dicabut.
+func RefreshToken(c *fiber.Ctx) error {
+	req := new(dto.RefreshTokenRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	if err := validateAuth.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	tokenHash := utils.HashToken(req.RefreshToken)
+	var (
+		session    models.UserSession
+		newToken   string
+		tokenReuse bool
+	)
+	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("refresh_token_hash = ?", tokenHash).First(&session).Error
+		if err == gorm.ErrRecordNotFound {
+			// Token lama yang sudah dirotasi: cabut sesinya
+			if errPrev := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("previous_token_hash = ?", tokenHash).First(&session).Error; errPrev == nil {
+				tokenReuse = true
+				now := time.Now()
+				return tx.Model(&session).Where("revoked_at IS NULL").
+					Updates(map[string]interface{}{"revoked_at": now, "revoked_reason": models.SessionRevokedTokenReuse}).Error
+			}
+			return gorm.ErrRecordNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if session.RevokedAt != nil || session.ExpiresAt.Before(time.Now()) {
+			return gorm.ErrRecordNotFound
+		}
+
+		if err := tx.Preload("RoleDetail").First(&session.User, session.UserID).Error; err != nil {
+			return err
+		}
+		if session.User.Status != "aktif" {
+			return gorm.ErrRecordNotFound
+		}
+
+		newToken, err = utils.GenerateOpaqueToken()
+		if err != nil {
+			return err
+		}
+		return tx.Model(&session).Updates(map[string]interface{}{
+			"previous_token_hash": session.RefreshTokenHash,
+			"refresh_token_hash":  utils.HashToken(newToken),
+			"expires_at":          time.Now().Add(config.AppConfig.RefreshTokenExpiry),
+			"last_seen_at":        time.Now(),
+			"ip_address":          c.IP(),
+		}).Error
+	})
+	if errTx != nil {
+		if errTx == gorm.ErrRecordNotFound {
+			return utils.ErrorResponse(c, fiber.StatusUnauthorized, "Refresh token tidak valid atau sesi sudah berakhir")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal memperbarui token", errTx.Error())
+	}
+	if tokenReuse {
+		return utils.ErrorResponse(c, fiber.StatusUnauthorized, "Refresh token sudah pernah dipakai, sesi dicabut demi keamanan")
+	}
+
+	response, err := buildLoginResponse(session.User, session, newToken)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal membuat token", err.Error())
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Token berhasil diperbarui", response)
+}
+
+// Logout mencabut sesi yang sedang dipakai sehingga access token dan refresh token-nya tidak berlaku lagi
+func Logout(c *fiber.Ctx) error {
+	sessionID, _ := c.Locals("session_id").(uint)
+	now := time.Now()
+	err := database.DB.Model(&models.UserSession{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Updates(map[string]interface{}{"revoked_at": now, "revoked_reason": models.SessionRevokedLogout}).Error
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal logout", err.Error())
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Logout berhasil", nil)
+}
+
+// mapSessionToResponse mengubah models.UserSession menjadi dto.SessionResponse
+func mapSessionToResponse(s models.UserSession, currentSessionID uint) dto.SessionResponse {
+	return dto.SessionResponse{
+		ID:         s.ID,
+		Device:     s.Device,
+		UserAgent:  s.UserAgent,
+		IPAddress:  s.IPAddress,
+		CreatedAt:  s.CreatedAt,
+		LastSeenAt: s.LastSeenAt,
+		ExpiresAt:  s.ExpiresAt,
+		Current:    s.ID == currentSessionID,
+	}
+}
+
+// listActiveSessions mengambil sesi aktif milik pengguna, yang terakhir dipakai di urutan teratas
+func listActiveSessions(c *fiber.Ctx, userID uint) error {
+	var sessions []models.UserSession
+	err := database.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC NULLS LAST, created_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal mengambil data sesi", err.Error())
+	}
+
+	currentSessionID, _ := c.Locals("session_id").(uint)
+	responses := []dto.SessionResponse{}
+	for _, s := range sessions {
+		responses = append(responses, mapSessionToResponse(s, currentSessionID))
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Data sesi berhasil diambil", responses)
+}
+
+// revokeSession mencabut satu sesi milik pengguna tertentu
+func revokeSession(c *fiber.Ctx, userID uint, reason string) error {
+	sessionID, err := strconv.ParseUint(c.Params("sessionId"), 10, 32)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Session ID tidak valid")
+	}
+
+	actorID, _ := c.Locals("user_id").(uint)
+	result := database.DB.Model(&models.UserSession{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", uint(sessionID), userID).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": reason, "revoked_by_id": actorID})
+	if result.Error != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal mencabut sesi", result.Error.Error())
+	}
+	if result.RowsAffected == 0 {
+		return utils.ErrorResponse(c, fiber.StatusNotFound, "Sesi aktif tidak ditemukan")
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Sesi berhasil dicabut", nil)
+}
+
+// parseUserIDParam membaca parameter :userId untuk endpoint sesi milik admin
+func parseUserIDParam(c *fiber.Ctx) (uint, error) {
+	userID, err := strconv.ParseUint(c.Params("userId"), 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	var user models.User
+	if err := database.DB.Unscoped().Select("id").First(&user, uint(userID)).Error; err != nil {
+		return 0, err
+	}
+	return user.ID, nil
+}
+
+// GetMySessions menampilkan semua sesi login aktif milik pengguna yang sedang login
+func GetMySessions(c *fiber.Ctx) error {
+	return listActiveSessions(c, c.Locals("user_id").(uint))
+}
+
+// RevokeMySession mencabut salah satu sesi milik sendiri, mis. yang tertinggal di PC resepsionis
+func RevokeMySession(c *fiber.Ctx) error {
+	return revokeSession(c, c.Locals("user_id").(uint), models.SessionRevokedByUser)
+}
+
+// RevokeMyOtherSessions mencabut semua sesi milik sendiri kecuali sesi yang sedang dipakai
+func RevokeMyOtherSessions(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+	currentSessionID, _ := c.Locals("session_id").(uint)
+	result := database.DB.Model(&models.UserSession{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, currentSessionID).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": models.SessionRevokedByUser, "revoked_by_id": userID})
+	if result.Error != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal mencabut sesi", result.Error.Error())
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, fmt.Sprintf("%d sesi lain berhasil dicabut", result.RowsAffected), nil)
+}
+
+// GetUserSessions menampilkan sesi login aktif milik seorang pengguna (admin)
+func GetUserSessions(c *fiber.Ctx) error {
+	userID, err := parseUserIDParam(c)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusNotFound, "Pengguna tidak ditemukan")
+	}
+	return listActiveSessions(c, userID)
+}
+
+// RevokeUserSession mencabut satu sesi milik seorang pengguna (admin)
+func RevokeUserSession(c *fiber.Ctx) error {
+	userID, err := parseUserIDParam(c)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusNotFound, "Pengguna tidak ditemukan")
+	}
+	return revokeSession(c, userID, models.SessionRevokedByAdmin)
+}
+
+// RevokeAllUserSessions mencabut semua sesi milik seorang pengguna (admin)
+func RevokeAllUserSessions(c *fiber.Ctx) error {
+	userID, err := parseUserIDParam(c)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusNotFound, "Pengguna tidak ditemukan")
+	}
+	actorID, _ := c.Locals("user_id").(uint)
+	result := database.DB.Model(&models.UserSession{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": models.SessionRevokedByAdmin, "revoked_by_id": actorID})
+	if result.Error != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal mencabut sesi", result.Error.Error())
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, fmt.Sprintf("%d sesi berhasil dicabut", result.RowsAffected), nil)
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/database"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/dto"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/models"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// dateOnly mengambil tanggal kalender (tanpa jam) dari t dalam UTC, untuk menghitung selisih hari
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// mapStockBatchToResponse memetakan batch (dengan MedicationCatalog ter-preload) ke DTO respons
+func mapStockBatchToResponse(b models.StockBatch, now time.Time) dto.StockBatchResponse {
+	response := dto.StockBatchResponse{
+		ID:                 b.ID,
+		MedicationID:       b.MedicationCatalogID,
+		MedicationCode:     b.MedicationCatalog.Kode,
+		MedicationName:     b.MedicationCatalog.Nama,
+		Satuan:             b.MedicationCatalog.Satuan,
+		NomorBatch:         b.NomorBatch,
+		TanggalKedaluwarsa: b.TanggalKedaluwarsa,
+		Expired:            batchExpired(b, now),
+		JumlahAwal:         b.JumlahAwal,
+		Sisa:               b.Sisa,
+		HargaBeli:          b.HargaBeli,
+		NilaiSisa:          roundCurrency(float64(b.Sisa) * b.HargaBeli),
+		CreatedAt:          b.CreatedAt,
+	}
+	if b.TanggalKedaluwarsa != nil {
+		days := int(dateOnly(*b.TanggalKedaluwarsa).Sub(dateOnly(now)).Hours() / 24)
+		response.DaysToExpiry = &days
+	}
+	return response
+}
+
+// CreateStockBatch menerima stok dalam batch baru (nomor batch, tanggal kedaluwarsa, harga beli) dan mencatat mutasi masuk
+func CreateStockBatch(c *fiber.Ctx) error {
+	req := new(dto.CreateStockBatchRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	if err := validate.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	var medication models.MedicationCatalog
+	if err := database.DB.Where("kode = ?", req.MedicationCode).First(&medication).Error; err != nil {
+		return stockErrorResponse(c, err, "Kesalahan server database")
+	}
+	batch := models.StockBatch{
+		MedicationCatalogID: medication.ID,
+		NomorBatch:          req.NomorBatch,
+		JumlahAwal:          req.Jumlah,
+		HargaBeli:           req.HargaBeli,
+	}
+	if req.TanggalKedaluwarsa != "" {
+		expiry, err := time.Parse("2006-01-02", req.TanggalKedaluwarsa)
+		if err != nil {
+			return utils.ErrorResponse(c, fiber.StatusBadRequest, "Format tanggal kedaluwarsa tidak valid (YYYY-MM-DD)", err.Error())
+		}
+		batch.TanggalKedaluwarsa = &expiry
+	}
+	reason := req.Reason
+	if reason == "" {
+		reason = "Penerimaan batch " + req.NomorBatch
+	}
+
+	ledger := newStockLedger(c)
+	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.StockBatch{}).Unscoped().
+			Where("medication_catalog_id = ? AND nomor_batch = ?", medication.ID, req.NomorBatch).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return &stockError{fiber.StatusConflict, fmt.Sprintf("Batch %s untuk obat ini sudah ada, catat tambahan lewat mutasi stok", req.NomorBatch)}
+		}
+		if err := tx.Create(&batch).Error; err != nil {
+			return err
+		}
+		movement, err := ledger.post(tx, models.StockMovement{
+			MedicationCatalogID: medication.ID,
+			StockBatchID:        &batch.ID,
+			Type:                models.StockMovementMasuk,
+			Quantity:            req.Jumlah,
+			Reason:              reason,
+		}, false)
+		if err != nil {
+			return err
+		}
+		batch.Sisa = movement.StockBatch.Sisa
+		return nil
+	})
+	if errTx != nil {
+		return stockErrorResponse(c, errTx, "Gagal menyimpan batch stok")
+	}
+
+	batch.MedicationCatalog = medication
+	return utils.SuccessResponse(c, fiber.StatusCreated, "Batch stok berhasil diterima", mapStockBatchToResponse(batch, time.Now()))
+}
+
+// GetStockBatches menampilkan batch stok, urut kedaluwarsa terdekat. Filter: medicationCode;
+// batch yang sudah habis hanya ditampilkan dengan includeEmpty=true.
+func GetStockBatches(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	if limit <= 0 {
+		limit = 20
+	}
+	if page <= 0 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+
+	query := database.DB.Model(&models.StockBatch{})
+	if code := c.Query("medicationCode"); code != "" {
+		query = query.Where("medication_catalog_id IN (?)", database.DB.Unscoped().Model(&models.MedicationCatalog{}).Select("id").Where("kode = ?", code))
+	}
+	if c.Query("includeEmpty") != "true" {
+		query = query.Where("sisa > 0")
+	}
+	query = query.Session(&gorm.Session{})
+
+	var totalRecords int64
+	if err := query.Count(&totalRecords).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal menghitung batch stok", err.Error())
+	}
+
+	var batches []models.StockBatch
+	if err := query.Preload("MedicationCatalog", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Order("tanggal_kedaluwarsa ASC NULLS LAST, id ASC").Offset(offset).Limit(limit).Find(&batches).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal mengambil batch stok", err.Error())
+	}
+
+	now := time.Now()
+	response := make([]dto.StockBatchResponse, 0, len(batches))
+	for _, b := range batches {
+		response = append(response, mapStockBatchToResponse(b, now))
+	}
+
+	paginationData := fiber.Map{
+		"currentPage":  page,
+		"totalPages":   int(math.Ceil(float64(totalRecords) / float64(limit))),
+		"totalRecords": totalRecords,
+		"pageSize":     limit,
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success":    true,
+		"data":       response,
+		"pagination": paginationData,
+		"message":    "Batch stok berhasil diambil",
+	})
+}
+
+// GetExpiringStockBatches menampilkan batch bersisa yang kedaluwarsa dalam N hari (?days, default 30),
+// termasuk yang sudah kedaluwarsa, urut tanggal kedaluwarsa terdekat
+func GetExpiringStockBatches(c *fiber.Ctx) error {
+	days, err := strconv.Atoi(c.Query("days", "30"))
+	if err != nil || days < 0 {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Parameter days harus berupa angka positif")
+	}
+
+	now := time.Now()
+	var batches []models.StockBatch
+	if err := database.DB.Preload("MedicationCatalog", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Where("sisa > 0 AND tanggal_kedaluwarsa IS NOT NULL AND tanggal_kedaluwarsa <= ?", now.AddDate(0, 0, days).Format("2006-01-02")).
+		Order("tanggal_kedaluwarsa ASC, id ASC").Find(&batches).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal mengambil batch yang akan kedaluwarsa", err.Error())
+	}
+
+	response := make([]dto.StockBatchResponse, 0, len(batches))
+	for _, b := range batches {
+		response = append(response, mapStockBatchToResponse(b, now))
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, fmt.Sprintf("Batch yang kedaluwarsa dalam %d hari berhasil diambil", days), response)
+}
+
+// WriteOffExpiredStock memusnahkan sisa stok batch yang sudah kedaluwarsa dan mencatatnya sebagai mutasi penyesuaian.
+// Tanpa batchIds semua batch kedaluwarsa yang masih bersisa dimusnahkan.
+func WriteOffExpiredStock(c *fiber.Ctx) error {
+	req := new(dto.WriteOffExpiredRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	if err := validate.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+	reason := "Pemusnahan stok kedaluwarsa"
+	if req.Reason != "" {
+		reason += ": " + req.Reason
+	}
+
+	now := time.Now()
+	ledger := newStockLedger(c)
+	result := dto.WriteOffResponse{Movements: []dto.StockMovementResponse{}}
+	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("sisa > 0")
+		if len(req.BatchIDs) > 0 {
+			query = query.Where("id IN ?", req.BatchIDs)
+		} else {
+			query = query.Where("tanggal_kedaluwarsa < ?", now.Format("2006-01-02"))
+		}
+		var batches []models.StockBatch
+		if err := query.Order("id asc").Find(&batches).Error; err != nil {
+			return err
+		}
+		if len(batches) == 0 {
+			return &stockError{fiber.StatusNotFound, "Tidak ada batch kedaluwarsa yang masih bersisa"}
+		}
+
+		for _, batch := range batches {
+			// Kunci master obat lebih dulu lalu batch, urutan yang sama dengan pemakaian FEFO, lalu baca ulang sisanya
+			if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.MedicationCatalog{}, batch.MedicationCatalogID).Error; err != nil {
+				return err
+			}
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&batch, batch.ID).Error; err != nil {
+				return err
+			}
+			if batch.Sisa == 0 {
+				continue
+			}
+			if !batchExpired(batch, now) {
+				return &stockError{fiber.StatusConflict, fmt.Sprintf("Batch %s belum kedaluwarsa", batch.NomorBatch)}
+			}
+			movement, err := ledger.post(tx, models.StockMovement{
+				MedicationCatalogID: batch.MedicationCatalogID,
+				StockBatchID:        &batch.ID,
+				Type:                models.StockMovementPenyesuaian,
+				Quantity:            -batch.Sisa,
+				Reason:              fmt.Sprintf("%s (batch %s)", reason, batch.NomorBatch),
+			}, true) // Sisa batch sudah diperiksa; stok master boleh minus bila sebelumnya ada obat EMR melebihi stok
+			if err != nil {
+				return err
+			}
+			result.Movements = append(result.Movements, mapStockMovementToResponse(movement))
+			result.TotalQuantity += batch.Sisa
+			result.TotalValue += float64(batch.Sisa) * batch.HargaBeli
+		}
+		return nil
+	})
+	if errTx != nil {
+		return stockErrorResponse(c, errTx, "Gagal memusnahkan stok kedaluwarsa")
+	}
+
+	result.TotalValue = roundCurrency(result.TotalValue)
+	return utils.SuccessResponse(c, fiber.StatusOK, "Stok kedaluwarsa berhasil dimusnahkan", result)
+}
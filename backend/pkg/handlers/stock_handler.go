@@ -0,0 +1,406 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/config"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/database"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/dto"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/models"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// insufficientStockError dikembalikan saat mutasi keluar melebihi stok tersedia
+type insufficientStockError struct {
+	Kode      string
+	Nama      string
+	Batch     string // Terisi jika kekurangan terjadi pada batch tertentu
+	Available int
+	Requested int
+}
+
+func (e *insufficientStockError) Error() string {
+	if e.Batch != "" {
+		return fmt.Sprintf("Stok %s (%s) batch %s tidak cukup: tersedia %d, dibutuhkan %d", e.Nama, e.Kode, e.Batch, e.Available, e.Requested)
+	}
+	return fmt.Sprintf("Stok %s (%s) tidak cukup: tersedia %d, dibutuhkan %d", e.Nama, e.Kode, e.Available, e.Requested)
+}
+
+// stockError adalah error stok yang dipetakan langsung ke status HTTP, mis. batch yang belum kedaluwarsa dimusnahkan
+type stockError struct {
+	Status  int
+	Message string
+}
+
+func (e *stockError) Error() string { return e.Message }
+
+// actingUser mengambil ID dan nama lengkap pengguna yang sedang login untuk dicatat sebagai pelaku perubahan
+func actingUser(c *fiber.Ctx) (*uint, string) {
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return nil, ""
+	}
+	name, _ := c.Locals("username").(string)
+	var user models.User
+	if err := database.DB.Select("nama_lengkap").First(&user, userID).Error; err == nil && user.NamaLengkap != "" {
+		name = user.NamaLengkap
+	}
+	return &userID, name
+}
+
+// stockLedger mencatat mutasi stok atas nama pengguna yang sedang login. Jika STOCK_OVERDRAW_POLICY=warn,
+// obat EMR yang melebihi stok yang bisa dipakai tetap disimpan dan peringatannya dikumpulkan untuk dikirim bersama respons.
+type stockLedger struct {
+	userID        *uint
+	userName      string
+	allowOverdraw bool
+	warnings      []string
+}
+
+func newStockLedger(c *fiber.Ctx) *stockLedger {
+	userID, userName := actingUser(c)
+	return &stockLedger{userID: userID, userName: userName, allowOverdraw: config.AppConfig.StockOverdrawPolicy == "warn"}
+}
+
+// post mengunci baris master obat (dan batch jika mutasi untuk batch tertentu), menerapkan perubahan stok
+// lalu mencatat mutasinya. Stok tidak boleh menjadi minus kecuali allowNegative (obat EMR dengan kebijakan "warn").
+func (l *stockLedger) post(tx *gorm.DB, movement models.StockMovement, allowNegative bool) (models.StockMovement, error) {
+	var medication models.MedicationCatalog
+	if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).First(&medication, movement.MedicationCatalogID).Error; err != nil {
+		return movement, err
+	}
+
+	if movement.StockBatchID != nil {
+		var batch models.StockBatch
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND medication_catalog_id = ?", *movement.StockBatchID, medication.ID).First(&batch).Error; err != nil {
+			return movement, err
+		}
+		if batch.Sisa+movement.Quantity < 0 {
+			return movement, &insufficientStockError{Kode: medication.Kode, Nama: medication.Nama, Batch: batch.NomorBatch, Available: batch.Sisa, Requested: -movement.Quantity}
+		}
+		if err := tx.Model(&batch).Update("sisa", batch.Sisa+movement.Quantity).Error; err != nil {
+			return movement, err
+		}
+		batch.Sisa += movement.Quantity
+		movement.StockBatch = &batch
+	}
+
+	stockAfter := medication.Stok + movement.Quantity
+	if movement.Quantity < 0 && stockAfter < 0 && !allowNegative {
+		return movement, &insufficientStockError{Kode: medication.Kode, Nama: medication.Nama, Available: medication.Stok, Requested: -movement.Quantity}
+	}
+	if err := tx.Unscoped().Model(&medication).Update("stok", stockAfter).Error; err != nil {
+		return movement, err
+	}
+
+	movement.StockAfter = stockAfter
+	movement.UserID = l.userID
+	movement.UserName = l.userName
+	batch := movement.StockBatch
+	movement.StockBatch = nil // Jangan biarkan GORM ikut menyimpan ulang batch
+	if err := tx.Create(&movement).Error; err != nil {
+		return movement, err
+	}
+	movement.StockBatch = batch
+	movement.MedicationCatalog = medication
+	return movement, nil
+}
+
+// batchExpired memeriksa apakah batch sudah lewat tanggal kedaluwarsanya (masih boleh dipakai pada hari kedaluwarsa)
+func batchExpired(batch models.StockBatch, now time.Time) bool {
+	return batch.TanggalKedaluwarsa != nil && batch.TanggalKedaluwarsa.Format("2006-01-02") < now.Format("2006-01-02")
+}
+
+// consume mengurangi stok sebanyak quantity secara FEFO: batch yang belum kedaluwarsa dengan tanggal kedaluwarsa
+// terdekat diambil lebih dulu, lalu stok tanpa batch. Satu mutasi dicatat per batch yang terpakai.
+// Jika stok yang bisa dipakai kurang, overdraw menentukan apakah ditolak atau dicatat dengan peringatan.
+func (l *stockLedger) consume(tx *gorm.DB, template models.StockMovement, quantity int, overdraw bool) ([]models.StockMovement, error) {
+	var medication models.MedicationCatalog
+	if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).First(&medication, template.MedicationCatalogID).Error; err != nil {
+		return nil, err
+	}
+	var batches []models.StockBatch
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("medication_catalog_id = ? AND sisa > 0", medication.ID).
+		Order("tanggal_kedaluwarsa ASC NULLS LAST, id ASC").Find(&batches).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	unbatched := medication.Stok
+	for _, batch := range batches {
+		unbatched -= batch.Sisa
+	}
+
+	var movements []models.StockMovement
+	remaining := quantity
+	for _, batch := range batches {
+		if remaining == 0 {
+			break
+		}
+		if batchExpired(batch, now) {
+			continue
+		}
+		take := min(remaining, batch.Sisa)
+		movement := template
+		movement.StockBatchID = &batch.ID
+		movement.Quantity = -take
+		posted, err := l.post(tx, movement, false)
+		if err != nil {
+			return nil, err
+		}
+		movements = append(movements, posted)
+		remaining -= take
+	}
+	if remaining == 0 {
+		return movements, nil
+	}
+
+	if shortage := remaining - max(unbatched, 0); shortage > 0 {
+		shortageErr := &insufficientStockError{Kode: medication.Kode, Nama: medication.Nama, Available: quantity - shortage, Requested: quantity}
+		if !overdraw {
+			return nil, shortageErr
+		}
+		l.warnings = append(l.warnings, shortageErr.Error())
+	}
+	movement := template
+	movement.Quantity = -remaining
+	posted, err := l.post(tx, movement, true)
+	if err != nil {
+		return nil, err
+	}
+	return append(movements, posted), nil
+}
+
+// restockEMRItem mengembalikan obat EMR ke batch asalnya, sebanyak-banyaknya sejumlah yang tercatat keluar untuk item ini.
+// Stok tanpa batch dikembalikan lebih dulu, lalu batch dengan kedaluwarsa terjauh, kebalikan dari urutan FEFO.
+// Item yang dibuat sebelum buku besar stok ada tidak pernah mengurangi stok sehingga tidak ada yang dikembalikan.
+func (l *stockLedger) restockEMRItem(tx *gorm.DB, template models.StockMovement, quantity int) error {
+	var consumed []struct {
+		StockBatchID *uint
+		Quantity     int
+	}
+	err := tx.Table("stock_movements AS m").
+		Select("m.stock_batch_id, -SUM(m.quantity) AS quantity").
+		Joins("LEFT JOIN stock_batches b ON b.id = m.stock_batch_id").
+		Where("m.medication_item_id = ? AND m.medication_catalog_id = ?", *template.MedicationItemID, template.MedicationCatalogID).
+		Group("m.stock_batch_id").
+		Having("SUM(m.quantity) < 0").
+		Order("m.stock_batch_id IS NOT NULL, MAX(b.tanggal_kedaluwarsa) DESC NULLS FIRST, m.stock_batch_id DESC").
+		Scan(&consumed).Error
+	if err != nil {
+		return err
+	}
+
+	remaining := quantity
+	for _, source := range consumed {
+		if remaining == 0 {
+			break
+		}
+		movement := template
+		movement.StockBatchID = source.StockBatchID
+		movement.Quantity = min(remaining, source.Quantity)
+		if _, err := l.post(tx, movement, false); err != nil {
+			return err
+		}
+		remaining -= movement.Quantity
+	}
+	return nil
+}
+
+// postEMRItem mencatat mutasi stok untuk perubahan jumlah obat di EMR. delta positif berarti obat yang diberikan
+// bertambah (stok keluar secara FEFO), delta negatif berarti obat dikurangi atau dihapus dari EMR (stok kembali).
+func (l *stockLedger) postEMRItem(tx *gorm.DB, visitID string, item models.MedicalRecordMedicationItem, delta int, reason string) error {
+	template := models.StockMovement{
+		MedicationCatalogID: item.MedicationCatalogID,
+		Type:                models.StockMovementKeluar,
+		Reason:              fmt.Sprintf("%s (kunjungan %s)", reason, visitID),
+		MedicalRecordID:     &item.MedicalRecordID,
+		MedicationItemID:    &item.ID,
+	}
+	switch {
+	case delta > 0:
+		_, err := l.consume(tx, template, delta, l.allowOverdraw)
+		return err
+	case delta < 0:
+		template.Type = models.StockMovementRetur
+		return l.restockEMRItem(tx, template, -delta)
+	}
+	return nil
+}
+
+// mapStockMovementToResponse memetakan mutasi stok (dengan MedicationCatalog dan StockBatch ter-preload) ke DTO respons
+func mapStockMovementToResponse(m models.StockMovement) dto.StockMovementResponse {
+	response := dto.StockMovementResponse{
+		ID:               m.ID,
+		CreatedAt:        m.CreatedAt,
+		MedicationID:     m.MedicationCatalogID,
+		MedicationCode:   m.MedicationCatalog.Kode,
+		MedicationName:   m.MedicationCatalog.Nama,
+		Type:             m.Type,
+		Quantity:         m.Quantity,
+		StockAfter:       m.StockAfter,
+		Reason:           m.Reason,
+		StockBatchID:     m.StockBatchID,
+		MedicalRecordID:  m.MedicalRecordID,
+		MedicationItemID: m.MedicationItemID,
+		StockOpnameID:    m.StockOpnameID,
+		UserID:           m.UserID,
+		UserName:         m.UserName,
+	}
+	if m.StockBatch != nil {
+		response.BatchNumber = m.StockBatch.NomorBatch
+	}
+	return response
+}
+
+// stockErrorResponse memetakan error mutasi stok ke respons HTTP
+func stockErrorResponse(c *fiber.Ctx, err error, fallbackMessage string) error {
+	var shortage *insufficientStockError
+	if errors.As(err, &shortage) {
+		return utils.ErrorResponse(c, fiber.StatusConflict, shortage.Error())
+	}
+	var sErr *stockError
+	if errors.As(err, &sErr) {
+		return utils.ErrorResponse(c, sErr.Status, sErr.Message)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return utils.ErrorResponse(c, fiber.StatusNotFound, "Master obat tidak ditemukan")
+	}
+	return utils.ErrorResponse(c, fiber.StatusInternalServerError, fallbackMessage, err.Error())
+}
+
+// CreateStockMovement mencatat mutasi stok manual (barang masuk, keluar, penyesuaian atau retur) beserta alasannya.
+// Dengan batchNumber mutasi diterapkan ke batch tersebut; tanpa batchNumber pengurangan diambil secara FEFO
+// dan penambahan dicatat sebagai stok tanpa batch. Penerimaan batch baru lewat CreateStockBatch.
+func CreateStockMovement(c *fiber.Ctx) error {
+	req := new(dto.CreateStockMovementRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	if err := validate.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+	if req.Type != models.StockMovementPenyesuaian && req.Quantity < 0 {
+		return utils.ValidationErrorResponse(c, "quantity harus positif untuk mutasi "+req.Type)
+	}
+
+	var medication models.MedicationCatalog
+	if err := database.DB.Where("kode = ?", req.MedicationCode).First(&medication).Error; err != nil {
+		return stockErrorResponse(c, err, "Kesalahan server database")
+	}
+	template := models.StockMovement{MedicationCatalogID: medication.ID, Type: req.Type, Reason: req.Reason}
+	if req.BatchNumber != "" {
+		var batch models.StockBatch
+		if err := database.DB.Where("medication_catalog_id = ? AND nomor_batch = ?", medication.ID, req.BatchNumber).First(&batch).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return utils.ErrorResponse(c, fiber.StatusNotFound, "Batch "+req.BatchNumber+" tidak ditemukan untuk obat ini")
+			}
+			return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
+		}
+		template.StockBatchID = &batch.ID
+	}
+
+	quantity := req.Quantity
+	if req.Type == models.StockMovementKeluar {
+		quantity = -quantity
+	}
+	ledger := newStockLedger(c)
+	var movements []models.StockMovement
+	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		if quantity < 0 && template.StockBatchID == nil {
+			var err error
+			movements, err = ledger.consume(tx, template, -quantity, false)
+			return err
+		}
+		template.Quantity = quantity
+		movement, err := ledger.post(tx, template, false)
+		movements = append(movements, movement)
+		return err
+	})
+	if errTx != nil {
+		return stockErrorResponse(c, errTx, "Gagal mencatat mutasi stok")
+	}
+
+	response := make([]dto.StockMovementResponse, 0, len(movements))
+	for _, m := range movements {
+		response = append(response, mapStockMovementToResponse(m))
+	}
+	return utils.SuccessResponse(c, fiber.StatusCreated, "Mutasi stok berhasil dicatat", response)
+}
+
+// GetStockMovements menampilkan buku besar stok. Filter: medicationCode, stockBatchId, type, medicalRecordId, stockOpnameId, startDate, endDate.
+func GetStockMovements(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	if limit <= 0 {
+		limit = 20
+	}
+	if page <= 0 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+
+	query := database.DB.Model(&models.StockMovement{})
+	if code := c.Query("medicationCode"); code != "" {
+		query = query.Where("medication_catalog_id IN (?)", database.DB.Unscoped().Model(&models.MedicationCatalog{}).Select("id").Where("kode = ?", code))
+	}
+	if stockBatchID := c.Query("stockBatchId"); stockBatchID != "" {
+		query = query.Where("stock_batch_id = ?", stockBatchID)
+	}
+	if movementType := c.Query("type"); movementType != "" {
+		query = query.Where("type = ?", movementType)
+	}
+	if medicalRecordID := c.Query("medicalRecordId"); medicalRecordID != "" {
+		query = query.Where("medical_record_id = ?", medicalRecordID)
+	}
+	if stockOpnameID := c.Query("stockOpnameId"); stockOpnameID != "" {
+		query = query.Where("stock_opname_id = ?", stockOpnameID)
+	}
+	if startDate := c.Query("startDate"); startDate != "" {
+		query = query.Where("created_at::date >= ?", startDate)
+	}
+	if endDate := c.Query("endDate"); endDate != "" {
+		query = query.Where("created_at::date <= ?", endDate)
+	}
+	query = query.Session(&gorm.Session{})
+
+	var totalRecords int64
+	if err := query.Count(&totalRecords).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal menghitung mutasi stok", err.Error())
+	}
+
+	var movements []models.StockMovement
+	if err := query.Preload("MedicationCatalog", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).Preload("StockBatch").
+		Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&movements).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal mengambil mutasi stok", err.Error())
+	}
+
+	response := make([]dto.StockMovementResponse, 0, len(movements))
+	for _, m := range movements {
+		response = append(response, mapStockMovementToResponse(m))
+	}
+
+	paginationData := fiber.Map{
+		"currentPage":  page,
+		"totalPages":   int(math.Ceil(float64(totalRecords) / float64(limit))),
+		"totalRecords": totalRecords,
+		"pageSize":     limit,
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success":    true,
+		"data":       response,
+		"pagination": paginationData,
+		"message":    "Mutasi stok berhasil diambil",
+	})
+}
@@ -0,0 +1,444 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/database"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/dto"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/models"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// mapStockOpnameToResponse memetakan sesi stock opname (dengan Items dan MedicationCatalog ter-preload) ke DTO respons.
+// Daftar obat hanya disertakan jika withItems.
+func mapStockOpnameToResponse(o models.StockOpname, withItems bool) dto.StockOpnameResponse {
+	response := dto.StockOpnameResponse{
+		ID:            o.ID,
+		NoOpname:      o.NoOpname,
+		Status:        o.Status,
+		Catatan:       o.Catatan,
+		StartedByName: o.StartedByName,
+		ClosedAt:      o.ClosedAt,
+		ClosedByName:  o.ClosedByName,
+		CreatedAt:     o.CreatedAt,
+	}
+
+	summary := &response.Summary
+	summary.TotalItem = len(o.Items)
+	for _, item := range o.Items {
+		itemResponse := dto.StockOpnameItemResponse{
+			MedicationID:   item.MedicationCatalogID,
+			MedicationCode: item.MedicationCatalog.Kode,
+			MedicationName: item.MedicationCatalog.Nama,
+			Satuan:         item.MedicationCatalog.Satuan,
+			StokSistem:     item.StokSistem,
+			StokSaatHitung: item.StokSaatHitung,
+			JumlahFisik:    item.JumlahFisik,
+			HargaBeli:      item.HargaBeli,
+			Catatan:        item.Catatan,
+			CountedAt:      item.CountedAt,
+			CountedByName:  item.CountedByName,
+		}
+		if item.JumlahFisik != nil {
+			selisih := *item.JumlahFisik - expectedStock(item)
+			nilai := roundCurrency(float64(selisih) * item.HargaBeli)
+			itemResponse.Selisih = &selisih
+			itemResponse.NilaiSelisih = &nilai
+
+			summary.SudahDihitung++
+			if selisih != 0 {
+				summary.ItemSelisih++
+			}
+			if nilai > 0 {
+				summary.NilaiLebih += nilai
+			} else {
+				summary.NilaiKurang -= nilai
+			}
+		}
+		if withItems {
+			response.Items = append(response.Items, itemResponse)
+		}
+	}
+	summary.BelumDihitung = summary.TotalItem - summary.SudahDihitung
+	summary.NilaiLebih = roundCurrency(summary.NilaiLebih)
+	summary.NilaiKurang = roundCurrency(summary.NilaiKurang)
+	summary.NilaiSelisihNeto = roundCurrency(summary.NilaiLebih - summary.NilaiKurang)
+	return response
+}
+
+// expectedStock adalah stok sistem pembanding hasil hitung: stok saat obat dihitung,
+// atau stok saat sesi dibuka untuk hasil hitung yang tersimpan sebelum kolom StokSaatHitung ada
+func expectedStock(item models.StockOpnameItem) int {
+	if item.StokSaatHitung != nil {
+		return *item.StokSaatHitung
+	}
+	return item.StokSistem
+}
+
+// preloadStockOpnameItems memuat obat dalam sesi urut kode, termasuk master obat yang sudah dihapus
+func preloadStockOpnameItems(db *gorm.DB) *gorm.DB {
+	return db.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Select("stock_opname_items.*").
+			Joins("JOIN medication_catalogs mc ON mc.id = stock_opname_items.medication_catalog_id").Order("mc.kode ASC")
+	}).Preload("Items.MedicationCatalog", func(db *gorm.DB) *gorm.DB { return db.Unscoped() })
+}
+
+// lockStockOpname mengunci sesi stock opname pada parameter :id dengan kekuatan kunci yang diberikan.
+// Pengisian hitung memakai kunci SHARE agar beberapa petugas bisa mengisi bersamaan,
+// sedangkan persetujuan/pembatalan memakai UPDATE sehingga menunggu semua pengisian selesai.
+func lockStockOpname(tx *gorm.DB, c *fiber.Ctx, strength string) (models.StockOpname, error) {
+	var opname models.StockOpname
+	opnameID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return opname, &stockError{fiber.StatusBadRequest, "Stock opname ID tidak valid"}
+	}
+	if err := tx.Clauses(clause.Locking{Strength: strength}).First(&opname, uint(opnameID)).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return opname, &stockError{fiber.StatusNotFound, "Sesi stock opname tidak ditemukan"}
+		}
+		return opname, err
+	}
+	if opname.Status != models.StockOpnameStatusBerjalan {
+		return opname, &stockError{fiber.StatusConflict, fmt.Sprintf("Sesi stock opname sudah %s dan terkunci", opname.Status)}
+	}
+	return opname, nil
+}
+
+// findStockOpnameResponse mengambil detail sesi beserta mutasi penyesuaian yang dicatat saat disetujui
+func findStockOpnameResponse(opnameID uint) (dto.StockOpnameResponse, error) {
+	var opname models.StockOpname
+	if err := preloadStockOpnameItems(database.DB).First(&opname, opnameID).Error; err != nil {
+		return dto.StockOpnameResponse{}, err
+	}
+	response := mapStockOpnameToResponse(opname, true)
+
+	var movements []models.StockMovement
+	if err := database.DB.Preload("MedicationCatalog", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).Preload("StockBatch").
+		Where("stock_opname_id = ?", opname.ID).Order("id ASC").Find(&movements).Error; err != nil {
+		return response, err
+	}
+	for _, m := range movements {
+		response.Movements = append(response.Movements, mapStockMovementToResponse(m))
+	}
+	return response, nil
+}
+
+// CreateStockOpname membuka sesi stock opname dan menyalin stok sistem serta HargaBeli setiap obat aktif
+// (atau hanya medicationCodes). Hanya satu sesi yang boleh berjalan pada satu waktu.
+func CreateStockOpname(c *fiber.Ctx) error {
+	req := new(dto.CreateStockOpnameRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	if err := validate.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	userID, userName := actingUser(c)
+	var opname models.StockOpname
+	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Kunci tabel agar dua permintaan bersamaan tidak sama-sama lolos pemeriksaan di bawah;
+		// index unik idx_stock_opnames_running tetap menjadi jaminan terakhir
+		if err := tx.Exec("LOCK TABLE stock_opnames IN SHARE ROW EXCLUSIVE MODE").Error; err != nil {
+			return err
+		}
+		var running int64
+		if err := tx.Model(&models.StockOpname{}).Where("status = ?", models.StockOpnameStatusBerjalan).Count(&running).Error; err != nil {
+			return err
+		}
+		if running > 0 {
+			return &stockError{fiber.StatusConflict, "Masih ada sesi stock opname yang berjalan. Setujui atau batalkan sesi tersebut lebih dulu."}
+		}
+
+		query := tx.Model(&models.MedicationCatalog{})
+		if len(req.MedicationCodes) > 0 {
+			query = query.Where("kode IN ?", req.MedicationCodes)
+		}
+		var medications []models.MedicationCatalog
+		if err := query.Order("kode ASC").Find(&medications).Error; err != nil {
+			return err
+		}
+		if len(medications) == 0 {
+			return &stockError{fiber.StatusBadRequest, "Tidak ada obat yang dapat dihitung"}
+		}
+		if len(req.MedicationCodes) > 0 && len(medications) != len(req.MedicationCodes) {
+			return &stockError{fiber.StatusBadRequest, "Sebagian kode obat tidak ditemukan atau tercantum lebih dari sekali"}
+		}
+
+		now := time.Now()
+		opname = models.StockOpname{
+			Status:        models.StockOpnameStatusBerjalan,
+			Catatan:       req.Catatan,
+			StartedByID:   userID,
+			StartedByName: userName,
+		}
+		for _, m := range medications {
+			opname.Items = append(opname.Items, models.StockOpnameItem{
+				MedicationCatalogID: m.ID,
+				StokSistem:          m.Stok,
+				HargaBeli:           m.HargaBeli,
+			})
+		}
+		if err := tx.Create(&opname).Error; err != nil {
+			return err
+		}
+		opname.NoOpname = fmt.Sprintf("SO-%s-%06d", now.Format("20060102"), opname.ID)
+		return tx.Model(&opname).Update("no_opname", opname.NoOpname).Error
+	})
+	if errTx != nil {
+		return stockErrorResponse(c, errTx, "Gagal membuka sesi stock opname")
+	}
+
+	response, _ := findStockOpnameResponse(opname.ID)
+	return utils.SuccessResponse(c, fiber.StatusCreated, "Sesi stock opname berhasil dibuka", response)
+}
+
+// GetStockOpnames menampilkan daftar sesi stock opname beserta ringkasan hitungnya. Filter: status.
+func GetStockOpnames(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "10"))
+	if limit <= 0 {
+		limit = 10
+	}
+	if page <= 0 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+
+	query := database.DB.Model(&models.StockOpname{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	query = query.Session(&gorm.Session{})
+
+	var totalRecords int64
+	if err := query.Count(&totalRecords).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal menghitung sesi stock opname", err.Error())
+	}
+
+	var opnames []models.StockOpname
+	if err := query.Preload("Items").Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&opnames).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal mengambil sesi stock opname", err.Error())
+	}
+
+	response := make([]dto.StockOpnameResponse, 0, len(opnames))
+	for _, o := range opnames {
+		response = append(response, mapStockOpnameToResponse(o, false))
+	}
+
+	paginationData := fiber.Map{
+		"currentPage":  page,
+		"totalPages":   int(math.Ceil(float64(totalRecords) / float64(limit))),
+		"totalRecords": totalRecords,
+		"pageSize":     limit,
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success":    true,
+		"data":       response,
+		"pagination": paginationData,
+		"message":    "Sesi stock opname berhasil diambil",
+	})
+}
+
+// GetStockOpnameByID menampilkan detail sesi: stok sistem, hasil hitung, selisih dan nilainya per obat
+func GetStockOpnameByID(c *fiber.Ctx) error {
+	opnameID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Stock opname ID tidak valid")
+	}
+
+	response, err := findStockOpnameResponse(uint(opnameID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "Sesi stock opname tidak ditemukan")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Sesi stock opname ditemukan", response)
+}
+
+// SubmitStockCount mengisi hasil hitung fisik untuk sebagian atau seluruh obat dalam sesi.
+// Obat yang sudah dihitung boleh dikirim ulang; hasil terakhir dan petugasnya yang disimpan.
+// Stok sistem saat itu ikut disimpan (master obat dikunci agar tidak berubah di tengah pembacaan) sebagai pembanding selisih.
+func SubmitStockCount(c *fiber.Ctx) error {
+	req := new(dto.SubmitStockCountRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	if err := validate.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	userID, userName := actingUser(c)
+	var opnameID uint
+	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		opname, err := lockStockOpname(tx, c, "SHARE")
+		if err != nil {
+			return err
+		}
+		opnameID = opname.ID
+
+		now := time.Now()
+		for _, entry := range req.Items {
+			var medication models.MedicationCatalog
+			if err := tx.Unscoped().Clauses(clause.Locking{Strength: "SHARE"}).Where("kode = ?", entry.MedicationCode).First(&medication).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return &stockError{fiber.StatusBadRequest, fmt.Sprintf("Obat dengan kode '%s' tidak termasuk dalam sesi ini", entry.MedicationCode)}
+				}
+				return err
+			}
+			result := tx.Model(&models.StockOpnameItem{}).
+				Where("stock_opname_id = ? AND medication_catalog_id = ?", opname.ID, medication.ID).
+				Updates(map[string]interface{}{
+					"jumlah_fisik":     *entry.JumlahFisik,
+					"stok_saat_hitung": medication.Stok,
+					"catatan":          entry.Catatan,
+					"counted_at":       now,
+					"counted_by_id":    userID,
+					"counted_by_name":  userName,
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return &stockError{fiber.StatusBadRequest, fmt.Sprintf("Obat dengan kode '%s' tidak termasuk dalam sesi ini", entry.MedicationCode)}
+			}
+		}
+		return nil
+	})
+	if errTx != nil {
+		return stockErrorResponse(c, errTx, "Gagal menyimpan hasil hitung")
+	}
+
+	response, _ := findStockOpnameResponse(opnameID)
+	return utils.SuccessResponse(c, fiber.StatusOK, "Hasil hitung berhasil disimpan", response)
+}
+
+// ApproveStockOpname menyetujui sesi dan mencatat selisih setiap obat yang sudah dihitung sebagai mutasi penyesuaian,
+// lalu mengunci sesi. Selisih dihitung terhadap stok sistem saat obat tersebut dihitung, sehingga mutasi sebelum dan
+// sesudah penghitungan (mis. obat EMR) tidak terhitung dua kali. Kekurangan diambil dari batch secara FEFO;
+// obat yang belum dihitung tidak disesuaikan.
+func ApproveStockOpname(c *fiber.Ctx) error {
+	req := new(dto.CloseStockOpnameRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	if err := validate.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	ledger := newStockLedger(c)
+	var opnameID uint
+	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		opname, err := lockStockOpname(tx, c, "UPDATE")
+		if err != nil {
+			return err
+		}
+		opnameID = opname.ID
+
+		var items []models.StockOpnameItem
+		if err := tx.Where("stock_opname_id = ? AND jumlah_fisik IS NOT NULL", opname.ID).Order("medication_catalog_id ASC").Find(&items).Error; err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return &stockError{fiber.StatusConflict, "Belum ada obat yang dihitung pada sesi ini"}
+		}
+
+		for _, item := range items {
+			expected := expectedStock(item)
+			delta := *item.JumlahFisik - expected
+			if delta == 0 {
+				continue
+			}
+			template := models.StockMovement{
+				MedicationCatalogID: item.MedicationCatalogID,
+				Type:                models.StockMovementPenyesuaian,
+				Reason:              fmt.Sprintf("Stock opname %s: sistem %d, fisik %d", opname.NoOpname, expected, *item.JumlahFisik),
+				StockOpnameID:       &opname.ID,
+			}
+			if delta < 0 {
+				// Hasil hitung adalah kondisi sebenarnya, jadi kekurangan tetap dicatat walau stok batch tidak mencukupi
+				if _, err := ledger.consume(tx, template, -delta, true); err != nil {
+					return err
+				}
+				continue
+			}
+			template.Quantity = delta
+			if _, err := ledger.post(tx, template, false); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		updates := map[string]interface{}{
+			"status":         models.StockOpnameStatusDisetujui,
+			"closed_at":      now,
+			"closed_by_id":   ledger.userID,
+			"closed_by_name": ledger.userName,
+		}
+		if req.Catatan != "" {
+			updates["catatan"] = joinNote(opname.Catatan, "Disetujui: "+req.Catatan)
+		}
+		return tx.Model(&opname).Updates(updates).Error
+	})
+	if errTx != nil {
+		return stockErrorResponse(c, errTx, "Gagal menyetujui stock opname")
+	}
+
+	response, _ := findStockOpnameResponse(opnameID)
+	return utils.SuccessResponseWithWarnings(c, fiber.StatusOK, "Stock opname disetujui dan selisih stok telah disesuaikan", response, ledger.warnings)
+}
+
+// CancelStockOpname membatalkan sesi yang masih berjalan tanpa mengubah stok
+func CancelStockOpname(c *fiber.Ctx) error {
+	req := new(dto.CloseStockOpnameRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	if err := validate.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	userID, userName := actingUser(c)
+	var opnameID uint
+	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		opname, err := lockStockOpname(tx, c, "UPDATE")
+		if err != nil {
+			return err
+		}
+		opnameID = opname.ID
+		updates := map[string]interface{}{
+			"status":         models.StockOpnameStatusDibatalkan,
+			"closed_at":      time.Now(),
+			"closed_by_id":   userID,
+			"closed_by_name": userName,
+		}
+		if req.Catatan != "" {
+			updates["catatan"] = joinNote(opname.Catatan, "Dibatalkan: "+req.Catatan)
+		}
+		return tx.Model(&opname).Updates(updates).Error
+	})
+	if errTx != nil {
+		return stockErrorResponse(c, errTx, "Gagal membatalkan stock opname")
+	}
+
+	response, _ := findStockOpnameResponse(opnameID)
+	return utils.SuccessResponse(c, fiber.StatusOK, "Sesi stock opname dibatalkan", response)
+}
+
+// joinNote menambahkan catatan baru di bawah catatan yang sudah ada
+func joinNote(existing, note string) string {
+	if existing == "" {
+		return note
+	}
+	return existing + "\n" + note
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/database"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/dto"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/models"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// findSupplier mengambil supplier berdasarkan parameter :id
+func findSupplier(c *fiber.Ctx) (models.Supplier, error) {
+	var supplier models.Supplier
+	supplierID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return supplier, gorm.ErrRecordNotFound
+	}
+	err = database.DB.First(&supplier, uint(supplierID)).Error
+	return supplier, err
+}
+
+// supplierNotFoundResponse memetakan error pencarian supplier ke respons HTTP
+func supplierNotFoundResponse(c *fiber.Ctx, err error) error {
+	if err == gorm.ErrRecordNotFound {
+		return utils.ErrorResponse(c, fiber.StatusNotFound, "Supplier tidak ditemukan")
+	}
+	return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
+}
+
+// CreateSupplier membuat supplier baru
+func CreateSupplier(c *fiber.Ctx) error {
+	req := new(dto.CreateSupplierRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	req.Kode = strings.TrimSpace(req.Kode)
+	if err := validate.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	// Unscoped agar kode milik supplier yang sudah dihapus juga terdeteksi (unique index tetap berlaku)
+	var existing models.Supplier
+	if err := database.DB.Unscoped().Where("LOWER(kode) = LOWER(?)", req.Kode).First(&existing).Error; err == nil {
+		return utils.ErrorResponse(c, fiber.StatusConflict, fmt.Sprintf("Supplier dengan kode '%s' sudah ada.", existing.Kode))
+	} else if err != gorm.ErrRecordNotFound {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
+	}
+
+	supplier := models.Supplier{
+		Kode:         req.Kode,
+		Nama:         req.Nama,
+		Alamat:       req.Alamat,
+		NomorTelepon: req.NomorTelepon,
+		Email:        req.Email,
+		Kontak:       req.Kontak,
+		Catatan:      req.Catatan,
+	}
+	if err := database.DB.Create(&supplier).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal menyimpan supplier", err.Error())
+	}
+	return utils.SuccessResponse(c, fiber.StatusCreated, "Supplier berhasil dibuat", supplier)
+}
+
+// GetSuppliers mengambil daftar supplier dengan pagination dan pencarian kode/nama
+func GetSuppliers(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "10"))
+	if limit <= 0 {
+		limit = 10
+	}
+	if page <= 0 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+
+	query := database.DB.Model(&models.Supplier{})
+	if search := c.Query("search"); search != "" {
+		searchPattern := "%" + search + "%"
+		query = query.Where("kode ILIKE ? OR nama ILIKE ?", searchPattern, searchPattern)
+	}
+	query = query.Session(&gorm.Session{})
+
+	var totalRecords int64
+	if err := query.Count(&totalRecords).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal menghitung total supplier", err.Error())
+	}
+
+	suppliers := []models.Supplier{}
+	if err := query.Order("nama ASC").Offset(offset).Limit(limit).Find(&suppliers).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal mengambil data supplier", err.Error())
+	}
+
+	paginationData := fiber.Map{
+		"currentPage":  page,
+		"totalPages":   int(math.Ceil(float64(totalRecords) / float64(limit))),
+		"totalRecords": totalRecords,
+		"pageSize":     limit,
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success":    true,
+		"data":       suppliers,
+		"pagination": paginationData,
+		"message":    "Data supplier berhasil diambil",
+	})
+}
+
+// GetSupplierByID mengambil detail supplier
+func GetSupplierByID(c *fiber.Ctx) error {
+	supplier, err := findSupplier(c)
+	if err != nil {
+		return supplierNotFoundResponse(c, err)
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Supplier ditemukan", supplier)
+}
+
+// UpdateSupplier memperbarui data supplier
+func UpdateSupplier(c *fiber.Ctx) error {
+	supplier, err := findSupplier(c)
+	if err != nil {
+		return supplierNotFoundResponse(c, err)
+	}
+
+	req := new(dto.UpdateSupplierRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	if err := validate.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	if req.Nama != "" {
+		supplier.Nama = req.Nama
+	}
+	if req.Alamat != "" {
+		supplier.Alamat = req.Alamat
+	}
+	if req.NomorTelepon != "" {
+		supplier.NomorTelepon = req.NomorTelepon
+	}
+	if req.Email != "" {
+		supplier.Email = req.Email
+	}
+	if req.Kontak != "" {
+		supplier.Kontak = req.Kontak
+	}
+	if req.Catatan != "" {
+		supplier.Catatan = req.Catatan
+	}
+
+	if err := database.DB.Save(&supplier).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal memperbarui supplier", err.Error())
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Supplier berhasil diperbarui", supplier)
+}
+
+// DeleteSupplier menghapus supplier (soft delete). Supplier yang masih memiliki PO terbuka tidak dapat dihapus.
+func DeleteSupplier(c *fiber.Ctx) error {
+	supplier, err := findSupplier(c)
+	if err != nil {
+		return supplierNotFoundResponse(c, err)
+	}
+
+	var openCount int64
+	if err := database.DB.Model(&models.PurchaseOrder{}).
+		Where("supplier_id = ? AND status IN ?", supplier.ID, []string{models.PurchaseOrderStatusDraft, models.PurchaseOrderStatusDikirim, models.PurchaseOrderStatusDiterimaSebagian}).
+		Count(&openCount).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
+	}
+	if openCount > 0 {
+		return utils.ErrorResponse(c, fiber.StatusConflict, fmt.Sprintf("Supplier masih memiliki %d purchase order yang belum selesai.", openCount))
+	}
+
+	if err := database.DB.Delete(&supplier).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal menghapus supplier", err.Error())
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Supplier berhasil dihapus", nil)
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/database"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/dto"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/models"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// deletedAtPtr mengubah gorm.DeletedAt menjadi *time.Time untuk respons
+func deletedAtPtr(d gorm.DeletedAt) *time.Time {
+	if !d.Valid {
+		return nil
+	}
+	t := d.Time
+	return &t
+}
+
+// mapTreatmentCatalogToResponse mengubah models.TreatmentCatalog menjadi dto.TreatmentCatalogResponse
+func mapTreatmentCatalogToResponse(t models.TreatmentCatalog) dto.TreatmentCatalogResponse {
+	return dto.TreatmentCatalogResponse{
+		ID:        t.ID,
+		Kode:      t.Kode,
+		Nama:      t.Nama,
+		Kategori:  t.Kategori,
+		Harga:     t.Harga,
+		Deskripsi: t.Deskripsi,
+		CreatedAt: t.CreatedAt,
+		UpdatedAt: t.UpdatedAt,
+		DeletedAt: deletedAtPtr(t.DeletedAt),
+	}
+}
+
+// CreateTreatmentCatalog membuat master tindakan baru
+func CreateTreatmentCatalog(c *fiber.Ctx) error {
+	req := new(dto.CreateTreatmentCatalogRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	req.Kode = strings.TrimSpace(req.Kode)
+	if err := validate.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	// Unscoped agar kode milik data yang sudah dihapus juga terdeteksi (unique index tetap berlaku)
+	var existing models.TreatmentCatalog
+	if err := database.DB.Unscoped().Where("LOWER(kode) = LOWER(?)", req.Kode).First(&existing).Error; err == nil {
+		if existing.DeletedAt.Valid {
+			return utils.ErrorResponse(c, fiber.StatusConflict, fmt.Sprintf("Tindakan dengan kode '%s' pernah dihapus. Pulihkan data tersebut alih-alih membuat baru.", existing.Kode))
+		}
+		return utils.ErrorResponse(c, fiber.StatusConflict, fmt.Sprintf("Tindakan dengan kode '%s' sudah ada.", existing.Kode))
+	} else if err != gorm.ErrRecordNotFound {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
+	}
+
+	treatment := models.TreatmentCatalog{
+		Kode:      req.Kode,
+		Nama:      req.Nama,
+		Kategori:  req.Kategori,
+		Harga:     req.Harga,
+		Deskripsi: req.Deskripsi,
+	}
+	if err := database.DB.Create(&treatment).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal menyimpan master tindakan", err.Error())
+	}
+	return utils.SuccessResponse(c, fiber.StatusCreated, "Master tindakan berhasil dibuat", mapTreatmentCatalogToResponse(treatment))
+}
+
+// GetTreatmentCatalogs mengambil daftar master tindakan dengan pagination dan pencarian
+func GetTreatmentCatalogs(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "10"))
+	if limit <= 0 {
+		limit = 10
+	}
+	if page <= 0 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+
+	query := database.DB.Model(&models.TreatmentCatalog{})
+	if c.Query("includeDeleted") == "true" {
+		query = query.Unscoped()
+	}
+	if search := c.Query("search"); search != "" {
+		searchPattern := "%" + search + "%"
+		query = query.Where("kode ILIKE ? OR nama ILIKE ?", searchPattern, searchPattern)
+	}
+	if kategori := c.Query("kategori"); kategori != "" {
+		query = query.Where("kategori = ?", kategori)
+	}
+	query = query.Session(&gorm.Session{})
+
+	var totalRecords int64
+	if err := query.Count(&totalRecords).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal menghitung total master tindakan", err.Error())
+	}
+
+	var treatments []models.TreatmentCatalog
+	if err := query.Order("kode ASC").Offset(offset).Limit(limit).Find(&treatments).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal mengambil data master tindakan", err.Error())
+	}
+
+	treatmentResponses := []dto.TreatmentCatalogResponse{}
+	for _, t := range treatments {
+		treatmentResponses = append(treatmentResponses, mapTreatmentCatalogToResponse(t))
+	}
+
+	paginationData := fiber.Map{
+		"currentPage":  page,
+		"totalPages":   int(math.Ceil(float64(totalRecords) / float64(limit))),
+		"totalRecords": totalRecords,
+		"pageSize":     limit,
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success":    true,
+		"data":       treatmentResponses,
+		"pagination": paginationData,
+		"message":    "Data master tindakan berhasil diambil",
+	})
+}
+
+// GetTreatmentCatalogByKode mengambil master tindakan berdasarkan kode
+func GetTreatmentCatalogByKode(c *fiber.Ctx) error {
+	var treatment models.TreatmentCatalog
+	if err := database.DB.Where("kode = ?", c.Params("kode")).First(&treatment).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "Master tindakan tidak ditemukan")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Master tindakan ditemukan", mapTreatmentCatalogToResponse(treatment))
+}
+
+// UpdateTreatmentCatalog memperbarui master tindakan berdasarkan kode
+func UpdateTreatmentCatalog(c *fiber.Ctx) error {
+	var treatment models.TreatmentCatalog
+	if err := database.DB.Where("kode = ?", c.Params("kode")).First(&treatment).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "Master tindakan tidak ditemukan")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
+	}
+
+	req := new(dto.UpdateTreatmentCatalogRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	if err := validate.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	if req.Nama != "" {
+		treatment.Nama = req.Nama
+	}
+	if req.Kategori != "" {
+		treatment.Kategori = req.Kategori
+	}
+	if req.Harga != nil {
+		treatment.Harga = *req.Harga
+	}
+	if req.Deskripsi != "" {
+		treatment.Deskripsi = req.Deskripsi
+	}
+
+	if err := database.DB.Save(&treatment).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal memperbarui master tindakan", err.Error())
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Master tindakan berhasil diperbarui", mapTreatmentCatalogToResponse(treatment))
+}
+
+// DeleteTreatmentCatalog menghapus master tindakan (soft delete)
+func DeleteTreatmentCatalog(c *fiber.Ctx) error {
+	var treatment models.TreatmentCatalog
+	if err := database.DB.Where("kode = ?", c.Params("kode")).First(&treatment).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "Master tindakan tidak ditemukan")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
+	}
+
+	if err := database.DB.Delete(&treatment).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal menghapus master tindakan", err.Error())
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Master tindakan berhasil dihapus", nil)
+}
+
+// RestoreTreatmentCatalog memulihkan master tindakan yang sudah dihapus
+func RestoreTreatmentCatalog(c *fiber.Ctx) error {
+	var treatment models.TreatmentCatalog
+	if err := database.DB.Unscoped().Where("kode = ?", c.Params("kode")).First(&treatment).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "Master tindakan tidak ditemukan")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
+	}
+	if !treatment.DeletedAt.Valid {
+		return utils.ErrorResponse(c, fiber.StatusConflict, "Master tindakan tidak dalam keadaan terhapus")
+	}
+
+	if err := database.DB.Unscoped().Model(&treatment).Update("deleted_at", nil).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal memulihkan master tindakan", err.Error())
+	}
+	treatment.DeletedAt = gorm.DeletedAt{}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Master tindakan berhasil dipulihkan", mapTreatmentCatalogToResponse(treatment))
+}
@@ -0,0 +1,460 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/config"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/database"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/dto"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/models"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// recoveryCodeCount adalah jumlah kode pemulihan yang dibuat setiap kali 2FA diaktifkan atau kode dibuat ulang
+const recoveryCodeCount = 10
+
+// twoFactorError membawa status HTTP dan pesan untuk kegagalan 2FA yang disebabkan klien
+type twoFactorError struct {
+	Status  int
+	Message string
+}
+
+func (e *twoFactorError) Error() string { return e.Message }
+
+var (
+	errTwoFactorAlreadyEnabled = &twoFactorError{fiber.StatusConflict, "Autentikasi dua langkah sudah aktif"}
+	errTwoFactorNotStarted     = &twoFactorError{fiber.StatusBadRequest, "Mulai pendaftaran autentikasi dua langkah terlebih dahulu"}
+	errTwoFactorInvalidCode    = &twoFactorError{fiber.StatusUnauthorized, "Kode autentikasi tidak valid"}
+)
+
+// twoFactorChallengeResponse mengirim challenge token setelah password benar; sesi baru dibuat setelah langkah kedua
+func twoFactorChallengeResponse(c *fiber.Ctx, user models.User, purpose string) error {
+	token, err := utils.GenerateChallengeToken(user.ID, purpose)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal membuat challenge token", err.Error())
+	}
+	response := dto.TwoFactorChallengeResponse{
+		TwoFactorRequired: purpose == utils.ChallengeTwoFactor,
+		SetupRequired:     purpose == utils.ChallengeTwoFactorSetup,
+		ChallengeToken:    token,
+		ExpiresIn:         int64(config.AppConfig.TwoFactorChallengeExpiry.Seconds()),
+	}
+	message := "Masukkan kode dari aplikasi authenticator"
+	if response.SetupRequired {
+		message = "Role Anda mewajibkan autentikasi dua langkah, silakan daftarkan aplikasi authenticator"
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, message, response)
+}
+
+// userFromChallenge memvalidasi challenge token dan memuat pengguna yang masih boleh login
+func userFromChallenge(challengeToken string, purpose string) (models.User, error) {
+	var user models.User
+	userID, _, err := utils.ParseChallengeToken(challengeToken, purpose)
+	if err != nil {
+		return user, &twoFactorError{fiber.StatusUnauthorized, err.Error()}
+	}
+	if err := database.DB.Preload("RoleDetail").First(&user, userID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return user, &twoFactorError{fiber.StatusUnauthorized, "Challenge token tidak valid"}
+		}
+		return user, err
+	}
+	if user.Status != "aktif" {
+		return user, &twoFactorError{fiber.StatusForbidden, "Akun pengguna tidak aktif."}
+	}
+	if user.RoleDetail.ID == 0 {
+		return user, &twoFactorError{fiber.StatusForbidden, "Role pengguna sudah tidak aktif."}
+	}
+	return user, nil
+}
+
+// beginTwoFactorSetup membuat secret TOTP baru (belum aktif) untuk pengguna dan mengembalikan URI QR-nya
+func beginTwoFactorSetup(user *models.User) (dto.TwoFactorSetupResponse, error) {
+	if user.TwoFactorEnabled {
+		return dto.TwoFactorSetupResponse{}, errTwoFactorAlreadyEnabled
+	}
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return dto.TwoFactorSetupResponse{}, err
+	}
+	encrypted, err := utils.EncryptSecret(secret)
+	if err != nil {
+		return dto.TwoFactorSetupResponse{}, err
+	}
+	if err := database.DB.Model(user).Updates(map[string]interface{}{"two_factor_secret": encrypted, "two_factor_last_step": 0}).Error; err != nil {
+		return dto.TwoFactorSetupResponse{}, err
+	}
+	return dto.TwoFactorSetupResponse{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(config.AppConfig.TwoFactorIssuer, user.Username, secret),
+	}, nil
+}
+
+// verifyUserTOTP memeriksa kode authenticator; kode yang sama tidak bisa dipakai dua kali
+func verifyUserTOTP(db *gorm.DB, user *models.User, code string) (bool, error) {
+	if user.TwoFactorSecret == "" {
+		return false, nil
+	}
+	secret, err := utils.DecryptSecret(user.TwoFactorSecret)
+	if err != nil {
+		return false, err
+	}
+	step, ok := utils.VerifyTOTP(secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	// Update bersyarat agar dua request bersamaan dengan kode yang sama tidak sama-sama lolos
+	result := db.Model(&models.User{}).
+		Where("id = ? AND two_factor_last_step < ?", user.ID, step).
+		Update("two_factor_last_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// consumeRecoveryCode menandai kode pemulihan sebagai terpakai; false jika kode salah atau sudah dipakai
+func consumeRecoveryCode(db *gorm.DB, userID uint, code string) (bool, error) {
+	result := db.Model(&models.UserRecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, utils.HashToken(utils.NormalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// verifyTwoFactorCode menerima kode authenticator atau, jika gagal, kode pemulihan
+func verifyTwoFactorCode(db *gorm.DB, user *models.User, code string) (bool, error) {
+	ok, err := verifyUserTOTP(db, user, code)
+	if err != nil || ok {
+		return ok, err
+	}
+	return consumeRecoveryCode(db, user.ID, code)
+}
+
+// replaceRecoveryCodes mengganti seluruh kode pemulihan pengguna dan mengembalikan kode baru dalam bentuk asli
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.UserRecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]models.UserRecoveryCode, 0, len(codes))
+	for _, code := range codes {
+		records = append(records, models.UserRecoveryCode{UserID: userID, CodeHash: utils.HashToken(utils.NormalizeRecoveryCode(code))})
+	}
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// confirmTwoFactorSetup mengaktifkan 2FA setelah pengguna membuktikan authenticator-nya menghasilkan kode yang benar
+func confirmTwoFactorSetup(user *models.User, code string) ([]string, error) {
+	if user.TwoFactorEnabled {
+		return nil, errTwoFactorAlreadyEnabled
+	}
+	if user.TwoFactorSecret == "" {
+		return nil, errTwoFactorNotStarted
+	}
+
+	var codes []string
+	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		ok, err := verifyUserTOTP(tx, user, code)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errTwoFactorInvalidCode
+		}
+		now := time.Now()
+		if err := tx.Model(user).Updates(map[string]interface{}{"two_factor_enabled": true, "two_factor_enabled_at": now}).Error; err != nil {
+			return err
+		}
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if errTx != nil {
+		return nil, errTx
+	}
+	return codes, nil
+}
+
+// twoFactorErrorResponse memetakan error pendaftaran 2FA ke respons HTTP
+func twoFactorErrorResponse(c *fiber.Ctx, err error) error {
+	var tfErr *twoFactorError
+	if errors.As(err, &tfErr) {
+		return utils.ErrorResponse(c, tfErr.Status, tfErr.Message)
+	}
+	return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal memproses autentikasi dua langkah", err.Error())
+}
+
+// VerifyTwoFactorLogin menyelesaikan login dua langkah dengan kode authenticator atau kode pemulihan
+func VerifyTwoFactorLogin(c *fiber.Ctx) error {
+	req := new(dto.TwoFactorLoginRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	if err := validateAuth.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	user, err := userFromChallenge(req.ChallengeToken, utils.ChallengeTwoFactor)
+	if err != nil {
+		return twoFactorErrorResponse(c, err)
+	}
+	if !user.TwoFactorEnabled { // 2FA direset admin setelah challenge dibuat
+		return utils.ErrorResponse(c, fiber.StatusUnauthorized, "Challenge token tidak valid, silakan login ulang")
+	}
+
+	keys := loginThrottleKeys(user.Username, c.IP())
+	if handled, err := guardLogin(c, keys, user.Username, &user.ID); handled {
+		return err
+	}
+
+	var ok bool
+	if req.Code != "" {
+		ok, err = verifyUserTOTP(database.DB, &user, req.Code)
+	} else {
+		ok, err = consumeRecoveryCode(database.DB, user.ID, req.RecoveryCode)
+	}
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal memverifikasi kode", err.Error())
+	}
+	if !ok {
+		return failLogin(c, keys, user.Username, &user.ID, models.LoginEventTwoFactorFailed, "Kode autentikasi tidak valid")
+	}
+
+	return completeLogin(c, user, nil)
+}
+
+// SetupTwoFactorLogin memulai pendaftaran 2FA di tengah login untuk role yang mewajibkan 2FA
+func SetupTwoFactorLogin(c *fiber.Ctx) error {
+	req := new(dto.TwoFactorChallengeSetupRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	if err := validateAuth.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	user, err := userFromChallenge(req.ChallengeToken, utils.ChallengeTwoFactorSetup)
+	if err != nil {
+		return twoFactorErrorResponse(c, err)
+	}
+	response, err := beginTwoFactorSetup(&user)
+	if err != nil {
+		return twoFactorErrorResponse(c, err)
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Pindai QR code dengan aplikasi authenticator lalu konfirmasi dengan kode pertama", response)
+}
+
+// EnableTwoFactorLogin mengonfirmasi pendaftaran 2FA di tengah login lalu menyelesaikan login.
+// Kode pemulihan ikut dikirim di respons login dan tidak dapat dilihat lagi.
+func EnableTwoFactorLogin(c *fiber.Ctx) error {
+	req := new(dto.TwoFactorEnableRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	if err := validateAuth.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+	if req.ChallengeToken == "" {
+		return utils.ValidationErrorResponse(c, "challengeToken wajib diisi")
+	}
+
+	user, err := userFromChallenge(req.ChallengeToken, utils.ChallengeTwoFactorSetup)
+	if err != nil {
+		return twoFactorErrorResponse(c, err)
+	}
+	keys := loginThrottleKeys(user.Username, c.IP())
+	if handled, err := guardLogin(c, keys, user.Username, &user.ID); handled {
+		return err
+	}
+	codes, err := confirmTwoFactorSetup(&user, req.Code)
+	if err == errTwoFactorInvalidCode {
+		return failLogin(c, keys, user.Username, &user.ID, models.LoginEventTwoFactorFailed, "Kode autentikasi tidak valid")
+	}
+	if err != nil {
+		return twoFactorErrorResponse(c, err)
+	}
+	return completeLogin(c, user, codes)
+}
+
+// GetMyTwoFactorStatus menampilkan status 2FA milik pengguna yang sedang login
+func GetMyTwoFactorStatus(c *fiber.Ctx) error {
+	var user models.User
+	if err := database.DB.First(&user, c.Locals("user_id").(uint)).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusNotFound, "User tidak ditemukan")
+	}
+
+	var remaining int64
+	if user.TwoFactorEnabled {
+		database.DB.Model(&models.UserRecoveryCode{}).Where("user_id = ? AND used_at IS NULL", user.ID).Count(&remaining)
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Status autentikasi dua langkah berhasil diambil", dto.TwoFactorStatusResponse{
+		Enabled:                user.TwoFactorEnabled,
+		Required:               config.AppConfig.TwoFactorRequiredForRole(user.Role),
+		EnabledAt:              user.TwoFactorEnabledAt,
+		RecoveryCodesRemaining: remaining,
+	})
+}
+
+// SetupMyTwoFactor memulai pendaftaran 2FA milik sendiri; memanggil ulang sebelum konfirmasi akan mengganti secret
+func SetupMyTwoFactor(c *fiber.Ctx) error {
+	var user models.User
+	if err := database.DB.First(&user, c.Locals("user_id").(uint)).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusNotFound, "User tidak ditemukan")
+	}
+	response, err := beginTwoFactorSetup(&user)
+	if err != nil {
+		return twoFactorErrorResponse(c, err)
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Pindai QR code dengan aplikasi authenticator lalu konfirmasi dengan kode pertama", response)
+}
+
+// EnableMyTwoFactor mengonfirmasi pendaftaran 2FA milik sendiri dan mengembalikan kode pemulihan
+func EnableMyTwoFactor(c *fiber.Ctx) error {
+	req := new(dto.TwoFactorEnableRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	if err := validateAuth.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, c.Locals("user_id").(uint)).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusNotFound, "User tidak ditemukan")
+	}
+	codes, err := confirmTwoFactorSetup(&user, req.Code)
+	if err != nil {
+		return twoFactorErrorResponse(c, err)
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Autentikasi dua langkah berhasil diaktifkan. Simpan kode pemulihan di tempat aman.",
+		dto.TwoFactorRecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableMyTwoFactor menonaktifkan 2FA milik sendiri; ditolak jika role mewajibkan 2FA
+func DisableMyTwoFactor(c *fiber.Ctx) error {
+	req := new(dto.TwoFactorDisableRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	if err := validateAuth.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, c.Locals("user_id").(uint)).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusNotFound, "User tidak ditemukan")
+	}
+	if !user.TwoFactorEnabled {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Autentikasi dua langkah belum aktif")
+	}
+	if config.AppConfig.TwoFactorRequiredForRole(user.Role) {
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "Role Anda mewajibkan autentikasi dua langkah")
+	}
+	if !utils.CheckPasswordHash(req.Password, user.PasswordHash) {
+		return utils.ErrorResponse(c, fiber.StatusUnauthorized, "Password tidak sesuai")
+	}
+	ok, err := verifyTwoFactorCode(database.DB, &user, req.Code)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal memverifikasi kode", err.Error())
+	}
+	if !ok {
+		return utils.ErrorResponse(c, fiber.StatusUnauthorized, "Kode autentikasi tidak valid")
+	}
+
+	if err := clearTwoFactor(database.DB, user.ID); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal menonaktifkan autentikasi dua langkah", err.Error())
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Autentikasi dua langkah berhasil dinonaktifkan", nil)
+}
+
+// RegenerateMyRecoveryCodes membuat ulang kode pemulihan; kode lama otomatis tidak berlaku
+func RegenerateMyRecoveryCodes(c *fiber.Ctx) error {
+	req := new(dto.TwoFactorCodeRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
+	}
+	if err := validateAuth.Struct(req); err != nil {
+		return utils.ValidationErrorResponse(c, err.Error())
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, c.Locals("user_id").(uint)).Error; err != nil {
+		return utils.ErrorResponse(c, fiber.StatusNotFound, "User tidak ditemukan")
+	}
+	if !user.TwoFactorEnabled {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Autentikasi dua langkah belum aktif")
+	}
+
+	var codes []string
+	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		ok, err := verifyUserTOTP(tx, &user, req.Code)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errTwoFactorInvalidCode
+		}
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if errTx != nil {
+		return twoFactorErrorResponse(c, errTx)
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Kode pemulihan baru berhasil dibuat. Simpan di tempat aman.",
+		dto.TwoFactorRecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// clearTwoFactor menghapus secret, status dan kode pemulihan 2FA pengguna
+func clearTwoFactor(tx *gorm.DB, userID uint) error {
+	err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"two_factor_enabled":    false,
+		"two_factor_secret":     "",
+		"two_factor_enabled_at": nil,
+		"two_factor_last_step":  0,
+	}).Error
+	if err != nil {
+		return err
+	}
+	return tx.Where("user_id = ?", userID).Delete(&models.UserRecoveryCode{}).Error
+}
+
+// ResetUserTwoFactor mereset 2FA pengguna (admin), mis. karena ponsel hilang.
+// Semua sesi pengguna dicabut; jika role mewajibkan 2FA, pengguna mendaftar ulang saat login berikutnya.
+func ResetUserTwoFactor(c *fiber.Ctx) error {
+	userID, err := strconv.ParseUint(c.Params("userId"), 10, 32)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "User ID tidak valid")
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, uint(userID)).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.ErrorResponse(c, fiber.StatusNotFound, "Pengguna tidak ditemukan")
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
+	}
+
+	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := clearTwoFactor(tx, user.ID); err != nil {
+			return err
+		}
+		return revokeUserSessions(tx, user.ID, models.SessionRevokedTwoFactor)
+	})
+	if errTx != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal mereset autentikasi dua langkah", errTx.Error())
+	}
+	return utils.SuccessResponse(c, fiber.StatusOK, "Autentikasi dua langkah pengguna berhasil direset", nil)
+}
@@ -23,12 +23,9 @@ var userValidate = validator.New()
 func RegisterUserByAdmin(c *fiber.Ctx) error {
 	req := new(dto.CreateUserRequest)
 	if err := c.BodyParser(req); err != nil {
-		fmt.Printf("[RegisterUserByAdmin] Error parsing body: %v. Body: %s\n", err, string(c.Body()))
 		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid, format body salah", err.Error())
 	}
 
-	fmt.Printf("[RegisterUserByAdmin] Received CreateUserRequest: %+v\n", req)
-
 	if err := userValidate.Struct(req); err != nil {
 		fmt.Printf("[RegisterUserByAdmin] Validation errors: %+v\n", err)
 		var errors []string
@@ -38,6 +35,18 @@ func RegisterUserByAdmin(c *fiber.Ctx) error {
 		return utils.ValidationErrorResponse(c, errors)
 	}
 
+	role, err := findRoleByKode(database.DB, req.Role)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.ErrorResponse(c, fiber.StatusBadRequest, fmt.Sprintf("Role '%s' tidak ditemukan.", req.Role))
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server saat memeriksa role", err.Error())
+	}
+
+	if violations := utils.ValidatePasswordPolicy(req.Password, req.Username); len(violations) > 0 {
+		return utils.ValidationErrorResponse(c, violations)
+	}
+
 	hashedPassword, err := utils.HashPassword(req.Password)
 	if err != nil {
 		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal hashing password", err.Error())
@@ -53,7 +62,7 @@ func RegisterUserByAdmin(c *fiber.Ctx) error {
 		Username:     req.Username,
 		Email:        req.Email,
 		PasswordHash: hashedPassword,
-		Role:         req.Role,
+		Role:         role.Kode,
 		Status:       status,
 		PhoneNumber:  req.PhoneNumber,
 	}
@@ -78,15 +87,16 @@ func RegisterUserByAdmin(c *fiber.Ctx) error {
 	}
 
 	responseUser := dto.UserResponse{
-		ID:          newUser.ID,
-		NamaLengkap: newUser.NamaLengkap,
-		Username:    newUser.Username,
-		Email:       newUser.Email,
-		Role:        newUser.Role,
-		Status:      newUser.Status,
-		PhoneNumber: newUser.PhoneNumber,
-		CreatedAt:   newUser.CreatedAt,
-		UpdatedAt:   newUser.UpdatedAt,
+		ID:               newUser.ID,
+		NamaLengkap:      newUser.NamaLengkap,
+		Username:         newUser.Username,
+		Email:            newUser.Email,
+		Role:             newUser.Role,
+		Status:           newUser.Status,
+		PhoneNumber:      newUser.PhoneNumber,
+		TwoFactorEnabled: newUser.TwoFactorEnabled,
+		CreatedAt:        newUser.CreatedAt,
+		UpdatedAt:        newUser.UpdatedAt,
 	}
 	return utils.SuccessResponse(c, fiber.StatusCreated, "Pengguna berhasil diregistrasi", responseUser)
 }
@@ -133,16 +143,17 @@ func GetAllUsers(c *fiber.Ctx) error {
 	userResponses := []dto.UserResponse{}
 	for _, user := range users {
 		userResponses = append(userResponses, dto.UserResponse{
-			ID:          user.ID,
-			NamaLengkap: user.NamaLengkap,
-			Username:    user.Username,
-			Email:       user.Email,
-			Role:        user.Role,
-			Status:      user.Status,
-			PhoneNumber: user.PhoneNumber,
-			LastLogin:   user.LastLogin,
-			CreatedAt:   user.CreatedAt,
-			UpdatedAt:   user.UpdatedAt,
+			ID:               user.ID,
+			NamaLengkap:      user.NamaLengkap,
+			Username:         user.Username,
+			Email:            user.Email,
+			Role:             user.Role,
+			Status:           user.Status,
+			PhoneNumber:      user.PhoneNumber,
+			TwoFactorEnabled: user.TwoFactorEnabled,
+			LastLogin:        user.LastLogin,
+			CreatedAt:        user.CreatedAt,
+			UpdatedAt:        user.UpdatedAt,
 		})
 	}
 
@@ -185,16 +196,17 @@ func GetUserByID(c *fiber.Ctx) error {
 	}
 
 	responseUser := dto.UserResponse{
-		ID:          user.ID,
-		NamaLengkap: user.NamaLengkap,
-		Username:    user.Username,
-		Email:       user.Email,
-		Role:        user.Role,
-		Status:      user.Status,
-		PhoneNumber: user.PhoneNumber,
-		LastLogin:   user.LastLogin,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
+		ID:               user.ID,
+		NamaLengkap:      user.NamaLengkap,
+		Username:         user.Username,
+		Email:            user.Email,
+		Role:             user.Role,
+		Status:           user.Status,
+		PhoneNumber:      user.PhoneNumber,
+		TwoFactorEnabled: user.TwoFactorEnabled,
+		LastLogin:        user.LastLogin,
+		CreatedAt:        user.CreatedAt,
+		UpdatedAt:        user.UpdatedAt,
 	}
 	return utils.SuccessResponse(c, fiber.StatusOK, "Data pengguna berhasil diambil", responseUser)
 }
@@ -217,10 +229,8 @@ func UpdateUser(c *fiber.Ctx) error {
 
 	req := new(dto.UpdateUserRequest)
 	if err := c.BodyParser(req); err != nil {
-		fmt.Printf("[UpdateUser] Error parsing body: %v. Body: %s\n", err, string(c.Body()))
 		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Request tidak valid", err.Error())
 	}
-	fmt.Printf("[UpdateUser] Received UpdateUserRequest for ID %d: %+v\n", userID, req)
 
 	if err := userValidate.Struct(req); err != nil {
 		fmt.Printf("[UpdateUser] Validation errors: %+v\n", err)
@@ -248,6 +258,8 @@ func UpdateUser(c *fiber.Ctx) error {
 		}
 	}
 
+	previousRole, previousStatus := user.Role, user.Status
+
 	// Update fields yang diizinkan
 	if req.NamaLengkap != "" {
 		user.NamaLengkap = req.NamaLengkap
@@ -259,7 +271,14 @@ func UpdateUser(c *fiber.Ctx) error {
 		user.Email = req.Email
 	}
 	if req.Role != "" {
-		user.Role = req.Role
+		role, errRole := findRoleByKode(database.DB, req.Role)
+		if errRole != nil {
+			if errRole == gorm.ErrRecordNotFound {
+				return utils.ErrorResponse(c, fiber.StatusBadRequest, fmt.Sprintf("Role '%s' tidak ditemukan.", req.Role))
+			}
+			return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server saat memeriksa role", errRole.Error())
+		}
+		user.Role = role.Kode
 	}
 	if req.Status != "" {
 		user.Status = req.Status
@@ -268,7 +287,11 @@ func UpdateUser(c *fiber.Ctx) error {
 		user.PhoneNumber = req.PhoneNumber
 	}
 
-	if req.Password != "" { // Jika password ingin diubah
+	passwordChanged := req.Password != ""
+	if passwordChanged { // Jika password ingin diubah
+		if err := checkNewPassword(database.DB, user, req.Password); err != nil {
+			return passwordErrorResponse(c, err)
+		}
 		hashedPassword, errHash := utils.HashPassword(req.Password)
 		if errHash != nil {
 			return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal hashing password baru", errHash.Error())
@@ -276,21 +299,41 @@ func UpdateUser(c *fiber.Ctx) error {
 		user.PasswordHash = hashedPassword
 	}
 
-	if err := database.DB.Save(&user).Error; err != nil {
-		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal memperbarui pengguna", err.Error())
+	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		// Password diganti admin: dicatat ke riwayat dan semua sesi dicabut
+		if passwordChanged {
+			if err := recordPasswordChange(tx, user.ID, user.PasswordHash); err != nil {
+				return err
+			}
+		}
+		// Nonaktif atau ganti role: semua sesi dicabut agar token lama tidak bisa dipakai lagi
+		if user.Status != "aktif" && previousStatus == "aktif" {
+			return revokeUserSessions(tx, user.ID, models.SessionRevokedUserStatus)
+		}
+		if user.Role != previousRole {
+			return revokeUserSessions(tx, user.ID, models.SessionRevokedRoleChanged)
+		}
+		return nil
+	})
+	if errTx != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal memperbarui pengguna", errTx.Error())
 	}
 
 	responseUser := dto.UserResponse{
-		ID:          user.ID,
-		NamaLengkap: user.NamaLengkap,
-		Username:    user.Username,
-		Email:       user.Email,
-		Role:        user.Role,
-		Status:      user.Status,
-		PhoneNumber: user.PhoneNumber,
-		LastLogin:   user.LastLogin,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
+		ID:               user.ID,
+		NamaLengkap:      user.NamaLengkap,
+		Username:         user.Username,
+		Email:            user.Email,
+		Role:             user.Role,
+		Status:           user.Status,
+		PhoneNumber:      user.PhoneNumber,
+		TwoFactorEnabled: user.TwoFactorEnabled,
+		LastLogin:        user.LastLogin,
+		CreatedAt:        user.CreatedAt,
+		UpdatedAt:        user.UpdatedAt,
 	}
 	return utils.SuccessResponse(c, fiber.StatusOK, "Data pengguna berhasil diperbarui", responseUser)
 }
@@ -311,8 +354,14 @@ func DeleteUser(c *fiber.Ctx) error {
 		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Kesalahan server database", err.Error())
 	}
 
-	if err := database.DB.Delete(&user).Error; err != nil {
-		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal menghapus pengguna", err.Error())
+	errTx := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&user).Error; err != nil {
+			return err
+		}
+		return revokeUserSessions(tx, user.ID, models.SessionRevokedUserDeleted)
+	})
+	if errTx != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal menghapus pengguna", errTx.Error())
 	}
 
 	return utils.SuccessResponse(c, fiber.StatusOK, "Pengguna berhasil dihapus (dinonaktifkan)", nil)
@@ -0,0 +1,223 @@
+package middleware
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/database" // Sesuaikan path
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/models"   // Sesuaikan path
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// AuditSnapshot adalah keadaan satu entitas pada saat tertentu, dipakai untuk menyusun diff sebelum/sesudah
+type AuditSnapshot struct {
+	EntityID  uint
+	PatientID *uint
+	Data      interface{}
+}
+
+// AuditLoader memuat snapshot entitas dari parameter rute (ID atau kode lain seperti NoRM/VisitID).
+// full=false dipakai untuk pembacaan sehingga relasi tidak perlu dimuat. Kembalikan nil jika tidak ditemukan.
+type AuditLoader func(key string, full bool) (*AuditSnapshot, error)
+
+// AuditTarget menjelaskan entitas yang diaudit pada satu rute
+type AuditTarget struct {
+	EntityType   string
+	Param        string // Parameter rute berisi ID entitas, kosong untuk rute daftar/pembuatan
+	PatientParam string // Parameter rute berisi ID pasien, untuk daftar yang difilter per pasien
+	Load         AuditLoader
+}
+
+// auditLogger dipakai untuk melaporkan kegagalan audit; diisi lewat SetAuditLogger saat rute disiapkan
+var auditLogger = zap.NewNop()
+
+// SetAuditLogger mengatur logger aplikasi untuk middleware audit
+func SetAuditLogger(logger *zap.Logger) {
+	if logger != nil {
+		auditLogger = logger
+	}
+}
+
+// auditIgnoredFields tidak dimasukkan ke diff karena selalu berubah dan tidak bermakna bagi auditor
+var auditIgnoredFields = map[string]bool{"createdAt": true, "updatedAt": true}
+
+// Audit mencatat aksi yang berhasil (status 2xx) pada entitas target ke audit_logs.
+// Untuk update dan delete, snapshot diambil sebelum handler dijalankan agar diff sebelum/sesudah bisa dibuat.
+// Jika audit log gagal ditulis, pembacaan dibatalkan dengan 500 agar data tidak keluar tanpa jejak;
+// perubahan data sudah tersimpan sehingga responsnya tetap dikirim dan kegagalan dicatat sebagai error.
+func Audit(action string, target AuditTarget) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := ""
+		if target.Param != "" {
+			key = c.Params(target.Param)
+		}
+
+		var before *AuditSnapshot
+		if (action == models.AuditActionUpdate || action == models.AuditActionDelete) && key != "" && target.Load != nil {
+			snapshot, err := target.Load(key, true)
+			if err != nil {
+				auditLogger.Warn("Gagal memuat snapshot audit", zap.String("entityType", target.EntityType), zap.String("key", key), zap.Error(err))
+			}
+			before = snapshot
+		}
+
+		if err := c.Next(); err != nil {
+			return err
+		}
+		if status := c.Response().StatusCode(); status < 200 || status >= 300 {
+			return nil
+		}
+
+		entry := models.AuditLog{
+			Action:     action,
+			EntityType: target.EntityType,
+			IPAddress:  c.IP(),
+			UserAgent:  c.Get(fiber.HeaderUserAgent),
+			Method:     c.Method(),
+			Path:       c.OriginalURL(),
+		}
+		if userID, ok := c.Locals("user_id").(uint); ok {
+			entry.ActorID = &userID
+		}
+		entry.ActorUsername, _ = c.Locals("username").(string)
+		entry.ActorRole, _ = c.Locals("role").(string)
+		if target.PatientParam != "" {
+			if patientID, err := strconv.ParseUint(c.Params(target.PatientParam), 10, 32); err == nil {
+				id := uint(patientID)
+				entry.PatientID = &id
+			}
+		}
+
+		var after *AuditSnapshot
+		switch action {
+		case models.AuditActionCreate:
+			if id := createdEntityID(c.Response().Body()); id != 0 && target.Load != nil {
+				after, _ = target.Load(strconv.FormatUint(uint64(id), 10), true)
+			}
+		case models.AuditActionUpdate:
+			if target.Load != nil {
+				// Gunakan ID dari snapshot awal karena kode di rute (mis. NoRM) bisa ikut berubah
+				if before != nil {
+					key = strconv.FormatUint(uint64(before.EntityID), 10)
+				}
+				if key != "" {
+					after, _ = target.Load(key, true)
+				}
+			}
+		case models.AuditActionRead:
+			if key != "" && target.Load != nil {
+				after, _ = target.Load(key, false)
+			}
+		}
+
+		for _, snapshot := range []*AuditSnapshot{before, after} {
+			if snapshot != nil {
+				entry.EntityID = snapshot.EntityID
+				if snapshot.PatientID != nil {
+					entry.PatientID = snapshot.PatientID
+				}
+			}
+		}
+		if action != models.AuditActionRead {
+			entry.Changes = auditDiff(before, after)
+		}
+
+		if err := database.DB.Create(&entry).Error; err != nil {
+			auditLogger.Error("Gagal menulis audit log",
+				zap.String("action", action),
+				zap.String("entityType", target.EntityType),
+				zap.Uint("entityId", entry.EntityID),
+				zap.String("method", entry.Method),
+				zap.String("path", entry.Path),
+				zap.Error(err))
+			if action == models.AuditActionRead {
+				c.Response().Header.Del(fiber.HeaderContentDisposition)
+				return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal mencatat jejak audit, silakan coba lagi")
+			}
+		}
+		return nil
+	}
+}
+
+// createdEntityID membaca data.id dari respons sukses standar
+func createdEntityID(body []byte) uint {
+	var payload struct {
+		Data struct {
+			ID uint `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return 0
+	}
+	return payload.Data.ID
+}
+
+// auditFields mengubah snapshot menjadi map field JSON tanpa field yang diabaikan
+func auditFields(snapshot *AuditSnapshot) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if snapshot == nil || snapshot.Data == nil {
+		return fields
+	}
+	raw, err := json.Marshal(snapshot.Data)
+	if err != nil {
+		return fields
+	}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return fields
+	}
+	return stripAuditIgnoredFields(fields).(map[string]interface{})
+}
+
+// stripAuditIgnoredFields menghapus timestamp teknis secara rekursif, termasuk di item relasi
+func stripAuditIgnoredFields(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if auditIgnoredFields[k] {
+				delete(v, k)
+				continue
+			}
+			v[k] = stripAuditIgnoredFields(child)
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = stripAuditIgnoredFields(child)
+		}
+		return v
+	}
+	return value
+}
+
+// auditDiff menyusun {"field": {"before": ..., "after": ...}} untuk field yang berbeda
+func auditDiff(before, after *AuditSnapshot) json.RawMessage {
+	beforeFields, afterFields := auditFields(before), auditFields(after)
+
+	type change struct {
+		Before interface{} `json:"before"`
+		After  interface{} `json:"after"`
+	}
+	changes := map[string]change{}
+	for k, v := range beforeFields {
+		if !reflect.DeepEqual(v, afterFields[k]) {
+			changes[k] = change{Before: v, After: afterFields[k]}
+		}
+	}
+	for k, v := range afterFields {
+		if _, seen := beforeFields[k]; !seen && v != nil {
+			changes[k] = change{After: v}
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	raw, err := json.Marshal(changes)
+	if err != nil {
+		return nil
+	}
+	return raw
+}
@@ -2,9 +2,12 @@ package middleware
 
 import (
 	"strings"
+	"time"
 
-	"github.com/MadeAgus22/dental-clinic-backend/pkg/config" // Sesuaikan path
-	"github.com/MadeAgus22/dental-clinic-backend/pkg/utils"  // Sesuaikan path
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/config"   // Sesuaikan path
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/database" // Sesuaikan path
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/models"   // Sesuaikan path
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/utils"    // Sesuaikan path
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
@@ -12,7 +15,6 @@ import (
 
 // JWTMiddleware memverifikasi token JWT
 func JWTMiddleware() fiber.Handler {
-	cfg := config.AppConfig
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
@@ -24,44 +26,103 @@ func JWTMiddleware() fiber.Handler {
 			return utils.ErrorResponse(c, fiber.StatusUnauthorized, "Format header Authorization tidak valid (expected: Bearer <token>)")
 		}
 
-		tokenString := parts[1]
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fiber.NewError(fiber.StatusUnauthorized, "Metode signing token tidak terduga")
-			}
-			return []byte(cfg.JWTSecretKey), nil
-		})
+		return authenticateToken(c, parts[1])
+	}
+}
 
-		if err != nil {
-			return utils.ErrorResponse(c, fiber.StatusUnauthorized, "Token tidak valid atau kedaluwarsa", err.Error())
+// StreamJWTMiddleware sama dengan JWTMiddleware, tetapi juga menerima token dari query "token"
+// karena EventSource di browser tidak dapat mengirim header Authorization.
+func StreamJWTMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if authHeader := c.Get("Authorization"); authHeader != "" {
+			parts := strings.Split(authHeader, " ")
+			if len(parts) != 2 || parts[0] != "Bearer" {
+				return utils.ErrorResponse(c, fiber.StatusUnauthorized, "Format header Authorization tidak valid (expected: Bearer <token>)")
+			}
+			return authenticateToken(c, parts[1])
 		}
 
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			// Simpan informasi user dari token ke Locals untuk digunakan di handler selanjutnya
-			// Pastikan tipe data sesuai saat mengambil dari c.Locals()
-			c.Locals("user_id", uint(claims["user_id"].(float64))) // JWT numbers are float64
-			c.Locals("username", claims["username"].(string))
-			c.Locals("role", claims["role"].(string))
-			return c.Next()
+		tokenString := c.Query("token")
+		if tokenString == "" {
+			return utils.ErrorResponse(c, fiber.StatusUnauthorized, "Token tidak ada")
 		}
+		return authenticateToken(c, tokenString)
+	}
+}
+
+// lastSeenUpdateInterval membatasi seberapa sering LastSeenAt sesi ditulis ke database
+const lastSeenUpdateInterval = time.Minute
 
-		return utils.ErrorResponse(c, fiber.StatusUnauthorized, "Klaim token tidak valid")
+// findActiveSession mengambil sesi yang belum dicabut/kedaluwarsa dan pemiliknya masih aktif.
+// Mengembalikan nil jika sesi tidak aktif.
+func findActiveSession(sessionID, userID uint) (*models.UserSession, error) {
+	var sessions []models.UserSession
+	err := database.DB.Model(&models.UserSession{}).
+		Joins("JOIN users ON users.id = user_sessions.user_id AND users.deleted_at IS NULL").
+		Where("user_sessions.id = ? AND user_sessions.user_id = ?", sessionID, userID).
+		Where("user_sessions.revoked_at IS NULL AND user_sessions.expires_at > ?", time.Now()).
+		Where("users.status = ?", "aktif").
+		Limit(1).Find(&sessions).Error
+	if err != nil || len(sessions) == 0 {
+		return nil, err
 	}
+	return &sessions[0], nil
 }
 
-// AuthorizeRole adalah middleware untuk membatasi akses berdasarkan role
-func AuthorizeRole(allowedRoles ...string) fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		currentRole, ok := c.Locals("role").(string)
+// SessionIsActive memastikan sesi belum dicabut/kedaluwarsa dan pemiliknya masih aktif
+func SessionIsActive(sessionID, userID uint) (bool, error) {
+	session, err := findActiveSession(sessionID, userID)
+	return session != nil, err
+}
+
+// touchSession memperbarui waktu dan IP terakhir sesi, paling sering sekali per lastSeenUpdateInterval
+func touchSession(session *models.UserSession, ip string) {
+	if session.LastSeenAt != nil && time.Since(*session.LastSeenAt) < lastSeenUpdateInterval && session.IPAddress == ip {
+		return
+	}
+	database.DB.Model(session).Updates(map[string]interface{}{"last_seen_at": time.Now(), "ip_address": ip})
+}
+
+// authenticateToken memvalidasi token lalu menyimpan identitas pengguna ke Locals
+func authenticateToken(c *fiber.Ctx, tokenString string) error {
+	cfg := config.AppConfig
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fiber.NewError(fiber.StatusUnauthorized, "Metode signing token tidak terduga")
+		}
+		return []byte(cfg.JWTSecretKey), nil
+	})
+
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusUnauthorized, "Token tidak valid atau kedaluwarsa", err.Error())
+	}
+
+	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+		userID := uint(claims["user_id"].(float64)) // JWT numbers are float64
+		sessionIDClaim, ok := claims["session_id"].(float64)
 		if !ok {
-			return utils.ErrorResponse(c, fiber.StatusForbidden, "Role pengguna tidak dapat diidentifikasi")
+			return utils.ErrorResponse(c, fiber.StatusUnauthorized, "Token tidak terikat ke sesi login, silakan login ulang")
+		}
+		session, err := findActiveSession(uint(sessionIDClaim), userID)
+		if err != nil {
+			return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal memeriksa sesi login", err.Error())
 		}
+		if session == nil {
+			return utils.ErrorResponse(c, fiber.StatusUnauthorized, "Sesi login sudah berakhir atau dicabut")
+		}
+		touchSession(session, c.IP())
 
-		for _, role := range allowedRoles {
-			if currentRole == role {
-				return c.Next()
-			}
+		// Simpan informasi user dari token ke Locals untuk digunakan di handler selanjutnya
+		// Pastikan tipe data sesuai saat mengambil dari c.Locals()
+		c.Locals("user_id", userID)
+		c.Locals("session_id", uint(sessionIDClaim))
+		c.Locals("username", claims["username"].(string))
+		c.Locals("role", claims["role"].(string))
+		if roleID, ok := claims["role_id"].(float64); ok {
+			c.Locals("role_id", uint(roleID))
 		}
-		return utils.ErrorResponse(c, fiber.StatusForbidden, "Anda tidak memiliki izin untuk mengakses sumber daya ini")
+		return c.Next()
 	}
+
+	return utils.ErrorResponse(c, fiber.StatusUnauthorized, "Klaim token tidak valid")
 }
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/database"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/models"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// permissionCacheTTL membatasi umur cache agar perubahan dari instance server lain tetap terbaca
+const permissionCacheTTL = 5 * time.Minute
+
+type cachedPermissions struct {
+	permissions map[string]bool
+	loadedAt    time.Time
+}
+
+// permissionCache menyimpan daftar permission per ID role. Kunci memakai ID (bukan kode)
+// agar token lama tetap valid saat kode role diganti.
+var permissionCache = struct {
+	sync.RWMutex
+	roles map[uint]cachedPermissions
+}{roles: make(map[uint]cachedPermissions)}
+
+// loadRolePermissions mengambil kode permission milik sebuah role dari database
+func loadRolePermissions(roleID uint) (map[string]bool, error) {
+	var role models.Role
+	if err := database.DB.Preload("Permissions").First(&role, roleID).Error; err != nil {
+		return nil, err
+	}
+
+	permissions := make(map[string]bool, len(role.Permissions))
+	for _, p := range role.Permissions {
+		permissions[p.Kode] = true
+	}
+	return permissions, nil
+}
+
+// rolePermissions mengembalikan permission role dari cache, atau memuatnya dari database jika belum ada/kedaluwarsa.
+// Map yang dikembalikan dipakai bersama dan tidak boleh diubah.
+func rolePermissions(roleID uint) (map[string]bool, error) {
+	permissionCache.RLock()
+	cached, ok := permissionCache.roles[roleID]
+	permissionCache.RUnlock()
+	if ok && time.Since(cached.loadedAt) < permissionCacheTTL {
+		return cached.permissions, nil
+	}
+
+	permissions, err := loadRolePermissions(roleID)
+	if err != nil {
+		return nil, err
+	}
+	permissionCache.Lock()
+	permissionCache.roles[roleID] = cachedPermissions{permissions: permissions, loadedAt: time.Now()}
+	permissionCache.Unlock()
+	return permissions, nil
+}
+
+// InvalidateRolePermissions menghapus cache permission untuk role tertentu.
+// Dipanggil setiap kali role atau daftar permission-nya diubah/dihapus.
+func InvalidateRolePermissions(roleIDs ...uint) {
+	permissionCache.Lock()
+	for _, id := range roleIDs {
+		delete(permissionCache.roles, id)
+	}
+	permissionCache.Unlock()
+}
+
+// currentRoleID mengambil ID role dari token; token lama tanpa klaim role_id dicari berdasarkan kode role.
+// gorm.ErrRecordNotFound berarti role tidak dapat diidentifikasi; error lain adalah kegagalan database.
+func currentRoleID(c *fiber.Ctx) (uint, error) {
+	if roleID, ok := c.Locals("role_id").(uint); ok && roleID != 0 {
+		return roleID, nil
+	}
+	roleKode, ok := c.Locals("role").(string)
+	if !ok {
+		return 0, gorm.ErrRecordNotFound
+	}
+	var role models.Role
+	if err := database.DB.Select("id").Where("kode = ?", roleKode).First(&role).Error; err != nil {
+		return 0, err
+	}
+	c.Locals("role_id", role.ID)
+	return role.ID, nil
+}
+
+// RequirePermission membatasi akses hanya untuk role yang memiliki salah satu kode permission yang diberikan.
+// Daftar permission milik role disimpan di Locals("permissions") agar bisa dipakai handler.
+func RequirePermission(permissionKodes ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		permissions, ok := c.Locals("permissions").(map[string]bool)
+		if !ok {
+			// Role yang tidak ada berarti tidak berhak (403); kegagalan database bukan masalah otorisasi (500)
+			roleID, err := currentRoleID(c)
+			if err == gorm.ErrRecordNotFound {
+				return utils.ErrorResponse(c, fiber.StatusForbidden, "Role pengguna tidak dapat diidentifikasi")
+			}
+			if err != nil {
+				return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Gagal memuat role pengguna", err.Error())
+			}
+			permissions, err = rolePermissions(roleID)
+			if err == gorm.ErrRecordNotFound {
+				return utils.ErrorResponse(c, fiber.StatusForbidden, "Role pengguna tidak dapat diidentifikasi")
+			}
+			if err != nil {
+				return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Hak akses role tidak dapat dimuat", err.Error())
+			}
+			c.Locals("permissions", permissions)
+		}
+
+		for _, kode := range permissionKodes {
+			if permissions[kode] {
+				return c.Next()
+			}
+		}
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "Anda tidak memiliki izin untuk mengakses sumber daya ini")
+	}
+}
+
+// HasPermission memeriksa apakah pengguna pada request ini memiliki kode permission tertentu.
+// Hanya bisa dipakai setelah RequirePermission dijalankan pada rute yang sama.
+func HasPermission(c *fiber.Ctx, permissionKode string) bool {
+	permissions, ok := c.Locals("permissions").(map[string]bool)
+	return ok && permissions[permissionKode]
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditLog mencatat siapa membaca atau mengubah data pasien, EMR, reservasi, antrian, tagihan, pengguna dan role (UU PDP, Permenkes 24/2022).
+// Tabel ini append-only: trigger database menolak UPDATE, DELETE dan TRUNCATE (lihat database.ensureAppendOnly).
+type AuditLog struct {
+	ID            uint            `gorm:"primarykey" json:"id"`
+	CreatedAt     time.Time       `gorm:"index" json:"createdAt"`
+	ActorID       *uint           `gorm:"index" json:"actorId,omitempty"` // Pengguna yang melakukan aksi
+	ActorUsername string          `gorm:"type:varchar(100)" json:"actorUsername"`
+	ActorRole     string          `gorm:"type:varchar(50)" json:"actorRole"`
+	IPAddress     string          `gorm:"type:varchar(45)" json:"ipAddress"`
+	UserAgent     string          `gorm:"type:text" json:"userAgent,omitempty"`
+	Action        string          `gorm:"type:varchar(20);not null;index" json:"action"`
+	EntityType    string          `gorm:"type:varchar(50);not null;index:idx_audit_logs_entity" json:"entityType"`
+	EntityID      uint            `gorm:"index:idx_audit_logs_entity" json:"entityId,omitempty"` // 0 untuk pembacaan daftar
+	PatientID     *uint           `gorm:"index" json:"patientId,omitempty"`                      // Pasien pemilik data, untuk penelusuran per pasien
+	Method        string          `gorm:"type:varchar(10)" json:"method"`
+	Path          string          `gorm:"type:text" json:"path"`
+	Changes       json.RawMessage `gorm:"type:jsonb" json:"changes,omitempty"` // {"field": {"before": ..., "after": ...}}
+}
+
+// Aksi pada AuditLog
+const (
+	AuditActionRead   = "read"
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+)
+
+// Jenis entitas pada AuditLog
+const (
+	AuditEntityPatient       = "patient"
+	AuditEntityMedicalRecord = "medical_record"
+	AuditEntityUser          = "user"
+	AuditEntityRole          = "role"
+	AuditEntityReservation   = "reservation"
+	AuditEntityQueueEntry    = "queue_entry"
+	AuditEntityInvoice       = "invoice"
+	AuditEntityPayment       = "payment"
+)
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+)
+
+// Status tagihan. Status berpindah otomatis berdasarkan total pembayaran yang masuk.
+const (
+	InvoiceStatusBelumLunas = "Belum Lunas"
+	InvoiceStatusSebagian   = "Sebagian"
+	InvoiceStatusLunas      = "Lunas"
+)
+
+// Jenis baris tagihan
+const (
+	InvoiceLineTypeTindakan = "tindakan"
+	InvoiceLineTypeObat     = "obat"
+)
+
+// Metode pembayaran yang diterima klinik
+const (
+	PaymentMethodTunai    = "tunai"
+	PaymentMethodDebit    = "debit"
+	PaymentMethodTransfer = "transfer"
+	PaymentMethodQRIS     = "qris"
+)
+
+// Invoice merepresentasikan tagihan yang dibuat dari item tindakan dan obat pada satu EMR
+type Invoice struct {
+	BaseModel
+	NoInvoice       string        `gorm:"type:varchar(50);uniqueIndex" json:"noInvoice"`
+	MedicalRecordID uint          `gorm:"not null;index" json:"medicalRecordId"`
+	MedicalRecord   MedicalRecord `gorm:"foreignKey:MedicalRecordID" json:"-"`
+	PatientID       uint          `gorm:"not null;index" json:"patientId"`
+	Patient         Patient       `gorm:"foreignKey:PatientID" json:"patient,omitempty"`
+	Tanggal         time.Time     `gorm:"type:timestamp with time zone;not null" json:"tanggal"`
+	Total           float64       `gorm:"not null;default:0" json:"total"`        // Jumlah SubTotal seluruh baris
+	TotalDibayar    float64       `gorm:"not null;default:0" json:"totalDibayar"` // Jumlah seluruh pembayaran
+	SisaTagihan     float64       `gorm:"not null;default:0" json:"sisaTagihan"`  // Total - TotalDibayar
+	Status          string        `gorm:"type:varchar(50);not null;default:'Belum Lunas';index" json:"status"`
+	Catatan         string        `gorm:"type:text" json:"catatan,omitempty"`
+	CreatedByID     uint          `json:"createdById"`
+
+	Lines    []InvoiceLine `gorm:"foreignKey:InvoiceID" json:"lines"`
+	Payments []Payment     `gorm:"foreignKey:InvoiceID" json:"payments"`
+}
+
+// InvoiceLine adalah satu baris tagihan, disalin dari item tindakan/obat EMR saat tagihan dibuat
+type InvoiceLine struct {
+	BaseModel
+	InvoiceID       uint    `gorm:"not null;index" json:"invoiceId"`
+	Tipe            string  `gorm:"type:varchar(20);not null" json:"tipe"` // tindakan, obat
+	ReferensiItemID uint    `json:"referensiItemId"`                       // ID MedicalRecordTreatmentItem / MedicalRecordMedicationItem
+	Kode            string  `gorm:"type:varchar(50)" json:"kode"`
+	Deskripsi       string  `gorm:"type:varchar(255)" json:"deskripsi"`
+	ToothNumber     string  `gorm:"type:varchar(10)" json:"toothNumber,omitempty"`
+	Quantity        int     `gorm:"not null" json:"quantity"`
+	HargaSatuan     float64 `gorm:"not null" json:"hargaSatuan"`
+	DiscountPercent float64 `gorm:"default:0" json:"discountPercent"`
+	SubTotal        float64 `gorm:"not null" json:"subTotal"`
+}
+
+// Payment merepresentasikan satu pembayaran (bisa sebagian) atas sebuah tagihan
+type Payment struct {
+	BaseModel
+	InvoiceID      uint      `gorm:"not null;index" json:"invoiceId"`
+	NoKwitansi     string    `gorm:"type:varchar(50);uniqueIndex" json:"noKwitansi"` // Nomor kwitansi berurutan, lihat receipt_number_seq
+	Tanggal        time.Time `gorm:"type:timestamp with time zone;not null" json:"tanggal"`
+	Metode         string    `gorm:"type:varchar(20);not null" json:"metode"`        // tunai, debit, transfer, qris
+	Jumlah         float64   `gorm:"not null" json:"jumlah"`                         // Nominal yang dialokasikan ke tagihan
+	UangDiterima   float64   `json:"uangDiterima,omitempty"`                         // Khusus tunai
+	Kembalian      float64   `json:"kembalian,omitempty"`                            // Khusus tunai
+	NoReferensi    string    `gorm:"type:varchar(100)" json:"noReferensi,omitempty"` // No. approval EDC / transfer / QRIS
+	Catatan        string    `gorm:"type:text" json:"catatan,omitempty"`
+	ReceivedByID   uint      `json:"receivedById"`
+	ReceivedByName string    `gorm:"type:varchar(255)" json:"receivedByName"`
+}
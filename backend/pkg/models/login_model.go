@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// LoginEvent mencatat setiap percobaan login (berhasil maupun gagal) untuk ditinjau admin
+type LoginEvent struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"createdAt"`
+	Username  string    `gorm:"type:varchar(100);index" json:"username"` // Username seperti yang diketik, bisa tidak terdaftar
+	UserID    *uint     `gorm:"index" json:"userId,omitempty"`
+	IPAddress string    `gorm:"type:varchar(45);index" json:"ipAddress"`
+	Device    string    `gorm:"type:varchar(100)" json:"device"`
+	UserAgent string    `gorm:"type:text" json:"userAgent"`
+	Success   bool      `gorm:"not null;index" json:"success"`
+	Reason    string    `gorm:"type:varchar(50)" json:"reason"`
+}
+
+// Alasan pada LoginEvent
+const (
+	LoginEventSuccess            = "success"
+	LoginEventInvalidCredentials = "invalid_credentials"
+	LoginEventUserInactive       = "user_inactive"
+	LoginEventRoleInactive       = "role_inactive"
+	LoginEventTwoFactorFailed    = "two_factor_failed"
+	LoginEventThrottled          = "throttled"  // Ditolak karena masih dalam masa tunggu/terkunci
+	LoginEventLockedOut          = "locked_out" // Kegagalan yang memicu penguncian
+)
+
+// LoginThrottle menyimpan penghitung kegagalan login per username atau per IP.
+// Disimpan di database agar tetap berlaku setelah restart dan saat server dijalankan lebih dari satu instance.
+type LoginThrottle struct {
+	ID           uint       `gorm:"primarykey" json:"id"`
+	Scope        string     `gorm:"type:varchar(20);not null;uniqueIndex:idx_login_throttle_scope_key" json:"scope"`
+	Key          string     `gorm:"type:varchar(150);not null;uniqueIndex:idx_login_throttle_scope_key" json:"key"` // Username (huruf kecil) atau alamat IP
+	FailedCount  int        `gorm:"not null;default:0" json:"failedCount"`
+	LastFailedAt *time.Time `json:"lastFailedAt,omitempty"`
+	LockedUntil  *time.Time `gorm:"index" json:"lockedUntil,omitempty"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+}
+
+// Cakupan LoginThrottle
+const (
+	LoginThrottleUsername = "username"
+	LoginThrottleIP       = "ip"
+)
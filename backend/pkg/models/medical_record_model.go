@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/MadeAgus22/dental-clinic-backend/types" // Sesuaikan path
@@ -11,6 +12,7 @@ import (
 type MedicalRecord struct {
 	BaseModel
 	VisitID       string    `gorm:"type:varchar(100);uniqueIndex;not null" json:"visitId"`
+	LegacyVisitID string    `gorm:"type:varchar(100);index" json:"legacyVisitId,omitempty"` // Nomor kunjungan sebelum penomoran ulang (cmd/renumber)
 	PatientID     uint      `gorm:"not null;index" json:"patientId"`
 	Patient       Patient   `gorm:"foreignKey:PatientID" json:"patient,omitempty"` // Untuk info pasien saat fetch EMR
 	DoctorID      uint      `gorm:"not null;index" json:"doctorId"`                // ID User dokter
@@ -22,11 +24,33 @@ type MedicalRecord struct {
 	Diagnosis     string    `gorm:"type:text" json:"diagnosis,omitempty"`
 	TreatmentPlan string    `gorm:"type:text" json:"treatmentPlan,omitempty"`
 	Notes         string    `gorm:"type:text" json:"notes,omitempty"`
-	BillingStatus string    `gorm:"type:varchar(50);default:'Belum Lunas'" json:"billingStatus"` // e.g., Belum Lunas, Lunas
+	BillingStatus string    `gorm:"type:varchar(50);default:'Belum Lunas'" json:"billingStatus"` // Salinan status Invoice (Belum Lunas, Sebagian, Lunas), diisi oleh modul billing
 
 	Treatments  []MedicalRecordTreatmentItem  `gorm:"foreignKey:MedicalRecordID" json:"treatments"`
 	Medications []MedicalRecordMedicationItem `gorm:"foreignKey:MedicalRecordID" json:"medications"`
 	Odontogram  []OdontogramDetail            `gorm:"foreignKey:MedicalRecordID" json:"odontogram"` // Detail Odontogram per gigi
+
+	Version int `gorm:"not null;default:0" json:"version"` // Nomor versi terakhir di medical_record_versions (0 untuk EMR lama yang belum punya riwayat), juga dipakai sebagai ETag
+
+	// Finalisasi (tanda tangan) oleh dokter pemeriksa. Setelah difinalisasi EMR terkunci dan koreksi hanya lewat addendum.
+	FinalizedAt      *time.Time              `gorm:"index" json:"finalizedAt,omitempty"`
+	FinalizedByID    *uint                   `json:"finalizedById,omitempty"`
+	FinalizedByName  string                  `gorm:"type:varchar(255)" json:"finalizedByName,omitempty"`
+	FinalizedVersion int                     `gorm:"not null;default:0" json:"finalizedVersion,omitempty"` // Versi yang ditandatangani
+	ContentHash      string                  `gorm:"type:varchar(64)" json:"contentHash,omitempty"`        // SHA-256 snapshot versi yang ditandatangani
+	Addenda          []MedicalRecordAddendum `gorm:"foreignKey:MedicalRecordID" json:"addenda,omitempty"`
+}
+
+// MedicalRecordAddendum adalah catatan tambahan pada EMR yang sudah difinalisasi, mis. koreksi atau hasil
+// pemeriksaan susulan. Isi EMR asli tidak berubah. Tabel ini append-only (lihat database.ensureAppendOnly).
+type MedicalRecordAddendum struct {
+	ID              uint      `gorm:"primarykey" json:"id"`
+	CreatedAt       time.Time `json:"createdAt"`
+	MedicalRecordID uint      `gorm:"not null;index" json:"medicalRecordId"`
+	AuthorID        uint      `gorm:"not null;index" json:"authorId"`
+	AuthorName      string    `gorm:"type:varchar(255)" json:"authorName"`
+	Reason          string    `gorm:"type:text;not null" json:"reason"`
+	Content         string    `gorm:"type:text;not null" json:"content"`
 }
 
 // OdontogramDetail menyimpan kondisi satu gigi pada satu EMR.
@@ -77,3 +101,79 @@ type MedicalRecordMedicationItem struct {
 	SubTotal            float64           `json:"subTotal"`                               // Price * Qty
 	Instruction         string            `gorm:"type:text" json:"instruction,omitempty"` // Aturan pakai
 }
+
+// MedicalRecordVersion menyimpan isi lengkap EMR setiap kali disimpan, beserta penulis dan waktunya.
+// Tabel ini append-only (lihat database.ensureAppendOnly) sehingga riwayat amandemen tidak dapat ditulis ulang.
+type MedicalRecordVersion struct {
+	ID              uint            `gorm:"primarykey" json:"id"`
+	CreatedAt       time.Time       `json:"createdAt"`
+	MedicalRecordID uint            `gorm:"not null;uniqueIndex:idx_medical_record_versions_number" json:"medicalRecordId"`
+	VersionNumber   int             `gorm:"not null;uniqueIndex:idx_medical_record_versions_number" json:"versionNumber"`
+	AuthorID        *uint           `gorm:"index" json:"authorId,omitempty"` // Kosong untuk versi awal EMR lama
+	AuthorName      string          `gorm:"type:varchar(255)" json:"authorName"`
+	ChangeReason    string          `gorm:"type:text" json:"changeReason,omitempty"`
+	Snapshot        json.RawMessage `gorm:"type:jsonb;not null" json:"snapshot"` // MedicalRecordSnapshot
+}
+
+// MedicalRecordSnapshot adalah isi EMR yang dibekukan pada satu versi.
+// Kode dan nama master ikut disalin agar versi lama tetap terbaca walaupun master data berubah.
+type MedicalRecordSnapshot struct {
+	VisitID       string    `json:"visitId"`
+	PatientID     uint      `json:"patientId"`
+	DoctorID      uint      `json:"doctorId"`
+	DoctorName    string    `json:"doctorName"`
+	ExamDate      time.Time `json:"examDate"`
+	VisitType     string    `json:"visitType"`
+	Complaint     string    `json:"complaint"`
+	Examination   string    `json:"examination"`
+	Diagnosis     string    `json:"diagnosis"`
+	TreatmentPlan string    `json:"treatmentPlan"`
+	Notes         string    `json:"notes"`
+
+	Treatments  []TreatmentItemSnapshot  `json:"treatments"`
+	Medications []MedicationItemSnapshot `json:"medications"`
+	Odontogram  []OdontogramSnapshot     `json:"odontogram"`
+}
+
+// TreatmentItemSnapshot adalah salinan MedicalRecordTreatmentItem pada satu versi
+type TreatmentItemSnapshot struct {
+	ID              uint    `json:"id"`
+	Code            string  `json:"code"`
+	Name            string  `json:"name"`
+	ToothNumber     string  `json:"toothNumber"`
+	Quantity        int     `json:"quantity"`
+	PriceAtTime     float64 `json:"priceAtTime"`
+	DiscountPercent float64 `json:"discountPercent"`
+	SubTotal        float64 `json:"subTotal"`
+	Notes           string  `json:"notes"`
+}
+
+// MedicationItemSnapshot adalah salinan MedicalRecordMedicationItem pada satu versi
+type MedicationItemSnapshot struct {
+	ID                 uint    `json:"id"`
+	Code               string  `json:"code"`
+	Name               string  `json:"name"`
+	Quantity           int     `json:"quantity"`
+	PricePerUnitAtTime float64 `json:"pricePerUnitAtTime"`
+	SubTotal           float64 `json:"subTotal"`
+	Instruction        string  `json:"instruction"`
+}
+
+// OdontogramSnapshot adalah salinan OdontogramDetail beserta riwayatnya pada satu versi
+type OdontogramSnapshot struct {
+	ID            uint                        `json:"id"`
+	ToothNumber   string                      `json:"toothNumber"`
+	Condition     types.ToothCondition        `json:"condition"`
+	TreatmentNote string                      `json:"treatmentNote"`
+	History       []OdontogramHistorySnapshot `json:"history"`
+}
+
+// OdontogramHistorySnapshot adalah salinan OdontogramHistory pada satu versi
+type OdontogramHistorySnapshot struct {
+	ID            uint                 `json:"id"`
+	Date          string               `json:"date"` // 2006-01-02
+	DoctorName    string               `json:"doctorName"`
+	FromCondition types.ToothCondition `json:"fromCondition"`
+	ToCondition   types.ToothCondition `json:"toCondition"`
+	Note          string               `json:"note"`
+}
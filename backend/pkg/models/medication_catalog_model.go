@@ -10,4 +10,8 @@ type MedicationCatalog struct {
 	HargaJual float64 `gorm:"not null" json:"hargaJual"`      // Harga jual ke pasien
 	Stok      int     `gorm:"default:0" json:"stok"`
 	Deskripsi string  `gorm:"type:text" json:"deskripsi,omitempty"`
+
+	// Titik pesan ulang: peringatan stok menipis muncul saat Stok < StokMinimum (0 = tidak dipantau)
+	StokMinimum     int `gorm:"not null;default:0" json:"stokMinimum"`
+	JumlahPemesanan int `gorm:"not null;default:0" json:"jumlahPemesanan"` // Jumlah pesan ulang standar (mis. satu dus)
 }
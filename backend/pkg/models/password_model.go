@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// PasswordHistory menyimpan hash password yang pernah dipakai pengguna untuk mencegah pemakaian ulang
+type PasswordHistory struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	UserID       uint      `gorm:"not null;index" json:"userId"`
+	PasswordHash string    `gorm:"type:varchar(255);not null" json:"-"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// PasswordResetToken adalah link reset password sekali pakai yang dibuat admin.
+// Token disimpan dalam bentuk hash; bentuk aslinya hanya ada di link yang diberikan ke pengguna.
+type PasswordResetToken struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	UserID      uint       `gorm:"not null;index" json:"userId"`
+	User        User       `gorm:"foreignKey:UserID" json:"-"`
+	TokenHash   string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	ExpiresAt   time.Time  `gorm:"type:timestamp with time zone;not null" json:"expiresAt"`
+	UsedAt      *time.Time `json:"usedAt,omitempty"`
+	CreatedByID uint       `gorm:"not null" json:"createdById"` // Admin yang membuat link
+	CreatedAt   time.Time  `json:"createdAt"`
+}
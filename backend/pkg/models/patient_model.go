@@ -8,6 +8,7 @@ import (
 type Patient struct {
 	BaseModel                  // Embed BaseModel
 	NoRM            string     `gorm:"type:varchar(50);uniqueIndex;not null" json:"noRm"`
+	NoRMLama        string     `gorm:"type:varchar(50);index" json:"noRmLama,omitempty"` // NoRM sebelum penomoran ulang (cmd/renumber), tetap bisa dipakai mencari
 	NamaLengkap     string     `gorm:"type:varchar(255);not null" json:"namaLengkap"`
 	TanggalLahir    *time.Time `gorm:"type:date" json:"tanggalLahir"`
 	JenisKelamin    string     `gorm:"type:varchar(20)" json:"jenisKelamin"` // Laki-laki, Perempuan
@@ -17,6 +18,8 @@ type Patient struct {
 	Alergi          string     `gorm:"type:text" json:"alergi,omitempty"`
 	RiwayatPenyakit string     `gorm:"type:text" json:"riwayatPenyakit,omitempty"`
 
+	Version int `gorm:"not null;default:1" json:"version"` // Naik setiap kali data diubah, dipakai sebagai ETag
+
 	// Relasi (jika diperlukan untuk eager loading atau query)
 	MedicalRecords []MedicalRecord `gorm:"foreignKey:PatientID" json:"-"` // Hindari circular dependency di JSON dasar
 	Reservations   []Reservation   `gorm:"foreignKey:PatientID" json:"-"`
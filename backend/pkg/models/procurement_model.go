@@ -0,0 +1,83 @@
+package models
+
+import "time"
+
+// Status purchase order. Draft masih bisa diubah; setelah dikirim ke supplier status berpindah otomatis
+// berdasarkan jumlah barang yang sudah diterima.
+const (
+	PurchaseOrderStatusDraft            = "draft"
+	PurchaseOrderStatusDikirim          = "dikirim"
+	PurchaseOrderStatusDiterimaSebagian = "diterima_sebagian"
+	PurchaseOrderStatusDiterima         = "diterima"
+	PurchaseOrderStatusDibatalkan       = "dibatalkan"
+)
+
+// Supplier adalah pemasok obat dan bahan klinik
+type Supplier struct {
+	BaseModel
+	Kode         string `gorm:"type:varchar(50);uniqueIndex;not null" json:"kode"`
+	Nama         string `gorm:"type:varchar(255);not null" json:"nama"`
+	Alamat       string `gorm:"type:text" json:"alamat,omitempty"`
+	NomorTelepon string `gorm:"type:varchar(50)" json:"nomorTelepon,omitempty"`
+	Email        string `gorm:"type:varchar(255)" json:"email,omitempty"`
+	Kontak       string `gorm:"type:varchar(255)" json:"kontak,omitempty"` // Nama sales/penanggung jawab di supplier
+	Catatan      string `gorm:"type:text" json:"catatan,omitempty"`
+}
+
+// PurchaseOrder adalah pesanan pembelian obat/bahan ke satu supplier
+type PurchaseOrder struct {
+	BaseModel
+	NoPO              string     `gorm:"type:varchar(50);uniqueIndex" json:"noPo"`
+	SupplierID        uint       `gorm:"not null;index" json:"supplierId"`
+	Supplier          Supplier   `gorm:"foreignKey:SupplierID" json:"supplier,omitempty"`
+	Status            string     `gorm:"type:varchar(30);not null;default:'draft';index" json:"status"`
+	TanggalPesan      time.Time  `gorm:"type:timestamp with time zone;not null" json:"tanggalPesan"`
+	TanggalDiharapkan *time.Time `gorm:"type:date" json:"tanggalDiharapkan,omitempty"` // Perkiraan tanggal barang datang
+	Total             float64    `gorm:"not null;default:0" json:"total"`              // Jumlah SubTotal seluruh baris
+	Catatan           string     `gorm:"type:text" json:"catatan,omitempty"`
+	CreatedByID       uint       `json:"createdById"`
+	SentAt            *time.Time `json:"sentAt,omitempty"`
+	SentByID          *uint      `json:"sentById,omitempty"`
+
+	Lines    []PurchaseOrderLine `gorm:"foreignKey:PurchaseOrderID" json:"lines"`
+	Receipts []GoodsReceipt      `gorm:"foreignKey:PurchaseOrderID" json:"receipts,omitempty"`
+}
+
+// PurchaseOrderLine adalah satu obat/bahan yang dipesan dalam purchase order
+type PurchaseOrderLine struct {
+	BaseModel
+	PurchaseOrderID     uint              `gorm:"not null;index" json:"purchaseOrderId"`
+	MedicationCatalogID uint              `gorm:"not null;index" json:"medicationCatalogId"`
+	MedicationCatalog   MedicationCatalog `gorm:"foreignKey:MedicationCatalogID" json:"medicationCatalog,omitempty"`
+	Jumlah              int               `gorm:"not null" json:"jumlah"`
+	JumlahDiterima      int               `gorm:"not null;default:0" json:"jumlahDiterima"`
+	HargaBeli           float64           `gorm:"not null" json:"hargaBeli"` // Harga beli per satuan yang disepakati
+	SubTotal            float64           `gorm:"not null" json:"subTotal"`  // Jumlah x HargaBeli
+}
+
+// GoodsReceipt mencatat satu kali penerimaan barang atas purchase order. Satu PO bisa diterima beberapa kali.
+type GoodsReceipt struct {
+	BaseModel
+	NoPenerimaan    string             `gorm:"type:varchar(50);uniqueIndex" json:"noPenerimaan"`
+	PurchaseOrderID uint               `gorm:"not null;index" json:"purchaseOrderId"`
+	TanggalTerima   time.Time          `gorm:"type:timestamp with time zone;not null" json:"tanggalTerima"`
+	NomorFaktur     string             `gorm:"type:varchar(100)" json:"nomorFaktur,omitempty"` // Nomor faktur/surat jalan dari supplier
+	Catatan         string             `gorm:"type:text" json:"catatan,omitempty"`
+	ReceivedByID    *uint              `json:"receivedById,omitempty"`
+	ReceivedByName  string             `gorm:"type:varchar(255)" json:"receivedByName"`
+	Lines           []GoodsReceiptLine `gorm:"foreignKey:GoodsReceiptID" json:"lines"`
+}
+
+// GoodsReceiptLine adalah jumlah yang diterima untuk satu baris PO, beserta batch tempat stoknya dicatat
+type GoodsReceiptLine struct {
+	BaseModel
+	GoodsReceiptID      uint       `gorm:"not null;index" json:"goodsReceiptId"`
+	PurchaseOrderLineID uint       `gorm:"not null;index" json:"purchaseOrderLineId"`
+	MedicationCatalogID uint       `gorm:"not null;index" json:"medicationCatalogId"`
+	Jumlah              int        `gorm:"not null" json:"jumlah"`
+	HargaBeli           float64    `gorm:"not null" json:"hargaBeli"` // Harga beli aktual sesuai faktur
+	NomorBatch          string     `gorm:"type:varchar(100)" json:"nomorBatch,omitempty"`
+	TanggalKedaluwarsa  *time.Time `gorm:"type:date" json:"tanggalKedaluwarsa,omitempty"`
+	StockBatchID        *uint      `gorm:"index" json:"stockBatchId,omitempty"` // Kosong jika diterima sebagai stok tanpa batch
+	StockMovementID     uint       `json:"stockMovementId"`
+}
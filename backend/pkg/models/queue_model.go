@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+)
+
+// QueueEntry merepresentasikan satu nomor antrian pasien pada seorang dokter di hari tertentu
+type QueueEntry struct {
+	BaseModel
+	Tanggal         time.Time      `gorm:"type:date;not null;uniqueIndex:idx_queue_doctor_date_number" json:"tanggal"`
+	DoctorID        uint           `gorm:"not null;uniqueIndex:idx_queue_doctor_date_number" json:"doctorId"`
+	DoctorName      string         `gorm:"type:varchar(255)" json:"doctorName"`
+	NomorAntrian    int            `gorm:"not null;uniqueIndex:idx_queue_doctor_date_number" json:"nomorAntrian"` // Nomor tiket, berurutan per dokter per hari
+	Urutan          int            `gorm:"not null;index" json:"urutan"`                                          // Posisi pemanggilan; pasien yang dilewati dipindah ke belakang
+	PatientID       uint           `gorm:"not null;index" json:"patientId"`
+	Patient         Patient        `gorm:"foreignKey:PatientID" json:"patient,omitempty"`
+	ReservationID   *uint          `gorm:"index" json:"reservationId,omitempty"` // Terisi jika check-in dari reservasi
+	Reservation     *Reservation   `gorm:"foreignKey:ReservationID" json:"-"`
+	Sumber          string         `gorm:"type:varchar(50);not null" json:"sumber"` // Reservasi, Walk-in
+	Keluhan         string         `gorm:"type:text" json:"keluhan,omitempty"`
+	Catatan         string         `gorm:"type:text" json:"catatan,omitempty"`
+	Status          string         `gorm:"type:varchar(20);not null;index" json:"status"`
+	JumlahPanggilan int            `gorm:"default:0" json:"jumlahPanggilan"`
+	CheckInAt       time.Time      `gorm:"type:timestamp with time zone;not null" json:"checkInAt"`
+	CalledAt        *time.Time     `gorm:"type:timestamp with time zone" json:"calledAt,omitempty"`
+	StartedAt       *time.Time     `gorm:"type:timestamp with time zone" json:"startedAt,omitempty"`
+	FinishedAt      *time.Time     `gorm:"type:timestamp with time zone" json:"finishedAt,omitempty"`
+	MedicalRecordID *uint          `gorm:"index" json:"medicalRecordId,omitempty"` // EMR yang dibuat saat pemeriksaan dimulai
+	MedicalRecord   *MedicalRecord `gorm:"foreignKey:MedicalRecordID" json:"-"`
+	CheckInByID     uint           `json:"checkInById"`
+}
+
+// Status antrian
+const (
+	QueueStatusMenunggu  = "menunggu"
+	QueueStatusDipanggil = "dipanggil"
+	QueueStatusDiperiksa = "diperiksa"
+	QueueStatusSelesai   = "selesai"
+	QueueStatusBatal     = "batal"
+)
+
+// Sumber kedatangan pasien di antrian
+const (
+	QueueSourceReservasi = "Reservasi"
+	QueueSourceWalkIn    = "Walk-in"
+)
@@ -18,3 +18,12 @@ type Reservation struct {
 	Status         string    `gorm:"type:varchar(50);default:'Dijadwalkan'" json:"status"` // Contoh: Dijadwalkan, Dikonfirmasi, Dibatalkan, Selesai
 	JenisKunjungan string    `gorm:"type:varchar(100)" json:"jenisKunjungan"`              // Reservasi, Walk-in
 }
+
+// Status reservasi yang dikenali sistem
+const (
+	ReservationStatusDijadwalkan  = "Dijadwalkan"
+	ReservationStatusDikonfirmasi = "Dikonfirmasi"
+	ReservationStatusHadir        = "Hadir"
+	ReservationStatusDibatalkan   = "Dibatalkan"
+	ReservationStatusSelesai      = "Selesai"
+)
@@ -6,6 +6,7 @@ type Role struct {
 	Nama      string `gorm:"type:varchar(100);uniqueIndex;not null" json:"nama"`
 	Kode      string `gorm:"type:varchar(50);uniqueIndex;not null" json:"kode"` // e.g., "admin", "dokter", "resepsionis"
 	Deskripsi string `gorm:"type:text" json:"deskripsi,omitempty"`
+	Version   int    `gorm:"not null;default:1" json:"version"` // Naik setiap kali role atau hak aksesnya diubah, dipakai sebagai ETag
 
 	// Relasi Many-to-Many dengan Permission
 	Permissions []Permission `gorm:"many2many:role_permissions;" json:"permissions,omitempty"`
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+)
+
+// UserSession adalah satu sesi login. Refresh token disimpan dalam bentuk hash dan dirotasi
+// setiap kali dipakai; access token membawa ID sesi sehingga bisa dicabut sebelum kedaluwarsa.
+type UserSession struct {
+	BaseModel
+	UserID            uint       `gorm:"not null;index" json:"userId"`
+	User              User       `gorm:"foreignKey:UserID" json:"-"`
+	RefreshTokenHash  string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	PreviousTokenHash string     `gorm:"type:varchar(64);index" json:"-"` // Token sebelum rotasi terakhir, untuk mendeteksi pemakaian ulang
+	ExpiresAt         time.Time  `gorm:"type:timestamp with time zone;not null" json:"expiresAt"`
+	RevokedAt         *time.Time `gorm:"type:timestamp with time zone;index" json:"revokedAt,omitempty"`
+	RevokedReason     string     `gorm:"type:varchar(100)" json:"revokedReason,omitempty"`
+	RevokedByID       *uint      `json:"revokedById,omitempty"` // Pengguna yang mencabut sesi (diri sendiri atau admin)
+
+	Device     string     `gorm:"type:varchar(100)" json:"device"` // Ringkasan perangkat dari User-Agent, mis. "Chrome di Windows"
+	UserAgent  string     `gorm:"type:text" json:"userAgent"`
+	IPAddress  string     `gorm:"type:varchar(45)" json:"ipAddress"` // IP saat login, diperbarui mengikuti aktivitas terakhir
+	LastSeenAt *time.Time `gorm:"type:timestamp with time zone" json:"lastSeenAt,omitempty"`
+}
+
+// Alasan pencabutan sesi
+const (
+	SessionRevokedLogout      = "logout"
+	SessionRevokedTokenReuse  = "refresh_token_reuse"
+	SessionRevokedUserStatus  = "user_deactivated"
+	SessionRevokedUserDeleted = "user_deleted"
+	SessionRevokedRoleChanged = "role_changed"
+	SessionRevokedByUser      = "revoked_by_user"
+	SessionRevokedByAdmin     = "revoked_by_admin"
+	SessionRevokedTwoFactor   = "two_factor_reset"
+	SessionRevokedPassword    = "password_changed"
+)
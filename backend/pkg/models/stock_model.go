@@ -0,0 +1,113 @@
+package models
+
+import "time"
+
+// Jenis mutasi stok
+const (
+	StockMovementMasuk       = "masuk"       // Barang diterima (pembelian, stok awal)
+	StockMovementKeluar      = "keluar"      // Barang dipakai/diberikan, termasuk obat di EMR
+	StockMovementPenyesuaian = "penyesuaian" // Koreksi hasil hitung fisik, rusak, hilang
+	StockMovementRetur       = "retur"       // Barang kembali ke stok, termasuk pembatalan obat di EMR
+)
+
+// StockMovement adalah satu baris buku besar stok obat/bahan. Stok di MedicationCatalog hanya berubah
+// lewat mutasi ini. Tabel ini append-only (lihat database.ensureAppendOnly); koreksi dicatat sebagai mutasi baru.
+type StockMovement struct {
+	ID                  uint              `gorm:"primarykey" json:"id"`
+	CreatedAt           time.Time         `gorm:"index" json:"createdAt"`
+	MedicationCatalogID uint              `gorm:"not null;index" json:"medicationCatalogId"`
+	MedicationCatalog   MedicationCatalog `gorm:"foreignKey:MedicationCatalogID" json:"medicationCatalog,omitempty"`
+	Type                string            `gorm:"type:varchar(20);not null;index" json:"type"`
+	Quantity            int               `gorm:"not null" json:"quantity"`   // Perubahan stok: positif menambah, negatif mengurangi
+	StockAfter          int               `gorm:"not null" json:"stockAfter"` // Stok setelah mutasi
+	Reason              string            `gorm:"type:text;not null" json:"reason"`
+
+	StockBatchID *uint       `gorm:"index" json:"stockBatchId,omitempty"` // Kosong untuk stok tanpa batch (stok lama atau penerimaan tanpa nomor batch)
+	StockBatch   *StockBatch `gorm:"foreignKey:StockBatchID" json:"stockBatch,omitempty"`
+
+	// Referensi sumber mutasi otomatis (obat yang diberikan lewat EMR, hasil stock opname)
+	MedicalRecordID  *uint `gorm:"index" json:"medicalRecordId,omitempty"`
+	MedicationItemID *uint `gorm:"index" json:"medicationItemId,omitempty"`
+	StockOpnameID    *uint `gorm:"index" json:"stockOpnameId,omitempty"` // Penyesuaian hasil stock opname
+
+	UserID   *uint  `gorm:"index" json:"userId,omitempty"`
+	UserName string `gorm:"type:varchar(255)" json:"userName"`
+}
+
+// StockBatch adalah satu batch penerimaan obat/bahan dengan tanggal kedaluwarsa dan harga belinya sendiri.
+// Jumlah Sisa semua batch bisa lebih kecil dari MedicationCatalog.Stok; selisihnya adalah stok tanpa batch.
+// Pemakaian lewat EMR mengambil batch yang paling cepat kedaluwarsa lebih dulu (FEFO).
+type StockBatch struct {
+	BaseModel
+	MedicationCatalogID uint              `gorm:"not null;uniqueIndex:idx_stock_batches_number" json:"medicationCatalogId"`
+	MedicationCatalog   MedicationCatalog `gorm:"foreignKey:MedicationCatalogID" json:"medicationCatalog,omitempty"`
+	NomorBatch          string            `gorm:"type:varchar(100);not null;uniqueIndex:idx_stock_batches_number" json:"nomorBatch"`
+	TanggalKedaluwarsa  *time.Time        `gorm:"type:date;index" json:"tanggalKedaluwarsa,omitempty"`
+	JumlahAwal          int               `gorm:"not null" json:"jumlahAwal"` // Jumlah saat batch diterima
+	Sisa                int               `gorm:"not null;default:0" json:"sisa"`
+	HargaBeli           float64           `json:"hargaBeli"`
+}
+
+// Status sesi stock opname. Sesi yang sudah disetujui atau dibatalkan terkunci dan tidak dapat diubah lagi.
+const (
+	StockOpnameStatusBerjalan   = "berjalan"
+	StockOpnameStatusDisetujui  = "disetujui"
+	StockOpnameStatusDibatalkan = "dibatalkan"
+)
+
+// StockOpname adalah satu sesi hitung fisik stok. Saat dibuka, stok sistem setiap obat disalin ke StockOpnameItem;
+// hasil hitung diisi bertahap (boleh oleh beberapa petugas) dan saat disetujui selisihnya dicatat sebagai mutasi penyesuaian.
+// Index unik parsial pada Status menjamin hanya satu sesi yang berjalan.
+type StockOpname struct {
+	BaseModel
+	NoOpname      string            `gorm:"type:varchar(50);uniqueIndex" json:"noOpname"`
+	Status        string            `gorm:"type:varchar(20);not null;default:'berjalan';index;uniqueIndex:idx_stock_opnames_running,where:status = 'berjalan'" json:"status"`
+	Catatan       string            `gorm:"type:text" json:"catatan,omitempty"`
+	StartedByID   *uint             `json:"startedById,omitempty"`
+	StartedByName string            `gorm:"type:varchar(255)" json:"startedByName"`
+	ClosedAt      *time.Time        `json:"closedAt,omitempty"` // Waktu disetujui atau dibatalkan
+	ClosedByID    *uint             `json:"closedById,omitempty"`
+	ClosedByName  string            `gorm:"type:varchar(255)" json:"closedByName,omitempty"`
+	Items         []StockOpnameItem `gorm:"foreignKey:StockOpnameID" json:"items,omitempty"`
+}
+
+// StockOpnameItem adalah satu obat dalam sesi stock opname. Selisih = JumlahFisik - StokSaatHitung, yaitu stok sistem
+// pada saat obat itu dihitung, sehingga mutasi antara sesi dibuka dan penghitungan tidak ikut terhitung sebagai selisih.
+type StockOpnameItem struct {
+	BaseModel
+	StockOpnameID       uint              `gorm:"not null;uniqueIndex:idx_stock_opname_items_medication" json:"stockOpnameId"`
+	MedicationCatalogID uint              `gorm:"not null;uniqueIndex:idx_stock_opname_items_medication" json:"medicationCatalogId"`
+	MedicationCatalog   MedicationCatalog `gorm:"foreignKey:MedicationCatalogID" json:"medicationCatalog,omitempty"`
+	StokSistem          int               `gorm:"not null" json:"stokSistem"` // Stok di MedicationCatalog saat sesi dibuka
+	HargaBeli           float64           `json:"hargaBeli"`                  // HargaBeli saat sesi dibuka, untuk menilai selisih
+	JumlahFisik         *int              `json:"jumlahFisik,omitempty"`      // Kosong jika belum dihitung
+	StokSaatHitung      *int              `json:"stokSaatHitung,omitempty"`   // Stok di MedicationCatalog saat hasil hitung disimpan
+	Catatan             string            `gorm:"type:text" json:"catatan,omitempty"`
+	CountedAt           *time.Time        `json:"countedAt,omitempty"`
+	CountedByID         *uint             `json:"countedById,omitempty"`
+	CountedByName       string            `gorm:"type:varchar(255)" json:"countedByName,omitempty"`
+}
+
+// Status peringatan stok menipis
+const (
+	LowStockAlertStatusAktif   = "aktif"   // Stok di bawah minimum dan belum ditindaklanjuti
+	LowStockAlertStatusDiakui  = "diakui"  // Sudah dilihat petugas (mis. sudah dibuat PO), stok masih di bawah minimum
+	LowStockAlertStatusSelesai = "selesai" // Stok kembali mencapai minimum
+)
+
+// LowStockAlert dibuat oleh pemeriksa stok berkala saat Stok sebuah obat turun di bawah StokMinimum.
+// Setiap obat paling banyak memiliki satu peringatan terbuka; peringatan selesai otomatis saat stok pulih.
+type LowStockAlert struct {
+	BaseModel
+	MedicationCatalogID uint              `gorm:"not null;index;uniqueIndex:idx_low_stock_alerts_open,where:resolved_at IS NULL" json:"medicationCatalogId"`
+	MedicationCatalog   MedicationCatalog `gorm:"foreignKey:MedicationCatalogID" json:"medicationCatalog,omitempty"`
+	Status              string            `gorm:"type:varchar(20);not null;default:'aktif';index" json:"status"`
+	Stok                int               `gorm:"not null" json:"stok"`        // Stok saat peringatan dibuat
+	StokMinimum         int               `gorm:"not null" json:"stokMinimum"` // StokMinimum saat peringatan dibuat
+
+	AcknowledgedAt     *time.Time `json:"acknowledgedAt,omitempty"`
+	AcknowledgedByID   *uint      `json:"acknowledgedById,omitempty"`
+	AcknowledgedByName string     `gorm:"type:varchar(255)" json:"acknowledgedByName,omitempty"`
+	Catatan            string     `gorm:"type:text" json:"catatan,omitempty"`
+	ResolvedAt         *time.Time `gorm:"index" json:"resolvedAt,omitempty"`
+}
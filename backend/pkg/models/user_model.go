@@ -10,12 +10,31 @@ type User struct {
 	NamaLengkap   string     `gorm:"type:varchar(255);not null" json:"namaLengkap"`
 	Username      string     `gorm:"type:varchar(100);uniqueIndex;not null" json:"username"`
 	Email         string     `gorm:"type:varchar(100);uniqueIndex;not null" json:"email"`
-	PasswordHash  string     `gorm:"type:varchar(255);not null" json:"-"`            // Tidak dikirim dalam JSON response standar
-	Role          string     `gorm:"type:varchar(50);not null" json:"role"`          // e.g., "admin", "dokter", "resepsionis"
+	PasswordHash  string     `gorm:"type:varchar(255);not null" json:"-"`         // Tidak dikirim dalam JSON response standar
+	Role          string     `gorm:"type:varchar(50);not null;index" json:"role"` // Kode role, foreign key ke roles.kode (e.g., "admin", "dokter")
+	RoleDetail    Role       `gorm:"foreignKey:Role;references:Kode;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"`
 	Status        string     `gorm:"type:varchar(20);default:'aktif'" json:"status"` // e.g., "aktif", "nonaktif"
 	LastLogin     *time.Time `json:"lastLogin,omitempty"`
 	PhoneNumber   string     `gorm:"type:varchar(20)" json:"phoneNumber,omitempty"`
 	ProfilePicURL string     `gorm:"type:varchar(255)" json:"profilePicUrl,omitempty"`
+
+	PasswordChangedAt *time.Time `json:"passwordChangedAt,omitempty"`
+
+	// Autentikasi dua langkah (TOTP). Secret terisi tetapi belum Enabled berarti pendaftaran belum dikonfirmasi.
+	TwoFactorEnabled   bool               `gorm:"not null;default:false" json:"twoFactorEnabled"`
+	TwoFactorSecret    string             `gorm:"type:varchar(255)" json:"-"` // Terenkripsi, lihat utils.EncryptSecret
+	TwoFactorEnabledAt *time.Time         `json:"twoFactorEnabledAt,omitempty"`
+	TwoFactorLastStep  int64              `gorm:"not null;default:0" json:"-"` // Langkah waktu kode terakhir, mencegah kode dipakai ulang
+	RecoveryCodes      []UserRecoveryCode `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// UserRecoveryCode adalah kode pemulihan 2FA sekali pakai, disimpan dalam bentuk hash
+type UserRecoveryCode struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"userId"`
+	CodeHash  string     `gorm:"type:varchar(64);not null" json:"-"`
+	UsedAt    *time.Time `json:"usedAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
 }
 
 // Anda mungkin ingin menambahkan fungsi terkait User di sini, misalnya untuk hashing password.
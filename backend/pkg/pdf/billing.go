@@ -0,0 +1,238 @@
+package pdf
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/models"
+)
+
+var paymentMethodLabels = map[string]string{
+	models.PaymentMethodTunai:    "Tunai",
+	models.PaymentMethodDebit:    "Kartu Debit",
+	models.PaymentMethodTransfer: "Transfer Bank",
+	models.PaymentMethodQRIS:     "QRIS",
+}
+
+// PaymentMethodLabel mengembalikan nama metode pembayaran untuk dicetak
+func PaymentMethodLabel(metode string) string {
+	if label, ok := paymentMethodLabels[metode]; ok {
+		return label
+	}
+	return metode
+}
+
+// fit memotong teks dengan "..." agar muat pada lebar sel
+func (d *document) fit(text string, width float64) string {
+	text = d.tr(text)
+	if d.GetStringWidth(text) <= width-2 {
+		return text
+	}
+	for len(text) > 0 && d.GetStringWidth(text+"...") > width-2 {
+		text = text[:len(text)-1]
+	}
+	return text + "..."
+}
+
+// writeInvoiceIdentity mencetak identitas pasien dan kunjungan pada tagihan
+func (d *document) writeInvoiceIdentity(invoice models.Invoice) {
+	d.writeLabelValue(35, "Nama Pasien", invoice.Patient.NamaLengkap)
+	d.writeLabelValue(35, "No. RM", invoice.Patient.NoRM)
+	d.writeLabelValue(35, "No. Kunjungan", invoice.MedicalRecord.VisitID)
+	if invoice.MedicalRecord.DoctorName != "" {
+		d.writeLabelValue(35, "Dokter", invoice.MedicalRecord.DoctorName)
+	}
+}
+
+// writeInvoiceLines mencetak tabel rincian tindakan dan obat
+func (d *document) writeInvoiceLines(lines []models.InvoiceLine) {
+	widths := []float64{8, 82, 12, 30, 14, 34}
+	headers := []string{"No", "Uraian", "Qty", "Harga", "Disc", "Jumlah"}
+
+	d.SetFont("Helvetica", "B", 9)
+	d.SetFillColor(230, 230, 230)
+	for i, header := range headers {
+		d.cell(widths[i], 7, header, "1", 0, "C", true)
+	}
+	d.Ln(-1)
+
+	d.SetFont("Helvetica", "", 9)
+	for i, line := range lines {
+		uraian := line.Deskripsi
+		if line.Tipe == models.InvoiceLineTypeTindakan && line.ToothNumber != "" {
+			uraian = fmt.Sprintf("%s (gigi %s)", uraian, line.ToothNumber)
+		}
+		discount := "-"
+		if line.DiscountPercent > 0 {
+			discount = strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.2f", line.DiscountPercent), "0"), ".") + "%"
+		}
+
+		d.CellFormat(widths[0], 6, fmt.Sprintf("%d", i+1), "1", 0, "C", false, 0, "")
+		d.CellFormat(widths[1], 6, d.fit(uraian, widths[1]), "1", 0, "L", false, 0, "")
+		d.CellFormat(widths[2], 6, fmt.Sprintf("%d", line.Quantity), "1", 0, "C", false, 0, "")
+		d.CellFormat(widths[3], 6, FormatRupiah(line.HargaSatuan), "1", 0, "R", false, 0, "")
+		d.CellFormat(widths[4], 6, discount, "1", 0, "C", false, 0, "")
+		d.CellFormat(widths[5], 6, FormatRupiah(line.SubTotal), "1", 1, "R", false, 0, "")
+	}
+	if len(lines) == 0 {
+		d.cell(0, 6, "Tidak ada tindakan atau obat yang ditagihkan.", "1", 1, "C", false)
+	}
+}
+
+// writeTotalRow mencetak satu baris ringkasan nominal yang rata kanan
+func (d *document) writeTotalRow(label string, amount float64, bold bool) {
+	style := ""
+	if bold {
+		style = "B"
+	}
+	d.SetFont("Helvetica", style, 10)
+	d.cell(d.contentWidth()-34, 6, label, "", 0, "R", false)
+	d.cell(34, 6, FormatRupiah(amount), "", 1, "R", false)
+}
+
+// writeSignature mencetak blok tanda tangan petugas di sisi kanan
+func (d *document) writeSignature(place, role, name string) {
+	d.Ln(8)
+	left, _, _, _ := d.GetMargins()
+	x := left + d.contentWidth() - 70
+	d.SetFont("Helvetica", "", 10)
+	d.SetX(x)
+	d.cell(70, 5, place, "", 1, "C", false)
+	d.SetX(x)
+	d.cell(70, 5, role, "", 1, "C", false)
+	d.Ln(16)
+	d.SetX(x)
+	d.SetFont("Helvetica", "BU", 10)
+	d.cell(70, 5, name, "", 1, "C", false)
+}
+
+// RenderInvoice membuat PDF invoice untuk satu tagihan beserta seluruh pembayarannya.
+// Invoice harus di-preload dengan Patient, MedicalRecord, Lines dan Payments.
+func RenderInvoice(w io.Writer, clinic ClinicInfo, invoice models.Invoice) error {
+	d := newDocument()
+	d.writeClinicHeader(clinic)
+	d.writeTitle("INVOICE")
+
+	d.writeLabelValue(35, "No. Invoice", invoice.NoInvoice)
+	d.writeLabelValue(35, "Tanggal", FormatTanggalJam(invoice.Tanggal))
+	d.writeInvoiceIdentity(invoice)
+
+	d.writeSectionTitle("Rincian Tagihan")
+	d.writeInvoiceLines(invoice.Lines)
+	d.Ln(2)
+	d.writeTotalRow("Total Tagihan", invoice.Total, true)
+
+	if len(invoice.Payments) > 0 {
+		d.writeSectionTitle("Riwayat Pembayaran")
+		d.SetFont("Helvetica", "", 9)
+		for _, payment := range invoice.Payments {
+			keterangan := fmt.Sprintf("%s - %s", FormatTanggalJam(payment.Tanggal), PaymentMethodLabel(payment.Metode))
+			if payment.NoKwitansi != "" {
+				keterangan += " (" + payment.NoKwitansi + ")"
+			}
+			if payment.NoReferensi != "" {
+				keterangan += " Ref: " + payment.NoReferensi
+			}
+			d.cell(d.contentWidth()-34, 6, keterangan, "", 0, "L", false)
+			d.cell(34, 6, FormatRupiah(payment.Jumlah), "", 1, "R", false)
+		}
+	}
+
+	d.Ln(2)
+	d.writeTotalRow("Total Dibayar", invoice.TotalDibayar, false)
+	d.writeTotalRow("Sisa Tagihan", invoice.SisaTagihan, true)
+	d.SetFont("Helvetica", "B", 10)
+	d.cell(0, 6, "Status: "+invoice.Status, "", 1, "R", false)
+
+	return d.Output(w)
+}
+
+// RenderReceipt membuat PDF kwitansi untuk satu pembayaran atas sebuah tagihan.
+// Nominal dibayar dan sisa dihitung sampai dengan pembayaran ini, sehingga kwitansi lama tetap konsisten.
+func RenderReceipt(w io.Writer, clinic ClinicInfo, invoice models.Invoice, payment models.Payment) error {
+	d := newDocument()
+	d.writeClinicHeader(clinic)
+	d.writeTitle("KWITANSI PEMBAYARAN")
+
+	d.writeLabelValue(40, "No. Kwitansi", payment.NoKwitansi)
+	d.writeLabelValue(40, "Tanggal", FormatTanggalJam(payment.Tanggal))
+	d.writeLabelValue(40, "Telah terima dari", fmt.Sprintf("%s (No. RM %s)", invoice.Patient.NamaLengkap, invoice.Patient.NoRM))
+	d.writeLabelValue(40, "Uang sejumlah", FormatRupiah(payment.Jumlah))
+	d.writeLabelValue(40, "Terbilang", Terbilang(payment.Jumlah)+" rupiah")
+	d.writeLabelValue(40, "Untuk pembayaran", fmt.Sprintf("Invoice %s, kunjungan %s", invoice.NoInvoice, invoice.MedicalRecord.VisitID))
+	metode := PaymentMethodLabel(payment.Metode)
+	if payment.NoReferensi != "" {
+		metode += " (Ref: " + payment.NoReferensi + ")"
+	}
+	d.writeLabelValue(40, "Metode", metode)
+
+	d.writeSectionTitle("Rincian Tagihan")
+	d.writeInvoiceLines(invoice.Lines)
+	d.Ln(2)
+
+	paidUntilNow := 0.0
+	for _, p := range invoice.Payments {
+		if p.ID <= payment.ID {
+			paidUntilNow += p.Jumlah
+		}
+	}
+	sisa := invoice.Total - paidUntilNow
+	if sisa < 0 {
+		sisa = 0
+	}
+
+	d.writeTotalRow("Total Tagihan", invoice.Total, true)
+	d.writeTotalRow("Pembayaran ini", payment.Jumlah, false)
+	if payment.Metode == models.PaymentMethodTunai && payment.UangDiterima > 0 {
+		d.writeTotalRow("Uang Diterima", payment.UangDiterima, false)
+		d.writeTotalRow("Kembalian", payment.Kembalian, false)
+	}
+	d.writeTotalRow("Total Dibayar", paidUntilNow, false)
+	d.writeTotalRow("Sisa Tagihan", sisa, true)
+
+	d.writeSignature(FormatTanggal(payment.Tanggal), "Petugas,", payment.ReceivedByName)
+
+	return d.Output(w)
+}
+
+var satuan = [...]string{"", "satu", "dua", "tiga", "empat", "lima", "enam", "tujuh", "delapan", "sembilan", "sepuluh", "sebelas"}
+
+// Terbilang mengubah nominal (dibulatkan ke rupiah terdekat) menjadi kata dalam bahasa Indonesia,
+// mis. 1500 -> "seribu lima ratus". Nominal negatif diawali "minus".
+func Terbilang(amount float64) string {
+	n := int64(math.Round(amount))
+	switch {
+	case n == 0:
+		return "nol"
+	case n < 0:
+		return "minus " + strings.TrimSpace(terbilang(-n))
+	}
+	return strings.TrimSpace(terbilang(n))
+}
+
+func terbilang(n int64) string {
+	switch {
+	case n < 12:
+		return satuan[n]
+	case n < 20:
+		return terbilang(n-10) + " belas"
+	case n < 100:
+		return strings.TrimSpace(terbilang(n/10) + " puluh " + terbilang(n%10))
+	case n < 200:
+		return strings.TrimSpace("seratus " + terbilang(n-100))
+	case n < 1000:
+		return strings.TrimSpace(terbilang(n/100) + " ratus " + terbilang(n%100))
+	case n < 2000:
+		return strings.TrimSpace("seribu " + terbilang(n-1000))
+	case n < 1000000:
+		return strings.TrimSpace(terbilang(n/1000) + " ribu " + terbilang(n%1000))
+	case n < 1000000000:
+		return strings.TrimSpace(terbilang(n/1000000) + " juta " + terbilang(n%1000000))
+	case n < 1000000000000:
+		return strings.TrimSpace(terbilang(n/1000000000) + " miliar " + terbilang(n%1000000000))
+	default:
+		return strings.TrimSpace(terbilang(n/1000000000000) + " triliun " + terbilang(n%1000000000000))
+	}
+}
@@ -0,0 +1,39 @@
+package pdf
+
+import "testing"
+
+func TestTerbilang(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount float64
+		want   string
+	}{
+		{"nol", 0, "nol"},
+		{"satu", 1, "satu"},
+		{"sebelas", 11, "sebelas"},
+		{"belasan", 17, "tujuh belas"},
+		{"puluhan", 45, "empat puluh lima"},
+		{"seratus", 100, "seratus"},
+		{"ratusan", 250, "dua ratus lima puluh"},
+		{"seribu", 1000, "seribu"},
+		{"seribu lima ratus", 1500, "seribu lima ratus"},
+		{"ribuan", 11000, "sebelas ribu"},
+		{"ratus ribu", 150000, "seratus lima puluh ribu"},
+		{"satu juta", 1000000, "satu juta"},
+		{"juta campuran", 2750500, "dua juta tujuh ratus lima puluh ribu lima ratus"},
+		{"miliar", 1000000000, "satu miliar"},
+		{"triliun", 3000000000001, "tiga triliun satu"},
+		{"desimal dibulatkan ke atas", 1500.5, "seribu lima ratus satu"},
+		{"desimal dibulatkan ke bawah", 1500.4, "seribu lima ratus"},
+		{"desimal di bawah setengah rupiah", 0.4, "nol"},
+		{"negatif", -1500, "minus seribu lima ratus"},
+		{"negatif desimal", -11.6, "minus dua belas"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Terbilang(tt.amount); got != tt.want {
+				t.Errorf("Terbilang(%v) = %q, want %q", tt.amount, got, tt.want)
+			}
+		})
+	}
+}
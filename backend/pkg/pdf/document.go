@@ -0,0 +1,148 @@
+// Package pdf berisi pembuat dokumen cetak (kwitansi, invoice, ringkasan EMR) dalam Go murni
+// sehingga tetap bisa dipakai saat klinik offline.
+package pdf
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// ClinicInfo adalah identitas klinik yang dicetak sebagai kop dokumen
+type ClinicInfo struct {
+	Name    string
+	Address string
+	Phone   string
+}
+
+var namaBulan = [...]string{"Januari", "Februari", "Maret", "April", "Mei", "Juni", "Juli", "Agustus", "September", "Oktober", "November", "Desember"}
+
+// document membungkus fpdf.Fpdf dengan translator karakter agar teks UTF-8 tercetak dengan font bawaan
+type document struct {
+	*fpdf.Fpdf
+	tr func(string) string
+}
+
+// newDocument membuat dokumen A4 portrait dengan margin standar dan nomor halaman di footer
+func newDocument() *document {
+	f := fpdf.New("P", "mm", "A4", "")
+	f.SetMargins(15, 15, 15)
+	f.SetAutoPageBreak(true, 20)
+	f.AliasNbPages("")
+
+	doc := &document{Fpdf: f, tr: f.UnicodeTranslatorFromDescriptor("")}
+	f.SetFooterFunc(func() {
+		f.SetY(-15)
+		f.SetFont("Helvetica", "I", 8)
+		f.SetTextColor(120, 120, 120)
+		f.CellFormat(0, 10, fmt.Sprintf("Halaman %d/{nb}", f.PageNo()), "", 0, "C", false, 0, "")
+		f.SetTextColor(0, 0, 0)
+	})
+	f.AddPage()
+	return doc
+}
+
+// cell menulis satu sel teks satu baris
+func (d *document) cell(w, h float64, text, border string, ln int, align string, fill bool) {
+	d.CellFormat(w, h, d.tr(text), border, ln, align, fill, 0, "")
+}
+
+// multiCell menulis teks yang dapat terpotong ke beberapa baris
+func (d *document) multiCell(w, h float64, text, border, align string, fill bool) {
+	d.MultiCell(w, h, d.tr(text), border, align, fill)
+}
+
+// contentWidth mengembalikan lebar area tulis di antara margin kiri dan kanan
+func (d *document) contentWidth() float64 {
+	pageWidth, _ := d.GetPageSize()
+	left, _, right, _ := d.GetMargins()
+	return pageWidth - left - right
+}
+
+// writeClinicHeader mencetak kop klinik dan garis pemisah
+func (d *document) writeClinicHeader(clinic ClinicInfo) {
+	d.SetFont("Helvetica", "B", 16)
+	d.cell(0, 8, clinic.Name, "", 1, "C", false)
+	d.SetFont("Helvetica", "", 9)
+	if clinic.Address != "" {
+		d.cell(0, 5, clinic.Address, "", 1, "C", false)
+	}
+	if clinic.Phone != "" {
+		d.cell(0, 5, "Telp. "+clinic.Phone, "", 1, "C", false)
+	}
+	d.Ln(2)
+	left, _, _, _ := d.GetMargins()
+	y := d.GetY()
+	d.SetLineWidth(0.6)
+	d.Line(left, y, left+d.contentWidth(), y)
+	d.SetLineWidth(0.2)
+	d.Ln(4)
+}
+
+// writeTitle mencetak judul dokumen di tengah
+func (d *document) writeTitle(title string) {
+	d.SetFont("Helvetica", "B", 13)
+	d.cell(0, 7, title, "", 1, "C", false)
+	d.Ln(2)
+}
+
+// writeLabelValue mencetak pasangan label : nilai dalam satu baris
+func (d *document) writeLabelValue(labelWidth float64, label, value string) {
+	d.SetFont("Helvetica", "", 10)
+	d.cell(labelWidth, 6, label, "", 0, "L", false)
+	d.cell(4, 6, ":", "", 0, "L", false)
+	d.multiCell(0, 6, value, "", "L", false)
+}
+
+// writeSectionTitle mencetak judul bagian dengan latar abu-abu
+func (d *document) writeSectionTitle(title string) {
+	d.Ln(2)
+	d.SetFont("Helvetica", "B", 10)
+	d.SetFillColor(230, 230, 230)
+	d.cell(0, 6, title, "", 1, "L", true)
+	d.Ln(1)
+}
+
+// FormatRupiah memformat nominal menjadi "Rp 1.250.000" (desimal hanya ditampilkan jika ada)
+func FormatRupiah(amount float64) string {
+	negative := amount < 0
+	if negative {
+		amount = -amount
+	}
+	whole := int64(amount)
+	cents := int64((amount-float64(whole))*100 + 0.5)
+	if cents == 100 {
+		whole++
+		cents = 0
+	}
+
+	digits := fmt.Sprintf("%d", whole)
+	var grouped strings.Builder
+	for i, r := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			grouped.WriteRune('.')
+		}
+		grouped.WriteRune(r)
+	}
+
+	result := "Rp " + grouped.String()
+	if cents > 0 {
+		result += fmt.Sprintf(",%02d", cents)
+	}
+	if negative && (whole > 0 || cents > 0) {
+		result = "-" + result
+	}
+	return result
+}
+
+// FormatTanggal memformat tanggal dengan nama bulan berbahasa Indonesia, mis. "18 Oktober 2026"
+func FormatTanggal(t time.Time) string {
+	return fmt.Sprintf("%d %s %d", t.Day(), namaBulan[t.Month()-1], t.Year())
+}
+
+// FormatTanggalJam memformat tanggal dan jam, mis. "18 Oktober 2026 14:05"
+func FormatTanggalJam(t time.Time) string {
+	return FormatTanggal(t) + " " + t.Format("15:04")
+}
@@ -0,0 +1,31 @@
+package pdf
+
+import "testing"
+
+func TestFormatRupiah(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount float64
+		want   string
+	}{
+		{"nol", 0, "Rp 0"},
+		{"sebelas", 11, "Rp 11"},
+		{"seratus", 100, "Rp 100"},
+		{"seribu", 1000, "Rp 1.000"},
+		{"satu juta", 1000000, "Rp 1.000.000"},
+		{"nominal besar", 1234567890123, "Rp 1.234.567.890.123"},
+		{"desimal", 1250000.5, "Rp 1.250.000,50"},
+		{"desimal dua digit", 99.99, "Rp 99,99"},
+		{"desimal dibulatkan ke rupiah berikutnya", 999.999, "Rp 1.000"},
+		{"negatif", -1250000, "-Rp 1.250.000"},
+		{"negatif desimal", -0.25, "-Rp 0,25"},
+		{"negatif yang dibulatkan menjadi nol", -0.001, "Rp 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatRupiah(tt.amount); got != tt.want {
+				t.Errorf("FormatRupiah(%v) = %q, want %q", tt.amount, got, tt.want)
+			}
+		})
+	}
+}
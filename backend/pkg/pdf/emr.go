@@ -0,0 +1,259 @@
+package pdf
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/models"
+	"github.com/MadeAgus22/dental-clinic-backend/types"
+)
+
+// toothStyle menentukan warna isian dan singkatan kondisi gigi pada odontogram
+type toothStyle struct {
+	r, g, b int
+	abbr    string
+	label   string
+}
+
+var toothStyles = map[types.ToothCondition]toothStyle{
+	types.Normal:    {255, 255, 255, "", "Normal"},
+	types.Caries:    {239, 83, 80, "K", "Karies"},
+	types.Filling:   {66, 165, 245, "T", "Tambalan"},
+	types.Missing:   {189, 189, 189, "X", "Hilang"},
+	types.Crown:     {255, 202, 40, "C", "Mahkota"},
+	types.RootCanal: {171, 71, 188, "PSA", "Perawatan Saluran Akar"},
+	types.Implant:   {102, 187, 106, "I", "Implan"},
+}
+
+// toothLegendOrder adalah urutan kondisi pada legenda odontogram
+var toothLegendOrder = []types.ToothCondition{types.Normal, types.Caries, types.Filling, types.Missing, types.Crown, types.RootCanal, types.Implant}
+
+// Susunan gigi notasi FDI dari sudut pandang pemeriksa: kanan pasien di sisi kiri kertas
+var (
+	permanentUpper  = []string{"18", "17", "16", "15", "14", "13", "12", "11", "21", "22", "23", "24", "25", "26", "27", "28"}
+	permanentLower  = []string{"48", "47", "46", "45", "44", "43", "42", "41", "31", "32", "33", "34", "35", "36", "37", "38"}
+	deciduousUpper  = []string{"55", "54", "53", "52", "51", "61", "62", "63", "64", "65"}
+	deciduousLower  = []string{"85", "84", "83", "82", "81", "71", "72", "73", "74", "75"}
+	toothBoxSize    = 10.0
+	toothMidlineGap = 4.0
+)
+
+// writeToothRow menggambar satu baris gigi; nomor gigi dicetak di atas (rahang atas) atau di bawah (rahang bawah)
+func (d *document) writeToothRow(teeth []string, conditions map[string]types.ToothCondition, numberOnTop bool) {
+	left, _, _, _ := d.GetMargins()
+	rowWidth := float64(len(teeth))*toothBoxSize + toothMidlineGap
+	x := left + (d.contentWidth()-rowWidth)/2
+	y := d.GetY()
+	boxY := y
+	if numberOnTop {
+		boxY = y + 5
+	}
+
+	for i, tooth := range teeth {
+		if i == len(teeth)/2 {
+			x += toothMidlineGap
+		}
+		style := toothStyles[types.Normal]
+		if condition, ok := conditions[tooth]; ok {
+			if s, known := toothStyles[condition]; known {
+				style = s
+			}
+		}
+
+		d.SetFont("Helvetica", "", 7)
+		numberY := boxY + toothBoxSize
+		if numberOnTop {
+			numberY = y
+		}
+		d.SetXY(x, numberY)
+		d.CellFormat(toothBoxSize, 5, tooth, "", 0, "C", false, 0, "")
+
+		d.SetFillColor(style.r, style.g, style.b)
+		d.Rect(x, boxY, toothBoxSize, toothBoxSize, "FD")
+		if style.abbr != "" {
+			d.SetFont("Helvetica", "B", 7)
+			d.SetXY(x, boxY)
+			d.CellFormat(toothBoxSize, toothBoxSize, style.abbr, "", 0, "C", false, 0, "")
+		}
+		x += toothBoxSize
+	}
+	d.SetXY(left, y+toothBoxSize+5)
+}
+
+// writeOdontogramChart menggambar odontogram gigi permanen, dan gigi sulung jika ada datanya
+func (d *document) writeOdontogramChart(odontogram []models.OdontogramDetail) {
+	conditions := make(map[string]types.ToothCondition, len(odontogram))
+	hasDeciduous := false
+	for _, tooth := range odontogram {
+		conditions[tooth.ToothNumber] = tooth.Condition
+		if tooth.ToothNumber != "" && strings.ContainsRune("5678", rune(tooth.ToothNumber[0])) {
+			hasDeciduous = true
+		}
+	}
+
+	// Halaman baru jika sisa ruang tidak cukup untuk seluruh bagan
+	_, pageHeight := d.GetPageSize()
+	needed := 2*(toothBoxSize+5) + 20
+	if hasDeciduous {
+		needed += 2 * (toothBoxSize + 5)
+	}
+	if d.GetY()+needed > pageHeight-20 {
+		d.AddPage()
+	}
+
+	d.SetDrawColor(80, 80, 80)
+	d.writeToothRow(permanentUpper, conditions, true)
+	if hasDeciduous {
+		d.writeToothRow(deciduousUpper, conditions, true)
+		d.writeToothRow(deciduousLower, conditions, false)
+	}
+	d.Ln(1)
+	d.writeToothRow(permanentLower, conditions, false)
+	d.SetDrawColor(0, 0, 0)
+
+	// Legenda
+	d.Ln(2)
+	d.SetFont("Helvetica", "", 7)
+	for _, condition := range toothLegendOrder {
+		style := toothStyles[condition]
+		d.SetFillColor(style.r, style.g, style.b)
+		d.Rect(d.GetX(), d.GetY()+1, 3, 3, "FD")
+		d.SetX(d.GetX() + 4)
+		label := style.label
+		if style.abbr != "" {
+			label = fmt.Sprintf("%s (%s)", style.label, style.abbr)
+		}
+		d.cell(d.GetStringWidth(label)+4, 5, label, "", 0, "L", false)
+	}
+	d.Ln(7)
+
+	// Catatan per gigi yang tidak normal
+	var notes []models.OdontogramDetail
+	for _, tooth := range odontogram {
+		if tooth.Condition != types.Normal || tooth.TreatmentNote != "" {
+			notes = append(notes, tooth)
+		}
+	}
+	if len(notes) > 0 {
+		d.SetFont("Helvetica", "", 9)
+		for _, tooth := range notes {
+			label := string(tooth.Condition)
+			if style, ok := toothStyles[tooth.Condition]; ok {
+				label = style.label
+			}
+			text := fmt.Sprintf("Gigi %s: %s", tooth.ToothNumber, label)
+			if tooth.TreatmentNote != "" {
+				text += " - " + tooth.TreatmentNote
+			}
+			d.multiCell(0, 5, text, "", "L", false)
+		}
+	}
+}
+
+// writeParagraph mencetak judul kecil dan isi teks klinis; isi kosong ditampilkan sebagai "-"
+func (d *document) writeParagraph(title, text string) {
+	d.SetFont("Helvetica", "B", 10)
+	d.cell(0, 6, title, "", 1, "L", false)
+	d.SetFont("Helvetica", "", 10)
+	if strings.TrimSpace(text) == "" {
+		text = "-"
+	}
+	d.multiCell(0, 5, text, "", "L", false)
+	d.Ln(1)
+}
+
+// patientAge menghitung umur pasien pada tanggal pemeriksaan
+func patientAge(birthDate *time.Time, at time.Time) string {
+	if birthDate == nil {
+		return "-"
+	}
+	years := at.Year() - birthDate.Year()
+	if at.Month() < birthDate.Month() || (at.Month() == birthDate.Month() && at.Day() < birthDate.Day()) {
+		years--
+	}
+	return fmt.Sprintf("%d tahun", years)
+}
+
+// RenderEMRSummary membuat PDF ringkasan satu rekam medis untuk rujukan atau salinan pasien.
+// EMR harus di-preload dengan Patient, Treatments.TreatmentCatalog, Medications.MedicationCatalog dan Odontogram.
+func RenderEMRSummary(w io.Writer, clinic ClinicInfo, emr models.MedicalRecord) error {
+	d := newDocument()
+	d.writeClinicHeader(clinic)
+	d.writeTitle("RINGKASAN REKAM MEDIS")
+
+	tanggalLahir := "-"
+	if emr.Patient.TanggalLahir != nil {
+		tanggalLahir = FormatTanggal(*emr.Patient.TanggalLahir)
+	}
+	d.writeLabelValue(38, "No. Kunjungan", emr.VisitID)
+	d.writeLabelValue(38, "Tanggal Periksa", FormatTanggalJam(emr.ExamDate))
+	d.writeLabelValue(38, "Dokter", emr.DoctorName)
+	d.writeLabelValue(38, "Nama Pasien", emr.Patient.NamaLengkap)
+	d.writeLabelValue(38, "No. RM", emr.Patient.NoRM)
+	d.writeLabelValue(38, "Tanggal Lahir / Umur", fmt.Sprintf("%s / %s", tanggalLahir, patientAge(emr.Patient.TanggalLahir, emr.ExamDate)))
+	if emr.Patient.JenisKelamin != "" {
+		d.writeLabelValue(38, "Jenis Kelamin", emr.Patient.JenisKelamin)
+	}
+	alergi := emr.Patient.Alergi
+	if alergi == "" {
+		alergi = "Tidak ada data"
+	}
+	d.writeLabelValue(38, "Alergi", alergi)
+
+	d.writeSectionTitle("Anamnesis & Pemeriksaan")
+	d.writeParagraph("Keluhan", emr.Complaint)
+	d.writeParagraph("Pemeriksaan", emr.Examination)
+	d.writeParagraph("Diagnosis", emr.Diagnosis)
+	d.writeParagraph("Rencana Perawatan", emr.TreatmentPlan)
+	if emr.Notes != "" {
+		d.writeParagraph("Catatan", emr.Notes)
+	}
+
+	d.writeSectionTitle("Odontogram")
+	d.writeOdontogramChart(emr.Odontogram)
+
+	d.writeSectionTitle("Tindakan")
+	if len(emr.Treatments) == 0 {
+		d.SetFont("Helvetica", "", 10)
+		d.cell(0, 6, "Tidak ada tindakan.", "", 1, "L", false)
+	} else {
+		widths := []float64{8, 62, 18, 12, 80}
+		d.SetFont("Helvetica", "B", 9)
+		d.SetFillColor(230, 230, 230)
+		for i, header := range []string{"No", "Tindakan", "Gigi", "Qty", "Catatan"} {
+			d.cell(widths[i], 7, header, "1", 0, "C", true)
+		}
+		d.Ln(-1)
+		d.SetFont("Helvetica", "", 9)
+		for i, t := range emr.Treatments {
+			d.CellFormat(widths[0], 6, fmt.Sprintf("%d", i+1), "1", 0, "C", false, 0, "")
+			d.CellFormat(widths[1], 6, d.fit(t.TreatmentCatalog.Nama, widths[1]), "1", 0, "L", false, 0, "")
+			d.CellFormat(widths[2], 6, d.tr(t.ToothNumber), "1", 0, "C", false, 0, "")
+			d.CellFormat(widths[3], 6, fmt.Sprintf("%d", t.Quantity), "1", 0, "C", false, 0, "")
+			d.CellFormat(widths[4], 6, d.fit(t.Notes, widths[4]), "1", 1, "L", false, 0, "")
+		}
+	}
+
+	d.writeSectionTitle("Resep / Obat")
+	if len(emr.Medications) == 0 {
+		d.SetFont("Helvetica", "", 10)
+		d.cell(0, 6, "Tidak ada obat yang diresepkan.", "", 1, "L", false)
+	} else {
+		for i, m := range emr.Medications {
+			d.SetFont("Helvetica", "B", 10)
+			d.multiCell(0, 5, fmt.Sprintf("%d. R/ %s  No. %d %s", i+1, m.MedicationCatalog.Nama, m.Quantity, m.MedicationCatalog.Satuan), "", "L", false)
+			d.SetFont("Helvetica", "", 10)
+			instruction := m.Instruction
+			if instruction == "" {
+				instruction = "-"
+			}
+			d.multiCell(0, 5, "     S. "+instruction, "", "L", false)
+		}
+	}
+
+	d.writeSignature(FormatTanggal(emr.ExamDate), "Dokter Pemeriksa,", emr.DoctorName)
+
+	return d.Output(w)
+}
@@ -3,6 +3,7 @@ package routes
 import (
 	"github.com/MadeAgus22/dental-clinic-backend/pkg/handlers"
 	"github.com/MadeAgus22/dental-clinic-backend/pkg/middleware"
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/models"
 
 	"github.com/gofiber/contrib/fiberzap"
 	"github.com/gofiber/fiber/v2"
@@ -13,65 +14,194 @@ import (
 
 func SetupRoutes(app *fiber.App, logger *zap.Logger) {
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "http://localhost:3000",
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
-		AllowMethods: "GET,POST,PUT,DELETE,PATCH,OPTIONS",
+		AllowOrigins:  "http://localhost:3000",
+		AllowHeaders:  "Origin, Content-Type, Accept, Authorization, Last-Event-ID, If-Match",
+		ExposeHeaders: "ETag",
+		AllowMethods:  "GET,POST,PUT,DELETE,PATCH,OPTIONS",
 	}))
 	app.Use(recover.New())
-	app.Use(fiberzap.New(fiberzap.Config{Logger: logger}))
+	app.Use(fiberzap.New(fiberzap.Config{
+		Logger:   logger,
+		SkipURIs: []string{"/api/v1/events/stream"}, // URL stream bisa berisi token di query
+	}))
+	middleware.SetAuditLogger(logger)
 
 	api := app.Group("/api/v1")
 
 	// Rute Autentikasi
 	auth := api.Group("/auth")
 	auth.Post("/login", handlers.LoginUser)
+	auth.Post("/refresh", handlers.RefreshToken)
+	auth.Post("/2fa/verify", handlers.VerifyTwoFactorLogin)
+	auth.Post("/2fa/setup", handlers.SetupTwoFactorLogin)
+	auth.Post("/2fa/enable", handlers.EnableTwoFactorLogin)
+	auth.Post("/password-reset/verify", handlers.VerifyPasswordResetToken)
+	auth.Post("/password-reset", handlers.ResetPassword)
+
+	// Stream event (SSE) didaftarkan sebelum middleware JWT grup karena token juga boleh dikirim lewat query
+	api.Get("/events/stream",
+		middleware.StreamJWTMiddleware(),
+		middleware.RequirePermission("patient:register_visit", "reservation:view_all", "reservation:view_doctor_specific", "emr:view", "inventory:view_stock"),
+		handlers.StreamEvents)
 
 	// Rute yang dilindungi JWT
 	protected := api.Use(middleware.JWTMiddleware())
 
 	protected.Get("/me", handlers.GetCurrentUser)
+	protected.Post("/auth/logout", handlers.Logout)
+	protected.Get("/me/sessions", handlers.GetMySessions)
+	protected.Delete("/me/sessions", handlers.RevokeMyOtherSessions)
+	protected.Delete("/me/sessions/:sessionId", handlers.RevokeMySession)
+	protected.Put("/me/password", handlers.ChangeMyPassword)
+	protected.Get("/me/2fa", handlers.GetMyTwoFactorStatus)
+	protected.Post("/me/2fa/setup", handlers.SetupMyTwoFactor)
+	protected.Post("/me/2fa/enable", handlers.EnableMyTwoFactor)
+	protected.Post("/me/2fa/disable", handlers.DisableMyTwoFactor)
+	protected.Post("/me/2fa/recovery-codes", handlers.RegenerateMyRecoveryCodes)
 
 	// Rute Manajemen Pengguna (Admin)
-	adminUserRoutes := protected.Group("/admin/users", middleware.AuthorizeRole("admin"))
-	adminUserRoutes.Post("/register", handlers.RegisterUserByAdmin)
-	adminUserRoutes.Get("/", handlers.GetAllUsers)
-	adminUserRoutes.Get("/:userId", handlers.GetUserByID)
-	adminUserRoutes.Put("/:userId", handlers.UpdateUser)
-	adminUserRoutes.Delete("/:userId", handlers.DeleteUser)
+	adminUserRoutes := protected.Group("/admin/users")
+	adminUserRoutes.Post("/register", middleware.RequirePermission("settings:manage_users"), middleware.Audit(models.AuditActionCreate, handlers.UserAudit), handlers.RegisterUserByAdmin)
+	adminUserRoutes.Get("/", middleware.RequirePermission("settings:view_users"), middleware.Audit(models.AuditActionRead, handlers.UserListAudit), handlers.GetAllUsers)
+	adminUserRoutes.Get("/:userId", middleware.RequirePermission("settings:view_users"), middleware.Audit(models.AuditActionRead, handlers.UserAudit), handlers.GetUserByID)
+	adminUserRoutes.Put("/:userId", middleware.RequirePermission("settings:manage_users"), middleware.Audit(models.AuditActionUpdate, handlers.UserAudit), handlers.UpdateUser)
+	adminUserRoutes.Delete("/:userId", middleware.RequirePermission("settings:manage_users"), middleware.Audit(models.AuditActionDelete, handlers.UserAudit), handlers.DeleteUser)
+	adminUserRoutes.Get("/:userId/sessions", middleware.RequirePermission("settings:view_users"), handlers.GetUserSessions)
+	adminUserRoutes.Delete("/:userId/sessions", middleware.RequirePermission("settings:manage_users"), handlers.RevokeAllUserSessions)
+	adminUserRoutes.Delete("/:userId/sessions/:sessionId", middleware.RequirePermission("settings:manage_users"), handlers.RevokeUserSession)
+	adminUserRoutes.Delete("/:userId/2fa", middleware.RequirePermission("settings:manage_users"), middleware.Audit(models.AuditActionUpdate, handlers.UserAudit), handlers.ResetUserTwoFactor)
+	adminUserRoutes.Post("/:userId/unlock", middleware.RequirePermission("settings:manage_users"), handlers.UnlockUserLogin)
+	adminUserRoutes.Post("/:userId/password-reset", middleware.RequirePermission("settings:manage_users"), middleware.Audit(models.AuditActionUpdate, handlers.UserAudit), handlers.CreatePasswordResetLink)
+
+	// Rute Keamanan Login (Admin)
+	adminSecurityRoutes := protected.Group("/admin/security")
+	adminSecurityRoutes.Get("/login-events", middleware.RequirePermission("settings:view_users"), handlers.GetLoginEvents)
+	adminSecurityRoutes.Get("/lockouts", middleware.RequirePermission("settings:view_users"), handlers.GetLoginLockouts)
+	adminSecurityRoutes.Delete("/lockouts/:id", middleware.RequirePermission("settings:manage_users"), handlers.DeleteLoginLockout)
+
+	// Rute Jejak Audit (Admin)
+	adminAuditRoutes := protected.Group("/admin/audit-logs")
+	adminAuditRoutes.Get("/", middleware.RequirePermission("settings:view_audit_log"), handlers.GetAuditLogs)
+	adminAuditRoutes.Get("/export", middleware.RequirePermission("settings:view_audit_log"), handlers.ExportAuditLogs)
 
 	// Rute Manajemen Role & Permission (Admin)
-	adminAccessRoutes := protected.Group("/admin/access", middleware.AuthorizeRole("admin"))
+	adminAccessRoutes := protected.Group("/admin/access")
 	// Role CRUD
-	adminAccessRoutes.Post("/roles", handlers.CreateRole)
-	adminAccessRoutes.Get("/roles", handlers.GetAllRoles)
-	adminAccessRoutes.Get("/roles/:roleId", handlers.GetRoleByID)
-	adminAccessRoutes.Put("/roles/:roleId", handlers.UpdateRole) // Handler ini juga akan mengupdate permissions
-	adminAccessRoutes.Delete("/roles/:roleId", handlers.DeleteRole)
+	adminAccessRoutes.Post("/roles", middleware.RequirePermission("settings:manage_roles"), middleware.Audit(models.AuditActionCreate, handlers.RoleAudit), handlers.CreateRole)
+	adminAccessRoutes.Get("/roles", middleware.RequirePermission("settings:view_roles"), middleware.Audit(models.AuditActionRead, handlers.RoleListAudit), handlers.GetAllRoles)
+	adminAccessRoutes.Get("/roles/:roleId", middleware.RequirePermission("settings:view_roles"), middleware.Audit(models.AuditActionRead, handlers.RoleAudit), handlers.GetRoleByID)
+	adminAccessRoutes.Put("/roles/:roleId", middleware.RequirePermission("settings:manage_roles"), middleware.Audit(models.AuditActionUpdate, handlers.RoleAudit), handlers.UpdateRole) // Handler ini juga akan mengupdate permissions
+	adminAccessRoutes.Delete("/roles/:roleId", middleware.RequirePermission("settings:manage_roles"), middleware.Audit(models.AuditActionDelete, handlers.RoleAudit), handlers.DeleteRole)
 	// Permission (Hanya GET semua)
-	adminAccessRoutes.Get("/permissions", handlers.GetAllPermissions)
+	adminAccessRoutes.Get("/permissions", middleware.RequirePermission("settings:view_roles"), handlers.GetAllPermissions)
 
 	// Rute Pasien
-	patientRoutes := protected.Group("/pasien", middleware.AuthorizeRole("admin", "resepsionis", "dokter"))
-	patientRoutes.Post("/", middleware.AuthorizeRole("admin", "resepsionis"), handlers.CreatePatient)
-	patientRoutes.Get("/", handlers.GetPatients)
-	patientRoutes.Get("/:id", handlers.GetPatientByIDOrNoRM)
-	patientRoutes.Put("/:id", middleware.AuthorizeRole("admin", "resepsionis"), handlers.UpdatePatient)
-	patientRoutes.Delete("/:id", middleware.AuthorizeRole("admin", "resepsionis"), handlers.DeletePatient)
+	patientRoutes := protected.Group("/pasien")
+	patientRoutes.Post("/", middleware.RequirePermission("patient:create"), middleware.Audit(models.AuditActionCreate, handlers.PatientAudit), handlers.CreatePatient)
+	patientRoutes.Get("/", middleware.RequirePermission("patient:view"), middleware.Audit(models.AuditActionRead, handlers.PatientListAudit), handlers.GetPatients)
+	patientRoutes.Get("/:id", middleware.RequirePermission("patient:view"), middleware.Audit(models.AuditActionRead, handlers.PatientAudit), handlers.GetPatientByIDOrNoRM)
+	patientRoutes.Put("/:id", middleware.RequirePermission("patient:update"), middleware.Audit(models.AuditActionUpdate, handlers.PatientAudit), handlers.UpdatePatient)
+	patientRoutes.Delete("/:id", middleware.RequirePermission("patient:delete"), middleware.Audit(models.AuditActionDelete, handlers.PatientAudit), handlers.DeletePatient)
 
 	// Rute EMR
-	emrRoutes := protected.Group("/emr", middleware.AuthorizeRole("admin", "dokter"))
-	emrRoutes.Post("/", handlers.CreateEMR)
-	emrRoutes.Get("/pasien/:patientId", handlers.GetEMRsByPatient)
-	emrRoutes.Get("/:id", handlers.GetEMRByID)
-	emrRoutes.Put("/:id", handlers.UpdateEMR)
-
-	// TODO: Rute untuk Master Data (Tindakan, Obat)
-	// masterDataRoutes := protected.Group("/master", middleware.AuthorizeRole("admin"))
-	// ...
-
-	// TODO: Rute untuk Reservasi
-	// reservationRoutes := protected.Group("/reservasi", middleware.AuthorizeRole("admin", "resepsionis"))
-	// ...
+	emrRoutes := protected.Group("/emr")
+	emrRoutes.Post("/", middleware.RequirePermission("emr:create"), middleware.Audit(models.AuditActionCreate, handlers.MedicalRecordAudit), handlers.CreateEMR)
+	emrRoutes.Get("/pending-signature", middleware.RequirePermission("emr:view"), handlers.GetPendingSignatureEMRs)
+	emrRoutes.Get("/pasien/:patientId", middleware.RequirePermission("emr:view"), middleware.Audit(models.AuditActionRead, handlers.PatientMedicalRecordsAudit), handlers.GetEMRsByPatient)
+	emrRoutes.Get("/:id", middleware.RequirePermission("emr:view"), middleware.Audit(models.AuditActionRead, handlers.MedicalRecordAudit), handlers.GetEMRByID)
+	emrRoutes.Put("/:id", middleware.RequirePermission("emr:update"), middleware.Audit(models.AuditActionUpdate, handlers.MedicalRecordAudit), handlers.UpdateEMR)
+	emrRoutes.Get("/:id/versions", middleware.RequirePermission("emr:view"), middleware.Audit(models.AuditActionRead, handlers.MedicalRecordAudit), handlers.GetEMRVersions)
+	emrRoutes.Get("/:id/versions/diff", middleware.RequirePermission("emr:view"), middleware.Audit(models.AuditActionRead, handlers.MedicalRecordAudit), handlers.DiffEMRVersions)
+	emrRoutes.Get("/:id/versions/:version", middleware.RequirePermission("emr:view"), middleware.Audit(models.AuditActionRead, handlers.MedicalRecordAudit), handlers.GetEMRVersion)
+	emrRoutes.Post("/:id/finalize", middleware.RequirePermission("emr:finalize"), middleware.Audit(models.AuditActionUpdate, handlers.MedicalRecordAudit), handlers.FinalizeEMR)
+	emrRoutes.Get("/:id/signature", middleware.RequirePermission("emr:view"), middleware.Audit(models.AuditActionRead, handlers.MedicalRecordAudit), handlers.GetEMRSignature)
+	emrRoutes.Get("/:id/addenda", middleware.RequirePermission("emr:view"), middleware.Audit(models.AuditActionRead, handlers.MedicalRecordAudit), handlers.GetEMRAddenda)
+	emrRoutes.Post("/:id/addenda", middleware.RequirePermission("emr:add_addendum"), middleware.Audit(models.AuditActionUpdate, handlers.MedicalRecordAudit), handlers.CreateEMRAddendum)
+	emrRoutes.Get("/:id/pdf", middleware.RequirePermission("emr:print"), middleware.Audit(models.AuditActionRead, handlers.MedicalRecordAudit), handlers.PrintEMR)
+
+	// Rute Master Data (Tindakan, Obat)
+	masterDataRoutes := protected.Group("/master")
+	masterDataRoutes.Get("/tindakan", middleware.RequirePermission("master:view_treatments"), handlers.GetTreatmentCatalogs)
+	masterDataRoutes.Get("/tindakan/:kode", middleware.RequirePermission("master:view_treatments"), handlers.GetTreatmentCatalogByKode)
+	masterDataRoutes.Post("/tindakan", middleware.RequirePermission("master:manage_treatments"), handlers.CreateTreatmentCatalog)
+	masterDataRoutes.Put("/tindakan/:kode", middleware.RequirePermission("master:manage_treatments"), handlers.UpdateTreatmentCatalog)
+	masterDataRoutes.Delete("/tindakan/:kode", middleware.RequirePermission("master:manage_treatments"), handlers.DeleteTreatmentCatalog)
+	masterDataRoutes.Post("/tindakan/:kode/restore", middleware.RequirePermission("master:manage_treatments"), handlers.RestoreTreatmentCatalog)
+
+	masterDataRoutes.Get("/obat", middleware.RequirePermission("master:view_medications"), handlers.GetMedicationCatalogs)
+	masterDataRoutes.Get("/obat/:kode", middleware.RequirePermission("master:view_medications"), handlers.GetMedicationCatalogByKode)
+	masterDataRoutes.Post("/obat", middleware.RequirePermission("master:manage_medications"), handlers.CreateMedicationCatalog)
+	masterDataRoutes.Put("/obat/:kode", middleware.RequirePermission("master:manage_medications"), handlers.UpdateMedicationCatalog)
+	masterDataRoutes.Delete("/obat/:kode", middleware.RequirePermission("master:manage_medications"), handlers.DeleteMedicationCatalog)
+	masterDataRoutes.Post("/obat/:kode/restore", middleware.RequirePermission("master:manage_medications"), handlers.RestoreMedicationCatalog)
+
+	// Rute Stok (buku besar mutasi stok, batch dan kedaluwarsa obat/bahan, stock opname, peringatan stok menipis)
+	stockRoutes := protected.Group("/stok")
+	stockRoutes.Get("/mutasi", middleware.RequirePermission("inventory:view_stock"), handlers.GetStockMovements)
+	stockRoutes.Post("/mutasi", middleware.RequirePermission("inventory:manage_stock"), handlers.CreateStockMovement)
+	stockRoutes.Get("/batch", middleware.RequirePermission("inventory:view_stock"), handlers.GetStockBatches)
+	stockRoutes.Get("/batch/kedaluwarsa", middleware.RequirePermission("inventory:view_stock"), handlers.GetExpiringStockBatches)
+	stockRoutes.Post("/batch", middleware.RequirePermission("inventory:manage_stock"), handlers.CreateStockBatch)
+	stockRoutes.Post("/batch/write-off-expired", middleware.RequirePermission("inventory:manage_stock"), handlers.WriteOffExpiredStock)
+	stockRoutes.Get("/opname", middleware.RequirePermission("inventory:view_stock"), handlers.GetStockOpnames)
+	stockRoutes.Get("/opname/:id", middleware.RequirePermission("inventory:view_stock"), handlers.GetStockOpnameByID)
+	stockRoutes.Post("/opname", middleware.RequirePermission("inventory:count_stock"), handlers.CreateStockOpname)
+	stockRoutes.Put("/opname/:id/hitung", middleware.RequirePermission("inventory:count_stock"), handlers.SubmitStockCount)
+	stockRoutes.Post("/opname/:id/setujui", middleware.RequirePermission("inventory:approve_stock_count"), handlers.ApproveStockOpname)
+	stockRoutes.Post("/opname/:id/batal", middleware.RequirePermission("inventory:approve_stock_count"), handlers.CancelStockOpname)
+	stockRoutes.Get("/peringatan", middleware.RequirePermission("inventory:view_stock"), handlers.GetLowStockAlerts)
+	stockRoutes.Post("/peringatan/cek", middleware.RequirePermission("inventory:manage_stock"), handlers.RunLowStockCheck)
+	stockRoutes.Post("/peringatan/:id/akui", middleware.RequirePermission("inventory:manage_stock"), handlers.AcknowledgeLowStockAlert)
+	stockRoutes.Get("/saran-pemesanan", middleware.RequirePermission("inventory:view_stock"), handlers.GetReorderSuggestions)
+
+	// Rute Pengadaan (supplier, purchase order dan penerimaan barang)
+	procurementRoutes := protected.Group("/pengadaan")
+	procurementRoutes.Get("/supplier", middleware.RequirePermission("procurement:view"), handlers.GetSuppliers)
+	procurementRoutes.Get("/supplier/:id", middleware.RequirePermission("procurement:view"), handlers.GetSupplierByID)
+	procurementRoutes.Post("/supplier", middleware.RequirePermission("procurement:manage"), handlers.CreateSupplier)
+	procurementRoutes.Put("/supplier/:id", middleware.RequirePermission("procurement:manage"), handlers.UpdateSupplier)
+	procurementRoutes.Delete("/supplier/:id", middleware.RequirePermission("procurement:manage"), handlers.DeleteSupplier)
+	procurementRoutes.Get("/po", middleware.RequirePermission("procurement:view"), handlers.GetPurchaseOrders)
+	procurementRoutes.Get("/po/:id", middleware.RequirePermission("procurement:view"), handlers.GetPurchaseOrderByID)
+	procurementRoutes.Post("/po", middleware.RequirePermission("procurement:manage"), handlers.CreatePurchaseOrder)
+	procurementRoutes.Put("/po/:id", middleware.RequirePermission("procurement:manage"), handlers.UpdatePurchaseOrder)
+	procurementRoutes.Post("/po/:id/kirim", middleware.RequirePermission("procurement:manage"), handlers.SendPurchaseOrder)
+	procurementRoutes.Post("/po/:id/batal", middleware.RequirePermission("procurement:manage"), handlers.CancelPurchaseOrder)
+	procurementRoutes.Post("/po/:id/penerimaan", middleware.RequirePermission("procurement:receive"), handlers.CreateGoodsReceipt)
+	procurementRoutes.Get("/laporan/po-outstanding", middleware.RequirePermission("procurement:view"), handlers.GetOutstandingPurchaseOrderReport)
+
+	// Rute Reservasi
+	reservationRoutes := protected.Group("/reservasi")
+	reservationRoutes.Post("/", middleware.RequirePermission("reservation:create"), handlers.CreateReservation)
+	reservationRoutes.Get("/", middleware.RequirePermission("reservation:view_all", "reservation:view_doctor_specific"), middleware.Audit(models.AuditActionRead, handlers.ReservationListAudit), handlers.GetReservations)
+	reservationRoutes.Get("/:id", middleware.RequirePermission("reservation:view_all", "reservation:view_doctor_specific"), middleware.Audit(models.AuditActionRead, handlers.ReservationAudit), handlers.GetReservationByID)
+	reservationRoutes.Put("/:id", middleware.RequirePermission("reservation:update"), handlers.UpdateReservation)
+	reservationRoutes.Post("/:id/cancel", middleware.RequirePermission("reservation:cancel"), handlers.CancelReservation)
+	reservationRoutes.Delete("/:id", middleware.RequirePermission("reservation:cancel"), handlers.CancelReservation) // Alias: reservasi tidak dihapus, hanya dibatalkan
+	reservationRoutes.Post("/:id/confirm-arrival", middleware.RequirePermission("reservation:confirm_arrival"), handlers.ConfirmReservationArrival)
+
+	// Rute Antrian (per dokter per hari)
+	queueRoutes := protected.Group("/antrian")
+	queueRoutes.Get("/", middleware.RequirePermission("patient:register_visit", "emr:view"), middleware.Audit(models.AuditActionRead, handlers.QueueListAudit), handlers.GetQueue)
+	queueRoutes.Get("/:id", middleware.RequirePermission("patient:register_visit", "emr:view"), middleware.Audit(models.AuditActionRead, handlers.QueueAudit), handlers.GetQueueEntryByID)
+	queueRoutes.Post("/check-in", middleware.RequirePermission("patient:register_visit"), handlers.CheckInQueue)
+	queueRoutes.Post("/call-next", middleware.RequirePermission("patient:register_visit", "emr:create"), handlers.CallNextQueue)
+	queueRoutes.Post("/:id/recall", middleware.RequirePermission("patient:register_visit", "emr:create"), handlers.RecallQueue)
+	queueRoutes.Post("/:id/skip", middleware.RequirePermission("patient:register_visit", "emr:create"), handlers.SkipQueue)
+	queueRoutes.Post("/:id/cancel", middleware.RequirePermission("patient:register_visit", "emr:create"), handlers.CancelQueue)
+	queueRoutes.Post("/:id/start", middleware.RequirePermission("emr:create"), middleware.Audit(models.AuditActionCreate, handlers.QueueExaminationAudit), handlers.StartQueueExamination)
+	queueRoutes.Post("/:id/finish", middleware.RequirePermission("emr:create"), handlers.FinishQueue)
+
+	// Rute Billing (Tagihan & Pembayaran)
+	billingRoutes := protected.Group("/billing")
+	billingRoutes.Post("/invoices", middleware.RequirePermission("billing:create"), handlers.CreateInvoice)
+	billingRoutes.Get("/invoices", middleware.RequirePermission("billing:view"), middleware.Audit(models.AuditActionRead, handlers.InvoiceListAudit), handlers.GetInvoices)
+	billingRoutes.Get("/invoices/:id", middleware.RequirePermission("billing:view"), middleware.Audit(models.AuditActionRead, handlers.InvoiceAudit), handlers.GetInvoiceByID)
+	billingRoutes.Post("/invoices/:id/regenerate", middleware.RequirePermission("billing:create"), handlers.RegenerateInvoice)
+	billingRoutes.Post("/invoices/:id/payments", middleware.RequirePermission("billing:process_payment"), handlers.CreatePayment)
+	billingRoutes.Get("/invoices/:id/pdf", middleware.RequirePermission("billing:print_receipt"), middleware.Audit(models.AuditActionRead, handlers.InvoiceAudit), handlers.PrintInvoice)
+	billingRoutes.Get("/emr/:emrId/pdf", middleware.RequirePermission("billing:print_receipt"), middleware.Audit(models.AuditActionRead, handlers.EMRInvoiceAudit), handlers.PrintInvoiceByEMR)
+	billingRoutes.Get("/payments/:paymentId/kwitansi", middleware.RequirePermission("billing:print_receipt"), middleware.Audit(models.AuditActionRead, handlers.PaymentAudit), handlers.PrintPaymentReceipt)
 
 	api.Get("/ping", func(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "ok", "message": "Pong!"})
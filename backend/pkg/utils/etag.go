@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SetETag mengirim nomor versi data sebagai header ETag, mis. "3"
+func SetETag(c *fiber.Ctx, version int) {
+	c.Set(fiber.HeaderETag, `"`+strconv.Itoa(version)+`"`)
+}
+
+// IfMatchVersion membaca nomor versi dari header If-Match (format ETag dari SetETag, prefix W/ diterima).
+// ok=false jika header kosong, berisi "*" atau bukan nomor versi.
+func IfMatchVersion(c *fiber.Ctx) (int, bool) {
+	value := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	value = strings.TrimPrefix(value, "W/")
+	value = strings.Trim(value, `"`)
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 0 {
+		return 0, false
+	}
+	return version, true
+}
+
+// VersionConflictResponse membuat respons 409/412 untuk perubahan yang berbenturan,
+// berisi salinan data terkini dari server beserta ETag-nya agar client bisa menggabungkan ulang
+func VersionConflictResponse(c *fiber.Ctx, statusCode int, message string, version int, current interface{}) error {
+	SetETag(c, version)
+	return c.Status(statusCode).JSON(fiber.Map{
+		"success": false,
+		"message": message,
+		"data":    current,
+	})
+}
@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// numberFormatToken mencocokkan placeholder pada format nomor, mis. {YYYY} atau {seq:06}
+var numberFormatToken = regexp.MustCompile(`\{([A-Za-z]+)(?::(\d+))?\}`)
+
+// NumberFormat adalah pola nomor dokumen (NoRM, nomor kunjungan) yang dapat dikonfigurasi.
+// Placeholder: {YYYY}, {YY}, {MM}, {DD} dari tanggal dokumen, {BRANCH} kode cabang klinik,
+// dan {seq} atau {seq:06} nomor urut dari sequence database (dengan lebar minimal berisi nol di depan).
+type NumberFormat struct {
+	pattern  string
+	branch   string
+	seqWidth int
+	matcher  *regexp.Regexp
+}
+
+// ParseNumberFormat memeriksa pola nomor; pola wajib memuat tepat satu {seq} agar nomor tidak bentrok
+func ParseNumberFormat(pattern, branch string) (*NumberFormat, error) {
+	f := &NumberFormat{pattern: pattern, branch: branch}
+	var expr strings.Builder
+	expr.WriteString("^")
+	seqCount, last := 0, 0
+	for _, loc := range numberFormatToken.FindAllStringSubmatchIndex(pattern, -1) {
+		expr.WriteString(regexp.QuoteMeta(pattern[last:loc[0]]))
+		last = loc[1]
+
+		name := pattern[loc[2]:loc[3]]
+		width := ""
+		if loc[4] >= 0 {
+			width = pattern[loc[4]:loc[5]]
+		}
+		if name != "seq" && width != "" {
+			return nil, fmt.Errorf("placeholder {%s} tidak menerima lebar", name)
+		}
+		switch name {
+		case "YYYY":
+			expr.WriteString(`\d{4}`)
+		case "YY", "MM", "DD":
+			expr.WriteString(`\d{2}`)
+		case "BRANCH":
+			if branch == "" {
+				return nil, fmt.Errorf("format %q memakai {BRANCH} tetapi kode cabang klinik belum diisi", pattern)
+			}
+			expr.WriteString(regexp.QuoteMeta(branch))
+		case "seq":
+			seqCount++
+			if width != "" {
+				f.seqWidth, _ = strconv.Atoi(width)
+			}
+			if f.seqWidth > 0 {
+				expr.WriteString(fmt.Sprintf(`(\d{%d,})`, f.seqWidth))
+			} else {
+				expr.WriteString(`(\d+)`)
+			}
+		default:
+			return nil, fmt.Errorf("placeholder {%s} tidak dikenal", name)
+		}
+	}
+	expr.WriteString(regexp.QuoteMeta(pattern[last:]))
+	expr.WriteString("$")
+
+	if seqCount != 1 {
+		return nil, fmt.Errorf("format %q harus memuat tepat satu {seq}", pattern)
+	}
+	f.matcher = regexp.MustCompile(expr.String())
+	return f, nil
+}
+
+// String mengembalikan pola asli
+func (f *NumberFormat) String() string { return f.pattern }
+
+// Format menyusun nomor dari tanggal dokumen dan nomor urut
+func (f *NumberFormat) Format(t time.Time, seq int64) string {
+	return numberFormatToken.ReplaceAllStringFunc(f.pattern, func(token string) string {
+		switch name := numberFormatToken.FindStringSubmatch(token)[1]; name {
+		case "YYYY":
+			return t.Format("2006")
+		case "YY":
+			return t.Format("06")
+		case "MM":
+			return t.Format("01")
+		case "DD":
+			return t.Format("02")
+		case "BRANCH":
+			return f.branch
+		}
+		return fmt.Sprintf("%0*d", f.seqWidth, seq)
+	})
+}
+
+// ParseSequence mengambil nomor urut dari nomor yang sesuai format; ok=false untuk nomor format lain (mis. nomor lama)
+func (f *NumberFormat) ParseSequence(number string) (int64, bool) {
+	match := f.matcher.FindStringSubmatch(number)
+	if match == nil {
+		return 0, false
+	}
+	seq, err := strconv.ParseInt(match[1], 10, 64)
+	return seq, err == nil
+}
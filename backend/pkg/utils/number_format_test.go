@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNumberFormatRoundTrip(t *testing.T) {
+	date := time.Date(2025, time.March, 7, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		pattern string
+		branch  string
+		seq     int64
+		want    string
+	}{
+		{"NoRM default", "RM-{YYYY}-{seq:06}", "", 42, "RM-2025-000042"},
+		{"nomor kunjungan default", "VISIT-{YYYY}{MM}{DD}-{seq:06}", "", 7, "VISIT-20250307-000007"},
+		{"tahun dua digit", "{YY}/{MM}/{seq:04}", "", 12, "25/03/0012"},
+		{"tanpa lebar", "P{seq}", "", 5, "P5"},
+		{"dengan cabang", "{BRANCH}-RM-{seq:06}", "DPS1", 123, "DPS1-RM-000123"},
+		{"melewati lebar 6 digit", "RM-{YYYY}-{seq:06}", "", 1234567, "RM-2025-1234567"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseNumberFormat(tt.pattern, tt.branch)
+			if err != nil {
+				t.Fatalf("ParseNumberFormat(%q): %v", tt.pattern, err)
+			}
+			got := f.Format(date, tt.seq)
+			if got != tt.want {
+				t.Fatalf("Format = %q, want %q", got, tt.want)
+			}
+			seq, ok := f.ParseSequence(got)
+			if !ok || seq != tt.seq {
+				t.Errorf("ParseSequence(%q) = %d, %v, want %d, true", got, seq, ok, tt.seq)
+			}
+		})
+	}
+}
+
+func TestNumberFormatParseSequence(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		branch  string
+		number  string
+		seq     int64
+		ok      bool
+	}{
+		{"sesuai format", "RM-{YYYY}-{seq:06}", "", "RM-2024-000315", 315, true},
+		{"lebih dari 6 digit", "RM-{YYYY}-{seq:06}", "", "RM-2024-10000001", 10000001, true},
+		{"kurang dari lebar minimal", "RM-{YYYY}-{seq:06}", "", "RM-2024-315", 0, false},
+		{"nomor lama format lain", "RM-{YYYY}-{seq:06}", "", "00315", 0, false},
+		{"prefiks berbeda", "RM-{YYYY}-{seq:06}", "", "XRM-2024-000315", 0, false},
+		{"cabang sesuai", "{BRANCH}-{seq:04}", "D.1", "D.1-0009", 9, true},
+		// Titik pada kode cabang di-quote, sehingga tidak cocok dengan karakter lain
+		{"cabang di-quote", "{BRANCH}-{seq:04}", "D.1", "DX1-0009", 0, false},
+		{"cabang lain", "{BRANCH}-{seq:04}", "D.1", "D.2-0009", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseNumberFormat(tt.pattern, tt.branch)
+			if err != nil {
+				t.Fatalf("ParseNumberFormat(%q): %v", tt.pattern, err)
+			}
+			seq, ok := f.ParseSequence(tt.number)
+			if ok != tt.ok || seq != tt.seq {
+				t.Errorf("ParseSequence(%q) = %d, %v, want %d, %v", tt.number, seq, ok, tt.seq, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParseNumberFormatRejectsInvalidPattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		branch  string
+	}{
+		{"tanpa seq", "RM-{YYYY}", ""},
+		{"dua seq", "{seq}-{seq:04}", ""},
+		{"placeholder tidak dikenal", "RM-{HH}-{seq}", ""},
+		{"lebar pada placeholder tanggal", "RM-{YYYY:4}-{seq}", ""},
+		{"cabang belum diisi", "{BRANCH}-{seq:06}", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseNumberFormat(tt.pattern, tt.branch); err == nil {
+				t.Errorf("ParseNumberFormat(%q) tidak mengembalikan error", tt.pattern)
+			}
+		})
+	}
+}
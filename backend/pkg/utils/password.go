@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/config" // Sesuaikan path
+)
+
+// ValidatePasswordPolicy memeriksa password baru terhadap kebijakan di konfigurasi.
+// Mengembalikan daftar pelanggaran; kosong berarti password memenuhi kebijakan.
+func ValidatePasswordPolicy(password, username string) []string {
+	policy := config.AppConfig.PasswordPolicy
+	var violations []string
+
+	if len([]rune(password)) < policy.MinLength {
+		violations = append(violations, fmt.Sprintf("Password minimal %d karakter", policy.MinLength))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hasSymbol = true
+		}
+	}
+	if policy.RequireUpper && !hasUpper {
+		violations = append(violations, "Password harus mengandung huruf besar")
+	}
+	if policy.RequireLower && !hasLower {
+		violations = append(violations, "Password harus mengandung huruf kecil")
+	}
+	if policy.RequireDigit && !hasDigit {
+		violations = append(violations, "Password harus mengandung angka")
+	}
+	if policy.RequireSymbol && !hasSymbol {
+		violations = append(violations, "Password harus mengandung simbol")
+	}
+	if username != "" && strings.EqualFold(password, username) {
+		violations = append(violations, "Password tidak boleh sama dengan username")
+	}
+	return violations
+}
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/MadeAgus22/dental-clinic-backend/pkg/config"
+)
+
+// withPasswordPolicy memasang kebijakan password sementara selama satu test
+func withPasswordPolicy(t *testing.T, policy config.PasswordPolicy) {
+	t.Helper()
+	previous := config.AppConfig
+	config.AppConfig = &config.Config{PasswordPolicy: policy}
+	t.Cleanup(func() { config.AppConfig = previous })
+}
+
+func TestValidatePasswordPolicy(t *testing.T) {
+	defaultPolicy := config.PasswordPolicy{MinLength: 8, RequireUpper: true, RequireLower: true, RequireDigit: true}
+	strictPolicy := config.PasswordPolicy{MinLength: 12, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}
+	loosePolicy := config.PasswordPolicy{MinLength: 4}
+
+	tests := []struct {
+		name     string
+		policy   config.PasswordPolicy
+		password string
+		username string
+		want     []string
+	}{
+		{"memenuhi kebijakan default", defaultPolicy, "Rahasia123", "budi", nil},
+		{"terlalu pendek", defaultPolicy, "Ra1", "budi", []string{"Password minimal 8 karakter"}},
+		{"panjang dihitung per karakter, bukan byte", defaultPolicy, "Ääää1b", "budi", []string{"Password minimal 8 karakter"}},
+		{"tanpa huruf besar", defaultPolicy, "rahasia123", "budi", []string{"Password harus mengandung huruf besar"}},
+		{"tanpa huruf kecil", defaultPolicy, "RAHASIA123", "budi", []string{"Password harus mengandung huruf kecil"}},
+		{"tanpa angka", defaultPolicy, "RahasiaSekali", "budi", []string{"Password harus mengandung angka"}},
+		{"simbol tidak wajib", defaultPolicy, "Rahasia123!", "budi", nil},
+		{"simbol wajib", strictPolicy, "RahasiaSekali123", "budi", []string{"Password harus mengandung simbol"}},
+		{"memenuhi kebijakan ketat", strictPolicy, "Rahasia-Sekali123", "budi", nil},
+		{"sama dengan username", defaultPolicy, "Dokter123", "dokter123", []string{"Password tidak boleh sama dengan username"}},
+		{"username kosong tidak diperiksa", loosePolicy, "abcd", "", nil},
+		{
+			"semua pelanggaran sekaligus", strictPolicy, "", "",
+			[]string{
+				"Password minimal 12 karakter",
+				"Password harus mengandung huruf besar",
+				"Password harus mengandung huruf kecil",
+				"Password harus mengandung angka",
+				"Password harus mengandung simbol",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withPasswordPolicy(t, tt.policy)
+			if got := ValidatePasswordPolicy(tt.password, tt.username); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidatePasswordPolicy(%q, %q) = %q, want %q", tt.password, tt.username, got, tt.want)
+			}
+		})
+	}
+}
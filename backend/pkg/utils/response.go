@@ -31,3 +31,16 @@ func ValidationErrorResponse(c *fiber.Ctx, errors interface{}) error {
 		"errors":  errors,
 	})
 }
+
+// SuccessResponseWithWarnings membuat respons sukses standar beserta peringatan yang tidak menggagalkan proses
+func SuccessResponseWithWarnings(c *fiber.Ctx, statusCode int, message string, data interface{}, warnings []string) error {
+	if len(warnings) == 0 {
+		return SuccessResponse(c, statusCode, message, data)
+	}
+	return c.Status(statusCode).JSON(fiber.Map{
+		"success":  true,
+		"message":  message,
+		"data":     data,
+		"warnings": warnings,
+	})
+}
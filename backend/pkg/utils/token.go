@@ -1,6 +1,10 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"time"
 
@@ -9,15 +13,18 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// GenerateJWT membuat token JWT baru untuk user
-func GenerateJWT(userID uint, username string, role string) (string, error) {
+// GenerateJWT membuat access token JWT baru untuk user yang terikat ke satu sesi login.
+// roleID disertakan agar hak akses tetap terbaca walaupun kode role diganti setelah token dibuat.
+func GenerateJWT(userID uint, username string, role string, roleID uint, sessionID uint) (string, error) {
 	cfg := config.AppConfig
 	claims := jwt.MapClaims{
-		"user_id":  userID,
-		"username": username,
-		"role":     role,
-		"exp":      time.Now().Add(cfg.JWTExpiry).Unix(), // Token expiry
-		"iat":      time.Now().Unix(),                    // Issued at
+		"user_id":    userID,
+		"username":   username,
+		"role":       role,
+		"role_id":    roleID,
+		"session_id": sessionID,
+		"exp":        time.Now().Add(cfg.JWTExpiry).Unix(), // Token expiry
+		"iat":        time.Now().Unix(),                    // Issued at
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -38,3 +45,61 @@ func ValidateJWT(tokenString string) (*jwt.Token, error) {
 		return []byte(cfg.JWTSecretKey), nil
 	})
 }
+
+// GenerateOpaqueToken membuat token acak (base64 URL-safe) untuk refresh token dan token sekali pakai
+func GenerateOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken menghasilkan hash SHA-256 (hex) dari token; hanya hash yang disimpan di database
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Tujuan challenge token pada login dua langkah
+const (
+	ChallengeTwoFactor      = "2fa_verify" // Pengguna sudah memakai 2FA, tinggal memasukkan kode
+	ChallengeTwoFactorSetup = "2fa_setup"  // Role mewajibkan 2FA tetapi pengguna belum mendaftar
+)
+
+// GenerateChallengeToken membuat token berumur pendek setelah password benar pada login dua langkah.
+// Token ini tidak membawa session_id sehingga ditolak JWTMiddleware sebagai access token.
+func GenerateChallengeToken(userID uint, purpose string) (string, error) {
+	cfg := config.AppConfig
+	claims := jwt.MapClaims{
+		"user_id": userID,
+		"purpose": purpose,
+		"exp":     time.Now().Add(cfg.TwoFactorChallengeExpiry).Unix(),
+		"iat":     time.Now().Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(cfg.JWTSecretKey))
+}
+
+// ParseChallengeToken memvalidasi challenge token dan mengembalikan ID pengguna jika tujuannya sesuai
+func ParseChallengeToken(tokenString string, purposes ...string) (uint, string, error) {
+	token, err := ValidateJWT(tokenString)
+	if err != nil || !token.Valid {
+		return 0, "", fmt.Errorf("challenge token tidak valid atau kedaluwarsa")
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return 0, "", fmt.Errorf("challenge token tidak valid")
+	}
+	purpose, _ := claims["purpose"].(string)
+	userID, okID := claims["user_id"].(float64)
+	if !okID || purpose == "" {
+		return 0, "", fmt.Errorf("challenge token tidak valid")
+	}
+	for _, p := range purposes {
+		if p == purpose {
+			return uint(userID), purpose, nil
+		}
+	}
+	return 0, "", fmt.Errorf("challenge token tidak berlaku untuk langkah ini")
+}
@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret adalah kunci uji SHA-1 dari RFC 6238 Appendix B ("12345678901234567890") dalam base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// Vektor uji RFC 6238 untuk SHA-1; kode 8 digit di RFC dipotong menjadi 6 digit terakhir
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestTOTPCodeRFC6238Vectors(t *testing.T) {
+	key, err := totpEncoding.DecodeString(rfc6238Secret)
+	if err != nil {
+		t.Fatalf("decode secret: %v", err)
+	}
+	for _, v := range rfc6238Vectors {
+		if got := totpCode(key, v.unix/totpPeriod); got != v.code {
+			t.Errorf("totpCode(T=%d) = %s, want %s", v.unix, got, v.code)
+		}
+	}
+}
+
+func TestVerifyTOTPRFC6238Vectors(t *testing.T) {
+	for _, v := range rfc6238Vectors {
+		step, ok := VerifyTOTP(rfc6238Secret, v.code, time.Unix(v.unix, 0))
+		if !ok {
+			t.Errorf("VerifyTOTP(T=%d, %s) ditolak", v.unix, v.code)
+			continue
+		}
+		if want := v.unix / totpPeriod; step != want {
+			t.Errorf("VerifyTOTP(T=%d) step = %d, want %d", v.unix, step, want)
+		}
+	}
+}
+
+func TestVerifyTOTPSkewWindow(t *testing.T) {
+	// Kode untuk T=1111111111 berada pada langkah 37037037; jam server digeser per langkah
+	const codeUnix = 1111111111
+	code := "050471"
+	codeStep := int64(codeUnix / totpPeriod)
+
+	tests := []struct {
+		name   string
+		offset int64 // selisih langkah antara jam server dan kode
+		ok     bool
+	}{
+		{"langkah sama", 0, true},
+		{"server satu langkah di depan", 1, true},
+		{"server satu langkah di belakang", -1, true},
+		{"server dua langkah di depan", 2, false},
+		{"server dua langkah di belakang", -2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix((codeStep+tt.offset)*totpPeriod, 0)
+			step, ok := VerifyTOTP(rfc6238Secret, code, now)
+			if ok != tt.ok {
+				t.Fatalf("VerifyTOTP ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && step != codeStep {
+				t.Errorf("VerifyTOTP step = %d, want %d", step, codeStep)
+			}
+		})
+	}
+}
+
+func TestVerifyTOTPInput(t *testing.T) {
+	at := time.Unix(59, 0)
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		ok     bool
+	}{
+		{"kode dengan spasi", rfc6238Secret, " 287 082 ", true},
+		{"secret huruf kecil", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", "287082", true},
+		{"kode salah", rfc6238Secret, "287083", false},
+		{"kode terlalu pendek", rfc6238Secret, "28708", false},
+		{"kode 8 digit", rfc6238Secret, "94287082", false},
+		{"secret tidak valid", "bukan-base32!", "287082", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := VerifyTOTP(tt.secret, tt.code, at); ok != tt.ok {
+				t.Errorf("VerifyTOTP(%q, %q) ok = %v, want %v", tt.secret, tt.code, ok, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package utils
+
+import "strings"
+
+// DescribeUserAgent meringkas header User-Agent menjadi label perangkat yang mudah dibaca,
+// mis. "Chrome di Windows". Hanya mengenali browser dan sistem operasi yang umum.
+func DescribeUserAgent(userAgent string) string {
+	if userAgent == "" {
+		return "Perangkat tidak dikenal"
+	}
+	ua := strings.ToLower(userAgent)
+
+	browser := "Browser lain"
+	switch {
+	case strings.Contains(ua, "edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "opr/") || strings.Contains(ua, "opera"):
+		browser = "Opera"
+	case strings.Contains(ua, "firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "chrome/") || strings.Contains(ua, "crios/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "safari/"):
+		browser = "Safari"
+	case strings.Contains(ua, "postman") || strings.Contains(ua, "curl") || strings.Contains(ua, "go-http-client"):
+		browser = "Klien API"
+	}
+
+	os := ""
+	switch {
+	case strings.Contains(ua, "windows"):
+		os = "Windows"
+	case strings.Contains(ua, "android"):
+		os = "Android"
+	case strings.Contains(ua, "iphone") || strings.Contains(ua, "ipad"):
+		os = "iOS"
+	case strings.Contains(ua, "mac os"):
+		os = "macOS"
+	case strings.Contains(ua, "linux"):
+		os = "Linux"
+	}
+
+	if os == "" {
+		return browser
+	}
+	return browser + " di " + os
+}